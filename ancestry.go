@@ -0,0 +1,543 @@
+package nanogit
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+)
+
+// commitGraphFetchDepth is how many generations of history a single fetch
+// asks the server for when the graph walk reaches a commit that is not in
+// storage yet. Larger values mean fewer round-trips on long histories at the
+// cost of transferring commits the walk may never visit. Trees are not
+// fetched: the walk uses the tree:0 filter.
+const commitGraphFetchDepth = 50
+
+// Paint flags used while walking the commit graph. They mirror the flags
+// git uses in paint_down_to_common.
+const (
+	flagParent1 uint8 = 1 << iota
+	flagParent2
+	flagStale
+	flagResult
+)
+
+// MergeBase returns the best common ancestor of commits a and b, as
+// `git merge-base a b` would. When the histories have several equally good
+// merge bases (criss-cross merges), the most recent one is returned.
+//
+// History is fetched lazily in batches of commitGraphFetchDepth generations
+// with the tree:0 filter, and every fetched commit is kept in the storage
+// from the context, so repeated calls with a shared storage are cheap.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - a: Hash of the first commit
+//   - b: Hash of the second commit
+//
+// Returns:
+//   - hash.Hash: Hash of the merge base
+//   - error: ErrNoMergeBase if the histories are unrelated, or an error if a commit cannot be fetched
+//
+// Example:
+//
+//	base, err := client.MergeBase(ctx, featureHash, mainHash)
+//	if errors.Is(err, nanogit.ErrNoMergeBase) {
+//	    fmt.Println("branches share no history")
+//	}
+func (c *httpClient) MergeBase(ctx context.Context, a, b hash.Hash) (hash.Hash, error) {
+	logger := log.FromContext(ctx)
+	logger.Debug("Compute merge base",
+		"a_hash", a.String(),
+		"b_hash", b.String())
+
+	ctx, allObjects := storage.FromContextOrInMemory(ctx)
+	graph := newCommitGraph(c, allObjects)
+
+	bases, err := graph.mergeBases(ctx, a, b)
+	if err != nil {
+		return hash.Zero, err
+	}
+
+	if len(bases) == 0 {
+		logger.Debug("No merge base found",
+			"a_hash", a.String(),
+			"b_hash", b.String())
+		return hash.Zero, ErrNoMergeBase
+	}
+
+	logger.Debug("Merge base computed",
+		"a_hash", a.String(),
+		"b_hash", b.String(),
+		"merge_base", bases[0].hash.String(),
+		"candidate_count", len(bases),
+		"commits_visited", len(graph.nodes))
+	return bases[0].hash, nil
+}
+
+// IsAncestor reports whether ancestor is reachable from descendant by
+// following parent links. A commit is considered its own ancestor, matching
+// `git merge-base --is-ancestor`.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - ancestor: Hash of the candidate ancestor commit
+//   - descendant: Hash of the commit whose history is searched
+//
+// Returns:
+//   - bool: True if ancestor is in the history of descendant
+//   - error: Error if a commit cannot be fetched
+//
+// Example:
+//
+//	// Only allow fast-forward merges into main.
+//	ok, err := client.IsAncestor(ctx, mainHash, featureHash)
+func (c *httpClient) IsAncestor(ctx context.Context, ancestor, descendant hash.Hash) (bool, error) {
+	logger := log.FromContext(ctx)
+	logger.Debug("Check ancestry",
+		"ancestor_hash", ancestor.String(),
+		"descendant_hash", descendant.String())
+
+	if ancestor.Is(descendant) {
+		return true, nil
+	}
+
+	ctx, allObjects := storage.FromContextOrInMemory(ctx)
+	graph := newCommitGraph(c, allObjects)
+
+	bases, err := graph.paintDownToCommon(ctx, ancestor, descendant)
+	if err != nil {
+		return false, err
+	}
+
+	isAncestor := false
+	for _, base := range bases {
+		if base.hash.Is(ancestor) {
+			isAncestor = true
+			break
+		}
+	}
+
+	logger.Debug("Ancestry checked",
+		"ancestor_hash", ancestor.String(),
+		"descendant_hash", descendant.String(),
+		"is_ancestor", isAncestor,
+		"commits_visited", len(graph.nodes))
+	return isAncestor, nil
+}
+
+// AheadBehind counts the commits that are reachable from a but not from b
+// (ahead) and the commits reachable from b but not from a (behind), like
+// `git rev-list --left-right --count a...b`. For a feature branch a and a
+// base branch b, behind is the "N commits behind main" number.
+//
+// The walk stops as soon as every remaining commit is reachable from both
+// sides, so only the diverging part of the history is fetched. As in git, the
+// counts rely on committer timestamps to order the walk and can be off when
+// the history contains commits with heavily skewed clocks.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - a: Hash of the first commit (typically the branch tip)
+//   - b: Hash of the second commit (typically the base branch tip)
+//
+// Returns:
+//   - ahead: Number of commits only in a's history
+//   - behind: Number of commits only in b's history
+//   - err: Error if a commit cannot be fetched
+//
+// Example:
+//
+//	ahead, behind, err := client.AheadBehind(ctx, featureHash, mainHash)
+//	if err != nil {
+//	    return err
+//	}
+//	fmt.Printf("%d ahead, %d behind main\n", ahead, behind)
+func (c *httpClient) AheadBehind(ctx context.Context, a, b hash.Hash) (ahead, behind int, err error) {
+	logger := log.FromContext(ctx)
+	logger.Debug("Count ahead and behind",
+		"a_hash", a.String(),
+		"b_hash", b.String())
+
+	if a.Is(b) {
+		return 0, 0, nil
+	}
+
+	ctx, allObjects := storage.FromContextOrInMemory(ctx)
+	graph := newCommitGraph(c, allObjects)
+
+	ahead, behind, err = graph.aheadBehind(ctx, a, b)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	logger.Debug("Ahead and behind counted",
+		"a_hash", a.String(),
+		"b_hash", b.String(),
+		"ahead", ahead,
+		"behind", behind,
+		"commits_visited", len(graph.nodes))
+	return ahead, behind, nil
+}
+
+// commitNode is the part of a commit the graph walks need: its parents, its
+// committer timestamp (to walk newest-first), and the walk's paint flags.
+type commitNode struct {
+	hash    hash.Hash
+	parents []hash.Hash
+	time    int64
+	flags   uint8
+}
+
+// commitGraph lazily materializes the commit graph of the remote repository.
+// Commit objects are read from the packfile storage first; missing ones are
+// fetched commitGraphFetchDepth generations at a time, with every fetched
+// object landing in the storage for later walks.
+type commitGraph struct {
+	client  *httpClient
+	storage storage.PackfileStorage
+	nodes   map[hash.Hash]*commitNode
+}
+
+func newCommitGraph(c *httpClient, allObjects storage.PackfileStorage) *commitGraph {
+	return &commitGraph{
+		client:  c,
+		storage: allObjects,
+		nodes:   make(map[hash.Hash]*commitNode),
+	}
+}
+
+// load makes sure every given commit is available as a node, fetching the
+// missing ones (and their recent ancestors) in a single request.
+func (g *commitGraph) load(ctx context.Context, hashes ...hash.Hash) error {
+	var missing []hash.Hash
+	for _, h := range hashes {
+		if _, ok := g.nodes[h]; ok {
+			continue
+		}
+		if obj, ok := g.storage.GetByType(h, protocol.ObjectTypeCommit); ok {
+			g.addNode(obj)
+			continue
+		}
+		missing = append(missing, h)
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Fetch commit history batch",
+		"want_count", len(missing),
+		"depth", commitGraphFetchDepth)
+
+	objects, err := g.client.Fetch(ctx, client.FetchOptions{
+		NoProgress:       true,
+		NoTreeFilter:     true,
+		Want:             missing,
+		Deepen:           commitGraphFetchDepth,
+		Done:             true,
+		MaxResponseBytes: g.client.limits.MultiObjectFetchMaxBytes,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not our ref") && len(missing) == 1 {
+			return NewObjectNotFoundError(missing[0])
+		}
+		return fmt.Errorf("fetch commit history: %w", err)
+	}
+
+	for _, h := range missing {
+		obj, ok := objects[h.String()]
+		if !ok || obj.Type != protocol.ObjectTypeCommit {
+			obj, ok = g.storage.GetByType(h, protocol.ObjectTypeCommit)
+			if !ok {
+				return NewObjectNotFoundError(h)
+			}
+		}
+		g.addNode(obj)
+	}
+
+	return nil
+}
+
+func (g *commitGraph) addNode(obj *protocol.PackfileObject) *commitNode {
+	node := &commitNode{
+		hash:    obj.Hash,
+		parents: obj.Commit.Parents,
+	}
+	if len(node.parents) == 0 && !obj.Commit.Parent.Is(hash.Zero) {
+		node.parents = []hash.Hash{obj.Commit.Parent}
+	}
+	if obj.Commit.Committer != nil {
+		node.time = obj.Commit.Committer.Timestamp
+	}
+	g.nodes[obj.Hash] = node
+	return node
+}
+
+// node returns the node for h, loading it if needed.
+func (g *commitGraph) node(ctx context.Context, h hash.Hash) (*commitNode, error) {
+	if err := g.load(ctx, h); err != nil {
+		return nil, err
+	}
+	return g.nodes[h], nil
+}
+
+// parents returns the parent nodes of n, loading all missing ones together.
+func (g *commitGraph) parents(ctx context.Context, n *commitNode) ([]*commitNode, error) {
+	if err := g.load(ctx, n.parents...); err != nil {
+		return nil, fmt.Errorf("load parents of %s: %w", n.hash.String(), err)
+	}
+	parents := make([]*commitNode, 0, len(n.parents))
+	for _, p := range n.parents {
+		parents = append(parents, g.nodes[p])
+	}
+	return parents, nil
+}
+
+// walk visits every commit reachable from tips once, loading history in
+// batches as the walk goes deeper.
+func (g *commitGraph) walk(ctx context.Context, tips []hash.Hash, fn func(*commitNode)) error {
+	if err := g.load(ctx, tips...); err != nil {
+		return err
+	}
+
+	seen := make(map[hash.Hash]bool, len(tips))
+	level := tips
+	for len(level) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var next []hash.Hash
+		for _, h := range level {
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+
+			n := g.nodes[h]
+			fn(n)
+			for _, p := range n.parents {
+				if !seen[p] {
+					next = append(next, p)
+				}
+			}
+		}
+
+		if err := g.load(ctx, next...); err != nil {
+			return err
+		}
+		level = next
+	}
+	return nil
+}
+
+// paintDownToCommon walks the histories of a and b newest-first, painting
+// each commit with the side(s) it is reachable from, and returns the commits
+// reachable from both that are not ancestors of another such commit. This is
+// git's paint_down_to_common.
+func (g *commitGraph) paintDownToCommon(ctx context.Context, a, b hash.Hash) ([]*commitNode, error) {
+	nodeA, err := g.node(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("load commit %s: %w", a.String(), err)
+	}
+	if a.Is(b) {
+		return []*commitNode{nodeA}, nil
+	}
+	nodeB, err := g.node(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("load commit %s: %w", b.String(), err)
+	}
+
+	queue := &commitQueue{}
+	nodeA.flags |= flagParent1
+	heap.Push(queue, nodeA)
+	nodeB.flags |= flagParent2
+	heap.Push(queue, nodeB)
+
+	var results []*commitNode
+	for queue.hasNonStale() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		n := heap.Pop(queue).(*commitNode)
+		flags := n.flags & (flagParent1 | flagParent2 | flagStale)
+		if flags == flagParent1|flagParent2 {
+			if n.flags&flagResult == 0 {
+				n.flags |= flagResult
+				results = append(results, n)
+			}
+			flags |= flagStale
+		}
+
+		parents, err := g.parents(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parents {
+			if p.flags&flags == flags {
+				continue
+			}
+			p.flags |= flags
+			heap.Push(queue, p)
+		}
+	}
+
+	// A result reached again through another result's stale paint is an
+	// ancestor of that result and therefore not a best common ancestor.
+	best := make([]*commitNode, 0, len(results))
+	for _, n := range results {
+		if n.flags&flagStale == 0 {
+			best = append(best, n)
+		}
+	}
+	return best, nil
+}
+
+// mergeBases returns the merge bases of a and b, newest first, with
+// candidates that are ancestors of other candidates removed.
+func (g *commitGraph) mergeBases(ctx context.Context, a, b hash.Hash) ([]*commitNode, error) {
+	candidates, err := g.paintDownToCommon(ctx, a, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) <= 1 {
+		return candidates, nil
+	}
+
+	// Clock skew can leave redundant candidates behind. Check each pair with
+	// a fresh walk, as git's remove_redundant does.
+	redundant := make(map[hash.Hash]bool, len(candidates))
+	for i, ci := range candidates {
+		for j, cj := range candidates {
+			if i == j || redundant[ci.hash] || redundant[cj.hash] {
+				continue
+			}
+			sub := newCommitGraph(g.client, g.storage)
+			common, err := sub.paintDownToCommon(ctx, ci.hash, cj.hash)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range common {
+				if n.hash.Is(ci.hash) {
+					redundant[ci.hash] = true
+				}
+			}
+		}
+	}
+
+	bases := make([]*commitNode, 0, len(candidates))
+	for _, n := range candidates {
+		if !redundant[n.hash] {
+			bases = append(bases, n)
+		}
+	}
+	sort.SliceStable(bases, func(i, j int) bool {
+		return bases[i].time > bases[j].time
+	})
+	return bases, nil
+}
+
+// aheadBehind paints the histories of a and b like paintDownToCommon, but
+// keeps walking until every queued commit is reachable from both sides, then
+// counts the commits painted by only one side.
+func (g *commitGraph) aheadBehind(ctx context.Context, a, b hash.Hash) (int, int, error) {
+	nodeA, err := g.node(ctx, a)
+	if err != nil {
+		return 0, 0, fmt.Errorf("load commit %s: %w", a.String(), err)
+	}
+	nodeB, err := g.node(ctx, b)
+	if err != nil {
+		return 0, 0, fmt.Errorf("load commit %s: %w", b.String(), err)
+	}
+
+	queue := &commitQueue{}
+	nodeA.flags |= flagParent1
+	heap.Push(queue, nodeA)
+	nodeB.flags |= flagParent2
+	heap.Push(queue, nodeB)
+
+	for queue.hasNonStale() {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+
+		n := heap.Pop(queue).(*commitNode)
+		flags := n.flags & (flagParent1 | flagParent2)
+		if flags == flagParent1|flagParent2 {
+			n.flags |= flagStale
+		}
+
+		parents, err := g.parents(ctx, n)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, p := range parents {
+			if p.flags&flags == flags {
+				continue
+			}
+			p.flags |= flags
+			if p.flags&(flagParent1|flagParent2) == flagParent1|flagParent2 {
+				p.flags |= flagStale
+			}
+			heap.Push(queue, p)
+		}
+	}
+
+	var ahead, behind int
+	for _, n := range g.nodes {
+		switch n.flags & (flagParent1 | flagParent2) {
+		case flagParent1:
+			ahead++
+		case flagParent2:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// commitQueue is a max-heap of commit nodes ordered by committer time, so
+// the walk always continues from the newest commit it knows about.
+type commitQueue []*commitNode
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	if q[i].time != q[j].time {
+		return q[i].time > q[j].time
+	}
+	return q[i].hash.String() < q[j].hash.String()
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x any) { *q = append(*q, x.(*commitNode)) }
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return n
+}
+
+// hasNonStale reports whether any queued commit is still reachable from only
+// one side; once none is, the walk cannot discover anything new.
+func (q commitQueue) hasNonStale() bool {
+	for _, n := range q {
+		if n.flags&flagStale == 0 {
+			return true
+		}
+	}
+	return false
+}
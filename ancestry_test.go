@@ -0,0 +1,224 @@
+package nanogit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildForkedHistory creates:
+//
+//	root - c1 - c2 - main1 - main2        (main)
+//	             \
+//	              feat1 - feat2 - feat3   (feature)
+func buildForkedHistory(t *testing.T) (repo *testRepo, c2, main2, feat3 hash.Hash) {
+	t.Helper()
+	repo = newTestRepo(t)
+	root := repo.commit("root", map[string]string{"a.txt": "a"})
+	c1 := repo.commit("c1", map[string]string{"a.txt": "a1"}, root)
+	c2 = repo.commit("c2", map[string]string{"a.txt": "a2"}, c1)
+	feat1 := repo.commit("feat1", map[string]string{"a.txt": "a2", "f.txt": "1"}, c2)
+	main1 := repo.commit("main1", map[string]string{"a.txt": "m1"}, c2)
+	feat2 := repo.commit("feat2", map[string]string{"a.txt": "a2", "f.txt": "2"}, feat1)
+	main2 = repo.commit("main2", map[string]string{"a.txt": "m2"}, main1)
+	feat3 = repo.commit("feat3", map[string]string{"a.txt": "a2", "f.txt": "3"}, feat2)
+	return repo, c2, main2, feat3
+}
+
+func TestMergeBase(t *testing.T) {
+	t.Parallel()
+
+	t.Run("forked branches", func(t *testing.T) {
+		t.Parallel()
+		repo, c2, main2, feat3 := buildForkedHistory(t)
+
+		base, err := repo.client().MergeBase(context.Background(), feat3, main2)
+		require.NoError(t, err)
+		assert.Equal(t, c2, base)
+
+		base, err = repo.client().MergeBase(context.Background(), main2, feat3)
+		require.NoError(t, err)
+		assert.Equal(t, c2, base)
+	})
+
+	t.Run("linear history returns the older commit", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		c1 := repo.commit("c1", map[string]string{"a.txt": "1"})
+		c2 := repo.commit("c2", map[string]string{"a.txt": "2"}, c1)
+		c3 := repo.commit("c3", map[string]string{"a.txt": "3"}, c2)
+
+		base, err := repo.client().MergeBase(context.Background(), c3, c1)
+		require.NoError(t, err)
+		assert.Equal(t, c1, base)
+	})
+
+	t.Run("same commit", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		c1 := repo.commit("c1", map[string]string{"a.txt": "1"})
+
+		base, err := repo.client().MergeBase(context.Background(), c1, c1)
+		require.NoError(t, err)
+		assert.Equal(t, c1, base)
+	})
+
+	t.Run("after merging main into the feature branch", func(t *testing.T) {
+		t.Parallel()
+		repo, _, main2, feat3 := buildForkedHistory(t)
+		merge := repo.commit("merge main", map[string]string{"a.txt": "m2", "f.txt": "3"}, feat3, main2)
+		main3 := repo.commit("main3", map[string]string{"a.txt": "m3"}, main2)
+
+		base, err := repo.client().MergeBase(context.Background(), merge, main3)
+		require.NoError(t, err)
+		assert.Equal(t, main2, base)
+	})
+
+	t.Run("criss-cross merge returns the newest base", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		root := repo.commit("root", map[string]string{"a.txt": "0"})
+		a1 := repo.commit("a1", map[string]string{"a.txt": "a"}, root)
+		b1 := repo.commit("b1", map[string]string{"a.txt": "b"}, root)
+		a2 := repo.commit("a2", map[string]string{"a.txt": "ab"}, a1, b1)
+		b2 := repo.commit("b2", map[string]string{"a.txt": "ba"}, b1, a1)
+
+		base, err := repo.client().MergeBase(context.Background(), a2, b2)
+		require.NoError(t, err)
+		assert.Equal(t, b1, base)
+	})
+
+	t.Run("unrelated histories", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		a := repo.commit("a", map[string]string{"a.txt": "a"})
+		b := repo.commit("b", map[string]string{"b.txt": "b"})
+
+		_, err := repo.client().MergeBase(context.Background(), a, b)
+		require.ErrorIs(t, err, ErrNoMergeBase)
+	})
+
+	t.Run("unknown commit", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		a := repo.commit("a", map[string]string{"a.txt": "a"})
+		missing := hash.MustFromHex("1234567890123456789012345678901234567890")
+
+		_, err := repo.client().MergeBase(context.Background(), a, missing)
+		require.ErrorIs(t, err, ErrObjectNotFound)
+	})
+
+	t.Run("reuses history from the context storage", func(t *testing.T) {
+		t.Parallel()
+		repo, c2, main2, feat3 := buildForkedHistory(t)
+		ctx, _ := storage.FromContextOrInMemory(context.Background())
+
+		_, err := repo.client().MergeBase(ctx, feat3, main2)
+		require.NoError(t, err)
+		fetches := len(repo.fetches)
+		require.NotZero(t, fetches)
+
+		base, err := repo.client().MergeBase(ctx, main2, feat3)
+		require.NoError(t, err)
+		assert.Equal(t, c2, base)
+		assert.Len(t, repo.fetches, fetches, "second walk should be served from storage")
+	})
+
+	t.Run("fetches commits without trees", func(t *testing.T) {
+		t.Parallel()
+		repo, c2, main2, feat3 := buildForkedHistory(t)
+		ctx, store := storage.FromContextOrInMemory(context.Background())
+		repo.fetchHook = func(opts client.FetchOptions) error {
+			assert.True(t, opts.NoTreeFilter, "history walks should use the tree:0 filter")
+			return nil
+		}
+
+		base, err := repo.client().MergeBase(ctx, feat3, main2)
+		require.NoError(t, err)
+		assert.Equal(t, c2, base)
+		for _, h := range store.GetAllKeys() {
+			obj, ok := store.Get(h)
+			require.True(t, ok)
+			assert.Equal(t, protocol.ObjectTypeCommit, obj.Type, "unexpected object %s", h.String())
+		}
+	})
+}
+
+func TestIsAncestor(t *testing.T) {
+	t.Parallel()
+	repo, c2, main2, feat3 := buildForkedHistory(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		ancestor   hash.Hash
+		descendant hash.Hash
+		want       bool
+	}{
+		{name: "fork point is an ancestor of main", ancestor: c2, descendant: main2, want: true},
+		{name: "fork point is an ancestor of feature", ancestor: c2, descendant: feat3, want: true},
+		{name: "descendant is not an ancestor", ancestor: feat3, descendant: c2, want: false},
+		{name: "diverged branches", ancestor: main2, descendant: feat3, want: false},
+		{name: "commit is its own ancestor", ancestor: feat3, descendant: feat3, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.client().IsAncestor(ctx, tt.ancestor, tt.descendant)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAheadBehind(t *testing.T) {
+	t.Parallel()
+
+	t.Run("diverged branches", func(t *testing.T) {
+		t.Parallel()
+		repo, _, main2, feat3 := buildForkedHistory(t)
+
+		ahead, behind, err := repo.client().AheadBehind(context.Background(), feat3, main2)
+		require.NoError(t, err)
+		assert.Equal(t, 3, ahead)
+		assert.Equal(t, 2, behind)
+	})
+
+	t.Run("fast-forward", func(t *testing.T) {
+		t.Parallel()
+		repo, c2, main2, _ := buildForkedHistory(t)
+
+		ahead, behind, err := repo.client().AheadBehind(context.Background(), c2, main2)
+		require.NoError(t, err)
+		assert.Equal(t, 0, ahead)
+		assert.Equal(t, 2, behind)
+	})
+
+	t.Run("same commit", func(t *testing.T) {
+		t.Parallel()
+		repo, _, main2, _ := buildForkedHistory(t)
+
+		ahead, behind, err := repo.client().AheadBehind(context.Background(), main2, main2)
+		require.NoError(t, err)
+		assert.Zero(t, ahead)
+		assert.Zero(t, behind)
+	})
+
+	t.Run("merge commit counts both sides once", func(t *testing.T) {
+		t.Parallel()
+		repo, _, main2, feat3 := buildForkedHistory(t)
+		merge := repo.commit("merge main", map[string]string{"a.txt": "m2", "f.txt": "3"}, feat3, main2)
+		main3 := repo.commit("main3", map[string]string{"a.txt": "m3"}, main2)
+
+		ahead, behind, err := repo.client().AheadBehind(context.Background(), merge, main3)
+		require.NoError(t, err)
+		assert.Equal(t, 4, ahead)
+		assert.Equal(t, 1, behind)
+	})
+}
@@ -0,0 +1,680 @@
+package nanogit
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parsedPatch is one patch of the input of ApplyPatch: a git format-patch
+// email, or a plain diff.
+type parsedPatch struct {
+	// mail is nil for a plain diff.
+	mail  *patchMail
+	files []*patchFile
+}
+
+// patchMail is the header and message of a git format-patch email.
+type patchMail struct {
+	author  Author
+	message string
+}
+
+// patchFile is the change of a single file in a patch. Paths are empty on
+// the side of a new or deleted file.
+type patchFile struct {
+	oldPath, newPath string
+	// oldMode and newMode are zero when the patch does not give them.
+	oldMode, newMode uint32
+	isNew, isDeleted bool
+	isRename, isCopy bool
+	// oldIndex and newIndex are the abbreviated hashes of the index line.
+	oldIndex, newIndex string
+	hunks              []patchHunk
+	// binary is set for a binary change, with binaryData when the patch
+	// carries it.
+	binary     bool
+	binaryData *binaryPatch
+}
+
+// patchHunk is a hunk of a unified diff.
+type patchHunk struct {
+	oldStart, oldCount int
+	newStart, newCount int
+	lines              []hunkLine
+}
+
+// hunkLine is a line of a hunk: op is ' ' for context, '-' for a deleted
+// line and '+' for an added one. The text keeps its line ending.
+type hunkLine struct {
+	op   byte
+	text string
+}
+
+// binaryPatch is the data of a "GIT binary patch": the new content, or a
+// delta against the old content.
+type binaryPatch struct {
+	delta bool
+	data  []byte
+}
+
+var (
+	// mailStartPattern matches the first line of a git format-patch email.
+	mailStartPattern = regexp.MustCompile(`^From [0-9a-f]{40} `)
+	// hunkHeaderPattern matches the header of a hunk.
+	hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+)
+
+// patchParser parses patches line by line.
+type patchParser struct {
+	lines []string
+	next  int
+}
+
+// parsePatches parses unified diffs, git diffs and git format-patch
+// emails. Text outside of them, such as a commit message before a diff, is
+// skipped.
+func parsePatches(data []byte) ([]*parsedPatch, error) {
+	p := &patchParser{lines: splitLinesWithEnds(data)}
+
+	var patches []*parsedPatch
+	current := func() *parsedPatch {
+		if len(patches) == 0 {
+			patches = append(patches, &parsedPatch{})
+		}
+		return patches[len(patches)-1]
+	}
+	for p.next < len(p.lines) {
+		line := p.line()
+		switch {
+		case mailStartPattern.MatchString(line):
+			header, err := p.parseMail()
+			if err != nil {
+				return nil, err
+			}
+			patches = append(patches, &parsedPatch{mail: header})
+		case strings.HasPrefix(line, "diff --git "):
+			file, err := p.parseGitDiff()
+			if err != nil {
+				return nil, err
+			}
+			current().files = append(current().files, file)
+		case strings.HasPrefix(line, "--- ") && p.next+1 < len(p.lines) && strings.HasPrefix(p.lines[p.next+1], "+++ "):
+			file, err := p.parseUnifiedDiff()
+			if err != nil {
+				return nil, err
+			}
+			current().files = append(current().files, file)
+		default:
+			p.next++
+		}
+	}
+
+	for _, patch := range patches {
+		if len(patch.files) == 0 {
+			return nil, fmt.Errorf("%w: no changes found", ErrInvalidPatch)
+		}
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("%w: no changes found", ErrInvalidPatch)
+	}
+	return patches, nil
+}
+
+// line returns the next line without its line ending.
+func (p *patchParser) line() string {
+	return strings.TrimRight(p.lines[p.next], "\r\n")
+}
+
+// errorf returns an ErrInvalidPatch error about the next line.
+func (p *patchParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidPatch, p.next+1, fmt.Sprintf(format, args...))
+}
+
+// parseMail parses the header and message of a git format-patch email, up
+// to the "---" line that ends the message or the first diff. Encoded words
+// in the header and a quoted-printable body are decoded.
+func (p *patchParser) parseMail() (*patchMail, error) {
+	p.next++ // "From <hash> <date>"
+
+	// Header fields, unfolding continuation lines.
+	fields := make(map[string]string)
+	var last string
+	for ; p.next < len(p.lines); p.next++ {
+		line := p.line()
+		if line == "" {
+			p.next++
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && last != "" {
+			fields[last] += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, p.errorf("invalid email header %q", line)
+		}
+		last = strings.ToLower(name)
+		fields[last] = strings.TrimSpace(value)
+	}
+
+	from, err := mail.ParseAddress(fields["from"])
+	if err != nil {
+		return nil, p.errorf("invalid author %q: %v", fields["from"], err)
+	}
+	header := &patchMail{author: Author{Name: from.Name, Email: from.Address}}
+	if date := fields["date"]; date != "" {
+		if header.author.Time, err = mail.ParseDate(date); err != nil {
+			return nil, p.errorf("invalid date %q: %v", date, err)
+		}
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(fields["subject"])
+	if err != nil {
+		return nil, p.errorf("invalid subject %q: %v", fields["subject"], err)
+	}
+	if strings.EqualFold(fields["content-transfer-encoding"], "quoted-printable") {
+		if err := p.decodeQuotedPrintable(); err != nil {
+			return nil, err
+		}
+	}
+
+	var body []string
+	for ; p.next < len(p.lines); p.next++ {
+		line := p.line()
+		if line == "---" || strings.HasPrefix(line, "diff --git ") {
+			break
+		}
+		body = append(body, line)
+	}
+	header.message = patchSubject(subject) + "\n"
+	if text := strings.TrimSpace(strings.Join(body, "\n")); text != "" {
+		header.message += "\n" + text + "\n"
+	}
+	return header, nil
+}
+
+// decodeQuotedPrintable decodes the rest of the current email, up to the
+// next one, in place.
+func (p *patchParser) decodeQuotedPrintable() error {
+	end := p.next
+	for end < len(p.lines) && !mailStartPattern.MatchString(p.lines[end]) {
+		end++
+	}
+	body := strings.NewReader(strings.Join(p.lines[p.next:end], ""))
+	decoded, err := io.ReadAll(quotedprintable.NewReader(body))
+	if err != nil {
+		return p.errorf("invalid quoted-printable body: %v", err)
+	}
+	p.lines = slices.Concat(p.lines[:p.next], splitLinesWithEnds(decoded), p.lines[end:])
+	return nil
+}
+
+// patchSubject returns the subject of a patch email without the
+// "[PATCH n/m]" prefixes.
+func patchSubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	for strings.HasPrefix(subject, "[") {
+		end := strings.Index(subject, "]")
+		if end < 0 {
+			break
+		}
+		subject = strings.TrimSpace(subject[end+1:])
+	}
+	return subject
+}
+
+// parseGitDiff parses the diff of a file in git's format, starting at its
+// "diff --git" line.
+func (p *patchParser) parseGitDiff() (*patchFile, error) {
+	file := &patchFile{}
+	var err error
+	file.oldPath, file.newPath, err = gitDiffPaths(strings.TrimPrefix(p.line(), "diff --git "))
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.next++
+
+	for p.next < len(p.lines) {
+		line := p.line()
+		key, value, _ := strings.Cut(line, " ")
+		switch {
+		case strings.HasPrefix(line, "old mode "):
+			file.oldMode, err = parseFileMode(strings.TrimPrefix(line, "old mode "))
+		case strings.HasPrefix(line, "new mode "):
+			file.newMode, err = parseFileMode(strings.TrimPrefix(line, "new mode "))
+		case strings.HasPrefix(line, "deleted file mode "):
+			file.isDeleted = true
+			file.oldMode, err = parseFileMode(strings.TrimPrefix(line, "deleted file mode "))
+		case strings.HasPrefix(line, "new file mode "):
+			file.isNew = true
+			file.newMode, err = parseFileMode(strings.TrimPrefix(line, "new file mode "))
+		case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "copy from "):
+			file.isRename = key == "rename"
+			file.isCopy = key == "copy"
+			file.oldPath, err = unquotePatchPath(strings.TrimPrefix(value, "from "))
+		case strings.HasPrefix(line, "rename to "), strings.HasPrefix(line, "copy to "):
+			file.newPath, err = unquotePatchPath(strings.TrimPrefix(value, "to "))
+		case strings.HasPrefix(line, "similarity index "), strings.HasPrefix(line, "dissimilarity index "):
+		case key == "index":
+			hashes, mode, _ := strings.Cut(value, " ")
+			file.oldIndex, file.newIndex, _ = strings.Cut(hashes, "..")
+			if mode != "" {
+				file.oldMode, err = parseFileMode(mode)
+				file.newMode = file.oldMode
+			}
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			// The paths are known from the diff --git line and the rename
+			// lines; /dev/null is implied by new and deleted file modes.
+		case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"):
+			file.binary = true
+		case line == "GIT binary patch":
+			file.binary = true
+			if file.binaryData, err = p.parseBinaryPatch(); err != nil {
+				return nil, err
+			}
+			continue
+		case strings.HasPrefix(line, "@@ "):
+			hunk, err := p.parseHunk()
+			if err != nil {
+				return nil, err
+			}
+			file.hunks = append(file.hunks, hunk)
+			continue
+		default:
+			return p.finishFile(file), nil
+		}
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.next++
+	}
+	return p.finishFile(file), nil
+}
+
+// finishFile clears the paths of the missing side of a new or deleted
+// file.
+func (p *patchParser) finishFile(file *patchFile) *patchFile {
+	if file.isNew {
+		file.oldPath = ""
+	}
+	if file.isDeleted {
+		file.newPath = ""
+	}
+	return file
+}
+
+// gitDiffPaths returns the paths of a "diff --git" line, after its prefix.
+func gitDiffPaths(paths string) (string, string, error) {
+	if strings.HasPrefix(paths, `"`) {
+		// Quoted paths: "a/x y" "b/x y"
+		end := 1
+		for ; end < len(paths) && (paths[end] != '"' || paths[end-1] == '\\'); end++ {
+		}
+		oldPath, err := unquotePatchPath(paths[:end+1])
+		if err != nil {
+			return "", "", err
+		}
+		newPath, err := unquotePatchPath(strings.TrimSpace(paths[end+1:]))
+		if err != nil {
+			return "", "", err
+		}
+		return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/"), nil
+	}
+
+	// Without a rename both halves are the same, which is the only way to
+	// split paths holding " b/".
+	if half := (len(paths) - 1) / 2; len(paths)%2 == 1 && paths[half] == ' ' &&
+		strings.HasPrefix(paths, "a/") && paths[half+1:half+3] == "b/" && paths[2:half] == paths[half+3:] {
+		return paths[2:half], paths[half+3:], nil
+	}
+	oldPath, newPath, ok := strings.Cut(paths, " b/")
+	if !ok || !strings.HasPrefix(oldPath, "a/") {
+		return "", "", fmt.Errorf("invalid paths %q", paths)
+	}
+	return strings.TrimPrefix(oldPath, "a/"), newPath, nil
+}
+
+// unquotePatchPath returns a path of a patch, unquoting git's C-style
+// quoting of paths with special characters.
+func unquotePatchPath(path string) (string, error) {
+	if !strings.HasPrefix(path, `"`) {
+		return path, nil
+	}
+	unquoted, err := strconv.Unquote(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted path %s", path)
+	}
+	return unquoted, nil
+}
+
+// parseFileMode parses an octal file mode of a patch.
+func parseFileMode(mode string) (uint32, error) {
+	parsed, err := strconv.ParseUint(strings.TrimSpace(mode), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %q", mode)
+	}
+	return uint32(parsed), nil
+}
+
+// parseUnifiedDiff parses the diff of a file in the plain unified format,
+// starting at its "---" line. The first component of the paths is
+// stripped, like `patch -p1` and `git apply` do, and /dev/null stands for
+// the missing side of a new or deleted file.
+func (p *patchParser) parseUnifiedDiff() (*patchFile, error) {
+	file := &patchFile{
+		oldPath: unifiedDiffPath(strings.TrimPrefix(p.line(), "--- ")),
+	}
+	p.next++
+	file.newPath = unifiedDiffPath(strings.TrimPrefix(p.line(), "+++ "))
+	p.next++
+	file.isNew = file.oldPath == ""
+	file.isDeleted = file.newPath == ""
+	if file.isNew && file.isDeleted {
+		return nil, p.errorf("both sides of the diff are /dev/null")
+	}
+
+	for p.next < len(p.lines) && strings.HasPrefix(p.line(), "@@ ") {
+		hunk, err := p.parseHunk()
+		if err != nil {
+			return nil, err
+		}
+		file.hunks = append(file.hunks, hunk)
+	}
+	if len(file.hunks) == 0 {
+		return nil, p.errorf("diff of %q has no hunks", file.newPath+file.oldPath)
+	}
+	return file, nil
+}
+
+// unifiedDiffPath returns the path of a "---" or "+++" line of a plain
+// unified diff, or an empty path for /dev/null.
+func unifiedDiffPath(path string) string {
+	// A timestamp may follow the path after a tab.
+	path, _, _ = strings.Cut(path, "\t")
+	path = strings.TrimSpace(path)
+	if unquoted, err := unquotePatchPath(path); err == nil {
+		path = unquoted
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	if _, rest, ok := strings.Cut(path, "/"); ok {
+		return rest
+	}
+	return path
+}
+
+// parseHunk parses a hunk, starting at its "@@" line.
+func (p *patchParser) parseHunk() (patchHunk, error) {
+	match := hunkHeaderPattern.FindStringSubmatch(p.line())
+	if match == nil {
+		return patchHunk{}, p.errorf("invalid hunk header %q", p.line())
+	}
+	number := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	hunk := patchHunk{
+		oldStart: number(match[1]),
+		oldCount: number(match[2]),
+		newStart: number(match[3]),
+		newCount: number(match[4]),
+	}
+	p.next++
+
+	oldLeft, newLeft := hunk.oldCount, hunk.newCount
+	for oldLeft > 0 || newLeft > 0 {
+		if p.next == len(p.lines) {
+			return patchHunk{}, p.errorf("hunk ends early")
+		}
+		line := p.lines[p.next]
+		op, text := line[0], line[1:]
+		switch {
+		case line == "\n" || line == "\r\n":
+			// An empty context line whose space was stripped.
+			op, text = ' ', line
+		case op == '\\':
+			p.noNewline(&hunk)
+			p.next++
+			continue
+		}
+		switch op {
+		case ' ':
+			oldLeft--
+			newLeft--
+		case '-':
+			oldLeft--
+		case '+':
+			newLeft--
+		default:
+			return patchHunk{}, p.errorf("hunk ends early")
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return patchHunk{}, p.errorf("hunk has more lines than its header counts")
+		}
+		hunk.lines = append(hunk.lines, hunkLine{op: op, text: text})
+		p.next++
+	}
+	if p.next < len(p.lines) && strings.HasPrefix(p.lines[p.next], `\`) {
+		p.noNewline(&hunk)
+		p.next++
+	}
+	return hunk, nil
+}
+
+// noNewline handles a "\ No newline at end of file" marker: the line
+// before it has no line ending.
+func (p *patchParser) noNewline(hunk *patchHunk) {
+	if n := len(hunk.lines); n > 0 {
+		hunk.lines[n-1].text = strings.TrimSuffix(strings.TrimSuffix(hunk.lines[n-1].text, "\n"), "\r")
+	}
+}
+
+// parseBinaryPatch parses the data of a "GIT binary patch", starting at
+// that line. Only the forward block is kept; the reverse block that may
+// follow is skipped.
+func (p *patchParser) parseBinaryPatch() (*binaryPatch, error) {
+	p.next++
+	var patch *binaryPatch
+	for block := 0; block < 2 && p.next < len(p.lines); block++ {
+		kind, size, ok := strings.Cut(p.line(), " ")
+		if !ok || (kind != "literal" && kind != "delta") {
+			if block == 0 {
+				return nil, p.errorf("invalid binary patch %q", p.line())
+			}
+			break
+		}
+		length, err := strconv.Atoi(size)
+		if err != nil {
+			return nil, p.errorf("invalid binary patch size %q", size)
+		}
+		p.next++
+
+		var compressed []byte
+		for ; p.next < len(p.lines) && p.line() != ""; p.next++ {
+			decoded, err := decodeBase85Line(p.line())
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			compressed = append(compressed, decoded...)
+		}
+		p.next++ // the blank line ending the block
+
+		if block == 1 {
+			continue
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, p.errorf("invalid binary patch data: %v", err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, p.errorf("invalid binary patch data: %v", err)
+		}
+		if len(data) != length {
+			return nil, p.errorf("binary patch has %d bytes instead of %d", len(data), length)
+		}
+		patch = &binaryPatch{delta: kind == "delta", data: data}
+	}
+	return patch, nil
+}
+
+// base85Alphabet is the alphabet of git's base85 encoding.
+const base85Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+// decodeBase85Line decodes a line of a git binary patch: a letter for the
+// number of bytes, A-Z for 1-26 and a-z for 27-52, and their base85
+// encoding, five characters for each four bytes.
+func decodeBase85Line(line string) ([]byte, error) {
+	if line == "" {
+		return nil, fmt.Errorf("empty binary patch line")
+	}
+	var length int
+	switch c := line[0]; {
+	case c >= 'A' && c <= 'Z':
+		length = int(c-'A') + 1
+	case c >= 'a' && c <= 'z':
+		length = int(c-'a') + 27
+	default:
+		return nil, fmt.Errorf("invalid binary patch line %q", line)
+	}
+	encoded := line[1:]
+	if len(encoded)%5 != 0 || len(encoded)/5*4 < length {
+		return nil, fmt.Errorf("invalid binary patch line %q", line)
+	}
+
+	decoded := make([]byte, 0, len(encoded)/5*4)
+	for i := 0; i < len(encoded); i += 5 {
+		var value uint64
+		for _, c := range []byte(encoded[i : i+5]) {
+			digit := strings.IndexByte(base85Alphabet, c)
+			if digit < 0 {
+				return nil, fmt.Errorf("invalid character %q in binary patch", c)
+			}
+			value = value*85 + uint64(digit)
+		}
+		if value > 0xffffffff {
+			return nil, fmt.Errorf("invalid binary patch line %q", line)
+		}
+		decoded = append(decoded, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+	}
+	return decoded[:length], nil
+}
+
+// hunkFailure is a hunk that did not apply, with its 1-based number.
+type hunkFailure struct {
+	hunk int
+	line int
+}
+
+// applyHunks applies the hunks of a file to its content. A hunk whose
+// lines are not where the patch expects them is looked for at the nearest
+// other line, and with fuzz, also without up to fuzz of its leading and
+// trailing context lines, like `patch --fuzz`. Hunks that do not apply are
+// returned; the others are applied regardless.
+func applyHunks(content []byte, hunks []patchHunk, fuzz int) ([]byte, []hunkFailure) {
+	lines := splitLinesWithEnds(content)
+	var out []string
+	var failures []hunkFailure
+	pos, offset := 0, 0
+
+	for n, hunk := range hunks {
+		var oldLines, newLines []string
+		for _, line := range hunk.lines {
+			if line.op != '+' {
+				oldLines = append(oldLines, line.text)
+			}
+			if line.op != '-' {
+				newLines = append(newLines, line.text)
+			}
+		}
+		lead, trail := hunk.context()
+		// expected is where the old lines start: after line oldStart for a
+		// hunk without old lines, at line oldStart otherwise.
+		expected := hunk.oldStart - 1
+		if hunk.oldCount == 0 {
+			expected = hunk.oldStart
+		}
+
+		at, dropped := -1, 0
+		for f := 0; f <= fuzz && at < 0; f++ {
+			dropLead, dropTrail := min(f, lead), min(f, trail)
+			if f > 0 && dropLead == 0 && dropTrail == 0 {
+				break // no context left to drop
+			}
+			at = findLines(lines, oldLines[dropLead:len(oldLines)-dropTrail], expected+dropLead+offset, pos)
+			if at >= 0 {
+				dropped = dropLead
+				oldLines = oldLines[dropLead : len(oldLines)-dropTrail]
+				newLines = newLines[dropLead : len(newLines)-dropTrail]
+			}
+		}
+		if at < 0 {
+			failures = append(failures, hunkFailure{hunk: n + 1, line: hunk.oldStart})
+			continue
+		}
+
+		out = append(out, lines[pos:at]...)
+		out = append(out, newLines...)
+		pos = at + len(oldLines)
+		offset = at - (expected + dropped)
+	}
+	out = append(out, lines[pos:]...)
+	return []byte(strings.Join(out, "")), failures
+}
+
+// context returns the number of context lines at the start and at the end
+// of the hunk.
+func (h patchHunk) context() (int, int) {
+	lead := 0
+	for lead < len(h.lines) && h.lines[lead].op == ' ' {
+		lead++
+	}
+	trail := 0
+	for trail < len(h.lines)-lead && h.lines[len(h.lines)-1-trail].op == ' ' {
+		trail++
+	}
+	return lead, trail
+}
+
+// findLines returns where want appears in lines at or after from, closest
+// to expected, or -1.
+func findLines(lines, want []string, expected, from int) int {
+	last := len(lines) - len(want)
+	if len(want) == 0 {
+		return min(max(expected, from), len(lines))
+	}
+	for distance := 0; ; distance++ {
+		before, after := expected-distance, expected+distance
+		if before < from && after > last {
+			return -1
+		}
+		for _, at := range []int{after, before} {
+			if at >= from && at <= last && linesEqual(lines[at:at+len(want)], want) {
+				return at
+			}
+		}
+	}
+}
+
+// linesEqual reports whether two lists of lines are the same.
+func linesEqual(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package nanogit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// encodedSubjectPatch is the output of `git format-patch` for a commit
+// whose author and subject are not ASCII.
+const encodedSubjectPatch = `From fb55ba108ffd34f76ed10e7294cbc0809b307110 Mon Sep 17 00:00:00 2001
+From: =?UTF-8?q?Zo=C3=AB=20Example?= <zoe@example.com>
+Date: Wed, 15 Nov 2023 08:00:00 +0000
+Subject: [PATCH] =?UTF-8?q?Fix=20na=C3=AFve=20parsing?=
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: 8bit
+
+Handle the café = résumé case.
+---
+ conf.txt | 3 ++-
+ 1 file changed, 2 insertions(+), 1 deletion(-)
+
+diff --git a/conf.txt b/conf.txt
+index 1337a53..7cdda23 100644
+--- a/conf.txt
++++ b/conf.txt
+@@ -1 +1,2 @@
+-a = 1
++a = 2
++name = "café"
+-- 
+2.39.5
+
+`
+
+func TestParsePatches(t *testing.T) {
+	t.Parallel()
+
+	t.Run("format-patch series", func(t *testing.T) {
+		t.Parallel()
+		patches, err := parsePatches([]byte(formatPatchSeries))
+		require.NoError(t, err)
+		require.Len(t, patches, 2)
+
+		first := patches[0]
+		require.Equal(t, "Alice Example", first.mail.author.Name)
+		require.Len(t, first.files, 4)
+		require.Equal(t, &patchFile{oldPath: "build.sh", newPath: "build.sh", oldMode: 0o100644, newMode: 0o100755}, first.files[0])
+		require.True(t, first.files[1].isNew)
+		require.Empty(t, first.files[1].oldPath)
+		require.Equal(t, []patchHunk{{oldStart: 0, oldCount: 0, newStart: 1, newCount: 1, lines: []hunkLine{{op: '+', text: "fresh\n"}}}}, first.files[1].hunks)
+		require.True(t, first.files[3].isDeleted)
+		require.Equal(t, "old.txt", first.files[3].oldPath)
+
+		second := patches[1]
+		require.True(t, second.files[0].isRename)
+		require.Equal(t, "docs.md", second.files[0].oldPath)
+		require.Equal(t, "guide.md", second.files[0].newPath)
+		require.True(t, second.files[1].binary)
+		require.Equal(t, &binaryPatch{data: []byte("\x00\x01\x02BINARY\xff")}, second.files[1].binaryData)
+	})
+
+	t.Run("encoded subject", func(t *testing.T) {
+		t.Parallel()
+		patches, err := parsePatches([]byte(encodedSubjectPatch))
+		require.NoError(t, err)
+		require.Equal(t, "Zoë Example", patches[0].mail.author.Name)
+		require.Equal(t, "Fix naïve parsing\n\nHandle the café = résumé case.\n", patches[0].mail.message)
+	})
+
+	t.Run("quoted-printable body", func(t *testing.T) {
+		t.Parallel()
+		// The same email as sent with a quoted-printable transfer encoding.
+		patch := strings.Replace(encodedSubjectPatch, "8bit", "quoted-printable", 1)
+		patch = strings.NewReplacer(" = ", " =3D ", "é", "=C3=A9", "-- \n", "--=20\n").Replace(patch)
+		require.Contains(t, patch, "+name =3D \"caf=C3=A9\"\n")
+
+		patches, err := parsePatches([]byte(patch))
+		require.NoError(t, err)
+		require.Equal(t, "Fix naïve parsing\n\nHandle the café = résumé case.\n", patches[0].mail.message)
+		require.Equal(t, []hunkLine{
+			{op: '-', text: "a = 1\n"},
+			{op: '+', text: "a = 2\n"},
+			{op: '+', text: "name = \"café\"\n"},
+		}, patches[0].files[0].hunks[0].lines)
+	})
+
+	t.Run("plain diff without newline at end", func(t *testing.T) {
+		t.Parallel()
+		patches, err := parsePatches([]byte("Some description\n" +
+			"--- /dev/null\n" +
+			"+++ new/dir/file.txt\n" +
+			"@@ -0,0 +1,2 @@\n" +
+			"+first\n" +
+			"+last\n" +
+			"\\ No newline at end of file\n"))
+		require.NoError(t, err)
+		require.Nil(t, patches[0].mail)
+		file := patches[0].files[0]
+		require.True(t, file.isNew)
+		require.Equal(t, "dir/file.txt", file.newPath)
+		require.Equal(t, []hunkLine{{op: '+', text: "first\n"}, {op: '+', text: "last"}}, file.hunks[0].lines)
+	})
+
+	t.Run("quoted paths", func(t *testing.T) {
+		t.Parallel()
+		patches, err := parsePatches([]byte("diff --git \"a/with space\\tand tab\" \"b/with space\\tand tab\"\n" +
+			"deleted file mode 100644\n" +
+			"index 3367afd..0000000\n"))
+		require.NoError(t, err)
+		require.Equal(t, "with space\tand tab", patches[0].files[0].oldPath)
+		require.True(t, patches[0].files[0].isDeleted)
+	})
+
+	for _, tt := range []struct {
+		name  string
+		patch string
+	}{
+		{name: "no diff", patch: "just text\n"},
+		{name: "short hunk", patch: "--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n-a\n+b\n"},
+		{name: "invalid binary data", patch: "diff --git a/x b/x\nGIT binary patch\nliteral 3\nB!!!!!\n\n"},
+		{name: "invalid author", patch: "From 04dec2e3016e1d685eda7706bdbb345f3df84f5f Mon Sep 17 00:00:00 2001\nFrom: nobody\n\n--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n"},
+	} {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := parsePatches([]byte(tt.patch))
+			require.ErrorIs(t, err, ErrInvalidPatch)
+		})
+	}
+}
+
+func TestApplyHunks(t *testing.T) {
+	t.Parallel()
+
+	// hunk builds a hunk from "op text" lines.
+	hunk := func(oldStart int, lines ...string) patchHunk {
+		h := patchHunk{oldStart: oldStart}
+		for _, line := range lines {
+			h.lines = append(h.lines, hunkLine{op: line[0], text: line[1:] + "\n"})
+			if line[0] != '+' {
+				h.oldCount++
+			}
+		}
+		return h
+	}
+	file := "a\nb\nc\nd\ne\nf\ng\n"
+
+	for _, tt := range []struct {
+		name     string
+		content  string
+		hunks    []patchHunk
+		fuzz     int
+		want     string
+		failures []hunkFailure
+	}{
+		{
+			name:    "exact",
+			content: file,
+			hunks:   []patchHunk{hunk(2, " b", "-c", "+C", " d"), hunk(6, " f", "+F", " g")},
+			want:    "a\nb\nC\nd\ne\nf\nF\ng\n",
+		},
+		{
+			name:    "offset",
+			content: "x\ny\n" + file,
+			hunks:   []patchHunk{hunk(2, " b", "-c", "+C", " d"), hunk(6, " f", "+F", " g")},
+			want:    "x\ny\na\nb\nC\nd\ne\nf\nF\ng\n",
+		},
+		{
+			name:    "nearest match",
+			content: "x\n" + file + "b\nc\nd\n",
+			hunks:   []patchHunk{hunk(2, " b", "-c", "+C", " d")},
+			want:    "x\na\nb\nC\nd\ne\nf\ng\nb\nc\nd\n",
+		},
+		{
+			name:    "insert at start",
+			content: file,
+			hunks:   []patchHunk{{oldStart: 0, lines: []hunkLine{{op: '+', text: "top\n"}}}},
+			want:    "top\n" + file,
+		},
+		{
+			name:     "context mismatch",
+			content:  file,
+			hunks:    []patchHunk{hunk(2, " b", "-c", "+C", " x"), hunk(6, " f", "+F", " g")},
+			want:     "a\nb\nc\nd\ne\nf\nF\ng\n",
+			failures: []hunkFailure{{hunk: 1, line: 2}},
+		},
+		{
+			name:    "fuzz",
+			content: file,
+			hunks:   []patchHunk{hunk(2, " b", "-c", "+C", " x")},
+			fuzz:    1,
+			want:    "a\nb\nC\nd\ne\nf\ng\n",
+		},
+		{
+			name:     "fuzz does not drop changed lines",
+			content:  file,
+			hunks:    []patchHunk{hunk(2, " b", "-x", "+C", " d")},
+			fuzz:     3,
+			want:     file,
+			failures: []hunkFailure{{hunk: 1, line: 2}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, failures := applyHunks([]byte(tt.content), tt.hunks, tt.fuzz)
+			require.Equal(t, tt.want, string(got))
+			require.Equal(t, tt.failures, failures)
+		})
+	}
+}
+
+func TestDecodeBase85Line(t *testing.T) {
+	t.Parallel()
+
+	got, err := decodeBase85Line("RcmZQzWODNKa}0|74*&?*0>}UW")
+	require.NoError(t, err)
+	require.Len(t, got, 18)
+
+	for _, line := range []string{"", "1abcde", "Babcd", "E" + strings.Repeat("~", 5)} {
+		_, err := decodeBase85Line(line)
+		require.Error(t, err, line)
+	}
+}
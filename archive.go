@@ -0,0 +1,360 @@
+package nanogit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// archiveChunkSize is how many files Archive fetches before writing them.
+// The archive is written in tree order, so blobs are fetched in chunks and
+// only one chunk of file contents is held in memory at a time.
+const archiveChunkSize = 100
+
+// ArchiveFormat is the container format written by Archive.
+type ArchiveFormat string
+
+const (
+	// ArchiveFormatTar writes an uncompressed tar archive.
+	ArchiveFormatTar ArchiveFormat = "tar"
+	// ArchiveFormatTarGz writes a gzip-compressed tar archive.
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+	// ArchiveFormatZip writes a zip archive.
+	ArchiveFormatZip ArchiveFormat = "zip"
+)
+
+// ArchiveOptions configures Archive.
+type ArchiveOptions struct {
+	// Format is the archive format. If empty, defaults to ArchiveFormatTar.
+	Format ArchiveFormat
+
+	// Prefix is prepended to every path in the archive, as with
+	// `git archive --prefix`. Use a trailing slash to put the files in a
+	// directory (e.g., "project-1.0/").
+	Prefix string
+
+	// IncludePaths specifies which files to include in the archive.
+	// Supports the same glob patterns as CloneOptions.IncludePaths.
+	// If empty, all files are included (unless excluded by ExcludePaths).
+	IncludePaths []string
+
+	// ExcludePaths specifies which files to leave out of the archive.
+	// Supports the same glob patterns as CloneOptions.ExcludePaths and
+	// takes precedence over IncludePaths.
+	ExcludePaths []string
+
+	// BatchSize specifies how many blobs to fetch in a single request.
+	// See CloneOptions.BatchSize.
+	BatchSize int
+
+	// Concurrency specifies how many blob fetches to perform in parallel.
+	// See CloneOptions.Concurrency.
+	Concurrency int
+}
+
+// Archive writes the tree of a commit to w as a tar, tar.gz or zip archive,
+// like `git archive`. Files are fetched in batches and written as they
+// arrive, in tree order, without touching the local filesystem.
+//
+// The archive matches what git produces: executable files keep their
+// executable bit, symlinks are stored as symlinks, every entry uses the
+// committer time as its modification time, and the commit hash is embedded
+// (as a pax global header comment for tar, as the archive comment for zip)
+// so that `git get-tar-commit-id` can read it back. Directories are written
+// for every path leading to an included file. Submodules are skipped.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - commitHash: Hash of the commit to archive
+//   - w: Destination of the archive
+//   - opts: Format, path prefix, path filters and fetch tuning
+//
+// Returns:
+//   - error: Error if the commit cannot be read, a blob cannot be fetched, or writing to w fails
+//
+// Example:
+//
+//	f, err := os.Create("release.tar.gz")
+//	if err != nil {
+//	    return err
+//	}
+//	defer f.Close()
+//
+//	err = client.Archive(ctx, commitHash, f, nanogit.ArchiveOptions{
+//	    Format:       nanogit.ArchiveFormatTarGz,
+//	    Prefix:       "myapp-1.0/",
+//	    ExcludePaths: []string{"docs/**", "*_test.go"},
+//	    BatchSize:    50,
+//	})
+func (c *httpClient) Archive(ctx context.Context, commitHash hash.Hash, w io.Writer, opts ArchiveOptions) error {
+	logger := log.FromContext(ctx)
+
+	format := opts.Format
+	if format == "" {
+		format = ArchiveFormatTar
+	}
+
+	logger.Debug("Starting archive",
+		"commit_hash", commitHash.String(),
+		"format", string(format),
+		"prefix", opts.Prefix,
+		"include_paths", opts.IncludePaths,
+		"exclude_paths", opts.ExcludePaths)
+
+	commit, err := c.GetCommit(ctx, commitHash)
+	if err != nil {
+		return fmt.Errorf("get commit %s: %w", commitHash.String(), err)
+	}
+
+	var archive archiveWriter
+	switch format {
+	case ArchiveFormatTar:
+		archive, err = newTarArchive(w, nil, commit)
+	case ArchiveFormatTarGz:
+		gz := gzip.NewWriter(w)
+		archive, err = newTarArchive(gz, gz, commit)
+	case ArchiveFormatZip:
+		archive, err = newZipArchive(w, commit)
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+	if err != nil {
+		return fmt.Errorf("write archive header: %w", err)
+	}
+
+	tree, err := c.GetFlatTree(ctx, commit.Hash)
+	if err != nil {
+		return fmt.Errorf("get tree for commit %s: %w", commit.Hash.String(), err)
+	}
+
+	var files []FlatTreeEntry
+	for _, entry := range tree.Entries {
+		if entry.Type == protocol.ObjectTypeBlob && c.shouldIncludePath(entry.Path, opts.IncludePaths, opts.ExcludePaths) {
+			files = append(files, entry)
+		}
+	}
+
+	if err := c.writeArchiveEntries(ctx, archive, files, opts); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("finish archive: %w", err)
+	}
+
+	logger.Debug("Archive completed",
+		"commit_hash", commit.Hash.String(),
+		"total_files", len(tree.Entries),
+		"archived_files", len(files))
+
+	return nil
+}
+
+// writeArchiveEntries fetches the blobs of files chunk by chunk and writes
+// them, preceded by their parent directories, in tree order.
+func (c *httpClient) writeArchiveEntries(ctx context.Context, archive archiveWriter, files []FlatTreeEntry, opts ArchiveOptions) error {
+	if strings.HasSuffix(opts.Prefix, "/") {
+		if err := archive.WriteDir(opts.Prefix); err != nil {
+			return fmt.Errorf("write directory %s: %w", opts.Prefix, err)
+		}
+	}
+
+	writtenDirs := make(map[string]bool)
+	var writeParents func(dir string) error
+	writeParents = func(dir string) error {
+		if dir == "." || writtenDirs[dir] {
+			return nil
+		}
+		if err := writeParents(path.Dir(dir)); err != nil {
+			return err
+		}
+		writtenDirs[dir] = true
+		name := opts.Prefix + dir + "/"
+		if err := archive.WriteDir(name); err != nil {
+			return fmt.Errorf("write directory %s: %w", name, err)
+		}
+		return nil
+	}
+
+	for start := 0; start < len(files); start += archiveChunkSize {
+		chunk := files[start:min(start+archiveChunkSize, len(files))]
+
+		var hashes []hash.Hash
+		contents := make(map[hash.Hash][]byte, len(chunk))
+		for _, entry := range chunk {
+			if _, ok := contents[entry.Hash]; !ok {
+				contents[entry.Hash] = nil
+				hashes = append(hashes, entry.Hash)
+			}
+		}
+
+		var mu sync.Mutex
+		err := c.GetBlobs(ctx, hashes, func(blob *Blob) error {
+			mu.Lock()
+			defer mu.Unlock()
+			contents[blob.Hash] = blob.Content
+			return nil
+		}, GetBlobsOptions{
+			BatchSize:   opts.BatchSize,
+			Concurrency: opts.Concurrency,
+		})
+		if err != nil {
+			return fmt.Errorf("fetch blobs: %w", err)
+		}
+
+		for _, entry := range chunk {
+			if err := writeParents(path.Dir(entry.Path)); err != nil {
+				return err
+			}
+
+			name := opts.Prefix + entry.Path
+			content := contents[entry.Hash]
+			switch entry.Mode {
+			case 0o120000:
+				err = archive.WriteSymlink(name, string(content))
+			case 0o100755:
+				err = archive.WriteFile(name, 0o755, content)
+			default:
+				err = archive.WriteFile(name, 0o644, content)
+			}
+			if err != nil {
+				return fmt.Errorf("write file %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// archiveWriter writes the entries of one archive format.
+type archiveWriter interface {
+	// WriteDir writes a directory. name ends with a slash.
+	WriteDir(name string) error
+	// WriteFile writes a regular file with the given permission bits.
+	WriteFile(name string, perm fs.FileMode, content []byte) error
+	// WriteSymlink writes a symbolic link to target.
+	WriteSymlink(name, target string) error
+	// Close finishes the archive without closing the underlying writer.
+	Close() error
+}
+
+// tarArchive writes tar archives the way git does: a pax global header with
+// the commit hash, entries owned by root, and permissions with the default
+// tar.umask of 0002 applied.
+type tarArchive struct {
+	tw      *tar.Writer
+	gz      *gzip.Writer
+	modTime time.Time
+}
+
+func newTarArchive(w io.Writer, gz *gzip.Writer, commit *Commit) (*tarArchive, error) {
+	a := &tarArchive{tw: tar.NewWriter(w), gz: gz, modTime: commit.Time()}
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: map[string]string{"comment": commit.Hash.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *tarArchive) header(name string, typeflag byte, mode int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: typeflag,
+		Name:     name,
+		Mode:     mode,
+		ModTime:  a.modTime,
+		Uname:    "root",
+		Gname:    "root",
+	}
+}
+
+func (a *tarArchive) WriteDir(name string) error {
+	return a.tw.WriteHeader(a.header(name, tar.TypeDir, 0o775))
+}
+
+func (a *tarArchive) WriteFile(name string, perm fs.FileMode, content []byte) error {
+	mode := int64(0o664)
+	if perm&0o111 != 0 {
+		mode = 0o775
+	}
+	hdr := a.header(name, tar.TypeReg, mode)
+	hdr.Size = int64(len(content))
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(content)
+	return err
+}
+
+func (a *tarArchive) WriteSymlink(name, target string) error {
+	hdr := a.header(name, tar.TypeSymlink, 0o777)
+	hdr.Linkname = target
+	return a.tw.WriteHeader(hdr)
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if a.gz != nil {
+		return a.gz.Close()
+	}
+	return nil
+}
+
+// zipArchive writes zip archives the way git does: the commit hash as the
+// archive comment, Unix modes in the external attributes, and symlinks
+// stored uncompressed with their target as content.
+type zipArchive struct {
+	zw      *zip.Writer
+	modTime time.Time
+}
+
+func newZipArchive(w io.Writer, commit *Commit) (*zipArchive, error) {
+	a := &zipArchive{zw: zip.NewWriter(w), modTime: commit.Time()}
+	if err := a.zw.SetComment(commit.Hash.String()); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *zipArchive) write(name string, mode fs.FileMode, method uint16, content []byte) error {
+	hdr := &zip.FileHeader{Name: name, Method: method, Modified: a.modTime}
+	hdr.SetMode(mode)
+	f, err := a.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+func (a *zipArchive) WriteDir(name string) error {
+	return a.write(name, fs.ModeDir|0o755, zip.Store, nil)
+}
+
+func (a *zipArchive) WriteFile(name string, perm fs.FileMode, content []byte) error {
+	return a.write(name, perm, zip.Deflate, content)
+}
+
+func (a *zipArchive) WriteSymlink(name, target string) error {
+	return a.write(name, fs.ModeSymlink|0o777, zip.Store, []byte(target))
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
@@ -0,0 +1,180 @@
+package nanogit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type archivedEntry struct {
+	name     string
+	mode     fs.FileMode
+	content  string
+	linkname string
+}
+
+func newArchiveTestRepo(t *testing.T) (*testRepo, hash.Hash) {
+	t.Helper()
+	repo := newTestRepo(t)
+	commit := repo.commit("initial", map[string]string{
+		"README.md":         "readme",
+		"docs/guide.md":     "guide",
+		"docs/api/index.md": "api",
+		"scripts/run.sh":    "exec:#!/bin/sh",
+		"latest":            "symlink:docs/guide.md",
+	})
+	return repo, commit
+}
+
+func readTarArchive(t *testing.T, r io.Reader) (entries []archivedEntry, comment string) {
+	t.Helper()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, comment
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			comment = hdr.PAXRecords["comment"]
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries = append(entries, archivedEntry{
+			name:     hdr.Name,
+			mode:     hdr.FileInfo().Mode(),
+			content:  string(content),
+			linkname: hdr.Linkname,
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	t.Parallel()
+
+	t.Run("tar keeps modes, symlinks and the commit hash", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newArchiveTestRepo(t)
+
+		var buf bytes.Buffer
+		err := repo.client().Archive(context.Background(), commit, &buf, ArchiveOptions{BatchSize: 10})
+		require.NoError(t, err)
+
+		entries, comment := readTarArchive(t, &buf)
+		assert.Equal(t, commit.String(), comment)
+		assert.Equal(t, []archivedEntry{
+			{name: "README.md", mode: 0o664, content: "readme"},
+			{name: "docs/", mode: fs.ModeDir | 0o775},
+			{name: "docs/api/", mode: fs.ModeDir | 0o775},
+			{name: "docs/api/index.md", mode: 0o664, content: "api"},
+			{name: "docs/guide.md", mode: 0o664, content: "guide"},
+			{name: "latest", mode: fs.ModeSymlink | 0o777, linkname: "docs/guide.md"},
+			{name: "scripts/", mode: fs.ModeDir | 0o775},
+			{name: "scripts/run.sh", mode: 0o775, content: "#!/bin/sh"},
+		}, entries)
+	})
+
+	t.Run("tar.gz with prefix and filters", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newArchiveTestRepo(t)
+
+		var buf bytes.Buffer
+		err := repo.client().Archive(context.Background(), commit, &buf, ArchiveOptions{
+			Format:       ArchiveFormatTarGz,
+			Prefix:       "app-1.0/",
+			IncludePaths: []string{"docs/**", "README.md"},
+			ExcludePaths: []string{"docs/api/**"},
+		})
+		require.NoError(t, err)
+
+		gz, err := gzip.NewReader(&buf)
+		require.NoError(t, err)
+		entries, comment := readTarArchive(t, gz)
+		assert.Equal(t, commit.String(), comment)
+
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.name)
+		}
+		assert.Equal(t, []string{
+			"app-1.0/",
+			"app-1.0/README.md",
+			"app-1.0/docs/",
+			"app-1.0/docs/guide.md",
+		}, names)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newArchiveTestRepo(t)
+
+		var buf bytes.Buffer
+		err := repo.client().Archive(context.Background(), commit, &buf, ArchiveOptions{Format: ArchiveFormatZip})
+		require.NoError(t, err)
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		assert.Equal(t, commit.String(), zr.Comment)
+
+		files := make(map[string]*zip.File)
+		for _, f := range zr.File {
+			files[f.Name] = f
+		}
+		require.Contains(t, files, "docs/")
+		assert.True(t, files["docs/"].Mode().IsDir())
+		assert.Equal(t, fs.FileMode(0o755), files["scripts/run.sh"].Mode())
+		assert.Equal(t, fs.FileMode(0o644), files["README.md"].Mode())
+		assert.Equal(t, fs.ModeSymlink, files["latest"].Mode().Type())
+		assert.Equal(t, repo.clock, files["README.md"].Modified.Unix())
+
+		rc, err := files["latest"].Open()
+		require.NoError(t, err)
+		target, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		assert.Equal(t, "docs/guide.md", string(target))
+	})
+
+	t.Run("files with identical contents", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("dupes", map[string]string{"a.txt": "same", "b/c.txt": "same"})
+
+		var buf bytes.Buffer
+		err := repo.client().Archive(context.Background(), commit, &buf, ArchiveOptions{})
+		require.NoError(t, err)
+
+		entries, _ := readTarArchive(t, &buf)
+		require.Len(t, entries, 3)
+		assert.Equal(t, "same", entries[0].content)
+		assert.Equal(t, "same", entries[2].content)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newArchiveTestRepo(t)
+
+		err := repo.client().Archive(context.Background(), commit, io.Discard, ArchiveOptions{Format: "rar"})
+		require.ErrorContains(t, err, `unsupported archive format "rar"`)
+	})
+
+	t.Run("unknown commit", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		missing := hash.MustFromHex("1234567890123456789012345678901234567890")
+
+		err := repo.client().Archive(context.Background(), missing, io.Discard, ArchiveOptions{})
+		require.ErrorIs(t, err, ErrObjectNotFound)
+	})
+}
@@ -0,0 +1,289 @@
+package nanogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+)
+
+// blamePageSize is how many commits Blame requests per ListCommits page.
+const blamePageSize = 100
+
+// BlameOptions configures Blame.
+type BlameOptions struct {
+	// StartLine is the first line to blame (1-based). If 0, starts at the
+	// first line.
+	StartLine int
+	// EndLine is the last line to blame (1-based, inclusive). If 0, ends at
+	// the last line.
+	EndLine int
+	// IgnoreWhitespace ignores whitespace when comparing versions, like
+	// `git blame -w`: a line whose only change is whitespace is attributed to
+	// the commit that last changed something else in it.
+	IgnoreWhitespace bool
+}
+
+// BlameLine is a line of a file together with the commit that introduced it.
+type BlameLine struct {
+	// LineNumber is the 1-based number of the line in the blamed version.
+	LineNumber int
+	// Content is the line, without its line terminator.
+	Content string
+	// Commit is the commit that last changed the line.
+	Commit *Commit
+	// OriginalLineNumber is the 1-based number of the line in Commit's
+	// version of the file.
+	OriginalLineNumber int
+	// OriginalPath is the path of the file in Commit, which differs from the
+	// blamed path when the file was renamed since.
+	OriginalPath string
+}
+
+// Blame returns, for each line of a file at a commit, the commit that last
+// changed it, like `git blame`. It walks the file's history with the
+// path-filtered ListCommits (following renames), fetches each version with
+// GetBlobByPath and diffs consecutive versions line by line; lines that are
+// unchanged between two versions are carried back to the older one. The walk
+// stops as soon as every requested line has been attributed.
+//
+// Only history reachable through ListCommits is considered, so for a merge
+// the lines are attributed along the path ListCommits takes.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - commitHash: Hash of the commit whose version of the file is blamed
+//   - path: Path of the file, relative to the repository root
+//   - opts: Line range and whitespace handling
+//
+// Returns:
+//   - []BlameLine: One entry per requested line, in order
+//   - error: Error if the file does not exist, the range is invalid or history cannot be fetched
+//
+// Example:
+//
+//	lines, err := client.Blame(ctx, commitHash, "provisioning/datasources.yaml", nanogit.BlameOptions{
+//	    StartLine: 10,
+//	    EndLine:   20,
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	for _, l := range lines {
+//	    fmt.Printf("%s %-20s %4d) %s\n", l.Commit.Hash.String()[:8], l.Commit.Author.Name, l.LineNumber, l.Content)
+//	}
+func (c *httpClient) Blame(ctx context.Context, commitHash hash.Hash, path string, opts BlameOptions) ([]BlameLine, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Blame file",
+		"commit_hash", commitHash.String(),
+		"path", path,
+		"start_line", opts.StartLine,
+		"end_line", opts.EndLine)
+
+	ctx, _ = storage.FromContextOrInMemory(ctx)
+
+	commit, err := c.GetCommit(ctx, commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("get commit %s: %w", commitHash.String(), err)
+	}
+
+	lines, err := c.blameVersion(ctx, commit, path)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := opts.StartLine, opts.EndLine
+	if start == 0 {
+		start = 1
+	}
+	if end == 0 {
+		end = len(lines)
+	}
+	if start < 1 || end > len(lines) || start > end {
+		return nil, fmt.Errorf("invalid line range %d-%d: file %s has %d lines", start, end, path, len(lines))
+	}
+
+	b := &blamer{
+		client:           c,
+		path:             path,
+		ignoreWhitespace: opts.IgnoreWhitespace,
+		result:           make([]BlameLine, end-start+1),
+		tracked:          lines,
+		pending:          make(map[int]int, end-start+1),
+	}
+	for i := start - 1; i < end; i++ {
+		b.result[i-start+1] = BlameLine{LineNumber: i + 1, Content: lines[i]}
+		b.pending[i] = i - start + 1
+	}
+
+	if err := b.run(ctx, commitHash); err != nil {
+		return nil, err
+	}
+
+	logger.Debug("Blame completed",
+		"commit_hash", commitHash.String(),
+		"path", path,
+		"line_count", len(b.result),
+		"versions_compared", b.versions)
+
+	return b.result, nil
+}
+
+// blameVersion returns the lines of the file at path in commit.
+func (c *httpClient) blameVersion(ctx context.Context, commit *Commit, path string) ([]string, error) {
+	blob, err := c.GetBlobByPath(ctx, commit.Tree, path)
+	if err != nil {
+		return nil, fmt.Errorf("get %s at %s: %w", path, commit.Hash.String(), err)
+	}
+	return splitLines(blob.Content), nil
+}
+
+// blamer holds the state of a single Blame call.
+type blamer struct {
+	client           *httpClient
+	path             string
+	ignoreWhitespace bool
+	result           []BlameLine
+
+	// tracked is the version of the file the pending lines refer to, owner
+	// the commit that produced it and ownerPath its path in that commit.
+	tracked   []string
+	owner     *Commit
+	ownerPath string
+	// pending maps the index of a not yet attributed line in tracked to its
+	// index in result.
+	pending  map[int]int
+	versions int
+}
+
+// run walks the history of the file and attributes the pending lines.
+// Each page of history starts at the last commit of the previous one, with
+// the path the file had there, so the walk never restarts from the top.
+func (b *blamer) run(ctx context.Context, commitHash hash.Hash) error {
+	start, startPath := commitHash, b.path
+	for first := true; len(b.pending) > 0; first = false {
+		commits, err := b.client.ListCommits(ctx, start, ListCommitsOptions{
+			PerPage: blamePageSize,
+			Path:    startPath,
+			Follow:  true,
+		})
+		if err != nil {
+			return fmt.Errorf("list commits for %s: %w", b.path, err)
+		}
+		if len(commits) == 0 {
+			break
+		}
+		last, full := commits[len(commits)-1], len(commits) == blamePageSize
+		if !first {
+			// The page starts with the commit the previous one ended with.
+			commits = commits[1:]
+		}
+
+		for i := range commits {
+			if err := b.compare(ctx, &commits[i]); err != nil {
+				return err
+			}
+			if len(b.pending) == 0 {
+				return nil
+			}
+		}
+
+		if !full {
+			break
+		}
+		start, startPath = last.Hash, last.Path
+		if startPath == "" {
+			startPath = b.path
+		}
+	}
+
+	// The oldest version introduced every line still pending.
+	if b.owner != nil {
+		for idx := range b.pending {
+			b.attribute(idx)
+		}
+	} else if len(b.pending) > 0 {
+		return fmt.Errorf("no commit in the history of %s changes it", b.path)
+	}
+	return nil
+}
+
+// compare diffs the version of the file in commit against the tracked
+// version. Tracked lines that are not in commit's version were introduced by
+// the current owner; the others are carried over to commit, which becomes
+// the new owner.
+func (b *blamer) compare(ctx context.Context, commit *Commit) error {
+	commitPath := commit.Path
+	if commitPath == "" {
+		commitPath = b.path
+	}
+
+	// A commit that deleted the file has no version of it: everything still
+	// pending was introduced by the owner, when the file was re-added.
+	older, err := b.client.blameVersion(ctx, commit, commitPath)
+	var pathErr *PathNotFoundError
+	if err != nil && !errors.As(err, &pathErr) {
+		return err
+	}
+	b.versions++
+
+	if b.owner == nil {
+		// The newest commit touching the file; its version is normally the
+		// blamed one, but any difference is still attributed to it.
+		b.owner, b.ownerPath = commit, commitPath
+	}
+
+	edits := diffLines(b.normalize(older), b.normalize(b.tracked))
+	carried := make(map[int]int, len(b.pending))
+	for _, e := range edits {
+		idx, ok := b.pending[e.B]
+		if !ok || e.Op == lineDelete {
+			continue
+		}
+		if e.Op == lineEqual {
+			carried[e.A] = idx
+			continue
+		}
+		b.attribute(e.B)
+	}
+
+	b.pending = carried
+	b.tracked = older
+	b.owner, b.ownerPath = commit, commitPath
+	return nil
+}
+
+// attribute assigns the tracked line at idx to the current owner.
+func (b *blamer) attribute(idx int) {
+	line := &b.result[b.pending[idx]]
+	line.Commit = b.owner
+	line.OriginalLineNumber = idx + 1
+	line.OriginalPath = b.ownerPath
+}
+
+// normalize returns the lines used for comparison, with whitespace removed
+// when IgnoreWhitespace is set.
+func (b *blamer) normalize(lines []string) []string {
+	if !b.ignoreWhitespace {
+		return lines
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, line)
+	}
+	return out
+}
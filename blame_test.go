@@ -0,0 +1,142 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlame(t *testing.T) {
+	t.Parallel()
+
+	// summarize returns "<commit>:<content>" for every line, where commit is
+	// a letter for the position of the blamed commit in commits (A, B, ...).
+	summarize := func(t *testing.T, lines []BlameLine, commits ...hash.Hash) []string {
+		t.Helper()
+		var out []string
+		for _, l := range lines {
+			require.NotNil(t, l.Commit, "line %d is not attributed", l.LineNumber)
+			idx := -1
+			for i, c := range commits {
+				if c == l.Commit.Hash {
+					idx = i
+				}
+			}
+			out = append(out, string(rune('A'+idx))+":"+l.Content)
+		}
+		return out
+	}
+
+	t.Run("attributes lines to the commits that changed them", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		c1 := repo.commit("add", map[string]string{"f.txt": "one\ntwo\nthree\n"})
+		c2 := repo.commit("edit two", map[string]string{"f.txt": "one\n2\nthree\n"}, c1)
+		c3 := repo.commit("other file", map[string]string{"f.txt": "one\n2\nthree\n", "g.txt": "g"}, c2)
+		c4 := repo.commit("insert", map[string]string{"f.txt": "zero\none\n2\nthree\n", "g.txt": "g"}, c3)
+
+		lines, err := repo.client().Blame(context.Background(), c4, "f.txt", BlameOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"D:zero", "A:one", "B:2", "A:three"}, summarize(t, lines, c1, c2, c3, c4))
+
+		assert.Equal(t, 2, lines[1].LineNumber)
+		assert.Equal(t, 1, lines[1].OriginalLineNumber)
+		assert.Equal(t, "f.txt", lines[1].OriginalPath)
+		assert.Equal(t, "Test", lines[1].Commit.Author.Name)
+	})
+
+	t.Run("line range", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		c1 := repo.commit("add", map[string]string{"f.txt": "a\nb\nc\nd\n"})
+		c2 := repo.commit("edit", map[string]string{"f.txt": "a\nB\nC\nd\n"}, c1)
+
+		lines, err := repo.client().Blame(context.Background(), c2, "f.txt", BlameOptions{StartLine: 2, EndLine: 3})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"B:B", "B:C"}, summarize(t, lines, c1, c2))
+		assert.Equal(t, 2, lines[0].LineNumber)
+
+		_, err = repo.client().Blame(context.Background(), c2, "f.txt", BlameOptions{StartLine: 3, EndLine: 9})
+		require.ErrorContains(t, err, "invalid line range")
+	})
+
+	t.Run("ignore whitespace", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		c1 := repo.commit("add", map[string]string{"f.txt": "if x {\nrun()\n}\n"})
+		c2 := repo.commit("indent", map[string]string{"f.txt": "if x {\n\trun()\n}\n"}, c1)
+
+		lines, err := repo.client().Blame(context.Background(), c2, "f.txt", BlameOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"A:if x {", "B:\trun()", "A:}"}, summarize(t, lines, c1, c2))
+
+		lines, err = repo.client().Blame(context.Background(), c2, "f.txt", BlameOptions{IgnoreWhitespace: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"A:if x {", "A:\trun()", "A:}"}, summarize(t, lines, c1, c2))
+	})
+
+	t.Run("follows renames", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		c1 := repo.commit("add", map[string]string{"old.txt": "a\nb\n"})
+		c2 := repo.commit("rename", map[string]string{"new.txt": "a\nb\n"}, c1)
+		c3 := repo.commit("edit", map[string]string{"new.txt": "a\nb\nc\n"}, c2)
+
+		lines, err := repo.client().Blame(context.Background(), c3, "new.txt", BlameOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"A:a", "A:b", "C:c"}, summarize(t, lines, c1, c2, c3))
+		assert.Equal(t, "old.txt", lines[0].OriginalPath)
+		assert.Equal(t, "new.txt", lines[2].OriginalPath)
+	})
+
+	t.Run("walks a history longer than a page", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+
+		// Every commit appends a line, except one that renames the file:
+		// the oldest commit of the first page of history, counted from head.
+		total := 2*blamePageSize + 10
+		renameAt := total - blamePageSize
+		content := "line 0\n"
+		head := repo.commit("add", map[string]string{"old.txt": content})
+		introduced := []hash.Hash{head}
+		for i := 1; i < total; i++ {
+			name := "new.txt"
+			if i < renameAt {
+				name = "old.txt"
+			}
+			if i != renameAt {
+				content += fmt.Sprintf("line %d\n", len(introduced))
+			}
+			head = repo.commit(fmt.Sprintf("commit %d", i), map[string]string{name: content}, head)
+			if i != renameAt {
+				introduced = append(introduced, head)
+			}
+		}
+
+		lines, err := repo.client().Blame(context.Background(), head, "new.txt", BlameOptions{})
+		require.NoError(t, err)
+		require.Len(t, lines, len(introduced))
+		for i, line := range lines {
+			require.NotNil(t, line.Commit, "line %d is not attributed", i+1)
+			assert.Equal(t, introduced[i], line.Commit.Hash, "line %d", i+1)
+			assert.True(t, strings.HasSuffix(line.Content, fmt.Sprint(i)))
+		}
+		assert.Equal(t, "old.txt", lines[0].OriginalPath)
+		assert.Equal(t, "new.txt", lines[len(lines)-1].OriginalPath)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		c1 := repo.commit("add", map[string]string{"f.txt": "a\n"})
+
+		_, err := repo.client().Blame(context.Background(), c1, "missing.txt", BlameOptions{})
+		require.ErrorIs(t, err, ErrObjectNotFound)
+	})
+}
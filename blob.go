@@ -11,6 +11,7 @@ import (
 	"github.com/grafana/nanogit/protocol/client"
 	"github.com/grafana/nanogit/protocol/hash"
 	"github.com/grafana/nanogit/storage"
+	"golang.org/x/sync/errgroup"
 )
 
 // GetBlob retrieves a blob (file content) from the repository by its hash.
@@ -204,3 +205,181 @@ func (c *httpClient) GetBlobByPath(ctx context.Context, rootHash hash.Hash, path
 
 	return nil, NewPathNotFoundError(path)
 }
+
+// GetBlobsOptions configures how GetBlobs groups and parallelizes requests.
+type GetBlobsOptions struct {
+	// BatchSize specifies how many blobs to fetch in a single request.
+	// A value of 0 or 1 fetches blobs individually.
+	// If a blob is not returned in a batch request, or the batch response
+	// exceeds the multi-object byte limit, the blobs of that batch are
+	// fetched individually instead.
+	BatchSize int
+
+	// Concurrency specifies how many requests to perform in parallel.
+	// A value of 0 or 1 fetches sequentially.
+	Concurrency int
+}
+
+// BlobFunc receives each blob fetched by GetBlobs. When
+// GetBlobsOptions.Concurrency is greater than one it is called concurrently,
+// from the goroutine that fetched the blob, so it must be safe for
+// concurrent use; slow work such as writing files then runs in parallel.
+// Returning an error stops GetBlobs, which returns that error.
+type BlobFunc func(blob *Blob) error
+
+// GetBlobs fetches many blobs using batched and concurrent requests and
+// streams each one to fn as soon as it arrives. It is the batch counterpart
+// of GetBlob, using the same batching with per-blob fallback as Clone.
+//
+// Blobs are delivered in no particular order and duplicate hashes are
+// fetched and delivered once. With a concurrency above one, fn is called
+// concurrently. Batched requests are capped by the client's multi-object
+// response limit (Limits.MultiObjectFetchMaxBytes, set with
+// options.WithLimits); a batch that exceeds it falls back to single-blob
+// requests, which are capped by the single-object limit.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - hashes: Hashes of the blobs to fetch
+//   - fn: Callback invoked once per blob
+//   - opts: Batch size and concurrency
+//
+// Returns:
+//   - error: ErrObjectNotFound if a blob does not exist, the error returned by fn, or a fetch error
+//
+// Example:
+//
+//	var mu sync.Mutex
+//	err := client.GetBlobs(ctx, hashes, func(blob *nanogit.Blob) error {
+//	    mu.Lock()
+//	    defer mu.Unlock()
+//	    return index.Add(blob.Hash, blob.Content)
+//	}, nanogit.GetBlobsOptions{BatchSize: 50, Concurrency: 4})
+func (c *httpClient) GetBlobs(ctx context.Context, hashes []hash.Hash, fn BlobFunc, opts GetBlobsOptions) error {
+	logger := log.FromContext(ctx)
+
+	seen := make(map[hash.Hash]struct{}, len(hashes))
+	unique := make([]hash.Hash, 0, len(hashes))
+	for _, h := range hashes {
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		unique = append(unique, h)
+	}
+
+	batchSize := max(opts.BatchSize, 1)
+	concurrency := max(opts.Concurrency, 1)
+
+	logger.Debug("Get blobs",
+		"blob_count", len(unique),
+		"batch_size", batchSize,
+		"concurrency", concurrency)
+
+	var batches [][]hash.Hash
+	for i := 0; i < len(unique); i += batchSize {
+		batches = append(batches, unique[i:min(i+batchSize, len(unique))])
+	}
+
+	if concurrency == 1 {
+		for i, batch := range batches {
+			logger.Debug("Processing blob batch",
+				"batch_number", i+1,
+				"batch_size", len(batch))
+
+			if err := c.processBlobBatch(ctx, batch, fn); err != nil {
+				return err
+			}
+		}
+	} else {
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(concurrency)
+		for i, batch := range batches {
+			g.Go(func() error {
+				logger.Debug("Processing blob batch concurrently",
+					"batch_number", i+1,
+					"batch_size", len(batch))
+
+				return c.processBlobBatch(gctx, batch, fn)
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+	}
+
+	logger.Debug("Blobs retrieved",
+		"blob_count", len(unique),
+		"batch_count", len(batches))
+	return nil
+}
+
+// processBlobBatch fetches a batch of blobs in a single request and hands
+// them to deliver, falling back to individual fetches for blobs the batch
+// response did not include.
+func (c *httpClient) processBlobBatch(ctx context.Context, batch []hash.Hash, deliver BlobFunc) error {
+	logger := log.FromContext(ctx)
+
+	var objects map[string]*protocol.PackfileObject
+	if len(batch) > 1 {
+		var err error
+		objects, err = c.fetchBlobBatch(ctx, batch)
+		if err != nil {
+			// An unknown hash fails the whole batch and an oversized batch
+			// trips the multi-object cap; both are resolved per blob below.
+			var tooLarge *client.ErrResponseTooLarge
+			if !errors.As(err, &tooLarge) && !strings.Contains(err.Error(), "not our ref") {
+				return fmt.Errorf("fetch blob batch: %w", err)
+			}
+			logger.Debug("Blob batch failed, fetching individually",
+				"batch_size", len(batch),
+				"error", err)
+			objects = nil
+		}
+	}
+
+	var missing []hash.Hash
+	for _, h := range batch {
+		obj, found := objects[h.String()]
+		if !found || obj.Type != protocol.ObjectTypeBlob {
+			missing = append(missing, h)
+			continue
+		}
+		if err := deliver(&Blob{Hash: h, Content: obj.Data}); err != nil {
+			return err
+		}
+	}
+
+	if len(missing) > 0 && len(batch) > 1 {
+		logger.Debug("Fetching missing blobs individually",
+			"count", len(missing))
+	}
+
+	for _, h := range missing {
+		blob, err := c.GetBlob(ctx, h)
+		if err != nil {
+			return fmt.Errorf("get blob %s: %w", h.String(), err)
+		}
+		if err := deliver(blob); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchBlobBatch fetches multiple blobs in a single request
+func (c *httpClient) fetchBlobBatch(ctx context.Context, hashes []hash.Hash) (map[string]*protocol.PackfileObject, error) {
+	objects, err := c.Fetch(ctx, client.FetchOptions{
+		NoProgress:       true,
+		Want:             hashes,
+		Done:             true,
+		NoExtraObjects:   true,
+		MaxResponseBytes: c.limits.MultiObjectFetchMaxBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch %d blobs: %w", len(hashes), err)
+	}
+
+	return objects, nil
+}
@@ -3,12 +3,18 @@ package nanogit
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/grafana/nanogit/protocol/client"
 	"github.com/grafana/nanogit/protocol/hash"
 	"github.com/stretchr/testify/require"
 )
@@ -111,3 +117,139 @@ func TestGetBlob(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBlobs(t *testing.T) {
+	t.Parallel()
+
+	newRepo := func(t *testing.T) (*testRepo, []hash.Hash) {
+		repo := newTestRepo(t)
+		var hashes []hash.Hash
+		for i := range 7 {
+			hashes = append(hashes, repo.blob(fmt.Sprintf("content %d", i)))
+		}
+		return repo, hashes
+	}
+
+	collect := func(t *testing.T, repo *testRepo, hashes []hash.Hash, opts GetBlobsOptions) map[hash.Hash]string {
+		t.Helper()
+		got := make(map[hash.Hash]string)
+		var mu sync.Mutex
+		err := repo.client().GetBlobs(context.Background(), hashes, func(blob *Blob) error {
+			mu.Lock()
+			defer mu.Unlock()
+			_, dup := got[blob.Hash]
+			require.False(t, dup, "blob %s delivered twice", blob.Hash)
+			got[blob.Hash] = string(blob.Content)
+			return nil
+		}, opts)
+		require.NoError(t, err)
+		return got
+	}
+
+	t.Run("fetches individually by default", func(t *testing.T) {
+		t.Parallel()
+		repo, hashes := newRepo(t)
+
+		got := collect(t, repo, hashes, GetBlobsOptions{})
+		require.Len(t, got, 7)
+		require.Equal(t, "content 3", got[hashes[3]])
+		require.Len(t, repo.fetches, 7)
+	})
+
+	t.Run("batches requests", func(t *testing.T) {
+		t.Parallel()
+		repo, hashes := newRepo(t)
+
+		got := collect(t, repo, hashes, GetBlobsOptions{BatchSize: 3})
+		require.Len(t, got, 7)
+		require.Len(t, repo.fetches, 3)
+		require.Len(t, repo.fetches[0], 3)
+		require.Len(t, repo.fetches[2], 1)
+	})
+
+	t.Run("deduplicates hashes", func(t *testing.T) {
+		t.Parallel()
+		repo, hashes := newRepo(t)
+
+		got := collect(t, repo, append(hashes, hashes...), GetBlobsOptions{BatchSize: 100})
+		require.Len(t, got, 7)
+		require.Len(t, repo.fetches, 1)
+		require.Len(t, repo.fetches[0], 7)
+	})
+
+	t.Run("fetches concurrently", func(t *testing.T) {
+		t.Parallel()
+		repo, hashes := newRepo(t)
+
+		got := collect(t, repo, hashes, GetBlobsOptions{BatchSize: 2, Concurrency: 3})
+		require.Len(t, got, 7)
+		require.Len(t, repo.fetches, 4)
+	})
+
+	t.Run("delivers blobs concurrently", func(t *testing.T) {
+		t.Parallel()
+		repo, hashes := newRepo(t)
+
+		// Each of the first two callbacks waits for the other to start,
+		// which only works when they run at the same time.
+		var started sync.WaitGroup
+		started.Add(2)
+		var calls atomic.Int32
+		err := repo.client().GetBlobs(context.Background(), hashes[:2], func(*Blob) error {
+			calls.Add(1)
+			started.Done()
+			done := make(chan struct{})
+			go func() {
+				started.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("callbacks did not overlap")
+			}
+		}, GetBlobsOptions{Concurrency: 2})
+		require.NoError(t, err)
+		require.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("falls back to single requests when the batch is too large", func(t *testing.T) {
+		t.Parallel()
+		repo, hashes := newRepo(t)
+		repo.fetchHook = func(opts client.FetchOptions) error {
+			if len(opts.Want) > 1 {
+				return &client.ErrResponseTooLarge{Limit: 10, Op: "fetch"}
+			}
+			return nil
+		}
+
+		got := collect(t, repo, hashes, GetBlobsOptions{BatchSize: 4})
+		require.Len(t, got, 7)
+		// Two failed batches, then one request per blob.
+		require.Len(t, repo.fetches, 2+7)
+	})
+
+	t.Run("reports missing blobs", func(t *testing.T) {
+		t.Parallel()
+		repo, hashes := newRepo(t)
+		missing := hash.MustFromHex("1234567890123456789012345678901234567890")
+
+		err := repo.client().GetBlobs(context.Background(), append(hashes, missing), func(*Blob) error { return nil }, GetBlobsOptions{BatchSize: 4})
+		require.ErrorIs(t, err, ErrObjectNotFound)
+	})
+
+	t.Run("stops on callback error", func(t *testing.T) {
+		t.Parallel()
+		repo, hashes := newRepo(t)
+		stop := errors.New("stop")
+
+		calls := 0
+		err := repo.client().GetBlobs(context.Background(), hashes, func(*Blob) error {
+			calls++
+			return stop
+		}, GetBlobsOptions{BatchSize: 2})
+		require.ErrorIs(t, err, stop)
+		require.Equal(t, 1, calls)
+	})
+}
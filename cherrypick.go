@@ -0,0 +1,391 @@
+package nanogit
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+)
+
+// CherryPickOptions configures CherryPick.
+type CherryPickOptions struct {
+	// Message is the message of the new commit. If empty, the message of
+	// the picked commit is used. The "(cherry picked from commit <hash>)"
+	// trailer is appended to it either way.
+	Message string
+
+	// Author is the author of the new commit. If its name and email are
+	// empty, the author of the picked commit is kept.
+	Author Author
+
+	// Committer is the committer of the new commit. It is required.
+	Committer Committer
+
+	// WriterOptions configure the StagedWriter that creates and pushes the
+	// commit, e.g. to sign it.
+	WriterOptions []WriterOption
+}
+
+// RevertOptions configures Revert.
+type RevertOptions struct {
+	// Message is the message of the new commit. If empty, it is
+	// `Revert "<subject of the reverted commit>"`. The "This reverts
+	// commit <hash>." line is appended to it either way.
+	Message string
+
+	// Author is the author of the new commit. If its name and email are
+	// empty, the committer is used.
+	Author Author
+
+	// Committer is the committer of the new commit. It is required.
+	Committer Committer
+
+	// WriterOptions configure the StagedWriter that creates and pushes the
+	// commit, e.g. to sign it.
+	WriterOptions []WriterOption
+}
+
+// CherryPick applies the changes a commit made to its parent on top of the
+// branch onto, like `git cherry-pick -x`, and pushes the new commit. The
+// changes are computed with CompareCommits and staged through a
+// StagedWriter: a file of onto that matches the parent's version takes the
+// commit's version, and a text file that changed on onto as well is merged
+// line by line like in Merge. Changes already present on onto are skipped.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - onto: Branch to apply the commit to, with the hash it is expected to point at
+//   - commitHash: Hash of the commit to pick, which must have a single parent
+//   - opts: Message, author, committer and writer options
+//
+// Returns:
+//   - *Commit: The new commit the branch now points at
+//   - error: MergeConflictError when onto changed the same lines or files differently,
+//     ErrNothingToCommit when onto already has every change, or a fetch or push error
+//
+// Example:
+//
+//	release, err := client.GetRef(ctx, "refs/heads/release-1.2")
+//	if err != nil {
+//	    return err
+//	}
+//	commit, err := client.CherryPick(ctx, release, fixHash, nanogit.CherryPickOptions{
+//	    Committer: nanogit.Committer{Name: "Backport Bot", Email: "bot@example.com", Time: time.Now()},
+//	})
+func (c *httpClient) CherryPick(ctx context.Context, onto Ref, commitHash hash.Hash, opts CherryPickOptions) (*Commit, error) {
+	commit, parent, err := c.getSingleParentCommit(ctx, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	author := opts.Author
+	if author.Name == "" && author.Email == "" {
+		author = commit.Author
+	}
+	message := opts.Message
+	if message == "" {
+		message = commit.Message
+	}
+	message = appendTrailer(message, fmt.Sprintf("(cherry picked from commit %s)", commitHash.String()))
+
+	return c.applyCommitChanges(ctx, onto, parent, commitHash, message, author, opts.Committer, opts.WriterOptions)
+}
+
+// Revert applies the inverse of the changes a commit made to its parent on
+// top of the branch onto, like `git revert`, and pushes the new commit.
+// Conflicts are detected and reported the same way as in CherryPick, with
+// the commit as the expected base.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - onto: Branch to revert the commit on, with the hash it is expected to point at
+//   - commitHash: Hash of the commit to revert, which must have a single parent
+//   - opts: Message, author, committer and writer options
+//
+// Returns:
+//   - *Commit: The new commit the branch now points at
+//   - error: MergeConflictError when onto changed the same lines or files since,
+//     ErrNothingToCommit when the changes are already undone, or a fetch or push error
+//
+// Example:
+//
+//	main, err := client.GetRef(ctx, "refs/heads/main")
+//	if err != nil {
+//	    return err
+//	}
+//	commit, err := client.Revert(ctx, main, badConfigHash, nanogit.RevertOptions{
+//	    Committer: nanogit.Committer{Name: "Deploy Bot", Email: "bot@example.com", Time: time.Now()},
+//	})
+func (c *httpClient) Revert(ctx context.Context, onto Ref, commitHash hash.Hash, opts RevertOptions) (*Commit, error) {
+	commit, parent, err := c.getSingleParentCommit(ctx, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	author := opts.Author
+	if author.Name == "" && author.Email == "" {
+		author = Author(opts.Committer)
+	}
+	message := opts.Message
+	if message == "" {
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		message = fmt.Sprintf("Revert \"%s\"", subject)
+	}
+	message = strings.TrimRight(message, "\n") + fmt.Sprintf("\n\nThis reverts commit %s.\n", commitHash.String())
+
+	return c.applyCommitChanges(ctx, onto, commitHash, parent, message, author, opts.Committer, opts.WriterOptions)
+}
+
+// getSingleParentCommit returns a commit and its parent. Root and merge
+// commits are rejected, since there is no single diff to apply.
+func (c *httpClient) getSingleParentCommit(ctx context.Context, commitHash hash.Hash) (*Commit, hash.Hash, error) {
+	commit, err := c.GetCommit(ctx, commitHash)
+	if err != nil {
+		return nil, hash.Zero, fmt.Errorf("get commit %s: %w", commitHash.String(), err)
+	}
+	if len(commit.Parents) != 1 {
+		return nil, hash.Zero, fmt.Errorf("commit %s has %d parents, expected 1", commitHash.String(), len(commit.Parents))
+	}
+	return commit, commit.Parents[0], nil
+}
+
+// applyCommitChanges stages the changes between the commits from and to on
+// top of onto, commits them and pushes the commit. For each changed path,
+// the version in from is the base of a three-way merge between onto and to.
+func (c *httpClient) applyCommitChanges(ctx context.Context, onto Ref, from, to hash.Hash, message string, author Author, committer Committer, writerOptions []WriterOption) (*Commit, error) {
+	logger := log.FromContext(ctx)
+	logger.Debug("Apply commit changes",
+		"ref_name", onto.Name,
+		"ref_hash", onto.Hash.String(),
+		"from_hash", from.String(),
+		"to_hash", to.String())
+
+	// Share fetched trees between CompareCommits and the writer.
+	ctx, _ = storage.FromContextOrInMemory(ctx)
+
+	changes, err := c.CompareCommits(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("compare %s with %s: %w", from.String(), to.String(), err)
+	}
+
+	writer, err := c.NewStagedWriter(ctx, onto, writerOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("create writer for %s: %w", onto.Name, err)
+	}
+	staged := writer.(*stagedWriter)
+	defer func() { _ = staged.Cleanup(ctx) }()
+
+	m := &treeMerge{
+		base:   make(map[string]*FlatTreeEntry),
+		ours:   make(map[string]*FlatTreeEntry),
+		theirs: make(map[string]*FlatTreeEntry),
+		result: make(map[string]*mergedEntry),
+	}
+	var paths []string
+	for _, change := range changes {
+		base := change.Status != protocol.FileStatusAdded && change.OldType == protocol.ObjectTypeBlob
+		changed := change.Status != protocol.FileStatusDeleted && change.Type == protocol.ObjectTypeBlob
+		if !base && !changed {
+			continue
+		}
+		if base {
+			// CompareCommits only sets OldMode for modified files.
+			mode := change.OldMode
+			if change.Status == protocol.FileStatusDeleted {
+				mode = change.Mode
+			}
+			m.base[change.Path] = &FlatTreeEntry{Path: change.Path, Hash: change.OldHash, Mode: mode, Type: protocol.ObjectTypeBlob}
+		}
+		if changed {
+			m.theirs[change.Path] = &FlatTreeEntry{Path: change.Path, Hash: change.Hash, Mode: change.Mode, Type: protocol.ObjectTypeBlob}
+		}
+		if entry, ok := staged.treeEntries[change.Path]; ok && entry.Type == protocol.ObjectTypeBlob {
+			m.ours[change.Path] = entry
+		} else if entry, ok := staged.submoduleEntries[change.Path]; ok {
+			m.ours[change.Path] = entry
+		}
+		paths = append(paths, change.Path)
+	}
+	sort.Strings(paths)
+
+	if err := c.mergePaths(ctx, m, paths); err != nil {
+		return nil, err
+	}
+	m.conflicts = append(m.conflicts, staged.fileDirectoryConflicts(paths, m.result)...)
+	if len(m.conflicts) > 0 {
+		sort.SliceStable(m.conflicts, func(i, j int) bool { return m.conflicts[i].Path < m.conflicts[j].Path })
+		logger.Debug("Commit changes conflict",
+			"ref_name", onto.Name,
+			"conflict_count", len(m.conflicts))
+		return nil, NewMergeConflictError(m.conflicts)
+	}
+
+	if err := staged.applyPaths(ctx, paths, m.result); err != nil {
+		return nil, fmt.Errorf("stage changes: %w", err)
+	}
+
+	commit, err := staged.Commit(ctx, message, author, committer)
+	if err != nil {
+		return nil, err
+	}
+	if err := staged.Push(ctx); err != nil {
+		return nil, fmt.Errorf("push commit: %w", err)
+	}
+
+	logger.Debug("Commit changes applied",
+		"ref_name", onto.Name,
+		"commit_hash", commit.Hash.String(),
+		"change_count", len(paths))
+
+	return commit, nil
+}
+
+// fileDirectoryConflicts returns conflicts for merged files that cannot be
+// staged because the writer has a directory at their path, or a file at
+// the path of one of their directories that the merge keeps.
+func (w *stagedWriter) fileDirectoryConflicts(paths []string, result map[string]*mergedEntry) []MergeConflict {
+	changed := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		changed[path] = true
+	}
+
+	var conflicts []MergeConflict
+	for _, path := range paths {
+		if _, ok := result[path]; !ok {
+			continue
+		}
+		conflict := false
+		if entry, ok := w.treeEntries[path]; ok && entry.Type == protocol.ObjectTypeTree {
+			conflict = true
+		}
+		for i := strings.LastIndex(path, "/"); i >= 0 && !conflict; i = strings.LastIndex(path[:i], "/") {
+			dir := path[:i]
+			if entry, ok := w.treeEntries[dir]; ok && entry.Type != protocol.ObjectTypeTree {
+				// Fine only if the file is deleted along the way.
+				_, kept := result[dir]
+				conflict = kept || !changed[dir]
+			}
+		}
+		if conflict {
+			conflicts = append(conflicts, MergeConflict{Path: path, Reason: MergeConflictFileDirectory})
+		}
+	}
+	return conflicts
+}
+
+// applyPaths stages the merged entries of the given paths: files missing
+// from the result are deleted, the others created or updated.
+func (w *stagedWriter) applyPaths(ctx context.Context, paths []string, result map[string]*mergedEntry) error {
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		current, exists := w.treeEntries[path]
+		merged, ok := result[path]
+		if !ok {
+			if exists {
+				if _, err := w.DeleteBlob(ctx, path); err != nil {
+					return err
+				}
+				for i := strings.LastIndex(path, "/"); i >= 0; i = strings.LastIndex(path[:i], "/") {
+					dirs[path[:i]] = true
+				}
+			}
+			continue
+		}
+
+		op := writerOp{kind: writerOpCreateBlob, path: path, hash: merged.Hash, mode: merged.Mode}
+		if merged.content != nil {
+			blobHash, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, merged.content)
+			if err != nil {
+				return fmt.Errorf("hash blob at %q: %w", path, err)
+			}
+			op.hash = blobHash
+		}
+		if exists {
+			if current.Hash == op.hash && current.Mode == op.mode {
+				continue
+			}
+			op.kind = writerOpUpdateBlob
+		}
+		if merged.content != nil {
+			if _, err := w.writer.AddBlob(merged.content); err != nil {
+				return fmt.Errorf("create blob at %q: %w", path, err)
+			}
+		}
+		if err := w.stageBlob(ctx, op); err != nil {
+			return err
+		}
+	}
+
+	// Like git, drop the directories the deletions left empty, deepest
+	// first.
+	emptyDirs := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		emptyDirs = append(emptyDirs, dir)
+	}
+	sort.Slice(emptyDirs, func(i, j int) bool { return emptyDirs[i] > emptyDirs[j] })
+	for _, dir := range emptyDirs {
+		if entry, ok := w.treeEntries[dir]; !ok || entry.Type != protocol.ObjectTypeTree || !w.isEmptyDir(dir) {
+			continue
+		}
+		if _, err := w.DeleteTree(ctx, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEmptyDir reports whether no file, directory or submodule is staged
+// inside dir.
+func (w *stagedWriter) isEmptyDir(dir string) bool {
+	prefix := dir + "/"
+	for _, entries := range []map[string]*FlatTreeEntry{w.treeEntries, w.submoduleEntries} {
+		for path := range entries {
+			if strings.HasPrefix(path, prefix) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// appendTrailer appends a trailer line to a commit message. Like git, it
+// joins the trailers already ending the message, and is separated from the
+// body by a blank line otherwise.
+func appendTrailer(message, trailer string) string {
+	message = strings.TrimRight(message, "\n")
+	separator := "\n\n"
+	if trailerBlock(strings.Split(message, "\n")) {
+		separator = "\n"
+	}
+	return message + separator + trailer + "\n"
+}
+
+// trailerBlock reports whether the last paragraph of a message, which is
+// not its subject, is made of trailer lines.
+func trailerBlock(lines []string) bool {
+	for i := len(lines) - 1; i > 0; i-- {
+		if lines[i] == "" {
+			return true
+		}
+		if !isTrailerLine(lines[i]) {
+			return false
+		}
+	}
+	return false
+}
+
+// isTrailerLine reports whether a line looks like a "Key: value" trailer or
+// a "(cherry picked from commit ...)" line.
+func isTrailerLine(line string) bool {
+	if strings.HasPrefix(line, "(cherry picked from commit ") {
+		return true
+	}
+	key, _, ok := strings.Cut(line, ": ")
+	return ok && key != "" && !strings.ContainsAny(key, " \t")
+}
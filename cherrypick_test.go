@@ -0,0 +1,241 @@
+package nanogit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CherryPick(t *testing.T) {
+	t.Parallel()
+
+	committer := Committer{Name: "Backport Bot", Email: "bot@example.com", Time: time.Unix(1700000000, 0)}
+	base := map[string]string{
+		"README.md":      "line 1\nline 2\nline 3\nline 4\nline 5\n",
+		"config.yaml":    "replicas: 1\n",
+		"docs/guide.md":  "guide\n",
+		"old/remove.txt": "remove\n",
+	}
+	// with returns a copy of files with changes applied; an empty value
+	// deletes the file.
+	with := func(files map[string]string, changes map[string]string) map[string]string {
+		out := make(map[string]string, len(files))
+		for path, content := range files {
+			out[path] = content
+		}
+		for path, content := range changes {
+			if content == "" {
+				delete(out, path)
+			} else {
+				out[path] = content
+			}
+		}
+		return out
+	}
+	// setup creates a release branch from base with the release changes,
+	// and a fix on top of base with the fix changes.
+	setup := func(t *testing.T, release, fix map[string]string) (*testRepo, Ref, hash.Hash) {
+		repo := newTestRepo(t)
+		root := repo.commit("base", base)
+		releaseHash := repo.commit("release", with(base, release), root)
+		fixHash := repo.commit("Fix the thing\n\nLonger explanation.", with(base, fix), root)
+		repo.ref("refs/heads/release", releaseHash)
+		return repo, Ref{Name: "refs/heads/release", Hash: releaseHash}, fixHash
+	}
+	files := func(t *testing.T, repo *testRepo, commit hash.Hash) map[string]string {
+		t.Helper()
+		tree, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		out := make(map[string]string)
+		for _, entry := range tree.Entries {
+			if entry.Mode == 0o40000 {
+				continue
+			}
+			blob, err := repo.client().GetBlob(context.Background(), entry.Hash)
+			require.NoError(t, err)
+			content := string(blob.Content)
+			if entry.Mode == 0o100755 {
+				content = "exec:" + content
+			}
+			out[entry.Path] = content
+		}
+		return out
+	}
+
+	t.Run("applies the commit on the branch", func(t *testing.T) {
+		t.Parallel()
+		repo, release, fix := setup(t, map[string]string{
+			"README.md": "line 1 release\nline 2\nline 3\nline 4\nline 5\n",
+		}, map[string]string{
+			"README.md":      "line 1\nline 2\nline 3\nline 4\nline 5 fixed\n",
+			"config.yaml":    "exec:replicas: 2\n",
+			"new/file.txt":   "new\n",
+			"old/remove.txt": "",
+		})
+
+		commit, err := repo.client().CherryPick(context.Background(), release, fix, CherryPickOptions{Committer: committer})
+		require.NoError(t, err)
+		require.Equal(t, commit.Hash, repo.refHash("refs/heads/release"))
+		require.Equal(t, release.Hash, commit.Parent)
+		require.Equal(t, "Fix the thing\n\nLonger explanation.\n\n(cherry picked from commit "+fix.String()+")\n", commit.Message)
+		require.Equal(t, "Test", commit.Author.Name)
+		require.Equal(t, committer.Name, commit.Committer.Name)
+		require.Equal(t, with(base, map[string]string{
+			"README.md":      "line 1 release\nline 2\nline 3\nline 4\nline 5 fixed\n",
+			"config.yaml":    "exec:replicas: 2\n",
+			"new/file.txt":   "new\n",
+			"old/remove.txt": "",
+		}), files(t, repo, commit.Hash))
+
+		// The deleted file's directory is gone too.
+		tree, err := repo.client().GetFlatTree(context.Background(), commit.Hash)
+		require.NoError(t, err)
+		for _, entry := range tree.Entries {
+			require.NotEqual(t, "old", entry.Path)
+		}
+
+		// Picking it again finds nothing to do.
+		release.Hash = commit.Hash
+		_, err = repo.client().CherryPick(context.Background(), release, fix, CherryPickOptions{Committer: committer})
+		require.ErrorIs(t, err, ErrNothingToCommit)
+	})
+
+	t.Run("reports conflicts", func(t *testing.T) {
+		t.Parallel()
+		repo, release, fix := setup(t, map[string]string{
+			"README.md":      "line 1\nline 2\nline 3\nline 4\nline 5 release\n",
+			"config.yaml":    "",
+			"docs/guide.md":  "guide\nrelease\n",
+			"old/remove.txt": "remove\nrelease\n",
+		}, map[string]string{
+			"README.md":      "line 1\nline 2\nline 3\nline 4\nline 5 fixed\n",
+			"config.yaml":    "replicas: 2\n",
+			"docs/guide.md":  "guide\n\nmore\n",
+			"old/remove.txt": "",
+		})
+
+		_, err := repo.client().CherryPick(context.Background(), release, fix, CherryPickOptions{Committer: committer})
+		var conflictErr *MergeConflictError
+		require.True(t, errors.As(err, &conflictErr))
+		reasons := make(map[string]MergeConflictReason)
+		for _, conflict := range conflictErr.Conflicts {
+			reasons[conflict.Path] = conflict.Reason
+		}
+		require.Equal(t, map[string]MergeConflictReason{
+			"README.md":      MergeConflictContent,
+			"config.yaml":    MergeConflictModifyDelete,
+			"docs/guide.md":  MergeConflictContent,
+			"old/remove.txt": MergeConflictModifyDelete,
+		}, reasons)
+		require.Equal(t, []MergeConflictHunk{{
+			BaseLine: 5, OursLine: 5, TheirsLine: 5,
+			Base: []string{"line 5"}, Ours: []string{"line 5 release"}, Theirs: []string{"line 5 fixed"},
+		}}, conflictErr.Conflicts[0].Hunks)
+		require.Zero(t, repo.pushes)
+	})
+
+	t.Run("file where the commit adds a directory", func(t *testing.T) {
+		t.Parallel()
+		repo, release, fix := setup(t, map[string]string{"new": "file\n"}, map[string]string{"new/file.txt": "new\n"})
+
+		_, err := repo.client().CherryPick(context.Background(), release, fix, CherryPickOptions{Committer: committer})
+		var conflictErr *MergeConflictError
+		require.True(t, errors.As(err, &conflictErr))
+		require.Equal(t, []MergeConflict{{Path: "new/file.txt", Reason: MergeConflictFileDirectory}}, conflictErr.Conflicts)
+	})
+
+	t.Run("rejects merge commits", func(t *testing.T) {
+		t.Parallel()
+		repo, release, fix := setup(t, nil, map[string]string{"a.txt": "a\n"})
+		merge := repo.commit("merge", base, release.Hash, fix)
+
+		_, err := repo.client().CherryPick(context.Background(), release, merge, CherryPickOptions{Committer: committer})
+		require.ErrorContains(t, err, "has 2 parents")
+	})
+}
+
+func TestClient_Revert(t *testing.T) {
+	t.Parallel()
+
+	committer := Committer{Name: "Deploy Bot", Email: "bot@example.com", Time: time.Unix(1700000000, 0)}
+	base := map[string]string{
+		"config.yaml": "replicas: 1\ntimeout: 10\n",
+		"app.yaml":    "name: app\n",
+	}
+
+	t.Run("undoes the changes of the commit", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		root := repo.commit("base", base)
+		bad := repo.commit("Bump replicas", map[string]string{
+			"config.yaml":  "replicas: 100\ntimeout: 10\n",
+			"app.yaml":     "name: app\n",
+			"extra/x.yaml": "x\n",
+		}, root)
+		later := repo.commit("Raise timeout", map[string]string{
+			"config.yaml":  "replicas: 100\ntimeout: 10\n\n\ntimeout_retry: 30\n",
+			"app.yaml":     "name: app\n",
+			"extra/x.yaml": "x\n",
+		}, bad)
+		repo.ref("refs/heads/main", later)
+		main := Ref{Name: "refs/heads/main", Hash: later}
+
+		commit, err := repo.client().Revert(context.Background(), main, bad, RevertOptions{Committer: committer})
+		require.NoError(t, err)
+		require.Equal(t, "Revert \"Bump replicas\"\n\nThis reverts commit "+bad.String()+".\n", commit.Message)
+		require.Equal(t, committer.Name, commit.Author.Name)
+		require.Equal(t, commit.Hash, repo.refHash("refs/heads/main"))
+
+		tree, err := repo.client().GetFlatTree(context.Background(), commit.Hash)
+		require.NoError(t, err)
+		got := make(map[string]string)
+		for _, entry := range tree.Entries {
+			if entry.Mode == 0o40000 {
+				got[entry.Path] = "dir"
+				continue
+			}
+			blob, err := repo.client().GetBlob(context.Background(), entry.Hash)
+			require.NoError(t, err)
+			got[entry.Path] = string(blob.Content)
+		}
+		require.Equal(t, map[string]string{
+			"config.yaml": "replicas: 1\ntimeout: 10\n\n\ntimeout_retry: 30\n",
+			"app.yaml":    "name: app\n",
+		}, got)
+	})
+
+	t.Run("reports conflicts with later changes", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		root := repo.commit("base", base)
+		bad := repo.commit("Bump replicas", map[string]string{"config.yaml": "replicas: 100\ntimeout: 10\n", "app.yaml": "name: app\n"}, root)
+		later := repo.commit("Tune replicas", map[string]string{"config.yaml": "replicas: 50\ntimeout: 10\n", "app.yaml": "name: app\n"}, bad)
+		repo.ref("refs/heads/main", later)
+
+		_, err := repo.client().Revert(context.Background(), Ref{Name: "refs/heads/main", Hash: later}, bad, RevertOptions{Committer: committer, Message: "Roll back"})
+		require.ErrorIs(t, err, ErrMergeConflict)
+		require.Zero(t, repo.pushes)
+	})
+}
+
+func TestAppendTrailer(t *testing.T) {
+	t.Parallel()
+
+	const trailer = "(cherry picked from commit abc)"
+	for _, tt := range []struct {
+		message string
+		want    string
+	}{
+		{message: "Subject", want: "Subject\n\n" + trailer + "\n"},
+		{message: "Subject\n\nBody.\n", want: "Subject\n\nBody.\n\n" + trailer + "\n"},
+		{message: "Fix: subject only", want: "Fix: subject only\n\n" + trailer + "\n"},
+		{message: "Subject\n\nSigned-off-by: A <a@example.com>\n", want: "Subject\n\nSigned-off-by: A <a@example.com>\n" + trailer + "\n"},
+		{message: "Subject\n\nNote: this is not\na trailer block", want: "Subject\n\nNote: this is not\na trailer block\n\n" + trailer + "\n"},
+	} {
+		require.Equal(t, tt.want, appendTrailer(tt.message, trailer), tt.message)
+	}
+}
@@ -19,8 +19,10 @@ var catFileCmd = &cobra.Command{
 	Short: "Display the contents of a file",
 	Long: `Display the contents of a file from a Git repository at a specific reference.
 
-The ref can be a branch name, tag name, or commit hash.
-The path is the file path within the repository.
+The ref can be any revision understood by git rev-parse that names a commit
+or tree: a branch or tag name, a full or abbreviated commit hash, or an
+expression such as main~2, v1.0^{tree} or main:docs.
+The path is the file path within the repository (or within the tree named by ref).
 The repository argument is optional when NANOGIT_REPO is set.
 
 Examples:
@@ -37,6 +39,9 @@ Examples:
   # Display file from a commit hash
   nanogit cat-file https://github.com/grafana/nanogit.git abc123 src/main.go
 
+  # Display file as it was two commits ago
+  nanogit cat-file https://github.com/grafana/nanogit.git main~2 README.md
+
   # Output with metadata in JSON format
   nanogit cat-file https://github.com/grafana/nanogit.git main README.md --json
 
@@ -57,20 +62,14 @@ func runCatFile(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Resolve ref to get the commit hash
-	commitHash, err := resolveRef(ctx, client, ref)
+	// Resolve ref to the tree to read the file from
+	treeHash, err := resolveTree(ctx, client, ref)
 	if err != nil {
 		return fmt.Errorf("failed to resolve ref %q: %w", ref, err)
 	}
 
-	// Get the commit to find the tree hash
-	commit, err := client.GetCommit(ctx, commitHash)
-	if err != nil {
-		return fmt.Errorf("failed to get commit: %w", err)
-	}
-
 	// Get the file blob by path
-	blob, err := client.GetBlobByPath(ctx, commit.Tree, filePath)
+	blob, err := client.GetBlobByPath(ctx, treeHash, filePath)
 	if err != nil {
 		return fmt.Errorf("failed to get file %q: %w", filePath, err)
 	}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/grafana/nanogit"
+	"github.com/grafana/nanogit/protocol/hash"
 	"github.com/spf13/cobra"
 )
 
@@ -52,6 +54,14 @@ var (
 	cloneExclude     []string
 	cloneBatchSize   int
 	cloneConcurrency int
+	cloneManifest    string
+	cloneGit         bool
+	cloneBranch      string
+	cloneSafeLinks   bool
+	cloneSparse      string
+	cloneCone        bool
+	cloneResumable   bool
+	cloneProgress    bool
 )
 
 func init() {
@@ -62,6 +72,14 @@ func init() {
 	cloneCmd.Flags().StringSliceVar(&cloneExclude, "exclude", nil, "Exclude paths (glob patterns, e.g., 'node_modules/**', '*.tmp')")
 	cloneCmd.Flags().IntVar(&cloneBatchSize, "batch-size", 50, "Number of blobs to fetch per request (default 50)")
 	cloneCmd.Flags().IntVar(&cloneConcurrency, "concurrency", 10, "Number of parallel blob fetches (default 10)")
+	cloneCmd.Flags().StringVar(&cloneManifest, "manifest", "", "Manifest file recording the cloned commit; when it exists, the destination is updated incrementally")
+	cloneCmd.Flags().BoolVar(&cloneGit, "git", false, "Write a .git directory so the destination is a shallow git repository")
+	cloneCmd.Flags().StringVar(&cloneBranch, "branch", "", "Branch to create and check out with --git (default: detached HEAD)")
+	cloneCmd.Flags().StringVar(&cloneSparse, "sparse", "", "File of sparse-checkout patterns (one per line, as in .git/info/sparse-checkout)")
+	cloneCmd.Flags().BoolVar(&cloneCone, "cone", false, "Treat the --sparse file as cone-mode directories")
+	cloneCmd.Flags().BoolVar(&cloneResumable, "resumable", false, "Keep a journal of written files so that an interrupted clone can be resumed by running it again")
+	cloneCmd.Flags().BoolVar(&cloneProgress, "progress", false, "Report the files and bytes written on stderr")
+	cloneCmd.Flags().BoolVar(&cloneSafeLinks, "safe-symlinks", false, "Fail if a symbolic link of the repository points outside the destination")
 }
 
 var cloneCmd = &cobra.Command{
@@ -98,8 +116,20 @@ Examples:
   # Clone only specific directories
   nanogit clone https://github.com/grafana/nanogit.git ./my-repo --include 'src/**' --include 'docs/**'
 
+  # Clone only the directories listed in a sparse-checkout file
+  nanogit clone https://github.com/grafana/nanogit.git ./my-repo --sparse ./dirs.txt --cone
+
+  # Resume a large clone where it stopped if it gets interrupted
+  nanogit clone https://github.com/grafana/nanogit.git ./my-repo --resumable --progress
+
   # Clone with batching and concurrency for better performance
-  nanogit clone https://github.com/grafana/nanogit.git ./my-repo --batch-size 100 --concurrency 20`,
+  nanogit clone https://github.com/grafana/nanogit.git ./my-repo --batch-size 100 --concurrency 20
+
+  # Keep a directory in sync, fetching only what changed since the last run
+  nanogit clone https://github.com/grafana/nanogit.git ./my-repo --manifest ./my-repo.json
+
+  # Clone into a git repository that git status, log and commit work on
+  nanogit clone https://github.com/grafana/nanogit.git ./my-repo --ref main --git --branch main`,
 	Args: cloneArgs,
 	RunE: runClone,
 }
@@ -128,14 +158,40 @@ func runClone(cmd *cobra.Command, args []string) error {
 		fmt.Fprintf(os.Stderr, "Cloning %s at %s to %s...\n", repoURL, ref, destPath)
 	}
 
+	var sparsePatterns []string
+	if cloneSparse != "" {
+		data, err := os.ReadFile(cloneSparse)
+		if err != nil {
+			return fmt.Errorf("failed to read sparse patterns: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			sparsePatterns = append(sparsePatterns, strings.TrimSuffix(line, "\r"))
+		}
+	}
+
 	// Prepare clone options
 	cloneOpts := nanogit.CloneOptions{
-		Path:         destPath,
-		Hash:         commitHash,
-		IncludePaths: cloneInclude,
-		ExcludePaths: cloneExclude,
-		BatchSize:    cloneBatchSize,
-		Concurrency:  cloneConcurrency,
+		Path:           destPath,
+		Hash:           commitHash,
+		IncludePaths:   cloneInclude,
+		ExcludePaths:   cloneExclude,
+		BatchSize:      cloneBatchSize,
+		Concurrency:    cloneConcurrency,
+		ManifestPath:   cloneManifest,
+		InitRepository: cloneGit,
+		Branch:         cloneBranch,
+		SparsePatterns: sparsePatterns,
+		ConeMode:       cloneCone,
+		SafeSymlinks:   cloneSafeLinks,
+		Resumable:      cloneResumable,
+	}
+	if cloneProgress {
+		cloneOpts.Progress = func(p nanogit.CloneProgress) {
+			fmt.Fprintf(os.Stderr, "\rWriting files: %d/%d (%d bytes)", p.FilesDone, p.FilesTotal, p.BytesDone)
+			if p.FilesDone == p.FilesTotal {
+				fmt.Fprintln(os.Stderr)
+			}
+		}
 	}
 
 	// Clone the repository
@@ -170,11 +226,19 @@ type cloneResultJSON struct {
 			Email string `json:"email"`
 		} `json:"author"`
 	} `json:"commit"`
-	Path  string `json:"path"`
-	Files struct {
-		Total    int `json:"total"`
-		Filtered int `json:"filtered"`
+	Path   string `json:"path"`
+	GitDir string `json:"git_dir,omitempty"`
+	Files  struct {
+		Total       int `json:"total"`
+		Filtered    int `json:"filtered"`
+		Symlinks    int `json:"symlinks"`
+		Executables int `json:"executables"`
+		Submodules  int `json:"submodules"`
 	} `json:"files"`
+	Update *struct {
+		Previous string `json:"previous"`
+		Changed  int    `json:"changed"`
+	} `json:"update,omitempty"`
 	Performance struct {
 		BatchSize   int `json:"batch_size"`
 		Concurrency int `json:"concurrency"`
@@ -192,8 +256,21 @@ func outputCloneJSON(result *nanogit.CloneResult, batchSize, concurrency int, in
 	output.Commit.Author.Name = result.Commit.Author.Name
 	output.Commit.Author.Email = result.Commit.Author.Email
 	output.Path = result.Path
+	output.GitDir = result.GitDir
 	output.Files.Total = result.TotalFiles
 	output.Files.Filtered = result.FilteredFiles
+	output.Files.Symlinks = len(result.SpecialFiles.Symlinks)
+	output.Files.Executables = len(result.SpecialFiles.Executables)
+	output.Files.Submodules = len(result.SpecialFiles.Submodules)
+	if result.Previous != hash.Zero {
+		output.Update = &struct {
+			Previous string `json:"previous"`
+			Changed  int    `json:"changed"`
+		}{
+			Previous: result.Previous.String(),
+			Changed:  len(result.Changes),
+		}
+	}
 	output.Performance.BatchSize = batchSize
 	output.Performance.Concurrency = concurrency
 
@@ -218,6 +295,16 @@ func outputCloneHuman(result *nanogit.CloneResult, batchSize, concurrency int, i
 	fmt.Printf("  Message:     %s\n", firstLine(result.Commit.Message))
 	fmt.Printf("  Author:      %s <%s>\n", result.Commit.Author.Name, result.Commit.Author.Email)
 	fmt.Printf("  Files:       %d of %d cloned to %s\n", result.FilteredFiles, result.TotalFiles, result.Path)
+	if special := result.SpecialFiles; len(special.Symlinks)+len(special.Executables)+len(special.Submodules) > 0 {
+		fmt.Printf("  Special:     %d symlinks, %d executables, %d submodules (not cloned)\n",
+			len(special.Symlinks), len(special.Executables), len(special.Submodules))
+	}
+	if result.GitDir != "" {
+		fmt.Printf("  Git dir:     %s\n", result.GitDir)
+	}
+	if result.Previous != hash.Zero {
+		fmt.Printf("  Updated:     %d changed files since %s\n", len(result.Changes), result.Previous.String())
+	}
 	fmt.Printf("  Batch size:  %d\n", batchSize)
 	fmt.Printf("  Concurrency: %d\n", concurrency)
 
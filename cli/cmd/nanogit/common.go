@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/grafana/nanogit"
+	"github.com/grafana/nanogit/protocol"
 	"github.com/grafana/nanogit/protocol/hash"
 	"github.com/spf13/cobra"
 )
@@ -52,42 +53,40 @@ func looksLikeRepoURL(s string) bool {
 	return strings.Contains(s, "://")
 }
 
-// resolveRef resolves a ref name to a commit hash.
-// It tries multiple strategies:
-// 1. Try as full ref name (refs/heads/main, refs/tags/v1.0.0)
-// 2. Try as branch name (main -> refs/heads/main)
-// 3. Try as tag name (v1.0.0 -> refs/tags/v1.0.0)
-// 4. Try as commit hash directly
+// resolveRef resolves a revision to a commit hash. It accepts everything
+// nanogit.Client.ResolveRevision does (branch and tag names, abbreviated
+// hashes, main~2, v1.0^{commit}, ...) and peels annotated tags to the commit
+// they point to. A rev:path revision is not peeled, since a suffix after it
+// would be read as part of the path, and fails unless it names a commit.
 func resolveRef(ctx context.Context, client nanogit.Client, ref string) (hash.Hash, error) {
-	// Try as-is first (might already be a full ref or commit hash)
-	if strings.HasPrefix(ref, "refs/") {
-		refObj, err := client.GetRef(ctx, ref)
-		if err == nil {
-			return refObj.Hash, nil
-		}
+	expr := ref
+	if !strings.Contains(ref, ":") {
+		expr += "^{commit}"
 	}
-
-	// Try as branch name
-	refObj, err := client.GetRef(ctx, "refs/heads/"+ref)
-	if err == nil {
-		return refObj.Hash, nil
+	h, typ, err := client.ResolveRevision(ctx, expr)
+	if err != nil {
+		return hash.Hash{}, err
 	}
-
-	// Try as tag name
-	refObj, err = client.GetRef(ctx, "refs/tags/"+ref)
-	if err == nil {
-		return refObj.Hash, nil
+	if typ != protocol.ObjectTypeCommit {
+		return hash.Hash{}, fmt.Errorf("%s names a %s, not a commit", ref, typ.Bytes())
 	}
+	return h, nil
+}
 
-	// Try as commit hash directly
-	h, err := hash.FromHex(ref)
-	if err == nil {
-		// Verify the commit exists
-		_, err = client.GetCommit(ctx, h)
-		if err == nil {
-			return h, nil
-		}
+// resolveTree resolves a revision to a tree hash. Besides commit-ish
+// revisions it accepts tree expressions such as main^{tree} or main:docs.
+func resolveTree(ctx context.Context, client nanogit.Client, ref string) (hash.Hash, error) {
+	h, typ, err := client.ResolveRevision(ctx, ref)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if typ == protocol.ObjectTypeTree {
+		return h, nil
 	}
 
-	return hash.Hash{}, fmt.Errorf("reference not found: %s", ref)
+	h, _, err = client.ResolveRevision(ctx, h.String()+"^{tree}")
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return h, nil
 }
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"testing"
 
+	"github.com/grafana/nanogit/mocks"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -51,3 +55,23 @@ func TestLooksLikeRepoURL(t *testing.T) {
 	assert.False(t, looksLikeRepoURL("my-repo"))
 	assert.False(t, looksLikeRepoURL("/tmp/repo"))
 }
+
+func TestResolveRef(t *testing.T) {
+	commit := hash.MustFromHex("1234567890123456789012345678901234567890")
+
+	client := &mocks.FakeClient{}
+	client.ResolveRevisionReturns(commit, protocol.ObjectTypeCommit, nil)
+	h, err := resolveRef(context.Background(), client, "v1.0")
+	require.NoError(t, err)
+	assert.Equal(t, commit, h)
+	_, expr := client.ResolveRevisionArgsForCall(0)
+	assert.Equal(t, "v1.0^{commit}", expr)
+
+	// A rev:path revision is resolved without the peel suffix.
+	client = &mocks.FakeClient{}
+	client.ResolveRevisionReturns(commit, protocol.ObjectTypeTree, nil)
+	_, err = resolveRef(context.Background(), client, "main:docs")
+	require.ErrorContains(t, err, "main:docs names a tree, not a commit")
+	_, expr = client.ResolveRevisionArgsForCall(0)
+	assert.Equal(t, "main:docs", expr)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/grafana/nanogit"
@@ -32,7 +33,9 @@ var lsTreeCmd = &cobra.Command{
 	Short: "List the contents of a tree object",
 	Long: `List the contents of a tree object from a Git repository at a specific reference.
 
-The ref can be a branch name, tag name, or commit hash.
+The ref can be any revision understood by git rev-parse that names a commit
+or tree: a branch or tag name, a full or abbreviated commit hash, or an
+expression such as main~2, v1.0^{tree} or main:docs.
 By default, shows mode, type, hash, and name for each entry (like git ls-tree).
 The repository argument is optional when NANOGIT_REPO is set.
 
@@ -68,23 +71,24 @@ func runLsTree(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Resolve ref to get the commit hash
-	commitHash, err := resolveRef(ctx, client, ref)
-	if err != nil {
-		return fmt.Errorf("failed to resolve ref %q: %w", ref, err)
+	// Get tree contents based on flags
+	if lsTreeRecursive {
+		// The flat tree is built from a commit; the path of a rev:path
+		// revision selects the entries under it.
+		rev, dir, _ := strings.Cut(ref, ":")
+		commitHash, err := resolveRef(ctx, client, rev)
+		if err != nil {
+			return fmt.Errorf("failed to resolve ref %q: %w", ref, err)
+		}
+		return listRecursiveTree(ctx, client, commitHash, path.Join(dir, lsTreePath))
 	}
 
-	// Get the commit to find the tree hash
-	commit, err := client.GetCommit(ctx, commitHash)
+	// Resolve ref to the tree to list
+	treeHash, err := resolveTree(ctx, client, ref)
 	if err != nil {
-		return fmt.Errorf("failed to get commit: %w", err)
-	}
-
-	// Get tree contents based on flags
-	if lsTreeRecursive {
-		return listRecursiveTree(ctx, client, commitHash, lsTreePath)
+		return fmt.Errorf("failed to resolve ref %q: %w", ref, err)
 	}
-	return listTree(ctx, client, commit.Tree, lsTreePath)
+	return listTree(ctx, client, treeHash, lsTreePath)
 }
 
 func listTree(ctx context.Context, client nanogit.Client, treeHash hash.Hash, path string) error {
@@ -225,12 +229,6 @@ func outputFlatTreeHuman(entries []nanogit.FlatTreeEntry) error {
 	return nil
 }
 
-// resolveRef resolves a ref name to a commit hash.
-// It tries multiple strategies:
-// 1. Try as full ref name (refs/heads/main, refs/tags/v1.0.0)
-// 2. Try as branch name (main -> refs/heads/main)
-// 3. Try as tag name (v1.0.0 -> refs/tags/v1.0.0)
-// 4. Try as commit hash directly
 func objectTypeToString(objType protocol.ObjectType) string {
 	switch objType {
 	case protocol.ObjectTypeBlob:
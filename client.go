@@ -3,6 +3,8 @@ package nanogit
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"sync"
 
 	"github.com/grafana/nanogit/log"
@@ -23,12 +25,24 @@ type StagedWriter interface {
 	BlobExists(ctx context.Context, path string) (bool, error)
 
 	// CreateBlob stages a new file to be written at the given path.
+	// WithExecutable and WithSymlink set the mode of the file.
 	// Returns the hash of the created blob.
-	CreateBlob(ctx context.Context, path string, content []byte) (hash.Hash, error)
+	CreateBlob(ctx context.Context, path string, content []byte, options ...BlobOption) (hash.Hash, error)
+
+	// CreateBlobFromReader stages a new file of size bytes read from r at the given path,
+	// streaming it into the packfile storage instead of holding it in memory.
+	// Returns the hash of the created blob.
+	CreateBlobFromReader(ctx context.Context, path string, r io.Reader, size int64, options ...BlobOption) (hash.Hash, error)
 
 	// UpdateBlob stages an update to an existing file at the given path.
+	// The file keeps its mode unless an option sets another one.
 	// Returns the hash of the updated blob.
-	UpdateBlob(ctx context.Context, path string, content []byte) (hash.Hash, error)
+	UpdateBlob(ctx context.Context, path string, content []byte, options ...BlobOption) (hash.Hash, error)
+
+	// SetMode stages a mode change of the file at the given path, to a
+	// regular file (0o100644), an executable file (0o100755) or a symbolic
+	// link (0o120000).
+	SetMode(ctx context.Context, path string, mode uint32) error
 
 	// DeleteBlob stages the deletion of a file at the given path.
 	// Returns the hash of the tree after deletion.
@@ -51,13 +65,56 @@ type StagedWriter interface {
 	// Returns the hash of the moved tree.
 	MoveTree(ctx context.Context, srcPath, destPath string) (hash.Hash, error)
 
+	// AddSubmodule stages a submodule at the given path, pinned to commit,
+	// and its section with url in .gitmodules.
+	AddSubmodule(ctx context.Context, path, url string, commit hash.Hash) error
+
+	// UpdateSubmodule stages a new commit pin for the submodule at the given path.
+	UpdateSubmodule(ctx context.Context, path string, commit hash.Hash) error
+
+	// Status returns the paths added, modified, deleted and renamed
+	// relative to the commit the reference points at, up to Push.
+	Status() (*WriterStatus, error)
+
+	// Diff returns the files changed relative to the commit the reference
+	// points at, up to Push. WithPatches sets their patches.
+	Diff(ctx context.Context, opts ...CompareCommitsOption) ([]CommitFile, error)
+
+	// Checkpoint marks the staged changes and commits, for Rollback to
+	// restore. A successful Push, Amend, Squash and ResetTo discard
+	// checkpoints.
+	Checkpoint() (*WriterCheckpoint, error)
+
+	// Rollback restores the writer to a checkpoint, dropping the changes,
+	// commits and objects staged after it.
+	Rollback(checkpoint *WriterCheckpoint) error
+
+	// ApplyPatch stages the changes of a unified diff, a git diff or a
+	// series of git format-patch emails, committing each email with
+	// opts.Commit. Nothing is staged when a patch does not apply.
+	ApplyPatch(ctx context.Context, patch io.Reader, opts ApplyPatchOptions) ([]AppliedPatch, error)
+
 	// Commit creates a new commit with all staged changes.
 	// Returns the hash of the created commit.
 	Commit(ctx context.Context, message string, author Author, committer Committer) (*Commit, error)
 
+	// Amend replaces the last unpushed commit with a commit of its changes
+	// and the changes staged since. An empty message keeps its message.
+	Amend(ctx context.Context, message string, author Author, committer Committer) (*Commit, error)
+
+	// Squash replaces the last n unpushed commits with a single commit.
+	// An empty message joins their messages.
+	Squash(ctx context.Context, n int, message string) (*Commit, error)
+
+	// ResetTo drops the unpushed commits after commit, the staged changes
+	// and the objects they added.
+	ResetTo(ctx context.Context, commit hash.Hash) error
+
 	// Push sends all committed changes to the remote repository.
 	// This is the final step that makes changes visible to others.
 	// It will update the reference to point to the last commit.
+	// With WithAutoRebase, a push rejected because the reference moved is
+	// rebased onto the new tip and retried.
 	Push(ctx context.Context) error
 
 	// Cleanup releases any resources held by the writer and clears all staged changes.
@@ -132,6 +189,10 @@ type Client interface {
 	// Tip: pass Commit.Tree as the root.
 	GetBlobByPath(ctx context.Context, rootHash hash.Hash, path string) (*Blob, error)
 
+	// GetBlobs fetches many blobs with batched, concurrent requests (falling
+	// back to single-blob requests when needed) and passes each one to fn.
+	GetBlobs(ctx context.Context, hashes []hash.Hash, fn BlobFunc, opts GetBlobsOptions) error
+
 	// GetFlatTree retrieves a recursive listing of every file and directory
 	// reachable from the given commit or tree hash, with each entry carrying
 	// its full path from the repository root.
@@ -146,6 +207,22 @@ type Client interface {
 	// returns the root tree itself.
 	GetTreeByPath(ctx context.Context, rootHash hash.Hash, path string) (*Tree, error)
 
+	// WalkTree walks the tree of a commit or tree hash depth-first, calling
+	// fn for every entry and fetching directories only when the walk enters
+	// them. fn can return SkipDir or SkipAll; WalkTreeOptions sets a start
+	// path and a maximum depth.
+	WalkTree(ctx context.Context, rootHash hash.Hash, fn WalkFunc, opts WalkTreeOptions) error
+
+	// ResolveRevision resolves a git revision expression such as "main",
+	// "v1.0^{tree}", "abc1234~2" or "HEAD:docs/README.md" to an object hash
+	// and its type, like `git rev-parse`.
+	ResolveRevision(ctx context.Context, expr string) (hash.Hash, protocol.ObjectType, error)
+
+	// FS returns a read-only io/fs view of the tree of a commit or tree hash.
+	// Directories and files are fetched lazily; the result implements
+	// fs.ReadDirFS, fs.ReadFileFS, fs.StatFS and fs.ReadLinkFS.
+	FS(ctx context.Context, rootHash hash.Hash) fs.FS
+
 	// GetCommit retrieves a single commit object, including its author,
 	// committer, message, parent hashes, and root tree hash.
 	GetCommit(ctx context.Context, hash hash.Hash) (*Commit, error)
@@ -157,15 +234,64 @@ type Client interface {
 
 	// ListCommits walks the history backwards from startCommit and returns
 	// the matching commits. ListCommitsOptions provides pagination (Page,
-	// PerPage) and filtering (Path, Since, Until).
+	// PerPage) and filtering (Path, Since, Until); Follow tracks a file across renames.
 	ListCommits(ctx context.Context, startCommit hash.Hash, options ListCommitsOptions) ([]Commit, error)
 
+	// MergeBase returns the best common ancestor of two commits, like
+	// `git merge-base`. It returns ErrNoMergeBase for unrelated histories.
+	MergeBase(ctx context.Context, a, b hash.Hash) (hash.Hash, error)
+
+	// Merge merges a commit into a branch and pushes a merge commit, using
+	// a three-way merge of trees and, for files both sides changed, of
+	// lines. Conflicts are reported with a MergeConflictError unless
+	// MergeOptions.Strategy resolves them.
+	Merge(ctx context.Context, ref Ref, other hash.Hash, opts MergeOptions) (*MergeResult, error)
+
+	// CherryPick applies the changes a commit made to its parent on top of a
+	// branch and pushes a commit carrying the "(cherry picked from commit
+	// <hash>)" trailer. Files the branch changed too are merged like in
+	// Merge, and conflicts are reported with a MergeConflictError.
+	CherryPick(ctx context.Context, onto Ref, commit hash.Hash, opts CherryPickOptions) (*Commit, error)
+
+	// Revert applies the inverse of the changes a commit made to its parent
+	// on top of a branch and pushes a commit whose message says "This
+	// reverts commit <hash>.". Conflicts are reported like in CherryPick.
+	Revert(ctx context.Context, onto Ref, commit hash.Hash, opts RevertOptions) (*Commit, error)
+
+	// IsAncestor reports whether ancestor is reachable from descendant. A
+	// commit is considered its own ancestor.
+	IsAncestor(ctx context.Context, ancestor, descendant hash.Hash) (bool, error)
+
+	// AheadBehind counts the commits reachable only from a (ahead) and only
+	// from b (behind), like `git rev-list --left-right --count a...b`.
+	AheadBehind(ctx context.Context, a, b hash.Hash) (ahead, behind int, err error)
+
 	// Clone writes a snapshot of the repository at CloneOptions.Hash to a
 	// local directory, optionally filtered to specific paths with glob
-	// patterns. It fetches only the objects the filtered snapshot needs; it
-	// does not create a .git directory or a working clone.
+	// patterns. It fetches only the objects the filtered snapshot needs and,
+	// unless CloneOptions.InitRepository is set, does not create a .git
+	// directory. With CloneOptions.Previous or ManifestPath it updates an
+	// existing clone incrementally. CloneOptions.FS writes the clone to
+	// another filesystem, such as memory.
 	Clone(ctx context.Context, opts CloneOptions) (*CloneResult, error)
 
+	// Archive writes the tree of a commit to w as a tar, tar.gz or zip
+	// archive, like `git archive`, keeping file modes and symlinks and
+	// embedding the commit hash. Paths can be filtered with the same glob
+	// patterns as Clone.
+	Archive(ctx context.Context, commitHash hash.Hash, w io.Writer, opts ArchiveOptions) error
+
+	// Grep searches the files of a commit for lines matching a regular
+	// expression, like `git grep`. Files are fetched in batches as the tree
+	// is walked, and the walk stops once GrepOptions.MaxMatches is reached.
+	Grep(ctx context.Context, commitHash hash.Hash, opts GrepOptions) ([]GrepMatch, error)
+
+	// Blame returns, for each line of a file at a commit, the commit that
+	// last changed it and the line's number in that commit, like
+	// `git blame`. BlameOptions limits the line range and can ignore
+	// whitespace changes.
+	Blame(ctx context.Context, commitHash hash.Hash, path string, opts BlameOptions) ([]BlameLine, error)
+
 	// NewStagedWriter creates a StagedWriter that stages changes on top of
 	// the commit currently referenced by ref, to be committed and pushed as
 	// one atomic update. WriterOption values choose where staged objects are
@@ -177,6 +303,9 @@ type Client interface {
 // It implements the Git Smart Protocol version 2 over HTTP/HTTPS transport.
 type httpClient struct {
 	client.RawClient
+	// repoURL is the repository URL the client was created with. Clone
+	// records it as the remote of an initialized repository.
+	repoURL string
 	// receivePackCapabilities is advertised on receive-pack ref update commands.
 	// When nil or empty, protocol.DefaultReceivePackCapabilities() is used.
 	receivePackCapabilities []protocol.Capability
@@ -245,6 +374,7 @@ func NewHTTPClient(repo string, opts ...options.Option) (Client, error) {
 
 	return &httpClient{
 		RawClient:               rawClient,
+		repoURL:                 repo,
 		receivePackCapabilities: resolved.ReceivePackCapabilities,
 		limits:                  resolved.Limits,
 		negotiateCaps:           resolved.NegotiateCapabilities,
@@ -2,17 +2,19 @@ package nanogit
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io/fs"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/grafana/nanogit/log"
 	"github.com/grafana/nanogit/protocol"
-	"github.com/grafana/nanogit/protocol/client"
 	"github.com/grafana/nanogit/protocol/hash"
-	"golang.org/x/sync/errgroup"
+	"github.com/grafana/nanogit/storage"
 )
 
 // CloneOptions provides configuration options for repository cloning operations.
@@ -21,9 +23,18 @@ import (
 // with no caching where only certain directories are needed.
 type CloneOptions struct {
 	// Path specifies the local filesystem path where files should be written.
-	// This field is required for clone operations.
+	// This field is required for clone operations, unless FS is set.
 	Path string
 
+	// FS is the filesystem the clone is written to, with Path (and
+	// ManifestPath) resolved within it; an empty Path is the root of FS.
+	// If nil, the local filesystem is used with paths resolved like the os
+	// package does. Use NewOSFS to confine a clone to a directory, or
+	// NewInMemoryFS to clone into memory. Resumable, ManifestPath, Previous
+	// and InitRepository read back what is in FS, so it must then also
+	// implement UpdatableFS.
+	FS WritableFS
+
 	// Hash specifies the commit hash to clone from.
 	// Use client.GetRef() to resolve branch/tag names to hashes first.
 	Hash hash.Hash
@@ -32,12 +43,16 @@ type CloneOptions struct {
 	// Only files and directories matching these patterns will be included.
 	// Supports glob patterns (e.g., "src/**", "*.go", "docs/api/*").
 	// If empty, all paths are included (unless excluded by ExcludePaths).
+	// Directories no pattern can match below are skipped before their
+	// trees are fetched.
 	IncludePaths []string
 
 	// ExcludePaths specifies which paths to exclude from the clone.
 	// Files and directories matching these patterns will be excluded.
 	// Supports glob patterns (e.g., "node_modules/**", "*.tmp", "test/**").
-	// ExcludePaths takes precedence over IncludePaths.
+	// ExcludePaths takes precedence over IncludePaths. Directories excluded
+	// with a pattern ending in "/**" are skipped before their trees are
+	// fetched.
 	ExcludePaths []string
 
 	// BatchSize specifies how many blobs to fetch in a single request.
@@ -56,6 +71,91 @@ type CloneOptions struct {
 	// individual fetching (fetches multiple blobs concurrently).
 	// Recommended value: 4-10 depending on network conditions and server capacity.
 	Concurrency int
+
+	// Previous is the commit that was previously cloned into Path with the same
+	// include/exclude filters. When set, Clone updates Path incrementally: it
+	// compares Previous with Hash, fetches only added and modified files,
+	// deletes removed files and moves renamed ones, instead of writing a full
+	// snapshot. If Path does not exist, a full clone is done.
+	Previous hash.Hash
+
+	// ManifestPath is the path of a manifest file recording the cloned commit
+	// and filters. When set, Clone writes it after a successful clone, and a
+	// later Clone with the same ManifestPath and no Previous reads it to update
+	// Path incrementally. If the filters changed since the manifest was written,
+	// files that are no longer included are deleted. The manifest should be
+	// kept outside of Path, or excluded, so it is not mistaken for a file of
+	// the repository.
+	ManifestPath string
+
+	// InitRepository turns Path into a git repository the git binary can
+	// work with (status, log, commit, ...). Clone writes a .git directory
+	// holding a packfile with the commit, its trees and the checked-out
+	// files, HEAD, an index matching the work tree, and a shallow file since
+	// the commit's history is not fetched. Files excluded by the filters are
+	// marked skip-worktree in the index and the repository is configured as
+	// a partial clone, so git does not report them as deleted.
+	InitRepository bool
+
+	// Branch is the branch InitRepository creates at Hash and checks out.
+	// If empty, HEAD is detached at Hash. It is ignored without
+	// InitRepository.
+	Branch string
+
+	// SparsePatterns selects the files to clone with the lines of a git
+	// sparse-checkout file, in gitignore syntax: a file is included when the
+	// last pattern matching it, or else matching its closest directory, is
+	// not negated with "!". A leading or inner "/" anchors a pattern to the
+	// root, a trailing "/" only matches directories, and "**" matches any
+	// number of directories. Blank lines and "#" comments are ignored.
+	// SparsePatterns applies on top of IncludePaths and ExcludePaths, and
+	// directories that cannot hold an included file are skipped before
+	// their trees are fetched.
+	SparsePatterns []string
+
+	// ConeMode reads SparsePatterns like a sparse-checkout in cone mode:
+	// each line names a directory cloned with everything below it, along
+	// with the files, but not the subdirectories, of its parents and of the
+	// root. The lines git writes for cone mode ("/*", "!/*/", "/src/",
+	// "!/src/*/") are understood too. With no SparsePatterns, only the
+	// files at the root are cloned.
+	ConeMode bool
+
+	// SafeSymlinks makes Clone fail with an UnsafeSymlinkError when a
+	// symbolic link of the repository could lead outside Path. A link is
+	// accepted when its target is relative, climbs only with leading ".."
+	// elements and does not climb above Path. Without it, links are written
+	// with whatever target the repository holds.
+	SafeSymlinks bool
+
+	// Resumable keeps a journal of the files written, named
+	// CloneJournalName, at the root of Path. When a clone fails halfway, a
+	// new Clone of the same commit with the same filters skips the files
+	// the journal lists once it has checked that their content still
+	// matches their hash, and only fetches the rest. The journal is removed
+	// when Clone succeeds.
+	Resumable bool
+
+	// Progress, if set, is called as files are written, with the number of
+	// files and bytes done so far and in total. It is never called
+	// concurrently.
+	Progress func(CloneProgress)
+}
+
+// CloneSpecialFiles lists the included entries of a clone that are not
+// regular files.
+type CloneSpecialFiles struct {
+	// Symlinks are the paths of symbolic links (mode 120000), which are
+	// written as symbolic links.
+	Symlinks []string
+
+	// Executables are the paths of executable files (mode 100755), which
+	// are written with the executable bit.
+	Executables []string
+
+	// Submodules are the paths of submodules (mode 160000). Their content
+	// is not cloned.
+	Submodules []string
 }
 
 // CloneResult contains the results of a clone operation.
@@ -70,11 +170,32 @@ type CloneResult struct {
 	// filtered according to the CloneOptions.
 	FlatTree *FlatTree
 
-	// TotalFiles is the total number of files in the cloned tree.
+	// TotalFiles is the total number of files in the cloned tree. The
+	// content of the directories skipped before their trees were fetched,
+	// those IncludePaths, ExcludePaths, SparsePatterns or ConeMode rule out,
+	// is not counted, so a filtered clone reports fewer files than the
+	// whole tree has. Without a filter, or with InitRepository, the whole
+	// tree is counted.
 	TotalFiles int
 
 	// FilteredFiles is the number of files after applying include/exclude filters.
 	FilteredFiles int
+
+	// Previous is the commit the clone was updated from, or hash.Zero when a
+	// full snapshot was written.
+	Previous hash.Hash
+
+	// Changes lists the file changes applied to Path by an incremental clone,
+	// restricted to the included paths. It is nil for a full snapshot.
+	Changes []CommitFile
+
+	// GitDir is the path of the .git directory written with
+	// CloneOptions.InitRepository, or empty.
+	GitDir string
+
+	// SpecialFiles lists the symbolic links, executable files and
+	// submodules of FlatTree.
+	SpecialFiles CloneSpecialFiles
 }
 
 // Clone clones a repository for the given reference with optional path filtering.
@@ -88,6 +209,27 @@ type CloneResult struct {
 //  3. Applies include/exclude filters to the tree structure
 //  4. Returns the filtered tree with only the requested paths
 //
+// When CloneOptions.Previous (or a manifest at CloneOptions.ManifestPath)
+// names the commit already present in Path, Clone updates Path
+// incrementally instead: only added and modified files are fetched, removed
+// files are deleted and renamed files are moved, within the include/exclude
+// filters. CloneResult.Changes lists what was applied.
+//
+// CloneOptions.SparsePatterns selects files with the lines of a git
+// sparse-checkout file, optionally in cone mode (CloneOptions.ConeMode).
+// The trees of the directories they leave out are not fetched at all.
+//
+// Files are written with the mode recorded in the commit: symbolic links
+// become symbolic links and executable files get the executable bit. Set
+// CloneOptions.SafeSymlinks to reject links leading outside Path.
+//
+// With CloneOptions.Resumable, a clone that fails halfway can be run
+// again to fetch only the files it had not written yet, and
+// CloneOptions.Progress reports the files and bytes written.
+//
+// With CloneOptions.InitRepository, Clone also writes a .git directory so
+// that Path is a shallow git repository at the cloned commit.
+//
 // Parameters:
 //   - ctx: Context for the operation
 //   - opts: Clone options including ref, depth, and path filters
@@ -121,20 +263,46 @@ func (c *httpClient) Clone(ctx context.Context, opts CloneOptions) (*CloneResult
 	if opts.Hash == hash.Zero {
 		return nil, fmt.Errorf("commit hash is required - use client.GetRef() to resolve branch/tag names to hashes")
 	}
+	if _, ok := opts.FS.(UpdatableFS); opts.FS != nil && !ok && opts.readsBack() {
+		return nil, fmt.Errorf("%T does not implement UpdatableFS, which Resumable, ManifestPath, Previous and InitRepository need", opts.FS)
+	}
 
 	logger.Debug("Starting clone operation",
 		"commit_hash", opts.Hash.String(),
 		"include_paths", opts.IncludePaths,
 		"exclude_paths", opts.ExcludePaths)
 
+	filter, err := c.newClonePathFilter(opts.IncludePaths, opts.ExcludePaths, opts.SparsePatterns, opts.ConeMode)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the commit object
 	commit, err := c.GetCommit(ctx, opts.Hash)
 	if err != nil {
 		return nil, fmt.Errorf("get commit %s: %w", opts.Hash.String(), err)
 	}
 
-	// Get the full tree structure
-	fullTree, err := c.GetFlatTree(ctx, commit.Hash)
+	// An initialized repository needs the raw commit and tree objects, which
+	// the tree fetch returns, so keep them around. Blobs are fetched with ctx
+	// and are not retained.
+	treeCtx := ctx
+	var objects storage.PackfileStorage
+	if opts.InitRepository {
+		treeCtx, objects = storage.FromContextOrInMemory(ctx)
+	}
+
+	// Get the full tree structure. The filters can skip whole directories,
+	// unless the index of an initialized repository needs every file.
+	var (
+		fullTree   *FlatTree
+		submodules []FlatTreeEntry
+	)
+	if filter.active() && !opts.InitRepository {
+		fullTree, submodules, err = c.getPrunedFlatTree(ctx, commit.Tree, filter.descend)
+	} else {
+		fullTree, submodules, err = c.getFlatTreeWithSubmodules(treeCtx, commit.Hash)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("get tree for commit %s: %w", commit.Hash.String(), err)
 	}
@@ -144,21 +312,17 @@ func (c *httpClient) Clone(ctx context.Context, opts CloneOptions) (*CloneResult
 		"total_entries", len(fullTree.Entries))
 
 	// Validate that path is provided
-	if opts.Path == "" {
-		return nil, fmt.Errorf("clone path is required")
+	if opts.FS == nil {
+		if opts.Path == "" {
+			return nil, fmt.Errorf("clone path is required")
+		}
+		opts.FS = &OSFS{}
+	} else if opts.Path == "" {
+		opts.Path = "."
 	}
 
 	// Apply path filters to the tree
-	filteredTree, err := c.filterTree(fullTree, opts.IncludePaths, opts.ExcludePaths)
-	if err != nil {
-		return nil, fmt.Errorf("filter tree: %w", err)
-	}
-
-	// Write files to filesystem
-	err = c.writeFilesToDisk(ctx, opts.Path, filteredTree, opts.BatchSize, opts.Concurrency)
-	if err != nil {
-		return nil, fmt.Errorf("write files to disk: %w", err)
-	}
+	filteredTree := c.filterTree(fullTree, filter)
 
 	result := &CloneResult{
 		Path:          opts.Path,
@@ -166,37 +330,119 @@ func (c *httpClient) Clone(ctx context.Context, opts CloneOptions) (*CloneResult
 		FlatTree:      filteredTree,
 		TotalFiles:    len(fullTree.Entries),
 		FilteredFiles: len(filteredTree.Entries),
+		SpecialFiles:  specialFiles(filteredTree, submodules, filter),
+	}
+
+	previous, err := c.previousClone(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case previous == nil:
+		// Write files to filesystem
+		err = c.writeFilesToDisk(ctx, opts, filteredTree)
+		if err != nil {
+			return nil, fmt.Errorf("write files to disk: %w", err)
+		}
+	case previous.filtersChanged(opts):
+		// The set of included files changed, so the diff between the commits
+		// is not enough: rewrite the snapshot and drop what is no longer included.
+		err = c.writeFilesToDisk(ctx, opts, filteredTree)
+		if err != nil {
+			return nil, fmt.Errorf("write files to disk: %w", err)
+		}
+		if err := c.removeStaleFiles(ctx, opts.FS.(UpdatableFS), opts.Path, previous, filteredTree); err != nil {
+			return nil, err
+		}
+		result.Previous = previous.commit
+	default:
+		result.Changes, err = c.updateFilesOnDisk(ctx, opts, filter, previous.commit, commit.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("update files on disk: %w", err)
+		}
+		result.Previous = previous.commit
+	}
+
+	if opts.InitRepository {
+		result.GitDir, err = c.initRepository(ctx, opts, commit, objects, fullTree, filteredTree, submodules)
+		if err != nil {
+			return nil, fmt.Errorf("initialize repository: %w", err)
+		}
+	}
+
+	if opts.ManifestPath != "" {
+		if err := writeCloneManifest(opts.FS.(UpdatableFS), opts.ManifestPath, commit.Hash, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Resumable {
+		if err := removeCloneJournal(opts.FS.(UpdatableFS), opts.Path); err != nil {
+			return nil, err
+		}
 	}
 
 	logger.Debug("Clone completed",
 		"commit_hash", commit.Hash.String(),
 		"total_files", result.TotalFiles,
 		"filtered_files", result.FilteredFiles,
+		"previous_hash", result.Previous.String(),
+		"change_count", len(result.Changes),
+		"git_dir", result.GitDir,
+		"symlink_count", len(result.SpecialFiles.Symlinks),
+		"executable_count", len(result.SpecialFiles.Executables),
 		"output_path", opts.Path)
 
 	return result, nil
 }
 
-// filterTree applies include and exclude path patterns to filter a FlatTree.
+// readsBack reports whether the options need to read back what is in FS,
+// which only an UpdatableFS can do.
+func (opts CloneOptions) readsBack() bool {
+	return opts.Resumable || opts.ManifestPath != "" || opts.Previous != hash.Zero || opts.InitRepository
+}
+
+// specialFiles collects the symbolic links and executable files of the
+// filtered tree and the included submodules.
+func specialFiles(tree *FlatTree, submodules []FlatTreeEntry, filter *clonePathFilter) CloneSpecialFiles {
+	var special CloneSpecialFiles
+	for _, entry := range tree.Entries {
+		switch entry.Mode {
+		case 0o120000:
+			special.Symlinks = append(special.Symlinks, entry.Path)
+		case 0o100755:
+			special.Executables = append(special.Executables, entry.Path)
+		}
+	}
+	for _, entry := range submodules {
+		if filter.includes(entry.Path) {
+			special.Submodules = append(special.Submodules, entry.Path)
+		}
+	}
+	return special
+}
+
+// filterTree applies the path filter of a clone to a FlatTree.
 // It returns a new FlatTree containing only entries that match the criteria.
-func (c *httpClient) filterTree(tree *FlatTree, includePaths, excludePaths []string) (*FlatTree, error) {
-	if len(includePaths) == 0 && len(excludePaths) == 0 {
+func (c *httpClient) filterTree(tree *FlatTree, filter *clonePathFilter) *FlatTree {
+	if !filter.active() {
 		// No filtering needed
-		return tree, nil
+		return tree
 	}
 
 	filtered := &FlatTree{
 		Entries: make([]FlatTreeEntry, 0, len(tree.Entries)),
+		Hash:    tree.Hash,
 	}
 
 	for _, entry := range tree.Entries {
-		included := c.shouldIncludePath(entry.Path, includePaths, excludePaths)
-		if included {
+		if filter.includesEntry(entry) {
 			filtered.Entries = append(filtered.Entries, entry)
 		}
 	}
 
-	return filtered, nil
+	return filtered
 }
 
 // shouldIncludePath determines if a path should be included based on include/exclude patterns.
@@ -216,6 +462,47 @@ func (c *httpClient) shouldIncludePath(path string, includePaths, excludePaths [
 	return matchesAnyPattern(path, includePaths)
 }
 
+// mayIncludeBelow reports whether a path below dir can be included by the
+// include and exclude patterns, so that a directory can be skipped without
+// fetching its tree. It errs on the side of descending.
+func mayIncludeBelow(dir string, includePaths, excludePaths []string) bool {
+	// A pattern ending with /** that matches a directory matches everything
+	// below it too.
+	for _, pattern := range excludePaths {
+		if strings.HasSuffix(pattern, "/**") && matchesSinglePattern(dir, pattern) {
+			return false
+		}
+	}
+	if len(includePaths) == 0 {
+		return true
+	}
+	dirSegments := strings.Split(dir, "/")
+	for _, pattern := range includePaths {
+		if patternMatchesBelow(strings.Split(pattern, "/"), dirSegments) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternMatchesBelow reports whether the pattern split into segments may
+// match a path strictly below the directory made of dirSegments. A "**"
+// segment can match any number of directories.
+func patternMatchesBelow(segments, dirSegments []string) bool {
+	for i, dirSegment := range dirSegments {
+		if i == len(segments) {
+			return false
+		}
+		if segments[i] == "**" {
+			return true
+		}
+		if matched, err := filepath.Match(segments[i], dirSegment); err != nil || !matched {
+			return false
+		}
+	}
+	return len(segments) > len(dirSegments)
+}
+
 // matchesAnyPattern checks if a path matches any of the given patterns.
 func matchesAnyPattern(path string, patterns []string) bool {
 	for _, pattern := range patterns {
@@ -238,274 +525,175 @@ func matchesSinglePattern(path, pattern string) bool {
 	return err == nil && matched
 }
 
-// writeFilesToDisk writes all files from the filtered tree to the specified directory path.
-// It creates the necessary directory structure and downloads blob content for each file.
-// If batchSize > 1, it fetches multiple blobs in batches to improve performance.
-// If concurrency > 1, it fetches blobs concurrently to improve performance.
-func (c *httpClient) writeFilesToDisk(ctx context.Context, basePath string, tree *FlatTree, batchSize, concurrency int) error {
+// writeFilesToDisk writes all files from the filtered tree to opts.Path in opts.FS.
+// It creates the necessary directory structure and downloads blob content for each file
+// with GetBlobs, so opts.BatchSize and opts.Concurrency have the same meaning as in
+// GetBlobsOptions. With opts.Resumable, the files are recorded in the journal, and
+// those an interrupted clone recorded are verified instead of fetched.
+func (c *httpClient) writeFilesToDisk(ctx context.Context, opts CloneOptions, tree *FlatTree) error {
 	logger := log.FromContext(ctx)
 	logger.Debug("Writing files to disk",
-		"base_path", basePath,
+		"base_path", opts.Path,
 		"file_count", len(tree.Entries),
-		"batch_size", batchSize,
-		"concurrency", concurrency)
+		"batch_size", opts.BatchSize,
+		"concurrency", opts.Concurrency)
 
 	// Create the base directory if it doesn't exist
-	if err := os.MkdirAll(basePath, 0755); err != nil {
-		return fmt.Errorf("create base directory %s: %w", basePath, err)
+	if err := opts.FS.MkdirAll(opts.Path, 0755); err != nil {
+		return fmt.Errorf("create base directory %s: %w", opts.Path, err)
 	}
 
-	// Collect all blob entries
-	var blobEntries []FlatTreeEntry
-	for _, entry := range tree.Entries {
-		if entry.Type == protocol.ObjectTypeBlob {
-			blobEntries = append(blobEntries, entry)
+	var journal *cloneJournal
+	if opts.Resumable {
+		var err error
+		journal, err = openCloneJournal(ctx, opts)
+		if err != nil {
+			return err
 		}
+		defer func() { _ = journal.Close() }()
 	}
 
-	// Normalize concurrency (0 or 1 means sequential)
-	if concurrency <= 1 {
-		concurrency = 1
+	// Collect all blob entries, grouped by hash so identical files are fetched
+	// once. Files a resumed clone already wrote are skipped.
+	progress := &cloneProgress{fn: opts.Progress}
+	entriesByHash := make(map[hash.Hash][]FlatTreeEntry)
+	var hashes []hash.Hash
+	for _, entry := range tree.Entries {
+		if entry.Type != protocol.ObjectTypeBlob {
+			continue
+		}
+		progress.progress.FilesTotal++
+		if journal != nil {
+			if size, ok := journal.resumed(opts, entry); ok {
+				if err := journal.record(entry); err != nil {
+					return err
+				}
+				progress.progress.FilesDone++
+				progress.progress.BytesDone += size
+				continue
+			}
+		}
+		if _, ok := entriesByHash[entry.Hash]; !ok {
+			hashes = append(hashes, entry.Hash)
+		}
+		entriesByHash[entry.Hash] = append(entriesByHash[entry.Hash], entry)
 	}
 
-	// Fetch and write blobs based on batch size
-	if batchSize <= 1 {
-		// Fetch individually (with optional concurrency)
-		return c.writeFilesIndividually(ctx, basePath, blobEntries, concurrency, logger)
+	if progress.progress.FilesDone > 0 {
+		logger.Debug("Resume clone",
+			"resumed_count", progress.progress.FilesDone,
+			"fetch_count", len(hashes))
 	}
+	if opts.Progress != nil {
+		total, ok, err := c.cloneBytesTotal(ctx, entriesByHash, hashes)
+		if err != nil {
+			return err
+		}
+		if ok {
+			progress.progress.BytesTotal = progress.progress.BytesDone + total
+		}
+	}
+	progress.report()
 
-	// Batch fetching enabled (with optional concurrency)
-	return c.writeFilesInBatches(ctx, basePath, blobEntries, batchSize, concurrency, logger)
-}
-
-// writeFilesIndividually fetches and writes each blob individually
-// with optional concurrency for improved performance
-func (c *httpClient) writeFilesIndividually(ctx context.Context, basePath string, entries []FlatTreeEntry, concurrency int, logger log.Logger) error {
-	if concurrency <= 1 {
-		// Sequential fetching (backward compatible)
-		for _, entry := range entries {
-			if err := c.writeFile(ctx, basePath, entry, logger); err != nil {
+	// Files are written concurrently; the journal and the progress are
+	// updated one file at a time.
+	var mu sync.Mutex
+	written := func(entry FlatTreeEntry, size int64) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if journal != nil {
+			if err := journal.record(entry); err != nil {
 				return err
 			}
 		}
+		progress.done(size)
 		return nil
 	}
-
-	// Concurrent fetching using errgroup
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(concurrency)
-
-	// Use mutex to safely log from multiple goroutines
-	var logMutex sync.Mutex
-
-	for _, entry := range entries {
-		entry := entry // capture loop variable
-		g.Go(func() error {
-			// Create parent directories first (with synchronization)
-			filePath := filepath.Join(basePath, entry.Path)
-			parentDir := filepath.Dir(filePath)
-			if err := os.MkdirAll(parentDir, 0755); err != nil {
-				return fmt.Errorf("create parent directory for %s: %w", entry.Path, err)
-			}
-
-			// Fetch blob
-			blob, err := c.GetBlob(ctx, entry.Hash)
-			if err != nil {
-				return fmt.Errorf("get blob content for %s: %w", entry.Path, err)
+	return c.GetBlobs(ctx, hashes, func(blob *Blob) error {
+		for _, entry := range entriesByHash[blob.Hash] {
+			if err := c.writeBlobToFile(ctx, opts, entry, blob.Content, logger); err != nil {
+				return err
 			}
-
-			// Write file
-			if err := os.WriteFile(filePath, blob.Content, 0644); err != nil {
-				return fmt.Errorf("write file %s: %w", entry.Path, err)
+			if err := written(entry, int64(len(blob.Content))); err != nil {
+				return err
 			}
-
-			// Safe logging
-			logMutex.Lock()
-			logger.Debug("File written",
-				"path", entry.Path,
-				"size", len(blob.Content))
-			logMutex.Unlock()
-
-			return nil
-		})
-	}
-
-	return g.Wait()
+		}
+		return nil
+	}, GetBlobsOptions{
+		BatchSize:   opts.BatchSize,
+		Concurrency: opts.Concurrency,
+	})
 }
 
-// writeFile fetches a single blob and writes it to disk
-func (c *httpClient) writeFile(ctx context.Context, basePath string, entry FlatTreeEntry, logger log.Logger) error {
-	filePath := filepath.Join(basePath, entry.Path)
+// writeBlobToFile writes blob data to a file of the clone, as a symbolic
+// link for mode 120000 and with the executable bit for mode 100755.
+func (c *httpClient) writeBlobToFile(ctx context.Context, opts CloneOptions, entry FlatTreeEntry, data []byte, logger log.Logger) error {
+	fsys, basePath := opts.FS, opts.Path
+	filePath := path.Join(basePath, entry.Path)
+	// Only a filesystem that can be read back may hold an existing clone.
+	updatable, _ := fsys.(UpdatableFS)
 
-	// Create parent directories if needed
-	parentDir := filepath.Dir(filePath)
-	if err := os.MkdirAll(parentDir, 0755); err != nil {
-		return fmt.Errorf("create parent directory for %s: %w", entry.Path, err)
-	}
-
-	// Get the blob content
-	blob, err := c.GetBlob(ctx, entry.Hash)
-	if err != nil {
-		return fmt.Errorf("get blob content for %s: %w", entry.Path, err)
-	}
-
-	// Write the file content
-	if err := os.WriteFile(filePath, blob.Content, 0644); err != nil {
-		return fmt.Errorf("write file %s: %w", entry.Path, err)
+	symlink := entry.Mode == 0o120000
+	if symlink && opts.SafeSymlinks && !isSafeSymlink(entry.Path, string(data)) {
+		return NewUnsafeSymlinkError(entry.Path, string(data))
 	}
 
-	logger.Debug("File written",
-		"path", entry.Path,
-		"size", len(blob.Content))
-
-	return nil
-}
-
-// writeFilesInBatches fetches and writes blobs in batches with fallback to individual fetching
-// with optional concurrency for improved performance
-func (c *httpClient) writeFilesInBatches(ctx context.Context, basePath string, entries []FlatTreeEntry, batchSize, concurrency int, logger log.Logger) error {
-	totalFiles := len(entries)
-	logger.Debug("Starting batch blob fetching",
-		"total_files", totalFiles,
-		"batch_size", batchSize,
-		"concurrency", concurrency)
-
-	// Split entries into batches
-	var batches [][]FlatTreeEntry
-	for i := 0; i < len(entries); i += batchSize {
-		end := i + batchSize
-		if end > len(entries) {
-			end = len(entries)
+	// Create parent directories if needed
+	parentDir := path.Dir(filePath)
+	if err := fsys.MkdirAll(parentDir, 0755); err != nil {
+		// When updating an existing clone, a file may stand where a directory
+		// is now needed.
+		if updatable == nil || !errors.Is(err, syscall.ENOTDIR) {
+			return fmt.Errorf("create parent directory for %s: %w", entry.Path, err)
 		}
-		batches = append(batches, entries[i:end])
-	}
-
-	if concurrency <= 1 {
-		// Sequential batch processing (backward compatible)
-		return c.processBlobBatchesSequentially(ctx, basePath, batches, logger)
-	}
-
-	// Concurrent batch processing
-	return c.processBlobBatchesConcurrently(ctx, basePath, batches, concurrency, logger)
-}
-
-// processBlobBatchesSequentially processes blob batches one at a time (backward compatible)
-func (c *httpClient) processBlobBatchesSequentially(ctx context.Context, basePath string, batches [][]FlatTreeEntry, logger log.Logger) error {
-	for i, batch := range batches {
-		logger.Debug("Processing batch",
-			"batch_number", i+1,
-			"batch_size", len(batch))
-
-		if err := c.processSingleBlobBatch(ctx, basePath, batch, logger); err != nil {
+		if err := removeFileParents(updatable, basePath, entry.Path); err != nil {
 			return err
 		}
-	}
-	return nil
-}
-
-// processBlobBatchesConcurrently processes multiple blob batches concurrently
-func (c *httpClient) processBlobBatchesConcurrently(ctx context.Context, basePath string, batches [][]FlatTreeEntry, concurrency int, logger log.Logger) error {
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(concurrency)
-
-	// Use mutex for safe logging
-	var logMutex sync.Mutex
-
-	for batchIdx, batch := range batches {
-		batchIdx := batchIdx
-		batch := batch
-		g.Go(func() error {
-			logMutex.Lock()
-			logger.Debug("Processing batch concurrently",
-				"batch_number", batchIdx+1,
-				"batch_size", len(batch))
-			logMutex.Unlock()
-
-			return c.processSingleBlobBatch(ctx, basePath, batch, logger)
-		})
-	}
-
-	return g.Wait()
-}
-
-// processSingleBlobBatch fetches and writes a single batch of blobs
-func (c *httpClient) processSingleBlobBatch(ctx context.Context, basePath string, batch []FlatTreeEntry, logger log.Logger) error {
-	// Attempt to fetch batch
-	blobs, err := c.fetchBlobBatch(ctx, batch)
-	if err != nil {
-		return fmt.Errorf("fetch blob batch: %w", err)
-	}
-
-	// Write fetched blobs and track missing ones
-	var missingEntries []FlatTreeEntry
-	for _, entry := range batch {
-		blob, found := blobs[entry.Hash.String()]
-		if !found {
-			// Blob not in batch response, will fetch individually later
-			missingEntries = append(missingEntries, entry)
-			logger.Debug("Blob missing from batch, will retry individually",
-				"path", entry.Path,
-				"hash", entry.Hash.String())
-			continue
+		if err := fsys.MkdirAll(parentDir, 0755); err != nil {
+			return fmt.Errorf("create parent directory for %s: %w", entry.Path, err)
 		}
+	}
 
-		// Write the fetched blob to disk
-		if err := c.writeBlobToFile(ctx, basePath, entry, blob.Data, logger); err != nil {
-			return err
+	// An existing clone may hold something else at this path. Directories
+	// and symbolic links are removed rather than written through, and a
+	// rewritten file keeps its permissions unless they are fixed below.
+	var existing fs.FileInfo
+	if updatable != nil {
+		var err error
+		existing, err = updatable.Lstat(filePath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("stat %s: %w", entry.Path, err)
 		}
 	}
-
-	// Fallback: fetch missing blobs individually
-	if len(missingEntries) > 0 {
-		logger.Debug("Fetching missing blobs individually",
-			"count", len(missingEntries))
-		for _, entry := range missingEntries {
-			if err := c.writeFile(ctx, basePath, entry, logger); err != nil {
-				return err
-			}
+	if existing != nil && (symlink || existing.IsDir() || existing.Mode()&fs.ModeSymlink != 0) {
+		if err := updatable.RemoveAll(filePath); err != nil {
+			return fmt.Errorf("remove %s: %w", entry.Path, err)
 		}
+		existing = nil
 	}
 
-	return nil
-}
-
-// fetchBlobBatch fetches multiple blobs in a single request
-func (c *httpClient) fetchBlobBatch(ctx context.Context, entries []FlatTreeEntry) (map[string]*protocol.PackfileObject, error) {
-	// Collect hashes to fetch
-	hashes := make([]hash.Hash, len(entries))
-	for i, entry := range entries {
-		hashes[i] = entry.Hash
-	}
-
-	// Fetch all hashes in a single request using the protocol Fetch
-	objects, err := c.Fetch(ctx, client.FetchOptions{
-		NoProgress:       true,
-		Want:             hashes,
-		Done:             true,
-		NoExtraObjects:   true,
-		MaxResponseBytes: c.limits.MultiObjectFetchMaxBytes,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("fetch %d blobs: %w", len(hashes), err)
+	if symlink {
+		if err := fsys.Symlink(string(data), filePath); err != nil {
+			return fmt.Errorf("create symbolic link %s: %w", entry.Path, err)
+		}
+		logger.Debug("Symbolic link written",
+			"path", entry.Path,
+			"target", string(data))
+		return nil
 	}
 
-	return objects, nil
-}
-
-// writeBlobToFile writes blob data to a file on disk
-func (c *httpClient) writeBlobToFile(ctx context.Context, basePath string, entry FlatTreeEntry, data []byte, logger log.Logger) error {
-	filePath := filepath.Join(basePath, entry.Path)
-
-	// Create parent directories if needed
-	parentDir := filepath.Dir(filePath)
-	if err := os.MkdirAll(parentDir, 0755); err != nil {
-		return fmt.Errorf("create parent directory for %s: %w", entry.Path, err)
+	perm := fs.FileMode(0644)
+	if entry.Mode == 0o100755 {
+		perm = 0755
 	}
-
-	// Write the file content
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := writeFile(fsys, filePath, data, perm); err != nil {
 		return fmt.Errorf("write file %s: %w", entry.Path, err)
 	}
+	if existing != nil && (existing.Mode()&0o111 != 0) != (perm&0o111 != 0) {
+		if err := updatable.Chmod(filePath, perm); err != nil {
+			return fmt.Errorf("change mode of %s: %w", entry.Path, err)
+		}
+	}
 
 	logger.Debug("File written",
 		"path", entry.Path,
@@ -514,6 +702,32 @@ func (c *httpClient) writeBlobToFile(ctx context.Context, basePath string, entry
 	return nil
 }
 
+// isSafeSymlink reports whether a symbolic link at linkPath, relative to the
+// clone, with the given target stays within the clone. The target must be
+// relative and may only climb with leading ".." elements, no higher than
+// the clone: a ".." after another element could climb out of a directory
+// reached through another link, which is not resolved here.
+func isSafeSymlink(linkPath, target string) bool {
+	if target == "" || path.IsAbs(target) {
+		return false
+	}
+	depth := strings.Count(linkPath, "/")
+	descended := false
+	for _, elem := range strings.Split(target, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			if descended || depth == 0 {
+				return false
+			}
+			depth--
+		default:
+			descended = true
+		}
+	}
+	return true
+}
+
 // matchesPatternWithDoubleStar checks if a pattern with **/ matches a path.
 // Handles patterns like:
 // - "**/*.go" - matches *.go at any depth
@@ -0,0 +1,260 @@
+package nanogit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// CloneJournalName is the name of the journal CloneOptions.Resumable keeps
+// at the root of the clone while files are written. It is removed once
+// Clone succeeds.
+const CloneJournalName = ".nanogit-clone-journal"
+
+// CloneProgress reports how many of the files a clone writes are done.
+// Files skipped because a resumed clone had already written them count as
+// done.
+type CloneProgress struct {
+	// FilesDone is the number of files written or verified so far.
+	FilesDone int
+
+	// FilesTotal is the number of files the clone writes. For an
+	// incremental clone, it only counts added and modified files.
+	FilesTotal int
+
+	// BytesDone is the size of the files written or verified so far.
+	BytesDone int64
+
+	// BytesTotal is the size of all the files. The sizes are asked for
+	// before the fetch with the protocol v2 object-info command; BytesTotal
+	// is zero when the server does not support it.
+	BytesTotal int64
+}
+
+// cloneJournalEntry is a line of the journal recording a file that was
+// written and verified.
+type cloneJournalEntry struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+// cloneJournal records the files of a clone as they are written, so that a
+// clone interrupted halfway can resume without fetching them again. The
+// first line holds the commit and filters, in the format of the manifest,
+// and each following line a file. Lines are appended as files are done; a
+// line cut short by a crash is ignored when the journal is read back.
+type cloneJournal struct {
+	w io.WriteCloser
+	// done holds the files the interrupted clone recorded, by path.
+	done map[string]hash.Hash
+}
+
+// openCloneJournal reads the journal an interrupted clone left in
+// opts.Path, keeping its files only if it was cloning the same commit with
+// the same filters, and starts a new journal in its place.
+func openCloneJournal(ctx context.Context, opts CloneOptions) (*cloneJournal, error) {
+	logger := log.FromContext(ctx)
+	name := path.Join(opts.Path, CloneJournalName)
+	header := cloneManifest{
+		Commit:         opts.Hash.String(),
+		IncludePaths:   opts.IncludePaths,
+		ExcludePaths:   opts.ExcludePaths,
+		SparsePatterns: opts.SparsePatterns,
+		ConeMode:       opts.ConeMode,
+	}
+
+	journal := &cloneJournal{done: make(map[string]hash.Hash)}
+	data, err := opts.FS.(UpdatableFS).ReadFile(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read clone journal %s: %w", name, err)
+	default:
+		journal.read(data, header)
+		logger.Debug("Read clone journal",
+			"journal_path", name,
+			"done_count", len(journal.done))
+	}
+
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("encode clone journal: %w", err)
+	}
+	journal.w, err = opts.FS.Create(name, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("create clone journal %s: %w", name, err)
+	}
+	if _, err := journal.w.Write(append(line, '\n')); err != nil {
+		_ = journal.w.Close()
+		return nil, fmt.Errorf("write clone journal %s: %w", name, err)
+	}
+	return journal, nil
+}
+
+// read loads the files of a previous journal whose first line matches
+// header.
+func (j *cloneJournal) read(data []byte, header cloneManifest) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	if !scanner.Scan() {
+		return
+	}
+	var previous cloneManifest
+	if err := json.Unmarshal(scanner.Bytes(), &previous); err != nil ||
+		previous.Commit != header.Commit ||
+		!slices.Equal(previous.IncludePaths, header.IncludePaths) ||
+		!slices.Equal(previous.ExcludePaths, header.ExcludePaths) ||
+		!slices.Equal(previous.SparsePatterns, header.SparsePatterns) ||
+		previous.ConeMode != header.ConeMode {
+		return
+	}
+	for scanner.Scan() {
+		var entry cloneJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		h, err := hash.FromHex(entry.Hash)
+		if err != nil {
+			continue
+		}
+		j.done[entry.Path] = h
+	}
+}
+
+// record appends a file that was written to the journal.
+func (j *cloneJournal) record(entry FlatTreeEntry) error {
+	line, err := json.Marshal(cloneJournalEntry{Path: entry.Path, Hash: entry.Hash.String()})
+	if err != nil {
+		return fmt.Errorf("encode clone journal: %w", err)
+	}
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write clone journal: %w", err)
+	}
+	return nil
+}
+
+// resumed reports whether the interrupted clone already wrote entry, and
+// the file on disk still matches it, along with the size of its content.
+func (j *cloneJournal) resumed(opts CloneOptions, entry FlatTreeEntry) (int64, bool) {
+	if done, ok := j.done[entry.Path]; !ok || done != entry.Hash {
+		return 0, false
+	}
+	fsys := opts.FS.(UpdatableFS)
+	name := path.Join(opts.Path, entry.Path)
+	info, err := fsys.Lstat(name)
+	if err != nil {
+		return 0, false
+	}
+	symlink := entry.Mode == 0o120000
+	if symlink != (info.Mode()&fs.ModeSymlink != 0) ||
+		!symlink && (entry.Mode == 0o100755) != (info.Mode()&0o111 != 0) {
+		return 0, false
+	}
+	data, err := readClonedFile(fsys, name, entry.Mode)
+	if err != nil {
+		return 0, false
+	}
+	if symlink && opts.SafeSymlinks && !isSafeSymlink(entry.Path, string(data)) {
+		// Written again so that the link is rejected.
+		return 0, false
+	}
+	h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, data)
+	if err != nil || h != entry.Hash {
+		return 0, false
+	}
+	return int64(len(data)), true
+}
+
+// Close closes the journal file.
+func (j *cloneJournal) Close() error {
+	return j.w.Close()
+}
+
+// removeCloneJournal removes the journal of a clone that succeeded.
+func removeCloneJournal(fsys UpdatableFS, basePath string) error {
+	name := path.Join(basePath, CloneJournalName)
+	if err := fsys.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove clone journal %s: %w", name, err)
+	}
+	return nil
+}
+
+// readClonedFile reads the content of a cloned file, or the target of a
+// symbolic link for mode 120000.
+func readClonedFile(fsys UpdatableFS, name string, mode uint32) ([]byte, error) {
+	if mode == 0o120000 {
+		target, err := fsys.ReadLink(name)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(target), nil
+	}
+	return fsys.ReadFile(name)
+}
+
+// cloneObjectInfoBatchSize is how many blob sizes a clone asks for per
+// object-info request.
+const cloneObjectInfoBatchSize = 1000
+
+// cloneBytesTotal returns the size of the files written from the blobs
+// with the given hashes, as reported by the object-info command. It
+// returns false when the server does not support the command or does not
+// know the size of a blob.
+func (c *httpClient) cloneBytesTotal(ctx context.Context, entriesByHash map[hash.Hash][]FlatTreeEntry, hashes []hash.Hash) (int64, bool, error) {
+	logger := log.FromContext(ctx)
+	var total int64
+	for batch := range slices.Chunk(hashes, cloneObjectInfoBatchSize) {
+		sizes, err := c.ObjectInfo(ctx, batch)
+		if errors.Is(err, client.ErrObjectInfoUnsupported) || protocol.IsGitServerError(err) {
+			logger.Debug("Object sizes not available", "error", err)
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, fmt.Errorf("get object sizes: %w", err)
+		}
+		for _, h := range batch {
+			size, ok := sizes[h]
+			if !ok {
+				logger.Debug("Object size not reported", "hash", h.String())
+				return 0, false, nil
+			}
+			total += size * int64(len(entriesByHash[h]))
+		}
+	}
+	return total, true, nil
+}
+
+// cloneProgress counts the files of a clone as they are done and reports
+// them to CloneOptions.Progress.
+type cloneProgress struct {
+	fn       func(CloneProgress)
+	progress CloneProgress
+}
+
+// done counts a file of the given size and reports the progress.
+func (p *cloneProgress) done(size int64) {
+	p.progress.FilesDone++
+	p.progress.BytesDone += size
+	p.report()
+}
+
+// report calls the callback, if any, with the current progress.
+func (p *cloneProgress) report() {
+	if p.fn == nil {
+		return
+	}
+	p.fn(p.progress)
+}
@@ -0,0 +1,196 @@
+package nanogit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClone_Resumable(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"a.txt":        "alpha",
+		"b.txt":        "bravo",
+		"c/d.txt":      "charlie delta",
+		"c/e.sh":       "exec:#!/bin/sh\necho echo",
+		"f/g/h.txt":    "hotel",
+		"f/link":       "symlink:g/h.txt",
+		"i.txt":        "india",
+		"j/k.txt":      "kilo",
+		"j/copy-a.txt": "alpha",
+	}
+	blobs := func(repo *testRepo) map[hash.Hash]bool {
+		set := make(map[hash.Hash]bool)
+		for _, content := range files {
+			content = strings.TrimPrefix(strings.TrimPrefix(content, "exec:"), "symlink:")
+			set[repo.blob(content)] = true
+		}
+		return set
+	}
+	// blobFetches counts the blobs wanted by the fetches after the first since.
+	blobFetches := func(repo *testRepo, isBlob map[hash.Hash]bool, since int) int {
+		count := 0
+		for _, wants := range repo.fetches[since:] {
+			for _, w := range wants {
+				if isBlob[w] {
+					count++
+				}
+			}
+		}
+		return count
+	}
+
+	t.Run("resumes an interrupted clone", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", files)
+		isBlob := blobs(repo)
+		dir := t.TempDir()
+
+		// Fail the fourth blob request.
+		errFlaky := errors.New("connection reset")
+		blobRequests := 0
+		repo.fetchHook = func(opts client.FetchOptions) error {
+			if len(opts.Want) == 1 && isBlob[opts.Want[0]] {
+				blobRequests++
+				if blobRequests == 4 {
+					return errFlaky
+				}
+			}
+			return nil
+		}
+		opts := CloneOptions{Path: dir, Hash: commit, Resumable: true}
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.ErrorIs(t, err, errFlaky)
+		require.FileExists(t, filepath.Join(dir, CloneJournalName))
+
+		// Blobs are fetched in path order: a.txt (and its copy), b.txt and
+		// c/d.txt were written before the failure.
+		journal, err := os.ReadFile(filepath.Join(dir, CloneJournalName))
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(journal)), "\n")
+		require.Len(t, lines, 1+4)
+		require.Contains(t, lines[1], `"a.txt"`)
+
+		// A recorded file that changed since is fetched again.
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("tampered"), 0o644))
+
+		repo.fetchHook = nil
+		repo.objectInfo = true
+		var reports []CloneProgress
+		opts.Progress = func(p CloneProgress) { reports = append(reports, p) }
+		fetches := len(repo.fetches)
+		result, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+
+		// Only the blobs of b.txt and c/d.txt are not fetched again.
+		require.Equal(t, len(isBlob)-2, blobFetches(repo, isBlob, fetches))
+		require.Equal(t, len(files), result.FilteredFiles-countTrees(result.FlatTree))
+		require.NoFileExists(t, filepath.Join(dir, CloneJournalName))
+		got := readClonedFiles(t, dir)
+		require.Equal(t, "alpha", got["a.txt"])
+		require.Equal(t, "hotel", got["f/g/h.txt"])
+		require.Equal(t, "#!/bin/sh\necho echo", got["c/e.sh"])
+		info, err := os.Stat(filepath.Join(dir, "c", "e.sh"))
+		require.NoError(t, err)
+		require.NotZero(t, info.Mode()&0o111)
+		target, err := os.Readlink(filepath.Join(dir, "f", "link"))
+		require.NoError(t, err)
+		require.Equal(t, "g/h.txt", target)
+
+		// The first report counts the resumed files, the last one everything.
+		require.Equal(t, 3, reports[0].FilesDone)
+		require.Equal(t, len(files), reports[0].FilesTotal)
+		last := reports[len(reports)-1]
+		require.Equal(t, len(files), last.FilesDone)
+		var size int64
+		for _, content := range files {
+			size += int64(len(strings.TrimPrefix(strings.TrimPrefix(content, "exec:"), "symlink:")))
+		}
+		require.Equal(t, size, last.BytesDone)
+		for i := 1; i < len(reports); i++ {
+			require.Equal(t, reports[i-1].FilesDone+1, reports[i].FilesDone)
+		}
+		// The total is known from the start.
+		for _, report := range reports {
+			require.Equal(t, size, report.BytesTotal)
+		}
+	})
+
+	t.Run("reports no byte total without object-info", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", files)
+		var last CloneProgress
+		opts := CloneOptions{Path: t.TempDir(), Hash: commit, Progress: func(p CloneProgress) { last = p }}
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.Equal(t, len(files), last.FilesDone)
+		require.NotZero(t, last.BytesDone)
+		require.Zero(t, last.BytesTotal)
+	})
+
+	t.Run("ignores the journal of another commit", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		first := repo.commit("first", files)
+		isBlob := blobs(repo)
+		second := repo.commit("second", map[string]string{"a.txt": "alpha"})
+		dir := t.TempDir()
+
+		_, err := repo.client().Clone(context.Background(), CloneOptions{Path: dir, Hash: first})
+		require.NoError(t, err)
+		journal := `{"commit":"` + first.String() + `"}` + "\n" + `{"path":"a.txt","hash":"` + repo.blob("alpha").String() + `"}` + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, CloneJournalName), []byte(journal), 0o644))
+
+		// a.txt has the same content in both commits, but the journal is
+		// for the first one.
+		fetches := len(repo.fetches)
+		_, err = repo.client().Clone(context.Background(), CloneOptions{Path: dir, Hash: second, Resumable: true})
+		require.NoError(t, err)
+		require.Equal(t, 1, blobFetches(repo, isBlob, fetches))
+	})
+
+	t.Run("tolerates a line cut short", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", files)
+		isBlob := blobs(repo)
+		fsys := NewInMemoryFS()
+
+		_, err := repo.client().Clone(context.Background(), CloneOptions{FS: fsys, Path: "repo", Hash: commit})
+		require.NoError(t, err)
+		journal := `{"commit":"` + commit.String() + `"}` + "\n" +
+			`{"path":"a.txt","hash":"` + repo.blob("alpha").String() + `"}` + "\n" +
+			`{"path":"b.txt","hash":"` + repo.blob("bravo").String() + `"}` + "\n" +
+			`{"path":"i.txt","ha`
+		require.NoError(t, writeFile(fsys, "repo/"+CloneJournalName, []byte(journal), 0o644))
+
+		fetches := len(repo.fetches)
+		_, err = repo.client().Clone(context.Background(), CloneOptions{FS: fsys, Path: "repo", Hash: commit, Resumable: true})
+		require.NoError(t, err)
+		// a.txt and its copy share a blob, which is still fetched for the copy.
+		require.Equal(t, len(isBlob)-1, blobFetches(repo, isBlob, fetches))
+		_, err = fsys.Lstat("repo/" + CloneJournalName)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+// countTrees returns the number of directories in tree.
+func countTrees(tree *FlatTree) int {
+	count := 0
+	for _, entry := range tree.Entries {
+		if entry.Mode == 0o40000 {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,489 @@
+package nanogit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+)
+
+// cloneRemoteName is the name of the remote written by InitRepository.
+const cloneRemoteName = "origin"
+
+// Flags of a version 2/3 git index entry.
+const (
+	indexEntryExtended     = 0x4000 // flags: the entry has a second flags field
+	indexEntrySkipWorktree = 0x4000 // extended flags: skip-worktree (sparse checkout)
+	indexEntryNameMask     = 0x0fff // flags: length of the path, capped
+)
+
+// gitRepository describes the .git directory written for a clone.
+type gitRepository struct {
+	fsys     UpdatableFS
+	basePath string
+	gitDir   string
+	commit   *Commit
+	// objects holds the commit and tree objects fetched for the clone.
+	objects storage.PackfileStorage
+	// trees lists every tree of the commit; checkedOut the blobs written to
+	// the work tree and submodules the gitlinks of the commit.
+	trees      []hash.Hash
+	checkedOut []FlatTreeEntry
+	files      []FlatTreeEntry
+	submodules []FlatTreeEntry
+	branch     string
+	remoteURL  string
+	// sparseCheckout is the content of info/sparse-checkout, for clones
+	// filtered with sparse patterns, and sparseCone whether it is in cone
+	// mode.
+	sparseCheckout []string
+	sparseCone     bool
+}
+
+// partial reports whether some files of the commit were not checked out, in
+// which case their blobs are missing from the repository.
+func (r *gitRepository) partial() bool {
+	return len(r.checkedOut) < len(r.files)
+}
+
+// initRepository turns the clone at opts.Path into a git repository by
+// writing a .git directory: a packfile holding the commit, all its trees and
+// the checked-out blobs, HEAD and the branch ref, a config, a shallow file
+// when the commit has parents, and an index matching the work tree.
+//
+// Files excluded by the clone filters are recorded in the index with the
+// skip-worktree bit, like a sparse checkout, and the repository is set up as
+// a partial clone of the remote so git tolerates their missing blobs.
+func (c *httpClient) initRepository(ctx context.Context, opts CloneOptions, commit *Commit, objects storage.PackfileStorage, fullTree, checkedOut *FlatTree, submodules []FlatTreeEntry) (string, error) {
+	repo := &gitRepository{
+		fsys:       opts.FS.(UpdatableFS),
+		basePath:   opts.Path,
+		gitDir:     path.Join(opts.Path, ".git"),
+		commit:     commit,
+		objects:    objects,
+		trees:      []hash.Hash{commit.Tree},
+		submodules: submodules,
+		branch:     opts.Branch,
+		remoteURL:  remoteURL(c.repoURL),
+	}
+	for _, entry := range fullTree.Entries {
+		switch entry.Type {
+		case protocol.ObjectTypeTree:
+			repo.trees = append(repo.trees, entry.Hash)
+		case protocol.ObjectTypeBlob:
+			repo.files = append(repo.files, entry)
+		}
+	}
+	for _, entry := range checkedOut.Entries {
+		if entry.Type == protocol.ObjectTypeBlob {
+			repo.checkedOut = append(repo.checkedOut, entry)
+		}
+	}
+
+	if len(opts.SparsePatterns) > 0 || opts.ConeMode {
+		sparse, err := parseSparsePatterns(opts.SparsePatterns, opts.ConeMode)
+		if err != nil {
+			return "", fmt.Errorf("parse sparse patterns: %w", err)
+		}
+		repo.sparseCheckout = sparse.fileLines(opts.SparsePatterns)
+		repo.sparseCone = opts.ConeMode
+	}
+
+	if repo.branch != "" {
+		if _, err := protocol.ParseRefName("refs/heads/" + repo.branch); err != nil {
+			return "", fmt.Errorf("invalid branch name %q: %w", repo.branch, err)
+		}
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Initialize git repository",
+		"git_dir", repo.gitDir,
+		"commit_hash", commit.Hash.String(),
+		"branch", repo.branch,
+		"tree_count", len(repo.trees),
+		"checked_out_count", len(repo.checkedOut),
+		"file_count", len(repo.files))
+
+	for _, dir := range []string{"objects/pack", "objects/info", "refs/heads", "refs/tags", "info"} {
+		if err := repo.fsys.MkdirAll(path.Join(repo.gitDir, dir), 0755); err != nil {
+			return "", fmt.Errorf("create %s: %w", dir, err)
+		}
+	}
+
+	packName, err := c.writeClonePack(ctx, repo)
+	if err != nil {
+		return "", fmt.Errorf("write packfile: %w", err)
+	}
+	if err := repo.writeRefs(); err != nil {
+		return "", err
+	}
+	if err := repo.writeConfig(); err != nil {
+		return "", err
+	}
+	if err := repo.writeSparseCheckout(); err != nil {
+		return "", err
+	}
+	if err := repo.writeIndex(); err != nil {
+		return "", fmt.Errorf("write index: %w", err)
+	}
+
+	logger.Debug("Git repository initialized",
+		"git_dir", repo.gitDir,
+		"pack", packName,
+		"partial", repo.partial())
+	return repo.gitDir, nil
+}
+
+// remoteURL returns the repository URL without credentials, as recorded in
+// the config of an initialized clone.
+func remoteURL(repo string) string {
+	u, err := url.Parse(repo)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	u.User = nil
+	return u.String()
+}
+
+// writeClonePack writes the objects of the clone to a packfile and its index
+// under objects/pack and returns the pack's name. Trees and the commit come
+// from the fetched objects; blobs are read back from the work tree, which
+// also covers files left untouched by an incremental clone.
+func (c *httpClient) writeClonePack(ctx context.Context, repo *gitRepository) (string, error) {
+	packDir := path.Join(repo.gitDir, "objects", "pack")
+
+	commitObj, ok := repo.objects.GetByType(repo.commit.Hash, protocol.ObjectTypeCommit)
+	if !ok {
+		return "", fmt.Errorf("commit %s: %w", repo.commit.Hash.String(), NewObjectNotFoundError(repo.commit.Hash))
+	}
+
+	// Trees and blobs can repeat; each object is stored once.
+	seen := map[hash.Hash]bool{repo.commit.Hash: true}
+	var trees []hash.Hash
+	for _, h := range repo.trees {
+		if !seen[h] {
+			seen[h] = true
+			trees = append(trees, h)
+		}
+	}
+	var blobs []FlatTreeEntry
+	for _, entry := range repo.checkedOut {
+		if !seen[entry.Hash] {
+			seen[entry.Hash] = true
+			blobs = append(blobs, entry)
+		}
+	}
+
+	// A leftover of an interrupted clone would be read-only.
+	tmpName := path.Join(packDir, "tmp_pack_nanogit")
+	_ = repo.fsys.Remove(tmpName)
+	tmp, err := repo.fsys.Create(tmpName, 0444)
+	if err != nil {
+		return "", err
+	}
+	// Clean up the temporary file if anything below fails.
+	closed := false
+	defer func() {
+		if !closed {
+			_ = tmp.Close()
+		}
+		_ = repo.fsys.Remove(tmpName)
+	}()
+
+	encoder, err := protocol.NewPackEncoder(tmp, crypto.SHA1, uint32(1+len(trees)+len(blobs)))
+	if err != nil {
+		return "", err
+	}
+	if err := encoder.Encode(*commitObj); err != nil {
+		return "", err
+	}
+
+	for _, h := range trees {
+		tree, ok := repo.objects.GetByType(h, protocol.ObjectTypeTree)
+		if !ok {
+			tree, err = c.getTree(ctx, h)
+			if err != nil {
+				return "", fmt.Errorf("get tree %s: %w", h.String(), err)
+			}
+		}
+		if err := encoder.Encode(*tree); err != nil {
+			return "", err
+		}
+	}
+
+	for _, entry := range blobs {
+		data, err := repo.readClonedBlob(entry)
+		if err != nil {
+			return "", err
+		}
+		if err := encoder.Encode(protocol.PackfileObject{Type: protocol.ObjectTypeBlob, Data: data, Hash: entry.Hash}); err != nil {
+			return "", err
+		}
+	}
+
+	checksum, err := encoder.Close()
+	if err != nil {
+		return "", err
+	}
+	closed = true
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	name := "pack-" + checksum.String()
+	var idx bytes.Buffer
+	if err := encoder.WriteIndex(&idx); err != nil {
+		return "", err
+	}
+	// The index is written before the pack is moved in place so that git
+	// never sees a pack without its index.
+	if err := writeFileAtomic(repo.fsys, path.Join(packDir, name+".idx"), idx.Bytes(), 0444); err != nil {
+		return "", err
+	}
+	if repo.partial() {
+		// Objects of a promisor pack may reference objects that are missing
+		// locally; this is what lets git accept the excluded blobs.
+		if err := writeFileAtomic(repo.fsys, path.Join(packDir, name+".promisor"), nil, 0444); err != nil {
+			return "", err
+		}
+	}
+	if err := repo.fsys.Rename(tmpName, path.Join(packDir, name+".pack")); err != nil {
+		return "", err
+	}
+
+	log.FromContext(ctx).Debug("Packfile written",
+		"pack", name,
+		"tree_count", len(trees),
+		"blob_count", len(blobs))
+	return name, nil
+}
+
+// readClonedBlob reads the content of a checked-out file, or the target of
+// a symbolic link, and checks that it still matches the blob it was written
+// from.
+func (r *gitRepository) readClonedBlob(entry FlatTreeEntry) ([]byte, error) {
+	data, err := readClonedFile(r.fsys, path.Join(r.basePath, entry.Path), entry.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", entry.Path, err)
+	}
+	h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, data)
+	if err != nil {
+		return nil, fmt.Errorf("hash %s: %w", entry.Path, err)
+	}
+	if h != entry.Hash {
+		return nil, fmt.Errorf("file %s was modified during clone: expected blob %s, got %s", entry.Path, entry.Hash.String(), h.String())
+	}
+	return data, nil
+}
+
+// writeRefs writes HEAD, the branch ref and its remote-tracking ref, and the
+// shallow file.
+func (r *gitRepository) writeRefs() error {
+	head := r.commit.Hash.String() + "\n"
+	if r.branch != "" {
+		head = "ref: refs/heads/" + r.branch + "\n"
+		if err := r.writeRef("refs/heads/" + r.branch); err != nil {
+			return err
+		}
+		if r.remoteURL != "" {
+			if err := r.writeRef("refs/remotes/" + cloneRemoteName + "/" + r.branch); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writeFileAtomic(r.fsys, path.Join(r.gitDir, "HEAD"), []byte(head), 0644); err != nil {
+		return fmt.Errorf("write HEAD: %w", err)
+	}
+
+	// Only the cloned commit is fetched, so its parents are cut off like in
+	// a depth 1 clone.
+	shallow := path.Join(r.gitDir, "shallow")
+	if len(r.commit.Parents) == 0 {
+		if err := r.fsys.Remove(shallow); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove shallow file: %w", err)
+		}
+		return nil
+	}
+	if err := writeFileAtomic(r.fsys, shallow, []byte(r.commit.Hash.String()+"\n"), 0644); err != nil {
+		return fmt.Errorf("write shallow file: %w", err)
+	}
+	return nil
+}
+
+// writeRef points a loose ref at the cloned commit.
+func (r *gitRepository) writeRef(name string) error {
+	refPath := path.Join(r.gitDir, name)
+	if err := r.fsys.MkdirAll(path.Dir(refPath), 0755); err != nil {
+		return fmt.Errorf("create directory for %s: %w", name, err)
+	}
+	if err := writeFileAtomic(r.fsys, refPath, []byte(r.commit.Hash.String()+"\n"), 0644); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeConfig writes the repository config: the remote the clone came from,
+// the upstream of the branch, and the partial clone settings when some files
+// were not checked out.
+func (r *gitRepository) writeConfig() error {
+	var sb strings.Builder
+	version := 0
+	if r.partial() {
+		// extensions.partialClone requires repository format version 1.
+		version = 1
+	}
+	fmt.Fprintf(&sb, "[core]\n\trepositoryformatversion = %d\n\tfilemode = true\n\tbare = false\n\tlogallrefupdates = true\n", version)
+	if r.sparseCheckout != nil {
+		fmt.Fprintf(&sb, "\tsparsecheckout = true\n\tsparsecheckoutcone = %t\n", r.sparseCone)
+	}
+
+	if r.remoteURL != "" || r.partial() {
+		fmt.Fprintf(&sb, "[remote %q]\n", cloneRemoteName)
+		if r.remoteURL != "" {
+			fmt.Fprintf(&sb, "\turl = %s\n", r.remoteURL)
+		}
+		fmt.Fprintf(&sb, "\tfetch = +refs/heads/*:refs/remotes/%s/*\n", cloneRemoteName)
+		if r.partial() {
+			sb.WriteString("\tpromisor = true\n\tpartialclonefilter = blob:none\n")
+		}
+	}
+	if r.partial() {
+		fmt.Fprintf(&sb, "[extensions]\n\tpartialclone = %s\n", cloneRemoteName)
+	}
+	if r.branch != "" && r.remoteURL != "" {
+		fmt.Fprintf(&sb, "[branch %q]\n\tremote = %s\n\tmerge = refs/heads/%s\n", r.branch, cloneRemoteName, r.branch)
+	}
+
+	if err := writeFileAtomic(r.fsys, path.Join(r.gitDir, "config"), []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+// writeSparseCheckout writes info/sparse-checkout, so that git commands
+// such as `git sparse-checkout reapply` keep the files the clone selected.
+// Without sparse patterns, a stale file is removed.
+func (r *gitRepository) writeSparseCheckout() error {
+	name := path.Join(r.gitDir, "info", "sparse-checkout")
+	if r.sparseCheckout == nil {
+		if err := r.fsys.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove sparse-checkout: %w", err)
+		}
+		return nil
+	}
+	var sb strings.Builder
+	for _, line := range r.sparseCheckout {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	if err := writeFileAtomic(r.fsys, name, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("write sparse-checkout: %w", err)
+	}
+	return nil
+}
+
+// writeIndex writes the index (.git/index) listing every file of the
+// commit. Checked-out files carry the stat data of the file on disk; the
+// others and submodules are marked skip-worktree or stored as gitlinks, so
+// `git status` reports a clean work tree.
+//
+// Only portable stat data (mtime, size, mode) is recorded, so git re-hashes
+// the files once to fill in the rest on the first `git status`.
+func (r *gitRepository) writeIndex() error {
+	checkedOut := make(map[string]bool, len(r.checkedOut))
+	for _, entry := range r.checkedOut {
+		checkedOut[entry.Path] = true
+	}
+
+	entries := make([]FlatTreeEntry, 0, len(r.files)+len(r.submodules))
+	entries = append(entries, r.files...)
+	entries = append(entries, r.submodules...)
+	slices.SortFunc(entries, func(a, b FlatTreeEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	version := uint32(2)
+	if r.partial() {
+		// Extended flags, needed for skip-worktree, require version 3.
+		version = 3
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("DIRC")
+	_ = binary.Write(&buf, binary.BigEndian, version)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(entries)))
+
+	for _, entry := range entries {
+		var info fs.FileInfo
+		skip := false
+		switch {
+		case entry.Mode == 0o160000:
+			// Submodules are not cloned; git expects an empty directory.
+			if err := r.fsys.MkdirAll(path.Join(r.basePath, entry.Path), 0755); err != nil {
+				return fmt.Errorf("create submodule directory %s: %w", entry.Path, err)
+			}
+		case checkedOut[entry.Path]:
+			var err error
+			info, err = r.fsys.Lstat(path.Join(r.basePath, entry.Path))
+			if err != nil {
+				return fmt.Errorf("stat %s: %w", entry.Path, err)
+			}
+		default:
+			skip = true
+		}
+		writeIndexEntry(&buf, entry, info, skip)
+	}
+
+	checksum := crypto.SHA1.New()
+	_, _ = checksum.Write(buf.Bytes())
+	buf.Write(checksum.Sum(nil))
+
+	return writeFileAtomic(r.fsys, path.Join(r.gitDir, "index"), buf.Bytes(), 0644)
+}
+
+// writeIndexEntry appends one index entry: stat data, mode, object hash,
+// flags and the NUL-padded path.
+func writeIndexEntry(buf *bytes.Buffer, entry FlatTreeEntry, info fs.FileInfo, skipWorktree bool) {
+	start := buf.Len()
+
+	var sec, nsec, size uint32
+	if info != nil {
+		mtime := info.ModTime()
+		sec, nsec, size = uint32(mtime.Unix()), uint32(mtime.Nanosecond()), uint32(info.Size())
+	}
+	// ctime and mtime, then dev and ino.
+	for _, v := range []uint32{sec, nsec, sec, nsec, 0, 0} {
+		_ = binary.Write(buf, binary.BigEndian, v)
+	}
+	// mode, uid, gid and size.
+	for _, v := range []uint32{entry.Mode, 0, 0, size} {
+		_ = binary.Write(buf, binary.BigEndian, v)
+	}
+	buf.Write(entry.Hash[:])
+
+	flags := uint16(min(len(entry.Path), indexEntryNameMask))
+	if skipWorktree {
+		flags |= indexEntryExtended
+	}
+	_ = binary.Write(buf, binary.BigEndian, flags)
+	if skipWorktree {
+		_ = binary.Write(buf, binary.BigEndian, uint16(indexEntrySkipWorktree))
+	}
+	buf.WriteString(entry.Path)
+
+	// Entries are padded with 1 to 8 NUL bytes to a multiple of 8 bytes.
+	padding := 8 - (buf.Len()-start)%8
+	buf.Write(make([]byte, padding))
+}
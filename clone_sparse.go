@@ -0,0 +1,508 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// prunedTreeBatchSize is how many trees getPrunedFlatTree requests at once.
+const prunedTreeBatchSize = 50
+
+// sparsePatterns matches paths against the lines of a git sparse-checkout
+// file, either as gitignore-style patterns or, in cone mode, as directories.
+type sparsePatterns struct {
+	cone bool
+
+	// patterns are the parsed lines of a non-cone file, in order.
+	patterns []sparsePattern
+
+	// recursive are the cone directories included with everything below
+	// them, and parents the directories leading to them, whose files (but
+	// not subdirectories) are included. Files at the root always are.
+	recursive []string
+	parents   map[string]bool
+}
+
+// sparsePattern is one gitignore-style line.
+type sparsePattern struct {
+	// segments are the slash-separated elements of an anchored pattern, or
+	// the single glob matched against the last element of a path otherwise.
+	segments []string
+	anchored bool
+	negate   bool
+	dirOnly  bool
+}
+
+// parseSparsePatterns parses the lines of a sparse-checkout file. Blank
+// lines and comments are ignored.
+//
+// In cone mode, a line is a directory to include recursively ("src/app"),
+// or one of the lines git writes for cone mode ("/*", "!/*/", "/src/",
+// "!/src/*/"), so that an existing cone sparse-checkout file can be used.
+func parseSparsePatterns(lines []string, cone bool) (*sparsePatterns, error) {
+	s := &sparsePatterns{cone: cone}
+	if cone {
+		return s, s.parseCone(lines)
+	}
+
+	for _, line := range lines {
+		p, ok, err := parseSparsePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			s.patterns = append(s.patterns, p)
+		}
+	}
+	return s, nil
+}
+
+// parseSparsePattern parses one gitignore-style line. It returns false for
+// blank lines and comments.
+func parseSparsePattern(line string) (sparsePattern, bool, error) {
+	var p sparsePattern
+	// Trailing spaces are ignored unless escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false, nil
+	}
+
+	pattern := line
+	switch {
+	case strings.HasPrefix(pattern, "!"):
+		p.negate = true
+		pattern = pattern[1:]
+	case strings.HasPrefix(pattern, "\\!"), strings.HasPrefix(pattern, "\\#"):
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	// A slash anywhere but at the end anchors the pattern to the root.
+	if strings.Contains(pattern, "/") {
+		p.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	if pattern == "" {
+		return p, false, nil
+	}
+
+	p.segments = strings.Split(pattern, "/")
+	if !p.anchored {
+		p.segments = []string{pattern}
+	}
+	for _, segment := range p.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return p, false, fmt.Errorf("invalid sparse pattern %q: %w", line, err)
+		}
+	}
+	return p, true, nil
+}
+
+// parseCone collects the directories of a cone-mode file.
+func (s *sparsePatterns) parseCone(lines []string) error {
+	parentOnly := make(map[string]bool)
+	var dirs []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"), line == "/*", line == "!/*/":
+			continue
+		case strings.HasPrefix(line, "!/") && strings.HasSuffix(line, "/*/"):
+			dir, err := coneDirectory(line, strings.TrimSuffix(line[2:], "/*/"))
+			if err != nil {
+				return err
+			}
+			parentOnly[dir] = true
+		default:
+			dir, err := coneDirectory(line, strings.Trim(line, "/"))
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+
+	s.parents = make(map[string]bool)
+	for _, dir := range dirs {
+		if parentOnly[dir] {
+			continue
+		}
+		s.recursive = append(s.recursive, dir)
+		for parent := path.Dir(dir); parent != "."; parent = path.Dir(parent) {
+			s.parents[parent] = true
+		}
+	}
+	return nil
+}
+
+// coneDirectory validates the directory of a cone-mode line and removes the
+// backslashes git escapes glob characters with.
+func coneDirectory(line, dir string) (string, error) {
+	invalid := fmt.Errorf("invalid cone pattern %q: not a directory", line)
+	if strings.HasPrefix(dir, "!") {
+		return "", invalid
+	}
+	var sb strings.Builder
+	for i := 0; i < len(dir); i++ {
+		switch dir[i] {
+		case '\\':
+			if i++; i < len(dir) {
+				sb.WriteByte(dir[i])
+			}
+		case '*', '?', '[':
+			return "", invalid
+		default:
+			sb.WriteByte(dir[i])
+		}
+	}
+	dir = sb.String()
+	if dir == "" || path.Clean(dir) != dir || dir == ".." || strings.HasPrefix(dir, "../") {
+		return "", invalid
+	}
+	return dir, nil
+}
+
+// includes reports whether a file is part of the sparse checkout.
+func (s *sparsePatterns) includes(file string) bool {
+	if s.cone {
+		dir := path.Dir(file)
+		return dir == "." || s.inRecursive(dir) || s.parents[dir]
+	}
+
+	// Like git, the deepest path that a pattern decides on wins: the file
+	// itself, then each of its directories.
+	isDir := false
+	for p := file; ; p, isDir = path.Dir(p), true {
+		if matched, include := s.decide(p, isDir); matched {
+			return include
+		}
+		if !strings.Contains(p, "/") {
+			return false
+		}
+	}
+}
+
+// descend reports whether any file below dir may be part of the sparse
+// checkout, so that a directory can be skipped without fetching its tree.
+func (s *sparsePatterns) descend(dir string) bool {
+	if s.cone {
+		return s.parents[dir] || s.inRecursive(dir)
+	}
+
+	for p := dir; ; p = path.Dir(p) {
+		if matched, include := s.decide(p, true); matched {
+			if include {
+				return true
+			}
+			break
+		}
+		if !strings.Contains(p, "/") {
+			break
+		}
+	}
+	// An excluded directory may still hold files that a pattern decides on
+	// more deeply.
+	segments := strings.Split(dir, "/")
+	for _, p := range s.patterns {
+		if !p.negate && (!p.anchored || couldMatchBelow(p.segments, segments)) {
+			return true
+		}
+	}
+	return false
+}
+
+// inRecursive reports whether dir is, or is below, a recursive cone directory.
+func (s *sparsePatterns) inRecursive(dir string) bool {
+	for _, r := range s.recursive {
+		if dir == r || strings.HasPrefix(dir, r+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// decide returns whether the last pattern matching p, if any, includes it.
+func (s *sparsePatterns) decide(p string, isDir bool) (matched, include bool) {
+	for i := len(s.patterns) - 1; i >= 0; i-- {
+		if s.patterns[i].match(p, isDir) {
+			return true, !s.patterns[i].negate
+		}
+	}
+	return false, false
+}
+
+// match reports whether the pattern matches name.
+func (p sparsePattern) match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !p.anchored {
+		matched, _ := path.Match(p.segments[0], path.Base(name))
+		return matched
+	}
+	return matchSegments(p.segments, strings.Split(name, "/"))
+}
+
+// matchSegments matches pattern elements against path elements, where a
+// "**" element matches any number of path elements, or at least one when it
+// ends the pattern ("dir/**" matches everything inside dir, not dir).
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			return len(segments) > 0
+		}
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, _ := path.Match(pattern[0], segments[0])
+	return matched && matchSegments(pattern[1:], segments[1:])
+}
+
+// couldMatchBelow reports whether an anchored pattern could match a path
+// strictly below the directory made of segments.
+func couldMatchBelow(pattern, segments []string) bool {
+	switch {
+	case len(segments) == 0:
+		return len(pattern) > 0
+	case len(pattern) == 0:
+		return false
+	case pattern[0] == "**":
+		return true
+	}
+	matched, _ := path.Match(pattern[0], segments[0])
+	return matched && couldMatchBelow(pattern[1:], segments[1:])
+}
+
+// fileLines renders the patterns as the content of .git/info/sparse-checkout.
+// Cone directories are written the way git writes them.
+func (s *sparsePatterns) fileLines(lines []string) []string {
+	if !s.cone {
+		return lines
+	}
+
+	out := []string{"/*", "!/*/"}
+	dirs := make([]string, 0, len(s.parents)+len(s.recursive))
+	for dir := range s.parents {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, s.recursive...)
+	slices.Sort(dirs)
+	dirs = slices.Compact(dirs)
+	escape := strings.NewReplacer("\\", "\\\\", "*", "\\*", "?", "\\?", "[", "\\[")
+	for _, dir := range dirs {
+		out = append(out, "/"+escape.Replace(dir)+"/")
+		if !s.inRecursive(dir) {
+			out = append(out, "!/"+escape.Replace(dir)+"/*/")
+		}
+	}
+	return out
+}
+
+// clonePathFilter decides which paths of a commit a clone includes, from
+// the glob IncludePaths and ExcludePaths and the sparse-checkout patterns.
+type clonePathFilter struct {
+	c            *httpClient
+	includePaths []string
+	excludePaths []string
+	// sparse is nil unless CloneOptions.SparsePatterns or ConeMode is set.
+	sparse *sparsePatterns
+}
+
+// newClonePathFilter builds the filter of a clone. sparse and cone are
+// CloneOptions.SparsePatterns and CloneOptions.ConeMode.
+func (c *httpClient) newClonePathFilter(includePaths, excludePaths, sparse []string, cone bool) (*clonePathFilter, error) {
+	f := &clonePathFilter{c: c, includePaths: includePaths, excludePaths: excludePaths}
+	if len(sparse) > 0 || cone {
+		var err error
+		if f.sparse, err = parseSparsePatterns(sparse, cone); err != nil {
+			return nil, fmt.Errorf("parse sparse patterns: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// active reports whether the filter leaves out anything.
+func (f *clonePathFilter) active() bool {
+	return len(f.includePaths) > 0 || len(f.excludePaths) > 0 || f.sparse != nil
+}
+
+// includes reports whether a file is part of the clone.
+func (f *clonePathFilter) includes(file string) bool {
+	if !f.c.shouldIncludePath(file, f.includePaths, f.excludePaths) {
+		return false
+	}
+	return f.sparse == nil || f.sparse.includes(file)
+}
+
+// includesEntry reports whether an entry of a flat tree is kept. Tree
+// entries are kept when files below them may be.
+func (f *clonePathFilter) includesEntry(entry FlatTreeEntry) bool {
+	if entry.Type != protocol.ObjectTypeTree {
+		return f.includes(entry.Path)
+	}
+	if !f.c.shouldIncludePath(entry.Path, f.includePaths, f.excludePaths) {
+		return false
+	}
+	return f.sparse == nil || f.sparse.descend(entry.Path)
+}
+
+// descend reports whether the filter may include files below dir.
+func (f *clonePathFilter) descend(dir string) bool {
+	if !mayIncludeBelow(dir, f.includePaths, f.excludePaths) {
+		return false
+	}
+	return f.sparse == nil || f.sparse.descend(dir)
+}
+
+// getPrunedFlatTree returns the flat tree of the root tree like
+// GetFlatTree, but only fetches the trees of the directories descend
+// accepts. A skipped directory is listed without its content. Trees are
+// fetched one level at a time with the tree:0 filter, so the server only
+// sends the requested trees.
+func (c *httpClient) getPrunedFlatTree(ctx context.Context, rootTree hash.Hash, descend func(dir string) bool) (*FlatTree, []FlatTreeEntry, error) {
+	logger := log.FromContext(ctx)
+
+	type dir struct {
+		path string
+		hash hash.Hash
+	}
+	trees := make(map[hash.Hash]*protocol.PackfileObject)
+	level := []dir{{hash: rootTree}}
+	pruned := 0
+	for depth := 0; len(level) > 0; depth++ {
+		var wants []hash.Hash
+		seen := make(map[hash.Hash]bool)
+		for _, d := range level {
+			if trees[d.hash] == nil && !seen[d.hash] {
+				seen[d.hash] = true
+				wants = append(wants, d.hash)
+			}
+		}
+		for start := 0; start < len(wants); start += prunedTreeBatchSize {
+			batch := wants[start:min(start+prunedTreeBatchSize, len(wants))]
+			objects, err := c.Fetch(ctx, client.FetchOptions{
+				NoProgress:       true,
+				NoTreeFilter:     true,
+				Want:             batch,
+				Done:             true,
+				MaxResponseBytes: c.limits.MultiObjectFetchMaxBytes,
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("fetch trees: %w", err)
+			}
+			for _, want := range batch {
+				obj, ok := objects[want.String()]
+				if !ok {
+					return nil, nil, NewObjectNotFoundError(want)
+				}
+				if obj.Type != protocol.ObjectTypeTree {
+					return nil, nil, NewUnexpectedObjectTypeError(want, protocol.ObjectTypeTree, obj.Type)
+				}
+				trees[want] = obj
+			}
+		}
+
+		var next []dir
+		for _, d := range level {
+			for _, e := range trees[d.hash].Tree {
+				if e.FileMode != 0o40000 {
+					continue
+				}
+				p := path.Join(d.path, e.FileName)
+				if !descend(p) {
+					pruned++
+					continue
+				}
+				h, err := getCachedHash(e.Hash)
+				if err != nil {
+					return nil, nil, fmt.Errorf("parsing entry hash %s: %w", e.Hash, err)
+				}
+				next = append(next, dir{path: p, hash: h})
+			}
+		}
+		logger.Debug("Fetched tree level",
+			"depth", depth,
+			"dir_count", len(level),
+			"fetched_count", len(wants),
+			"next_count", len(next))
+		level = next
+	}
+
+	tree := &FlatTree{Hash: rootTree}
+	var submodules []FlatTreeEntry
+	err := walkFetchedTrees(trees[rootTree], "", trees, descend, func(entry FlatTreeEntry) {
+		if entry.Mode == 0o160000 {
+			submodules = append(submodules, entry)
+			return
+		}
+		tree.Entries = append(tree.Entries, entry)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Debug("Pruned flat tree retrieved",
+		"tree_hash", rootTree.String(),
+		"tree_count", len(trees),
+		"entry_count", len(tree.Entries),
+		"pruned_count", pruned)
+	return tree, submodules, nil
+}
+
+// walkFetchedTrees visits the entries of tree and, depth-first in name
+// order like flatten, those of the subdirectories descend accepts.
+func walkFetchedTrees(tree *protocol.PackfileObject, basePath string, trees map[hash.Hash]*protocol.PackfileObject, descend func(string) bool, visit func(FlatTreeEntry)) error {
+	entries := slices.Clone(tree.Tree)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].FileName < entries[j].FileName
+	})
+	for _, e := range entries {
+		h, err := getCachedHash(e.Hash)
+		if err != nil {
+			return fmt.Errorf("parsing entry hash %s: %w", e.Hash, err)
+		}
+		entry := FlatTreeEntry{
+			Name: e.FileName,
+			Path: path.Join(basePath, e.FileName),
+			Mode: uint32(e.FileMode),
+			Hash: h,
+			Type: protocol.ObjectTypeBlob,
+		}
+		switch e.FileMode {
+		case 0o40000:
+			entry.Type = protocol.ObjectTypeTree
+		case 0o160000:
+			entry.Type = protocol.ObjectTypeCommit
+		}
+		visit(entry)
+		if entry.Type == protocol.ObjectTypeTree && descend(entry.Path) {
+			if err := walkFetchedTrees(trees[h], entry.Path, trees, descend, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package nanogit
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparsePatterns(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		patterns []string
+		cone     bool
+		included []string
+		excluded []string
+		descend  []string
+		pruned   []string
+	}{
+		{
+			name:     "root files only",
+			patterns: []string{"/*", "!/*/"},
+			included: []string{"README.md", "Makefile"},
+			excluded: []string{"src/main.go", "docs/a/b.md"},
+			pruned:   []string{"src", "docs/a"},
+		},
+		{
+			name:     "re-include a directory",
+			patterns: []string{"/*", "!/*/", "/docs/", "!/docs/internal/"},
+			included: []string{"README.md", "docs/guide.md", "docs/api/index.md"},
+			excluded: []string{"docs/internal/secret.md", "src/main.go"},
+			descend:  []string{"docs", "docs/api"},
+			pruned:   []string{"src", "docs/internal"},
+		},
+		{
+			name:     "unanchored patterns match at any depth",
+			patterns: []string{"*.md", "!skip.md", "testdata/"},
+			included: []string{"README.md", "a/b/c.md", "pkg/testdata/x.bin"},
+			excluded: []string{"skip.md", "a/skip.md", "main.go", "pkg/testdata.go"},
+			descend:  []string{"a", "a/b/c"},
+		},
+		{
+			name:     "double star",
+			patterns: []string{"src/**/gen/", "/docs/**", "**/keep.txt"},
+			included: []string{"src/gen/a.go", "src/x/y/gen/b.go", "docs/a", "docs/x/y.md", "any/where/keep.txt"},
+			excluded: []string{"src/a.go", "docs", "other/file.txt"},
+			descend:  []string{"src", "src/x", "docs"},
+		},
+		{
+			name:     "a file pattern decides before its directory",
+			patterns: []string{"/lib/", "!/lib/*.tmp"},
+			included: []string{"lib/a.go", "lib/sub/b.tmp"},
+			excluded: []string{"lib/c.tmp", "README.md"},
+			descend:  []string{"lib", "lib/sub"},
+			pruned:   []string{"src"},
+		},
+		{
+			name:     "escapes and comments",
+			patterns: []string{"# comment", "", "\\#hash", "\\!bang", "trailing   "},
+			included: []string{"#hash", "!bang", "dir/trailing"},
+			excluded: []string{"comment", "# comment"},
+		},
+		{
+			name:     "cone directories",
+			patterns: []string{"src/app", "docs"},
+			cone:     true,
+			included: []string{"README.md", "src/main.go", "src/app/a.go", "src/app/x/y.go", "docs/a/b.md"},
+			excluded: []string{"src/lib/l.go", "other/o.go"},
+			descend:  []string{"src", "src/app", "src/app/x", "docs", "docs/a"},
+			pruned:   []string{"src/lib", "other"},
+		},
+		{
+			name:     "cone file written by git",
+			patterns: []string{"/*", "!/*/", "/src/", "!/src/*/", "/src/app/", "/we\\*ird/"},
+			cone:     true,
+			included: []string{"README.md", "src/main.go", "src/app/a.go", "we*ird/x"},
+			excluded: []string{"src/lib/l.go"},
+			pruned:   []string{"src/lib", "other"},
+		},
+		{
+			name:     "cone without directories",
+			cone:     true,
+			included: []string{"README.md"},
+			excluded: []string{"src/main.go"},
+			pruned:   []string{"src"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := parseSparsePatterns(tt.patterns, tt.cone)
+			require.NoError(t, err)
+			for _, p := range tt.included {
+				require.True(t, s.includes(p), "%s should be included", p)
+			}
+			for _, p := range tt.excluded {
+				require.False(t, s.includes(p), "%s should be excluded", p)
+			}
+			for _, p := range tt.descend {
+				require.True(t, s.descend(p), "%s should be descended into", p)
+			}
+			for _, p := range tt.pruned {
+				require.False(t, s.descend(p), "%s should be pruned", p)
+			}
+		})
+	}
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		t.Parallel()
+		_, err := parseSparsePatterns([]string{"src/[a"}, false)
+		require.Error(t, err)
+		for _, line := range []string{"src/*.go", "!docs", "../up", "/a/../b/"} {
+			_, err := parseSparsePatterns([]string{line}, true)
+			require.Error(t, err, line)
+		}
+	})
+
+	t.Run("cone file lines", func(t *testing.T) {
+		t.Parallel()
+		s, err := parseSparsePatterns([]string{"src/app", "docs", "src/app/x"}, true)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/*", "!/*/", "/docs/", "/src/", "!/src/*/", "/src/app/", "/src/app/x/"}, s.fileLines(nil))
+	})
+}
+
+func TestClone_SparsePatterns(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"README.md":                  "readme",
+		"Makefile":                   "make",
+		"docs/guide.md":              "guide",
+		"docs/internal/secret.md":    "secret",
+		"src/main.go":                "package main",
+		"src/app/app.go":             "package app",
+		"src/app/handlers/h.go":      "package handlers",
+		"src/lib/lib.go":             "package lib",
+		"src/lib/deep/nested/d.go":   "package nested",
+		"vendor/github.com/x/x.go":   "package x",
+		"vendor/github.com/y/y.go":   "package y",
+		"tools/gen/generated.md":     "generated",
+		"tools/gen/deeper/skip.md":   "skip",
+		"tools/scripts/build.sh":     "exec:#!/bin/sh",
+		"tools/scripts/lint/lint.sh": "lint",
+	}
+
+	// wantedTrees returns the hashes of every object wanted by the fetches
+	// made after the first since.
+	wantedTrees := func(repo *testRepo, since int) map[string]bool {
+		wanted := make(map[string]bool)
+		for _, wants := range repo.fetches[since:] {
+			for _, w := range wants {
+				wanted[w.String()] = true
+			}
+		}
+		return wanted
+	}
+	// subtree returns the hash of the tree of dir in commit.
+	subtree := func(t *testing.T, repo *testRepo, commit hash.Hash, dir string) hash.Hash {
+		t.Helper()
+		tree, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		for _, entry := range tree.Entries {
+			if entry.Path == dir {
+				return entry.Hash
+			}
+		}
+		t.Fatalf("no directory %s", dir)
+		return hash.Zero
+	}
+
+	t.Run("gitignore patterns", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", files)
+		dir := t.TempDir()
+
+		fetches := len(repo.fetches)
+		result, err := repo.client().Clone(context.Background(), CloneOptions{
+			Path:           dir,
+			Hash:           commit,
+			SparsePatterns: []string{"/*", "!/*/", "/docs/", "!/docs/internal/", "/src/", "!/src/lib/", "tools/**/*.md", "!skip.md"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"README.md":              "readme",
+			"Makefile":               "make",
+			"docs/guide.md":          "guide",
+			"src/main.go":            "package main",
+			"src/app/app.go":         "package app",
+			"src/app/handlers/h.go":  "package handlers",
+			"tools/gen/generated.md": "generated",
+		}, readClonedFiles(t, dir))
+
+		// The trees of excluded directories were never requested.
+		wanted := wantedTrees(repo, fetches)
+		for _, pruned := range []string{"vendor", "src/lib", "docs/internal"} {
+			require.False(t, wanted[subtree(t, repo, commit, pruned).String()], "%s was fetched", pruned)
+		}
+		require.True(t, wanted[subtree(t, repo, commit, "tools/scripts").String()])
+		full, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		require.Less(t, result.TotalFiles, len(full.Entries))
+	})
+
+	t.Run("cone mode", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", files)
+		dir := t.TempDir()
+
+		fetches := len(repo.fetches)
+		_, err := repo.client().Clone(context.Background(), CloneOptions{
+			Path:           dir,
+			Hash:           commit,
+			SparsePatterns: []string{"src/app", "tools/scripts"},
+			ConeMode:       true,
+			ExcludePaths:   []string{"**/lint/**"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"README.md":              "readme",
+			"Makefile":               "make",
+			"src/main.go":            "package main",
+			"src/app/app.go":         "package app",
+			"src/app/handlers/h.go":  "package handlers",
+			"tools/scripts/build.sh": "#!/bin/sh",
+		}, readClonedFiles(t, dir))
+
+		wanted := wantedTrees(repo, fetches)
+		for _, pruned := range []string{"vendor", "src/lib", "docs", "tools/gen"} {
+			require.False(t, wanted[subtree(t, repo, commit, pruned).String()], "%s was fetched", pruned)
+		}
+	})
+
+	t.Run("include and exclude paths", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", files)
+		dir := t.TempDir()
+
+		fetches := len(repo.fetches)
+		result, err := repo.client().Clone(context.Background(), CloneOptions{
+			Path:         dir,
+			Hash:         commit,
+			IncludePaths: []string{"src/**", "tools/*/*.md"},
+			ExcludePaths: []string{"src/lib/**", "**/handlers/**"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"src/main.go":            "package main",
+			"src/app/app.go":         "package app",
+			"tools/gen/generated.md": "generated",
+		}, readClonedFiles(t, dir))
+
+		wanted := wantedTrees(repo, fetches)
+		for _, pruned := range []string{"docs", "vendor", "src/lib", "src/app/handlers", "tools/gen/deeper", "tools/scripts/lint"} {
+			require.False(t, wanted[subtree(t, repo, commit, pruned).String()], "%s was fetched", pruned)
+		}
+		require.True(t, wanted[subtree(t, repo, commit, "tools/scripts").String()])
+
+		// The pruned directories are not counted in TotalFiles.
+		full, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		require.Less(t, result.TotalFiles, len(full.Entries))
+	})
+
+	t.Run("changed patterns remove files no longer included", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", files)
+		dir := t.TempDir()
+		manifest := filepath.Join(t.TempDir(), "clone.json")
+
+		opts := CloneOptions{Path: dir, Hash: commit, ManifestPath: manifest, SparsePatterns: []string{"docs", "src"}, ConeMode: true}
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.FileExists(t, filepath.Join(dir, "docs", "guide.md"))
+
+		opts.SparsePatterns = []string{"src"}
+		result, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.Equal(t, commit, result.Previous)
+		require.NoDirExists(t, filepath.Join(dir, "docs"))
+		require.FileExists(t, filepath.Join(dir, "src", "lib", "deep", "nested", "d.go"))
+	})
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", files)
+
+		_, err := repo.client().Clone(context.Background(), CloneOptions{Path: t.TempDir(), Hash: commit, SparsePatterns: []string{"src/*"}, ConeMode: true})
+		require.ErrorContains(t, err, "parse sparse patterns")
+	})
+
+	t.Run("git agrees with the selection", func(t *testing.T) {
+		t.Parallel()
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git binary not available")
+		}
+
+		for _, opts := range []CloneOptions{
+			{SparsePatterns: []string{"/*", "!/*/", "/docs/", "!/docs/internal/", "src/", "!/src/lib/deep/", "tools/**/*.md", "!skip.md"}},
+			{SparsePatterns: []string{"src/app", "tools/scripts"}, ConeMode: true},
+		} {
+			repo := newTestRepo(t)
+			commit := repo.commit("initial", files)
+			dir := t.TempDir()
+			opts.Path, opts.Hash, opts.InitRepository, opts.Branch = dir, commit, true, "main"
+
+			_, err := repo.client().Clone(context.Background(), opts)
+			require.NoError(t, err)
+			before := readClonedFiles(t, dir)
+
+			// Reapplying the patterns with git checks out and removes
+			// nothing: it selects the same files.
+			cmd := exec.Command("git", "sparse-checkout", "reapply")
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1")
+			out, err := cmd.CombinedOutput()
+			require.NoError(t, err, "%s", out)
+
+			after := readClonedFiles(t, dir)
+			for name := range after {
+				if strings.HasPrefix(name, ".git/") {
+					delete(after, name)
+					delete(before, name)
+				}
+			}
+			require.Equal(t, before, after)
+		}
+	})
+}
@@ -1,8 +1,21 @@
 package nanogit
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
 	"github.com/stretchr/testify/require"
 )
 
@@ -1046,3 +1059,647 @@ func TestShouldIncludePath_RealWorldScenarios(t *testing.T) {
 		})
 	}
 }
+
+func TestClone_WritesFiles(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepo(t)
+	commit := repo.commit("initial", map[string]string{
+		"README.md":        "readme",
+		"docs/guide.md":    "guide",
+		"docs/copy.md":     "guide",
+		"src/main.go":      "package main",
+		"src/vendor/x.go":  "package x",
+		"assets/logo.txt":  "logo",
+		"assets/other.txt": "other",
+	})
+
+	for _, opts := range []CloneOptions{
+		{},
+		{BatchSize: 3},
+		{BatchSize: 2, Concurrency: 4},
+	} {
+		dir := t.TempDir()
+		opts.Path = dir
+		opts.Hash = commit
+		opts.ExcludePaths = []string{"src/vendor/**"}
+
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+
+		for path, content := range map[string]string{
+			"README.md":     "readme",
+			"docs/guide.md": "guide",
+			"docs/copy.md":  "guide",
+			"src/main.go":   "package main",
+		} {
+			data, err := os.ReadFile(filepath.Join(dir, path))
+			require.NoError(t, err)
+			require.Equal(t, content, string(data))
+		}
+		require.NoFileExists(t, filepath.Join(dir, "src/vendor/x.go"))
+	}
+}
+
+// tarFS is a write-only WritableFS that streams a clone into a tar archive.
+type tarFS struct {
+	w *tar.Writer
+}
+
+func (t *tarFS) MkdirAll(name string, perm fs.FileMode) error {
+	return t.w.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: int64(perm)})
+}
+
+func (t *tarFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	return &tarFile{fsys: t, name: name, perm: perm}, nil
+}
+
+func (t *tarFS) Symlink(oldname, newname string) error {
+	return t.w.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: newname, Linkname: oldname, Mode: 0777})
+}
+
+// tarFile buffers a file until it is closed, when its size is known.
+type tarFile struct {
+	bytes.Buffer
+	fsys *tarFS
+	name string
+	perm fs.FileMode
+}
+
+func (f *tarFile) Close() error {
+	if err := f.fsys.w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Mode: int64(f.perm), Size: int64(f.Len())}); err != nil {
+		return err
+	}
+	_, err := f.fsys.w.Write(f.Bytes())
+	return err
+}
+
+func TestClone_WriteOnlyFS(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepo(t)
+	commit := repo.commit("initial", map[string]string{
+		"README.md":     "readme",
+		"docs/guide.md": "guide",
+	})
+
+	var archive bytes.Buffer
+	fsys := &tarFS{w: tar.NewWriter(&archive)}
+	_, err := repo.client().Clone(context.Background(), CloneOptions{FS: fsys, Hash: commit})
+	require.NoError(t, err)
+	require.NoError(t, fsys.w.Close())
+
+	files := make(map[string]string)
+	r := tar.NewReader(&archive)
+	for {
+		header, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeReg {
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			files[header.Name] = string(data)
+		}
+	}
+	require.Equal(t, map[string]string{"README.md": "readme", "docs/guide.md": "guide"}, files)
+
+	// Options that read back an earlier clone need an UpdatableFS.
+	for _, opts := range []CloneOptions{
+		{Resumable: true},
+		{ManifestPath: "clone.json"},
+		{Previous: commit},
+		{InitRepository: true},
+	} {
+		opts.FS = fsys
+		opts.Hash = commit
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.ErrorContains(t, err, "does not implement UpdatableFS")
+	}
+}
+
+// readClonedFiles returns the files under dir as path -> content.
+func readClonedFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	return readFSFiles(t, os.DirFS(dir))
+}
+
+// readFSFiles returns the files of fsys as path -> content.
+func readFSFiles(t *testing.T, fsys fs.FS) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		files[p] = string(data)
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestClone_Incremental(t *testing.T) {
+	t.Parallel()
+
+	newHistory := func(t *testing.T) (repo *testRepo, c1, c2 hash.Hash) {
+		t.Helper()
+		repo = newTestRepo(t)
+		c1 = repo.commit("first", map[string]string{
+			"README.md":              "readme",
+			"docs/a.md":              "a",
+			"docs/b.md":              "b",
+			"old/name.txt":           "moved",
+			"vendor/x.go":            "x",
+			"tree-to-file/inner.txt": "inner",
+			"file-to-tree":           "file",
+		})
+		c2 = repo.commit("second", map[string]string{
+			"README.md":              "readme v2",
+			"docs/a.md":              "a",
+			"new/name.txt":           "moved",
+			"added.txt":              "added",
+			"vendor/x.go":            "x v2",
+			"tree-to-file":           "file",
+			"file-to-tree/inner.txt": "inner",
+		}, c1)
+		return repo, c1, c2
+	}
+
+	want := map[string]string{
+		"README.md":              "readme v2",
+		"docs/a.md":              "a",
+		"new/name.txt":           "moved",
+		"added.txt":              "added",
+		"tree-to-file":           "file",
+		"file-to-tree/inner.txt": "inner",
+	}
+
+	t.Run("manifest", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, c2 := newHistory(t)
+		dir := t.TempDir()
+		manifest := filepath.Join(t.TempDir(), "clone.json")
+		opts := CloneOptions{Path: dir, Hash: c1, ManifestPath: manifest, ExcludePaths: []string{"vendor/**"}}
+
+		result, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.Equal(t, hash.Zero, result.Previous)
+		require.Nil(t, result.Changes)
+
+		fetches := len(repo.fetches)
+		opts.Hash = c2
+		result, err = repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.Equal(t, c1, result.Previous)
+		require.Equal(t, want, readClonedFiles(t, dir))
+		require.NoDirExists(t, filepath.Join(dir, "old"))
+
+		var changed []string
+		for _, change := range result.Changes {
+			changed = append(changed, string(change.Status)+" "+change.Path)
+		}
+		require.Equal(t, []string{
+			"M README.md",
+			"A added.txt",
+			"D docs/b.md",
+			"R file-to-tree/inner.txt",
+			"R new/name.txt",
+			"M tree-to-file",
+		}, changed)
+
+		// Only the added and modified blobs were fetched for the update.
+		var blobWants []hash.Hash
+		for _, wants := range repo.fetches[fetches:] {
+			for _, w := range wants {
+				if obj := repo.objects[w.String()]; obj != nil && obj.Type == protocol.ObjectTypeBlob {
+					blobWants = append(blobWants, w)
+				}
+			}
+		}
+		require.ElementsMatch(t, []hash.Hash{repo.blob("readme v2"), repo.blob("added"), repo.blob("file")}, blobWants)
+	})
+
+	t.Run("previous commit", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, c2 := newHistory(t)
+		dir := t.TempDir()
+		opts := CloneOptions{Path: dir, Hash: c1, ExcludePaths: []string{"vendor/**"}}
+
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+
+		opts.Hash, opts.Previous = c2, c1
+		_, err = repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.Equal(t, want, readClonedFiles(t, dir))
+	})
+
+	t.Run("changed filters remove files no longer included", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, c2 := newHistory(t)
+		dir := t.TempDir()
+		manifest := filepath.Join(t.TempDir(), "clone.json")
+
+		_, err := repo.client().Clone(context.Background(), CloneOptions{Path: dir, Hash: c1, ManifestPath: manifest})
+		require.NoError(t, err)
+
+		_, err = repo.client().Clone(context.Background(), CloneOptions{
+			Path:         dir,
+			Hash:         c2,
+			ManifestPath: manifest,
+			ExcludePaths: []string{"vendor/**", "docs/**"},
+		})
+		require.NoError(t, err)
+
+		expected := maps.Clone(want)
+		delete(expected, "docs/a.md")
+		require.Equal(t, expected, readClonedFiles(t, dir))
+	})
+
+	t.Run("in-memory filesystem", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, c2 := newHistory(t)
+		fsys := NewInMemoryFS()
+		opts := CloneOptions{FS: fsys, Path: "clone", Hash: c1, ManifestPath: "clone.json", ExcludePaths: []string{"vendor/**"}}
+
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+
+		opts.Hash = c2
+		result, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.Equal(t, c1, result.Previous)
+
+		sub, err := fs.Sub(fsys, "clone")
+		require.NoError(t, err)
+		require.Equal(t, want, readFSFiles(t, sub))
+
+		manifest, err := fs.ReadFile(fsys, "clone.json")
+		require.NoError(t, err)
+		require.Contains(t, string(manifest), c2.String())
+	})
+
+	t.Run("missing directory falls back to a full clone", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, c2 := newHistory(t)
+		dir := filepath.Join(t.TempDir(), "clone")
+
+		result, err := repo.client().Clone(context.Background(), CloneOptions{
+			Path:         dir,
+			Hash:         c2,
+			Previous:     c1,
+			ExcludePaths: []string{"vendor/**"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, hash.Zero, result.Previous)
+		require.Equal(t, want, readClonedFiles(t, dir))
+	})
+}
+
+func TestClone_InitRepository(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+
+	// git runs the git binary in dir, isolated from any user or system config.
+	git := func(t *testing.T, dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_CONFIG_GLOBAL="+os.DevNull,
+			"GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %s: %s", strings.Join(args, " "), out)
+		return strings.TrimSpace(string(out))
+	}
+
+	newHistory := func(t *testing.T) (repo *testRepo, c1, c2 hash.Hash) {
+		t.Helper()
+		repo = newTestRepo(t)
+		c1 = repo.commit("first", map[string]string{
+			"README.md":       "readme",
+			"docs/guide.md":   "guide",
+			"src/main.go":     "package main",
+			"vendor/x/x.go":   "package x",
+			"vendor/y.go":     "package y",
+			"a-b.txt":         "sorts before a/",
+			"a/nested/c.txt":  "nested",
+			"docs/same-1.txt": "same",
+			"docs/same-2.txt": "same",
+		})
+		c2 = repo.commit("second", map[string]string{
+			"README.md":       "readme v2",
+			"docs/guide.md":   "guide",
+			"src/main.go":     "package main",
+			"vendor/x/x.go":   "package x v2",
+			"vendor/y.go":     "package y",
+			"a-b.txt":         "sorts before a/",
+			"a/nested/c.txt":  "nested",
+			"docs/same-1.txt": "same",
+			"docs/same-2.txt": "same",
+		}, c1)
+		return repo, c1, c2
+	}
+
+	t.Run("git can work with the clone", func(t *testing.T) {
+		t.Parallel()
+		repo, _, c2 := newHistory(t)
+		dir := t.TempDir()
+
+		result, err := repo.client().Clone(context.Background(), CloneOptions{
+			Path:           dir,
+			Hash:           c2,
+			InitRepository: true,
+			Branch:         "main",
+		})
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, ".git"), result.GitDir)
+		require.Equal(t, c2.String()+"\n", readFile(t, filepath.Join(dir, ".git", "shallow")))
+
+		git(t, dir, "fsck", "--strict")
+		require.Empty(t, git(t, dir, "status", "--porcelain"))
+		require.Equal(t, "main", git(t, dir, "symbolic-ref", "--short", "HEAD"))
+		require.Equal(t, c2.String()+" second", git(t, dir, "log", "--format=%H %s"))
+		require.Equal(t, "readme v2", git(t, dir, "show", "HEAD:README.md"))
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme v3"), 0644))
+		require.Equal(t, "M README.md", git(t, dir, "status", "--porcelain"))
+		git(t, dir, "commit", "-qam", "third")
+		require.Equal(t, c2.String(), git(t, dir, "rev-parse", "HEAD~1"))
+		require.Empty(t, git(t, dir, "status", "--porcelain"))
+	})
+
+	t.Run("detached HEAD without a branch", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, _ := newHistory(t)
+		dir := t.TempDir()
+
+		_, err := repo.client().Clone(context.Background(), CloneOptions{Path: dir, Hash: c1, InitRepository: true})
+		require.NoError(t, err)
+		require.NoFileExists(t, filepath.Join(dir, ".git", "shallow"))
+		require.Equal(t, c1.String(), git(t, dir, "rev-parse", "HEAD"))
+		require.Empty(t, git(t, dir, "status", "--porcelain"))
+	})
+
+	t.Run("excluded files are skipped in the index", func(t *testing.T) {
+		t.Parallel()
+		repo, _, c2 := newHistory(t)
+		dir := t.TempDir()
+
+		_, err := repo.client().Clone(context.Background(), CloneOptions{
+			Path:           dir,
+			Hash:           c2,
+			ExcludePaths:   []string{"vendor/**"},
+			InitRepository: true,
+			Branch:         "main",
+		})
+		require.NoError(t, err)
+		require.NoFileExists(t, filepath.Join(dir, "vendor", "y.go"))
+
+		require.Empty(t, git(t, dir, "status", "--porcelain"))
+		require.Contains(t, git(t, dir, "ls-files", "-t"), "S vendor/x/x.go")
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n"), 0644))
+		git(t, dir, "commit", "-qam", "third")
+		require.Empty(t, git(t, dir, "status", "--porcelain"))
+		require.Equal(t, repo.blob("package x v2").String(), git(t, dir, "rev-parse", "HEAD:vendor/x/x.go"))
+	})
+
+	t.Run("confined filesystem", func(t *testing.T) {
+		t.Parallel()
+		repo, _, c2 := newHistory(t)
+		dir := t.TempDir()
+		fsys, err := NewOSFS(dir)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = fsys.Close() })
+
+		result, err := repo.client().Clone(context.Background(), CloneOptions{FS: fsys, Hash: c2, InitRepository: true})
+		require.NoError(t, err)
+		require.Equal(t, ".git", result.GitDir)
+
+		git(t, dir, "fsck")
+		require.Empty(t, git(t, dir, "status", "--porcelain"))
+	})
+
+	t.Run("incremental update", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, c2 := newHistory(t)
+		dir := t.TempDir()
+		manifest := filepath.Join(t.TempDir(), "clone.json")
+		opts := CloneOptions{Path: dir, Hash: c1, ManifestPath: manifest, InitRepository: true, Branch: "main"}
+
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+
+		opts.Hash = c2
+		result, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.Equal(t, c1, result.Previous)
+
+		git(t, dir, "fsck")
+		require.Empty(t, git(t, dir, "status", "--porcelain"))
+		require.Equal(t, c2.String(), git(t, dir, "rev-parse", "HEAD"))
+	})
+
+	t.Run("symbolic links and executable files", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("special", map[string]string{
+			"bin/run.sh":   "exec:#!/bin/sh",
+			"docs/link.md": "symlink:../README.md",
+			"README.md":    "readme",
+		})
+		dir := t.TempDir()
+
+		_, err := repo.client().Clone(context.Background(), CloneOptions{Path: dir, Hash: commit, InitRepository: true, Branch: "main"})
+		require.NoError(t, err)
+
+		git(t, dir, "fsck", "--strict")
+		require.Empty(t, git(t, dir, "status", "--porcelain"))
+		require.Equal(t, "100644 README.md\n100755 bin/run.sh\n120000 docs/link.md",
+			git(t, dir, "ls-files", "--format=%(objectmode) %(path)"))
+	})
+}
+
+func TestClone_SpecialFiles(t *testing.T) {
+	t.Parallel()
+
+	newHistory := func(t *testing.T) (repo *testRepo, c1, c2 hash.Hash) {
+		t.Helper()
+		repo = newTestRepo(t)
+		c1 = repo.commit("first", map[string]string{
+			"README.md":          "readme",
+			"bin/run.sh":         "exec:#!/bin/sh",
+			"bin/tool":           "exec:tool",
+			"docs/readme-link":   "symlink:../README.md",
+			"link-to-file":       "symlink:README.md",
+			"file-to-link":       "file",
+			"docs/notes/old.txt": "notes",
+		})
+		c2 = repo.commit("second", map[string]string{
+			"README.md":          "readme v2",
+			"bin/run.sh":         "#!/bin/sh",
+			"bin/tool":           "exec:tool",
+			"docs/readme-link":   "symlink:../README.md",
+			"link-to-file":       "file",
+			"file-to-link":       "symlink:README.md",
+			"notes-link":         "symlink:docs/notes",
+			"docs/notes/old.txt": "notes",
+		}, c1)
+		return repo, c1, c2
+	}
+
+	requireLink := func(t *testing.T, name, target string) {
+		t.Helper()
+		got, err := os.Readlink(name)
+		require.NoError(t, err)
+		require.Equal(t, target, got)
+	}
+	requireExecutable := func(t *testing.T, name string, executable bool) {
+		t.Helper()
+		info, err := os.Lstat(name)
+		require.NoError(t, err)
+		require.True(t, info.Mode().IsRegular())
+		require.Equal(t, executable, info.Mode()&0o111 != 0)
+	}
+
+	t.Run("writes modes and summarizes them", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, _ := newHistory(t)
+		dir := t.TempDir()
+
+		result, err := repo.client().Clone(context.Background(), CloneOptions{Path: dir, Hash: c1, SafeSymlinks: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{"docs/readme-link", "link-to-file"}, result.SpecialFiles.Symlinks)
+		require.Equal(t, []string{"bin/run.sh", "bin/tool"}, result.SpecialFiles.Executables)
+		require.Empty(t, result.SpecialFiles.Submodules)
+
+		requireLink(t, filepath.Join(dir, "docs", "readme-link"), "../README.md")
+		require.Equal(t, "readme", readFile(t, filepath.Join(dir, "docs", "readme-link")))
+		requireExecutable(t, filepath.Join(dir, "bin", "run.sh"), true)
+		requireExecutable(t, filepath.Join(dir, "README.md"), false)
+	})
+
+	t.Run("incremental update applies mode changes", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, c2 := newHistory(t)
+		dir := t.TempDir()
+		manifest := filepath.Join(t.TempDir(), "clone.json")
+		opts := CloneOptions{Path: dir, Hash: c1, ManifestPath: manifest}
+
+		_, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+
+		opts.Hash = c2
+		result, err := repo.client().Clone(context.Background(), opts)
+		require.NoError(t, err)
+		require.Equal(t, c1, result.Previous)
+
+		var changed []string
+		for _, change := range result.Changes {
+			changed = append(changed, string(change.Status)+" "+change.Path)
+		}
+		require.Equal(t, []string{
+			"M README.md",
+			"M bin/run.sh",
+			"M file-to-link",
+			"M link-to-file",
+			"A notes-link",
+		}, changed)
+
+		requireExecutable(t, filepath.Join(dir, "bin", "run.sh"), false)
+		requireExecutable(t, filepath.Join(dir, "bin", "tool"), true)
+		requireExecutable(t, filepath.Join(dir, "link-to-file"), false)
+		require.Equal(t, "file", readFile(t, filepath.Join(dir, "link-to-file")))
+		requireLink(t, filepath.Join(dir, "file-to-link"), "README.md")
+		requireLink(t, filepath.Join(dir, "notes-link"), "docs/notes")
+		require.Equal(t, "readme v2", readFile(t, filepath.Join(dir, "README.md")))
+	})
+
+	t.Run("safe mode rejects escaping links", func(t *testing.T) {
+		t.Parallel()
+		for _, target := range []string{"/etc/passwd", "../../outside", "docs/../../outside", "docs/x/../..", ""} {
+			repo := newTestRepo(t)
+			commit := repo.commit("escape", map[string]string{
+				"README.md":   "readme",
+				"docs/escape": "symlink:" + target,
+			})
+
+			_, err := repo.client().Clone(context.Background(), CloneOptions{Path: t.TempDir(), Hash: commit, SafeSymlinks: true})
+			var unsafe *UnsafeSymlinkError
+			require.ErrorAs(t, err, &unsafe, "target %q", target)
+			require.Equal(t, "docs/escape", unsafe.Path)
+			require.Equal(t, target, unsafe.Target)
+
+			// Without the safe mode the link is written as is.
+			dir := t.TempDir()
+			_, err = repo.client().Clone(context.Background(), CloneOptions{Path: dir, Hash: commit})
+			if target == "" {
+				// An empty target cannot be created on disk.
+				require.Error(t, err)
+				continue
+			}
+			require.NoError(t, err)
+			requireLink(t, filepath.Join(dir, "docs", "escape"), target)
+		}
+	})
+
+	t.Run("in-memory filesystem", func(t *testing.T) {
+		t.Parallel()
+		repo, c1, _ := newHistory(t)
+		fsys := NewInMemoryFS()
+
+		_, err := repo.client().Clone(context.Background(), CloneOptions{FS: fsys, Hash: c1, SafeSymlinks: true})
+		require.NoError(t, err)
+
+		target, err := fsys.ReadLink("docs/readme-link")
+		require.NoError(t, err)
+		require.Equal(t, "../README.md", target)
+		data, err := fs.ReadFile(fsys, "docs/readme-link")
+		require.NoError(t, err)
+		require.Equal(t, "readme", string(data))
+		info, err := fsys.Lstat("bin/run.sh")
+		require.NoError(t, err)
+		require.Equal(t, fs.FileMode(0755), info.Mode())
+	})
+}
+
+func TestIsSafeSymlink(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		link, target string
+		safe         bool
+	}{
+		{"link", "README.md", true},
+		{"link", "./docs/guide.md", true},
+		{"docs/link", "../README.md", true},
+		{"a/b/link", "../../c/d", true},
+		{"link", "../README.md", false},
+		{"a/b/link", "../../../c", false},
+		{"link", "docs/../README.md", false},
+		{"link", "/etc/passwd", false},
+		{"link", "", false},
+	} {
+		require.Equal(t, tt.safe, isSafeSymlink(tt.link, tt.target), "%s -> %s", tt.link, tt.target)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return string(data)
+}
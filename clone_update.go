@@ -0,0 +1,352 @@
+package nanogit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// cloneManifest is the content of CloneOptions.ManifestPath.
+type cloneManifest struct {
+	Commit         string   `json:"commit"`
+	IncludePaths   []string `json:"include_paths,omitempty"`
+	ExcludePaths   []string `json:"exclude_paths,omitempty"`
+	SparsePatterns []string `json:"sparse_patterns,omitempty"`
+	ConeMode       bool     `json:"cone_mode,omitempty"`
+}
+
+// previousClone describes the clone an incremental Clone starts from.
+type previousClone struct {
+	commit         hash.Hash
+	includePaths   []string
+	excludePaths   []string
+	sparsePatterns []string
+	coneMode       bool
+	// fromManifest is set when the filters above were read from a manifest.
+	// With CloneOptions.Previous the filters are assumed to be unchanged.
+	fromManifest bool
+}
+
+// filtersChanged reports whether the previous clone used different filters.
+func (p *previousClone) filtersChanged(opts CloneOptions) bool {
+	return p.fromManifest &&
+		(!slices.Equal(p.includePaths, opts.IncludePaths) || !slices.Equal(p.excludePaths, opts.ExcludePaths) ||
+			!slices.Equal(p.sparsePatterns, opts.SparsePatterns) || p.coneMode != opts.ConeMode)
+}
+
+// previousClone returns the clone to update from, or nil when Clone must
+// write a full snapshot.
+func (c *httpClient) previousClone(ctx context.Context, opts CloneOptions) (*previousClone, error) {
+	if opts.Previous == hash.Zero && opts.ManifestPath == "" {
+		return nil, nil
+	}
+
+	logger := log.FromContext(ctx)
+	fsys := opts.FS.(UpdatableFS)
+	if _, err := fsys.Lstat(opts.Path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Debug("Clone path does not exist, writing full snapshot", "path", opts.Path)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("stat clone path %s: %w", opts.Path, err)
+	}
+
+	if opts.Previous != hash.Zero {
+		return &previousClone{commit: opts.Previous}, nil
+	}
+
+	data, err := fsys.ReadFile(opts.ManifestPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Debug("No clone manifest, writing full snapshot", "manifest_path", opts.ManifestPath)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read clone manifest %s: %w", opts.ManifestPath, err)
+	}
+
+	var manifest cloneManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parse clone manifest %s: %w", opts.ManifestPath, err)
+	}
+	commit, err := hash.FromHex(manifest.Commit)
+	if err != nil {
+		return nil, fmt.Errorf("parse clone manifest %s: invalid commit: %w", opts.ManifestPath, err)
+	}
+
+	logger.Debug("Read clone manifest",
+		"manifest_path", opts.ManifestPath,
+		"previous_hash", commit.String())
+
+	return &previousClone{
+		commit:         commit,
+		includePaths:   manifest.IncludePaths,
+		excludePaths:   manifest.ExcludePaths,
+		sparsePatterns: manifest.SparsePatterns,
+		coneMode:       manifest.ConeMode,
+		fromManifest:   true,
+	}, nil
+}
+
+// writeCloneManifest records the cloned commit and filters, replacing the
+// manifest atomically so an interrupted write never leaves a corrupt one.
+func writeCloneManifest(fsys UpdatableFS, manifestPath string, commit hash.Hash, opts CloneOptions) error {
+	data, err := json.MarshalIndent(cloneManifest{
+		Commit:         commit.String(),
+		IncludePaths:   opts.IncludePaths,
+		ExcludePaths:   opts.ExcludePaths,
+		SparsePatterns: opts.SparsePatterns,
+		ConeMode:       opts.ConeMode,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode clone manifest: %w", err)
+	}
+
+	if err := writeFileAtomic(fsys, manifestPath, data, 0644); err != nil {
+		return fmt.Errorf("write clone manifest %s: %w", manifestPath, err)
+	}
+	return nil
+}
+
+// updateFilesOnDisk brings a directory holding a clone of previous up to date
+// with head. Deletions are applied first, so a file replaced by a directory
+// (or the reverse) does not get in the way, then renames, then the added and
+// modified files are fetched and written. Only changes to included paths are
+// applied; they are returned as applied.
+func (c *httpClient) updateFilesOnDisk(ctx context.Context, opts CloneOptions, filter *clonePathFilter, previous, head hash.Hash) ([]CommitFile, error) {
+	logger := log.FromContext(ctx)
+	fsys := opts.FS.(UpdatableFS)
+
+	changes, err := c.CompareCommits(ctx, previous, head, WithRenameDetection())
+	if err != nil {
+		return nil, fmt.Errorf("compare %s with %s: %w", previous.String(), head.String(), err)
+	}
+
+	included := filter.includes
+
+	var (
+		applied      []CommitFile
+		renames      []CommitFile
+		replacedDirs []string
+		toWrite      = &FlatTree{}
+		addWrite     = func(change CommitFile) {
+			toWrite.Entries = append(toWrite.Entries, FlatTreeEntry{
+				Name: path.Base(change.Path),
+				Path: change.Path,
+				Mode: change.Mode,
+				Hash: change.Hash,
+				Type: change.Type,
+			})
+		}
+	)
+
+	for _, change := range changes {
+		switch change.Status {
+		case protocol.FileStatusDeleted:
+			if change.Type != protocol.ObjectTypeBlob || !included(change.Path) {
+				continue
+			}
+			if err := removeClonedFile(fsys, opts.Path, change.Path); err != nil {
+				return nil, err
+			}
+			applied = append(applied, change)
+
+		case protocol.FileStatusRenamed:
+			if change.Type != protocol.ObjectTypeBlob {
+				continue
+			}
+			oldIncluded, newIncluded := included(change.OldPath), included(change.Path)
+			switch {
+			case oldIncluded && newIncluded:
+				renames = append(renames, change)
+			case oldIncluded:
+				if err := removeClonedFile(fsys, opts.Path, change.OldPath); err != nil {
+					return nil, err
+				}
+				applied = append(applied, CommitFile{
+					Path:    change.OldPath,
+					Mode:    change.OldMode,
+					Hash:    change.OldHash,
+					Type:    change.OldType,
+					OldHash: change.OldHash,
+					OldType: change.OldType,
+					Status:  protocol.FileStatusDeleted,
+				})
+			case newIncluded:
+				addWrite(change)
+				applied = append(applied, CommitFile{
+					Path:   change.Path,
+					Mode:   change.Mode,
+					Hash:   change.Hash,
+					Type:   change.Type,
+					Status: protocol.FileStatusAdded,
+				})
+			}
+
+		default:
+			if change.Type != protocol.ObjectTypeBlob || !included(change.Path) {
+				continue
+			}
+			if change.OldType == protocol.ObjectTypeTree {
+				replacedDirs = append(replacedDirs, change.Path)
+			}
+			addWrite(change)
+			applied = append(applied, change)
+		}
+	}
+
+	for _, change := range renames {
+		if change.Mode == 0o120000 && opts.SafeSymlinks {
+			// A relative link may lead elsewhere from its new directory.
+			target, err := fsys.ReadLink(path.Join(opts.Path, change.OldPath))
+			if err == nil && !isSafeSymlink(change.Path, target) {
+				return nil, NewUnsafeSymlinkError(change.Path, target)
+			}
+		}
+		moved, err := renameClonedFile(fsys, opts.Path, change.OldPath, change.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !moved {
+			// The old file is gone from disk; fetch the content instead.
+			addWrite(change)
+		}
+		applied = append(applied, change)
+	}
+
+	// A directory that became a file must go, once renames have moved
+	// anything out of it.
+	for _, dir := range replacedDirs {
+		if err := fsys.RemoveAll(path.Join(opts.Path, dir)); err != nil {
+			return nil, fmt.Errorf("remove directory %s: %w", dir, err)
+		}
+	}
+
+	logger.Debug("Apply incremental clone changes",
+		"previous_hash", previous.String(),
+		"head_hash", head.String(),
+		"change_count", len(changes),
+		"applied_count", len(applied),
+		"write_count", len(toWrite.Entries))
+
+	if err := c.writeFilesToDisk(ctx, opts, toWrite); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(applied, func(a, b CommitFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return applied, nil
+}
+
+// removeStaleFiles deletes the files of a previous clone that are not part of
+// the new filtered tree, after the include/exclude filters changed.
+func (c *httpClient) removeStaleFiles(ctx context.Context, fsys UpdatableFS, basePath string, previous *previousClone, current *FlatTree) error {
+	oldTree, err := c.GetFlatTree(ctx, previous.commit)
+	if err != nil {
+		return fmt.Errorf("get tree for previous commit %s: %w", previous.commit.String(), err)
+	}
+
+	// The previous filters were valid when the manifest was written.
+	filter, err := c.newClonePathFilter(previous.includePaths, previous.excludePaths, previous.sparsePatterns, previous.coneMode)
+	if err != nil {
+		return fmt.Errorf("previous clone filters: %w", err)
+	}
+
+	keep := make(map[string]bool, len(current.Entries))
+	for _, entry := range current.Entries {
+		keep[entry.Path] = true
+	}
+
+	removed := 0
+	for _, entry := range oldTree.Entries {
+		if entry.Type != protocol.ObjectTypeBlob || keep[entry.Path] {
+			continue
+		}
+		if !filter.includes(entry.Path) {
+			continue
+		}
+		if err := removeClonedFile(fsys, basePath, entry.Path); err != nil {
+			return err
+		}
+		removed++
+	}
+
+	log.FromContext(ctx).Debug("Removed files no longer included",
+		"previous_hash", previous.commit.String(),
+		"removed_count", removed)
+	return nil
+}
+
+// removeClonedFile deletes a file of a clone and the directories it leaves
+// empty. A file that is already gone, or whose directory became a file, is
+// not an error.
+func removeClonedFile(fsys UpdatableFS, basePath, relPath string) error {
+	err := fsys.Remove(path.Join(basePath, relPath))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+		return fmt.Errorf("remove file %s: %w", relPath, err)
+	}
+	pruneEmptyDirs(fsys, basePath, path.Dir(relPath))
+	return nil
+}
+
+// renameClonedFile moves a file of a clone. It returns false, without error,
+// when the old file does not exist.
+func renameClonedFile(fsys UpdatableFS, basePath, oldPath, newPath string) (bool, error) {
+	from, to := path.Join(basePath, oldPath), path.Join(basePath, newPath)
+	if _, err := fsys.Lstat(from); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err := removeFileParents(fsys, basePath, newPath); err != nil {
+		return false, err
+	}
+	if err := fsys.MkdirAll(path.Dir(to), 0755); err != nil {
+		return false, fmt.Errorf("create parent directory for %s: %w", newPath, err)
+	}
+	if err := fsys.Rename(from, to); err != nil {
+		return false, fmt.Errorf("rename %s to %s: %w", oldPath, newPath, err)
+	}
+	pruneEmptyDirs(fsys, basePath, path.Dir(oldPath))
+	return true, nil
+}
+
+// removeFileParents removes any file standing where a parent directory of
+// relPath has to be created, which happens when a file of the previous clone
+// became a directory. Such files are not reported by CompareCommits, which
+// only reports the contents of the new directory.
+func removeFileParents(fsys UpdatableFS, basePath, relPath string) error {
+	for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+		info, err := fsys.Lstat(path.Join(basePath, dir))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if err := fsys.Remove(path.Join(basePath, dir)); err != nil {
+			return fmt.Errorf("remove file %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// pruneEmptyDirs removes dir and its parents, up to but excluding basePath,
+// as long as they are empty.
+func pruneEmptyDirs(fsys UpdatableFS, basePath, dir string) {
+	for dir != "." && dir != "/" && dir != "" {
+		full := path.Join(basePath, dir)
+		if info, err := fsys.Lstat(full); err != nil || !info.IsDir() {
+			return
+		}
+		if err := fsys.Remove(full); err != nil {
+			return
+		}
+		dir = path.Dir(dir)
+	}
+}
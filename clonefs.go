@@ -0,0 +1,756 @@
+package nanogit
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// WritableFS is a filesystem Clone writes to, set with CloneOptions.FS.
+// It only has to accept new directories, files and symbolic links, so a
+// write-only sink such as a tar stream can implement it. Names are
+// slash-separated and each file is written once.
+//
+// Options that read back an earlier clone (CloneOptions.Resumable,
+// ManifestPath, Previous and InitRepository) need an UpdatableFS. OSFS
+// writes to the local filesystem, optionally confined to a directory, and
+// InMemoryFS keeps everything in memory; both implement UpdatableFS.
+type WritableFS interface {
+	// MkdirAll creates a directory and any missing parents.
+	MkdirAll(name string, perm fs.FileMode) error
+	// Create creates or truncates a file for writing. The parent
+	// directory must exist.
+	Create(name string, perm fs.FileMode) (io.WriteCloser, error)
+	// Symlink creates newname as a symbolic link to oldname. It fails if
+	// newname exists.
+	Symlink(oldname, newname string) error
+}
+
+// UpdatableFS is a WritableFS that can also read back and change what it
+// holds, which resuming or updating a clone relies on. Errors should match
+// the os package's: wrap fs.ErrNotExist for missing files,
+// syscall.ENOTDIR when a parent is a file, syscall.EISDIR when writing over
+// a directory and syscall.ENOTEMPTY when removing a non-empty directory.
+type UpdatableFS interface {
+	WritableFS
+	// ReadFile returns the content of a file.
+	ReadFile(name string) ([]byte, error)
+	// Lstat describes a file without following symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+	// Remove removes a file or an empty directory.
+	Remove(name string) error
+	// RemoveAll removes a file or a directory and its contents. A missing
+	// name is not an error.
+	RemoveAll(name string) error
+	// Rename moves a file or directory, replacing an existing file.
+	Rename(oldname, newname string) error
+	// ReadLink returns the target of a symbolic link.
+	ReadLink(name string) (string, error)
+	// Chmod changes the permissions of a file.
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// writeFile writes data to a file of fsys, like os.WriteFile.
+func writeFile(fsys WritableFS, name string, data []byte, perm fs.FileMode) error {
+	f, err := fsys.Create(name, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeFileAtomic writes a file through a .lock file that is renamed in
+// place, like git does, so readers never see it half-written.
+func writeFileAtomic(fsys UpdatableFS, name string, data []byte, perm fs.FileMode) error {
+	lock := name + ".lock"
+	if err := writeFile(fsys, lock, data, perm); err != nil {
+		_ = fsys.Remove(lock)
+		return err
+	}
+	if err := fsys.Rename(lock, name); err != nil {
+		_ = fsys.Remove(lock)
+		return err
+	}
+	return nil
+}
+
+// OSFS is an UpdatableFS on the local filesystem. The zero value resolves
+// names like the os package does, relative to the working directory.
+// NewOSFS confines every name to a directory, rejecting names and symbolic
+// links that escape it.
+type OSFS struct {
+	root *os.Root
+}
+
+// NewOSFS returns an OSFS confined to dir, which must exist. Close it to
+// release the directory handle.
+//
+// Example:
+//
+//	fsys, err := nanogit.NewOSFS("/srv/tenants/acme")
+//	if err != nil {
+//	    return err
+//	}
+//	defer fsys.Close()
+//
+//	_, err = client.Clone(ctx, nanogit.CloneOptions{FS: fsys, Path: "repo", Hash: commitHash})
+func NewOSFS(dir string) (*OSFS, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &OSFS{root: root}, nil
+}
+
+// Close releases the directory of an OSFS created with NewOSFS.
+func (o *OSFS) Close() error {
+	if o.root == nil {
+		return nil
+	}
+	return o.root.Close()
+}
+
+// MkdirAll implements WritableFS.
+func (o *OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	if o.root == nil {
+		return os.MkdirAll(name, perm)
+	}
+	return o.root.MkdirAll(name, perm)
+}
+
+// Create implements WritableFS.
+func (o *OSFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	if o.root == nil {
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	}
+	return o.root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+// ReadFile implements UpdatableFS.
+func (o *OSFS) ReadFile(name string) ([]byte, error) {
+	if o.root == nil {
+		return os.ReadFile(name)
+	}
+	return o.root.ReadFile(name)
+}
+
+// Lstat implements UpdatableFS.
+func (o *OSFS) Lstat(name string) (fs.FileInfo, error) {
+	if o.root == nil {
+		return os.Lstat(name)
+	}
+	return o.root.Lstat(name)
+}
+
+// Remove implements UpdatableFS.
+func (o *OSFS) Remove(name string) error {
+	if o.root == nil {
+		return os.Remove(name)
+	}
+	return o.root.Remove(name)
+}
+
+// RemoveAll implements UpdatableFS.
+func (o *OSFS) RemoveAll(name string) error {
+	if o.root == nil {
+		return os.RemoveAll(name)
+	}
+	return o.root.RemoveAll(name)
+}
+
+// Rename implements UpdatableFS.
+func (o *OSFS) Rename(oldname, newname string) error {
+	if o.root == nil {
+		return os.Rename(oldname, newname)
+	}
+	return o.root.Rename(oldname, newname)
+}
+
+// Symlink implements WritableFS. The target is not validated: a link may
+// point outside the directory of an OSFS created with NewOSFS, but the OSFS
+// does not follow it there.
+func (o *OSFS) Symlink(oldname, newname string) error {
+	if o.root == nil {
+		return os.Symlink(oldname, newname)
+	}
+	return o.root.Symlink(oldname, newname)
+}
+
+// ReadLink implements UpdatableFS.
+func (o *OSFS) ReadLink(name string) (string, error) {
+	if o.root == nil {
+		return os.Readlink(name)
+	}
+	return o.root.Readlink(name)
+}
+
+// Chmod implements UpdatableFS.
+func (o *OSFS) Chmod(name string, mode fs.FileMode) error {
+	if o.root == nil {
+		return os.Chmod(name, mode)
+	}
+	return o.root.Chmod(name, mode)
+}
+
+// InMemoryFS is an UpdatableFS that keeps files in memory. It also implements
+// fs.FS, fs.ReadFileFS, fs.ReadDirFS, fs.StatFS and fs.ReadLinkFS, so a
+// clone can be read back with the io/fs helpers. Names must be valid io/fs
+// paths (see fs.ValidPath), so CloneOptions.Path must be relative. Symbolic
+// links are followed when they are the last element of a name, and links
+// leading outside the filesystem are treated as missing. It is safe for
+// concurrent use.
+type InMemoryFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+	// children indexes the names of the nodes in each directory.
+	children map[string]map[string]struct{}
+}
+
+// memNode is a file, directory or symbolic link of an InMemoryFS. The data
+// of a symbolic link is its target.
+type memNode struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewInMemoryFS returns an empty InMemoryFS.
+//
+// Example:
+//
+//	fsys := nanogit.NewInMemoryFS()
+//	_, err := client.Clone(ctx, nanogit.CloneOptions{FS: fsys, Hash: commitHash})
+//	if err != nil {
+//	    return err
+//	}
+//	readme, err := fs.ReadFile(fsys, "README.md")
+func NewInMemoryFS() *InMemoryFS {
+	return &InMemoryFS{
+		nodes:    map[string]*memNode{".": {mode: fs.ModeDir | 0755, modTime: time.Now()}},
+		children: make(map[string]map[string]struct{}),
+	}
+}
+
+// put stores node at name, which is not ".". Callers hold the lock.
+func (m *InMemoryFS) put(name string, node *memNode) {
+	if _, ok := m.nodes[name]; !ok {
+		dir := path.Dir(name)
+		if m.children[dir] == nil {
+			m.children[dir] = make(map[string]struct{})
+		}
+		m.children[dir][path.Base(name)] = struct{}{}
+	}
+	m.nodes[name] = node
+}
+
+// drop removes the node at name, which is not ".", but not its children.
+// Callers hold the lock.
+func (m *InMemoryFS) drop(name string) {
+	delete(m.nodes, name)
+	delete(m.children, name)
+	dir := path.Dir(name)
+	delete(m.children[dir], path.Base(name))
+	if len(m.children[dir]) == 0 {
+		delete(m.children, dir)
+	}
+}
+
+// descendants returns the names of the nodes below dir, parents first.
+// Callers hold the lock.
+func (m *InMemoryFS) descendants(dir string) []string {
+	var names []string
+	queue := []string{dir}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for child := range m.children[current] {
+			name := path.Join(current, child)
+			names = append(names, name)
+			queue = append(queue, name)
+		}
+	}
+	return names
+}
+
+// checkMemPath returns an error unless name is a valid io/fs path.
+func checkMemPath(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// checkParent returns an error unless the parent of name is a directory.
+// Callers hold the lock.
+func (m *InMemoryFS) checkParent(op, name string) error {
+	parent := path.Dir(name)
+	node, ok := m.nodes[parent]
+	if !ok {
+		if err := m.checkParent(op, parent); err != nil {
+			return err
+		}
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !node.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+// lookup returns the node at name. Callers hold the lock.
+func (m *InMemoryFS) lookup(op, name string) (*memNode, error) {
+	if node, ok := m.nodes[name]; ok {
+		return node, nil
+	}
+	if name != "." {
+		if err := m.checkParent(op, name); err != nil {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// memMaxLinks is how many symbolic links an InMemoryFS follows to resolve a
+// name, like the limit of Linux.
+const memMaxLinks = 40
+
+// follow resolves name while its last element is a symbolic link. It
+// returns the name it leads to and its node, which is nil when the name
+// does not exist. Callers hold the lock.
+func (m *InMemoryFS) follow(op, name string) (string, *memNode, error) {
+	target := name
+	for range memMaxLinks {
+		node, ok := m.nodes[target]
+		if !ok || node.mode&fs.ModeSymlink == 0 {
+			return target, node, nil
+		}
+		link := string(node.data)
+		if !path.IsAbs(link) {
+			link = path.Join(path.Dir(target), link)
+		}
+		if !fs.ValidPath(link) {
+			return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		target = link
+	}
+	return "", nil, &fs.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+}
+
+// resolve is like lookup but follows symbolic links. Callers hold the lock.
+func (m *InMemoryFS) resolve(op, name string) (string, *memNode, error) {
+	target, node, err := m.follow(op, name)
+	if err != nil {
+		return "", nil, err
+	}
+	if node == nil {
+		_, err := m.lookup(op, target)
+		return "", nil, err
+	}
+	return target, node, nil
+}
+
+// MkdirAll implements WritableFS.
+func (m *InMemoryFS) MkdirAll(name string, perm fs.FileMode) error {
+	if err := checkMemPath("mkdir", name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var missing []string
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		node, ok := m.nodes[dir]
+		if !ok {
+			missing = append(missing, dir)
+			continue
+		}
+		if !node.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+		break
+	}
+	now := time.Now()
+	for _, dir := range slices.Backward(missing) {
+		m.put(dir, &memNode{mode: fs.ModeDir | perm.Perm(), modTime: now})
+	}
+	return nil
+}
+
+// Create implements WritableFS. The content becomes visible when the
+// returned writer is closed. Like os.Create, it writes through a symbolic
+// link.
+func (m *InMemoryFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	if err := checkMemPath("open", name); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	target, _, err := m.follow("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkCreate(target); err != nil {
+		return nil, err
+	}
+	return &memWriter{fsys: m, name: target, perm: perm.Perm()}, nil
+}
+
+// checkCreate returns an error unless a file can be written at name.
+// Callers hold the lock.
+func (m *InMemoryFS) checkCreate(name string) error {
+	if name == "." {
+		return &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+	if err := m.checkParent("open", name); err != nil {
+		return err
+	}
+	if node, ok := m.nodes[name]; ok && node.mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+	return nil
+}
+
+// memWriter buffers the content of a file being written to an InMemoryFS.
+type memWriter struct {
+	fsys *InMemoryFS
+	name string
+	perm fs.FileMode
+	buf  bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memWriter) Close() error {
+	w.fsys.mu.Lock()
+	defer w.fsys.mu.Unlock()
+
+	if err := w.fsys.checkCreate(w.name); err != nil {
+		return err
+	}
+	mode := w.perm
+	if existing, ok := w.fsys.nodes[w.name]; ok {
+		// Like os.OpenFile, truncating keeps the permissions of the file.
+		mode = existing.mode
+	}
+	w.fsys.put(w.name, &memNode{data: w.buf.Bytes(), mode: mode, modTime: time.Now()})
+	return nil
+}
+
+// ReadFile implements UpdatableFS and fs.ReadFileFS.
+func (m *InMemoryFS) ReadFile(name string) ([]byte, error) {
+	if err := checkMemPath("open", name); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, node, err := m.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return slices.Clone(node.data), nil
+}
+
+// Lstat implements UpdatableFS.
+func (m *InMemoryFS) Lstat(name string) (fs.FileInfo, error) {
+	if err := checkMemPath("lstat", name); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, err := m.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memFileInfo{name: path.Base(name), node: node}, nil
+}
+
+// Stat implements fs.StatFS.
+func (m *InMemoryFS) Stat(name string) (fs.FileInfo, error) {
+	if err := checkMemPath("stat", name); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, node, err := m.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memFileInfo{name: path.Base(name), node: node}, nil
+}
+
+// Symlink implements WritableFS. The target is not validated.
+func (m *InMemoryFS) Symlink(oldname, newname string) error {
+	if err := checkMemPath("symlink", newname); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.nodes[newname]; ok {
+		return &fs.PathError{Op: "symlink", Path: newname, Err: fs.ErrExist}
+	}
+	if err := m.checkParent("symlink", newname); err != nil {
+		return err
+	}
+	m.put(newname, &memNode{data: []byte(oldname), mode: fs.ModeSymlink | 0777, modTime: time.Now()})
+	return nil
+}
+
+// ReadLink implements UpdatableFS and fs.ReadLinkFS.
+func (m *InMemoryFS) ReadLink(name string) (string, error) {
+	if err := checkMemPath("readlink", name); err != nil {
+		return "", err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, err := m.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if node.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return string(node.data), nil
+}
+
+// Chmod implements UpdatableFS. Like os.Chmod, it follows symbolic links.
+func (m *InMemoryFS) Chmod(name string, mode fs.FileMode) error {
+	if err := checkMemPath("chmod", name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, node, err := m.resolve("chmod", name)
+	if err != nil {
+		return err
+	}
+	// Nodes are replaced rather than modified, since file infos handed out
+	// earlier share them.
+	if target == "." {
+		m.nodes[target] = &memNode{data: node.data, mode: node.mode.Type() | mode.Perm(), modTime: node.modTime}
+		return nil
+	}
+	m.put(target, &memNode{data: node.data, mode: node.mode.Type() | mode.Perm(), modTime: node.modTime})
+	return nil
+}
+
+// Remove implements UpdatableFS.
+func (m *InMemoryFS) Remove(name string) error {
+	if err := checkMemPath("remove", name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	if node.mode.IsDir() && (name == "." || len(m.children[name]) > 0) {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	m.drop(name)
+	return nil
+}
+
+// RemoveAll implements UpdatableFS.
+func (m *InMemoryFS) RemoveAll(name string) error {
+	if err := checkMemPath("removeall", name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range slices.Backward(m.descendants(name)) {
+		m.drop(key)
+	}
+	if _, ok := m.nodes[name]; ok && name != "." {
+		m.drop(name)
+	}
+	return nil
+}
+
+// Rename implements UpdatableFS.
+func (m *InMemoryFS) Rename(oldname, newname string) error {
+	if err := checkMemPath("rename", oldname); err != nil {
+		return err
+	}
+	if err := checkMemPath("rename", newname); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.lookup("rename", oldname)
+	if err != nil {
+		return err
+	}
+	if oldname == newname {
+		return nil
+	}
+	if err := m.checkParent("rename", newname); err != nil {
+		return err
+	}
+	if target, ok := m.nodes[newname]; ok {
+		switch {
+		case target.mode.IsDir() && !node.mode.IsDir():
+			return &fs.PathError{Op: "rename", Path: newname, Err: syscall.EISDIR}
+		case !target.mode.IsDir() && node.mode.IsDir():
+			return &fs.PathError{Op: "rename", Path: newname, Err: syscall.ENOTDIR}
+		case target.mode.IsDir() && len(m.children[newname]) > 0:
+			return &fs.PathError{Op: "rename", Path: newname, Err: syscall.ENOTEMPTY}
+		}
+	}
+	if node.mode.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: newname, Err: syscall.EINVAL}
+	}
+
+	// The moved nodes are listed parents first, so that they are put back
+	// in that order.
+	keys := append([]string{oldname}, m.descendants(oldname)...)
+	nodes := make([]*memNode, len(keys))
+	for i, key := range keys {
+		nodes[i] = m.nodes[key]
+	}
+	for _, key := range slices.Backward(keys) {
+		m.drop(key)
+	}
+	if _, ok := m.nodes[newname]; ok {
+		m.drop(newname)
+	}
+	for i, key := range keys {
+		m.put(newname+strings.TrimPrefix(key, oldname), nodes[i])
+	}
+	return nil
+}
+
+// sortedChildren returns the sorted names of the direct children of dir.
+// Callers hold the lock.
+func (m *InMemoryFS) sortedChildren(dir string) []string {
+	names := make([]string, 0, len(m.children[dir]))
+	for name := range m.children[dir] {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open implements fs.FS.
+func (m *InMemoryFS) Open(name string) (fs.File, error) {
+	if err := checkMemPath("open", name); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	target, node, err := m.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := memFileInfo{name: path.Base(name), node: node}
+	if !node.mode.IsDir() {
+		return &memFile{info: info, Reader: bytes.NewReader(node.data)}, nil
+	}
+
+	names := m.sortedChildren(target)
+	entries := make([]fs.DirEntry, len(names))
+	for i, child := range names {
+		entries[i] = fs.FileInfoToDirEntry(memFileInfo{name: child, node: m.nodes[path.Join(target, child)]})
+	}
+	return &memDir{info: info, entries: entries}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (m *InMemoryFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := f.(*memDir)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	return dir.entries, nil
+}
+
+// memFileInfo describes a node of an InMemoryFS.
+type memFileInfo struct {
+	name string
+	node *memNode
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memFileInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memFileInfo) ModTime() time.Time { return i.node.modTime }
+func (i memFileInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memFileInfo) Sys() any           { return nil }
+
+// memFile is an open file of an InMemoryFS.
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+// memDir is an open directory of an InMemoryFS.
+type memDir struct {
+	info    memFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: syscall.EISDIR}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(rest))
+	d.offset += n
+	return rest[:n], nil
+}
+
+// Compile-time checks.
+var (
+	_ UpdatableFS   = (*OSFS)(nil)
+	_ UpdatableFS   = (*InMemoryFS)(nil)
+	_ fs.ReadFileFS = (*InMemoryFS)(nil)
+	_ fs.ReadDirFS  = (*InMemoryFS)(nil)
+	_ fs.StatFS     = (*InMemoryFS)(nil)
+	_ fs.ReadLinkFS = (*InMemoryFS)(nil)
+)
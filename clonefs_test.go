@@ -0,0 +1,165 @@
+package nanogit
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testUpdatableFS exercises the behavior Clone relies on from an
+// UpdatableFS.
+func testUpdatableFS(t *testing.T, fsys UpdatableFS) {
+	t.Helper()
+
+	require.NoError(t, fsys.MkdirAll("a/b", 0755))
+	require.NoError(t, writeFile(fsys, "a/b/c.txt", []byte("c"), 0644))
+	require.NoError(t, writeFileAtomic(fsys, "a/d.txt", []byte("d"), 0644))
+
+	data, err := fsys.ReadFile("a/b/c.txt")
+	require.NoError(t, err)
+	require.Equal(t, "c", string(data))
+
+	info, err := fsys.Lstat("a/d.txt")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), info.Size())
+	require.False(t, info.IsDir())
+
+	_, err = fsys.Lstat("a/missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fsys.Lstat("a/d.txt/x")
+	require.ErrorIs(t, err, syscall.ENOTDIR)
+	require.ErrorIs(t, fsys.MkdirAll("a/d.txt/x", 0755), syscall.ENOTDIR)
+	require.ErrorIs(t, writeFile(fsys, "a/b", nil, 0644), syscall.EISDIR)
+	require.ErrorIs(t, fsys.Remove("a/b"), syscall.ENOTEMPTY)
+
+	require.NoError(t, fsys.Rename("a/b", "e"))
+	data, err = fsys.ReadFile("e/c.txt")
+	require.NoError(t, err)
+	require.Equal(t, "c", string(data))
+	_, err = fsys.Lstat("a/b")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, fsys.Rename("a/d.txt", "e/c.txt"))
+	data, err = fsys.ReadFile("e/c.txt")
+	require.NoError(t, err)
+	require.Equal(t, "d", string(data))
+
+	require.NoError(t, fsys.Symlink("c.txt", "e/link"))
+	require.ErrorIs(t, fsys.Symlink("c.txt", "e/link"), fs.ErrExist)
+	target, err := fsys.ReadLink("e/link")
+	require.NoError(t, err)
+	require.Equal(t, "c.txt", target)
+	info, err = fsys.Lstat("e/link")
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&fs.ModeSymlink)
+	data, err = fsys.ReadFile("e/link")
+	require.NoError(t, err)
+	require.Equal(t, "d", string(data))
+	_, err = fsys.ReadLink("e/c.txt")
+	require.Error(t, err)
+
+	require.NoError(t, fsys.Chmod("e/c.txt", 0755))
+	info, err = fsys.Lstat("e/c.txt")
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0755), info.Mode().Perm())
+
+	require.NoError(t, fsys.Remove("e/link"))
+	_, err = fsys.Lstat("e/c.txt")
+	require.NoError(t, err)
+
+	require.NoError(t, fsys.Remove("a"))
+	require.NoError(t, fsys.RemoveAll("e"))
+	require.NoError(t, fsys.RemoveAll("e"))
+	_, err = fsys.Lstat("e/c.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestOSFS(t *testing.T) {
+	t.Parallel()
+
+	t.Run("confined to a directory", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		fsys, err := NewOSFS(dir)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = fsys.Close() })
+
+		testUpdatableFS(t, fsys)
+
+		require.Error(t, writeFile(fsys, "../escape.txt", []byte("x"), 0644))
+		require.NoError(t, os.Symlink(t.TempDir(), filepath.Join(dir, "link")))
+		require.Error(t, writeFile(fsys, "link/escape.txt", []byte("x"), 0644))
+	})
+
+	t.Run("zero value", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		fsys := &OSFS{}
+		require.NoError(t, fsys.MkdirAll(filepath.Join(dir, "x"), 0755))
+		require.NoError(t, writeFile(fsys, filepath.Join(dir, "x", "y.txt"), []byte("y"), 0644))
+		data, err := os.ReadFile(filepath.Join(dir, "x", "y.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "y", string(data))
+	})
+}
+
+func TestInMemoryFS(t *testing.T) {
+	t.Parallel()
+
+	testUpdatableFS(t, NewInMemoryFS())
+
+	fsys := NewInMemoryFS()
+	require.NoError(t, fsys.MkdirAll("docs/api", 0755))
+	require.NoError(t, writeFile(fsys, "docs/api/index.md", []byte("api"), 0644))
+	require.NoError(t, writeFile(fsys, "README.md", []byte("readme"), 0644))
+	require.NoError(t, fsys.Symlink("api/index.md", "docs/index.md"))
+	require.NoError(t, fstest.TestFS(fsys, "README.md", "docs/api/index.md", "docs/index.md"))
+
+	// Links are followed within the filesystem only.
+	data, err := fs.ReadFile(fsys, "docs/index.md")
+	require.NoError(t, err)
+	require.Equal(t, "api", string(data))
+	require.NoError(t, fsys.Symlink("../../outside", "docs/escape"))
+	_, err = fs.ReadFile(fsys, "docs/escape")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, fsys.Symlink("loop", "loop"))
+	_, err = fsys.Stat("loop")
+	require.ErrorIs(t, err, syscall.ELOOP)
+
+	// Writes are only visible once the file is closed.
+	w, err := fsys.Create("docs/draft.md", 0644)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("draft"))
+	require.NoError(t, err)
+	_, err = fsys.Lstat("docs/draft.md")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, w.Close())
+	data, err = fs.ReadFile(fsys, "docs/draft.md")
+	require.NoError(t, err)
+	require.Equal(t, "draft", string(data))
+
+	require.ErrorIs(t, fsys.Rename("docs", "docs/api/inner"), syscall.EINVAL)
+
+	// Directory listings follow renames and removals of whole trees.
+	require.NoError(t, fsys.Remove("docs/escape"))
+	require.NoError(t, fsys.Remove("loop"))
+	require.NoError(t, fsys.Rename("docs", "guides"))
+	require.NoError(t, fstest.TestFS(fsys, "README.md", "guides/api/index.md", "guides/draft.md"))
+	require.NoError(t, fsys.RemoveAll("guides/api"))
+	entries, err := fs.ReadDir(fsys, "guides")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{"draft.md", "index.md"}, names)
+	require.NoError(t, fsys.RemoveAll("."))
+	entries, err = fs.ReadDir(fsys, ".")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -48,15 +48,20 @@ type Commit struct {
 	// Tree is the hash of the root tree object that represents the state
 	// of the repository at the time of the commit
 	Tree hash.Hash
-	// Parent is the hash of the parent commit
-	// TODO: Merge commits can have multiple parents, but currently only single parent is supported
+	// Parent is the hash of the first parent commit, or hash.Zero for a root commit
 	Parent hash.Hash
+	// Parents lists all parent commits in order. Merge commits have two or more;
+	// for regular commits it holds the same single hash as Parent
+	Parents []hash.Hash
 	// Author is the person who created the changes in the commit
 	Author Author
 	// Committer is the person who created the commit object
 	Committer Committer
 	// Message is the commit message that describes the changes made in this commit
 	Message string
+	// Path is the path the followed file had at this commit. It is only set by
+	// ListCommits when ListCommitsOptions.Follow is enabled
+	Path string
 }
 
 // Time returns the timestamp when the commit object was created.
@@ -92,6 +97,10 @@ type CommitFile struct {
 	OldType protocol.ObjectType
 	// Status indicates the type of file change (added, modified, deleted, etc.)
 	Status protocol.FileStatus
+	// Patch is the change in git's unified diff format, starting with its
+	// "diff --git" line. It is only set when requested with WithPatches,
+	// and is empty for directories.
+	Patch string
 }
 
 // CompareCommitsOptions configures the behavior of CompareCommits.
@@ -100,6 +109,9 @@ type CompareCommitsOptions struct {
 	// as a single renamed file instead of separate delete and add entries.
 	// Enable it with WithRenameDetection.
 	DetectRenames bool
+	// Patches sets the Patch of every changed file. Enable it with
+	// WithPatches.
+	Patches bool
 }
 
 // CompareCommitsOption configures CompareCommits behavior.
@@ -115,6 +127,15 @@ func WithRenameDetection() CompareCommitsOption {
 	}
 }
 
+// WithPatches sets the Patch of every changed file to its diff in git's
+// unified format, with three lines of context. The contents of the changed
+// files are fetched in batches.
+func WithPatches() CompareCommitsOption {
+	return func(opts *CompareCommitsOptions) {
+		opts.Patches = true
+	}
+}
+
 func defaultCompareCommitsOptions() *CompareCommitsOptions {
 	return &CompareCommitsOptions{
 		DetectRenames: false,
@@ -212,6 +233,11 @@ func (c *httpClient) CompareCommits(ctx context.Context, baseCommit, headCommit
 	headTree := headRes.tree
 
 	changes := c.compareTrees(baseTree, headTree, options)
+	if options.Patches {
+		if err := addPatches(ctx, changes, c.readBlobs); err != nil {
+			return nil, err
+		}
+	}
 	logger.Debug("Commits compared",
 		"base_hash", baseCommit.String(),
 		"head_hash", headCommit.String(),
@@ -281,8 +307,8 @@ func (c *httpClient) compareTrees(base, head *FlatTree, opts *CompareCommitsOpti
 				Hash:   entry.Hash,
 				Type:   entry.Type,
 			})
-		} else if !baseInfo.hash.Is(entry.Hash) && entry.Type != protocol.ObjectTypeTree {
-			// File exists in both but has different content - it was modified
+		} else if (!baseInfo.hash.Is(entry.Hash) || baseInfo.mode != uint16(entry.Mode)) && entry.Type != protocol.ObjectTypeTree {
+			// File exists in both but has different content or mode - it was modified
 			changes = append(changes, CommitFile{
 				Path:    entry.Path,
 				Status:  protocol.FileStatusModified,
@@ -522,9 +548,10 @@ func packfileObjectToCommit(commit *protocol.PackfileObject) (*Commit, error) {
 	}
 
 	return &Commit{
-		Hash:   commit.Hash,
-		Tree:   commit.Commit.Tree,
-		Parent: commit.Commit.Parent,
+		Hash:    commit.Hash,
+		Tree:    commit.Commit.Tree,
+		Parent:  commit.Commit.Parent,
+		Parents: commit.Commit.Parents,
 		Author: Author{
 			Name:  commit.Commit.Author.Name,
 			Email: commit.Commit.Author.Email,
@@ -551,6 +578,13 @@ type ListCommitsOptions struct {
 	// Path filters commits to only those that affect the specified file or directory path
 	// If empty, all commits are included
 	Path string
+	// Follow continues the history of Path across renames, like `git log --follow`.
+	// When the file does not exist in a commit's parent, the commit is checked for a
+	// rename and the walk continues with the old path. Each returned commit reports
+	// the path the file had at that commit in Commit.Path.
+	// Renames are detected the same way as WithRenameDetection: only moves that keep
+	// the content unchanged are followed. Follow requires Path to name a file.
+	Follow bool
 	// Since filters commits to only those created after this time
 	// If zero, no time filtering is applied
 	Since time.Time
@@ -591,11 +625,21 @@ type ListCommitsOptions struct {
 //	for _, commit := range commits {
 //	    fmt.Printf("%s: %s\n", commit.Hash.String()[:8], commit.Message)
 //	}
+//
+//	// Get the full history of a file, including before it was moved
+//	history, err := client.ListCommits(ctx, mainBranchHash, nanogit.ListCommitsOptions{
+//	    Path:   "docs/guide.md",
+//	    Follow: true,
+//	})
+//	for _, commit := range history {
+//	    fmt.Printf("%s: %s\n", commit.Hash.String()[:8], commit.Path)
+//	}
 func (c *httpClient) ListCommits(ctx context.Context, startCommit hash.Hash, options ListCommitsOptions) ([]Commit, error) {
 	logger := log.FromContext(ctx)
 	logger.Debug("List commits",
 		"start_hash", startCommit.String(),
 		"path_filter", options.Path,
+		"follow", options.Follow,
 		"page", options.Page,
 		"per_page", options.PerPage)
 
@@ -605,12 +649,12 @@ func (c *httpClient) ListCommits(ctx context.Context, startCommit hash.Hash, opt
 
 	ctx, allObjects := storage.FromContextOrInMemory(ctx)
 
-	commitObjs, err := c.collectCommitObjects(ctx, startCommit, options, skip+collect, perPage, allObjects)
+	commitObjs, paths, err := c.collectCommitObjects(ctx, startCommit, options, skip+collect, perPage, allObjects)
 	if err != nil {
 		return nil, err
 	}
 
-	commits, err := c.paginateCommits(commitObjs, skip, collect)
+	commits, err := c.paginateCommits(commitObjs, paths, skip, collect)
 	if err != nil {
 		return nil, err
 	}
@@ -642,13 +686,22 @@ func (c *httpClient) validatePagination(options ListCommitsOptions) (int, int) {
 	return page, perPage
 }
 
-// collectCommitObjects traverses commit history and collects matching commits
-func (c *httpClient) collectCommitObjects(ctx context.Context, startCommit hash.Hash, options ListCommitsOptions, maxCommits, perPage int, allObjects storage.PackfileStorage) ([]*protocol.PackfileObject, error) {
+// collectCommitObjects traverses commit history and collects matching commits.
+// With options.Follow, it also returns the path the followed file had at each
+// collected commit, keyed by commit hash.
+func (c *httpClient) collectCommitObjects(ctx context.Context, startCommit hash.Hash, options ListCommitsOptions, maxCommits, perPage int, allObjects storage.PackfileStorage) ([]*protocol.PackfileObject, map[hash.Hash]string, error) {
 	logger := log.FromContext(ctx)
 	var commitObjs []*protocol.PackfileObject
 	visited := make(map[string]bool)
 	queue := []hash.Hash{startCommit}
 
+	follow := options.Follow && options.Path != ""
+	// paths tracks the followed path for every queued and collected commit.
+	paths := make(map[hash.Hash]string)
+	if follow {
+		paths[startCommit] = options.Path
+	}
+
 	for len(queue) > 0 && len(commitObjs) < maxCommits {
 		currentHash := queue[0]
 		queue = queue[1:]
@@ -660,12 +713,17 @@ func (c *httpClient) collectCommitObjects(ctx context.Context, startCommit hash.
 
 		commit, err := c.fetchCommitObject(ctx, currentHash, perPage, allObjects)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		matches, err := c.commitMatchesFilters(ctx, commit, &options, allObjects)
+		filters := options
+		if follow {
+			filters.Path = paths[currentHash]
+		}
+
+		matches, err := c.commitMatchesFilters(ctx, commit, &filters, allObjects)
 		if err != nil {
-			return nil, fmt.Errorf("check filters for commit %s: %w", currentHash.String(), err)
+			return nil, nil, fmt.Errorf("check filters for commit %s: %w", currentHash.String(), err)
 		}
 
 		if matches {
@@ -676,11 +734,60 @@ func (c *httpClient) collectCommitObjects(ctx context.Context, startCommit hash.
 		}
 
 		if !commit.Commit.Parent.Is(hash.Zero) {
+			if follow {
+				parentPath, err := c.followRename(ctx, commit, filters.Path, allObjects)
+				if err != nil {
+					return nil, nil, fmt.Errorf("follow %s in commit %s: %w", filters.Path, currentHash.String(), err)
+				}
+				if _, queued := paths[commit.Commit.Parent]; !queued {
+					paths[commit.Commit.Parent] = parentPath
+				}
+			}
 			queue = append(queue, commit.Commit.Parent)
 		}
 	}
 
-	return commitObjs, nil
+	return commitObjs, paths, nil
+}
+
+// followRename returns the path the file at path had in the parent of commit.
+// It is the same path unless the file is missing from the parent and commit
+// renamed it, in which case the pre-rename path is returned.
+func (c *httpClient) followRename(ctx context.Context, commit *protocol.PackfileObject, path string, allObjects storage.PackfileStorage) (string, error) {
+	logger := log.FromContext(ctx)
+
+	parentHash, err := c.hashForPath(ctx, commit.Commit.Parent, path, allObjects)
+	if err != nil {
+		return "", fmt.Errorf("hash for path in parent: %w", err)
+	}
+	if !parentHash.Is(hash.Zero) {
+		return path, nil
+	}
+
+	currentHash, err := c.hashForPath(ctx, commit.Hash, path, allObjects)
+	if err != nil {
+		return "", fmt.Errorf("hash for path: %w", err)
+	}
+	if currentHash.Is(hash.Zero) {
+		return path, nil
+	}
+
+	changes, err := c.CompareCommits(ctx, commit.Commit.Parent, commit.Hash, WithRenameDetection())
+	if err != nil {
+		return "", fmt.Errorf("detect renames: %w", err)
+	}
+
+	for _, change := range changes {
+		if change.Status == protocol.FileStatusRenamed && change.Path == path {
+			logger.Debug("Following rename",
+				"commit_hash", commit.Hash.String(),
+				"old_path", change.OldPath,
+				"new_path", path)
+			return change.OldPath, nil
+		}
+	}
+
+	return path, nil
 }
 
 // fetchCommitObject fetches a single commit object
@@ -714,7 +821,7 @@ func (c *httpClient) fetchCommitObject(ctx context.Context, commitHash hash.Hash
 }
 
 // paginateCommits applies pagination to the collected commits
-func (c *httpClient) paginateCommits(commitObjs []*protocol.PackfileObject, skip, collect int) ([]Commit, error) {
+func (c *httpClient) paginateCommits(commitObjs []*protocol.PackfileObject, paths map[hash.Hash]string, skip, collect int) ([]Commit, error) {
 	if skip >= len(commitObjs) {
 		return []Commit{}, nil
 	}
@@ -726,6 +833,7 @@ func (c *httpClient) paginateCommits(commitObjs []*protocol.PackfileObject, skip
 		if err != nil {
 			return nil, fmt.Errorf("parse commit %s: %w", obj.Hash.String(), err)
 		}
+		commit.Path = paths[obj.Hash]
 		commits = append(commits, *commit)
 	}
 
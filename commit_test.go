@@ -1,6 +1,7 @@
 package nanogit
 
 import (
+	"context"
 	"testing"
 
 	"github.com/grafana/nanogit/protocol"
@@ -448,3 +449,71 @@ func TestDetectRenames_TreeEntries(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareCommits_WithPatches(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepo(t)
+	base := repo.commit("base", map[string]string{"a.txt": "one\ntwo\n", "dir/b.txt": "b\n"})
+	head := repo.commit("head", map[string]string{"a.txt": "one\n2\n", "dir/c.txt": "c"}, base)
+
+	changes, err := repo.client().CompareCommits(context.Background(), base, head, WithPatches())
+	require.NoError(t, err)
+	patches := make(map[string]string, len(changes))
+	for _, change := range changes {
+		patches[change.Path] = change.Patch
+	}
+	assert.Contains(t, patches["a.txt"], "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n")
+	assert.Contains(t, patches["dir/b.txt"], "deleted file mode 100644\n")
+	assert.Contains(t, patches["dir/c.txt"], "+c\n\\ No newline at end of file\n")
+	assert.Empty(t, patches["dir"])
+
+	changes, err = repo.client().CompareCommits(context.Background(), base, head)
+	require.NoError(t, err)
+	for _, change := range changes {
+		assert.Empty(t, change.Patch)
+	}
+}
+
+func TestListCommits_Follow(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepo(t)
+	c1 := repo.commit("add guide", map[string]string{"guide.md": "v1", "other.txt": "x"})
+	c2 := repo.commit("edit guide", map[string]string{"guide.md": "v2", "other.txt": "x"}, c1)
+	c3 := repo.commit("move guide", map[string]string{"docs/guide.md": "v2", "other.txt": "x"}, c2)
+	c4 := repo.commit("touch other", map[string]string{"docs/guide.md": "v2", "other.txt": "y"}, c3)
+	c5 := repo.commit("edit moved guide", map[string]string{"docs/guide.md": "v3", "other.txt": "y"}, c4)
+
+	t.Run("without follow stops at the rename", func(t *testing.T) {
+		t.Parallel()
+		commits, err := repo.client().ListCommits(context.Background(), c5, ListCommitsOptions{Path: "docs/guide.md"})
+		require.NoError(t, err)
+		require.Len(t, commits, 2)
+		assert.Equal(t, c5, commits[0].Hash)
+		assert.Equal(t, c3, commits[1].Hash)
+		assert.Empty(t, commits[0].Path)
+	})
+
+	t.Run("follow continues with the old path", func(t *testing.T) {
+		t.Parallel()
+		commits, err := repo.client().ListCommits(context.Background(), c5, ListCommitsOptions{Path: "docs/guide.md", Follow: true})
+		require.NoError(t, err)
+		require.Len(t, commits, 4)
+
+		assert.Equal(t, []hash.Hash{c5, c3, c2, c1}, []hash.Hash{commits[0].Hash, commits[1].Hash, commits[2].Hash, commits[3].Hash})
+		assert.Equal(t, "docs/guide.md", commits[0].Path)
+		assert.Equal(t, "docs/guide.md", commits[1].Path)
+		assert.Equal(t, "guide.md", commits[2].Path)
+		assert.Equal(t, "guide.md", commits[3].Path)
+	})
+
+	t.Run("follow respects pagination", func(t *testing.T) {
+		t.Parallel()
+		commits, err := repo.client().ListCommits(context.Background(), c5, ListCommitsOptions{Path: "docs/guide.md", Follow: true, PerPage: 2, Page: 2})
+		require.NoError(t, err)
+		require.Len(t, commits, 2)
+		assert.Equal(t, c2, commits[0].Hash)
+		assert.Equal(t, "guide.md", commits[0].Path)
+	})
+}
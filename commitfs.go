@@ -0,0 +1,481 @@
+package nanogit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+)
+
+// FS returns a read-only view of the tree of a commit (or of a tree hash)
+// as an io/fs file system. Directories and files are fetched lazily, the
+// first time they are opened or listed, and kept in the storage from the
+// context (an in-memory storage is attached if the context has none), so
+// repeated reads are served locally.
+//
+// The returned file system implements fs.ReadDirFS, fs.ReadFileFS,
+// fs.StatFS and fs.ReadLinkFS. File modes follow the tree entries:
+// executables report 0o755, regular files 0o644 and symlinks
+// fs.ModeSymlink. Like InMemoryFS, Open, ReadFile and Stat follow
+// symlinks that are the last element of a name, while Lstat and ReadLink
+// do not; links leading outside the tree are treated as missing.
+// Submodule entries are omitted, as in GetTree. When rootHash is a commit,
+// every file reports the committer time as its modification time;
+// FileInfo.Sys returns the TreeEntry.
+//
+// Because git does not store blob sizes in trees, Stat and DirEntry.Info
+// on a file ask the server for its size with the object-info command,
+// unless the blob was already fetched. Servers without the command get
+// the blob fetched instead.
+//
+// The context is used for every lazy fetch, so it should outlive the use of
+// the file system. Errors are returned as *fs.PathError; the underlying
+// nanogit errors can be checked with errors.Is.
+//
+// Parameters:
+//   - ctx: Context for the lazy fetches
+//   - rootHash: Hash of the commit or tree to expose
+//
+// Returns:
+//   - fs.FS: The read-only file system
+//
+// Example:
+//
+//	fsys := client.FS(ctx, commitHash)
+//	tmpl, err := template.ParseFS(fsys, "templates/*.tmpl")
+//	if err != nil {
+//	    return err
+//	}
+//	http.Handle("/", http.FileServerFS(fsys))
+func (c *httpClient) FS(ctx context.Context, rootHash hash.Hash) fs.FS {
+	ctx, allObjects := storage.FromContextOrInMemory(ctx)
+	return &commitFS{
+		client:   c,
+		storage:  allObjects,
+		ctx:      ctx,
+		rootHash: rootHash,
+	}
+}
+
+// commitFS implements the fs.FS returned by FS.
+type commitFS struct {
+	client   *httpClient
+	storage  storage.PackfileStorage
+	ctx      context.Context
+	rootHash hash.Hash
+
+	rootOnce sync.Once
+	rootTree hash.Hash
+	modTime  time.Time
+	rootErr  error
+
+	// sizesMu guards sizes, the blob sizes the server reported, and
+	// noObjectInfo, set once the server turned out not to report them.
+	sizesMu      sync.Mutex
+	sizes        map[hash.Hash]int64
+	noObjectInfo bool
+}
+
+var (
+	_ fs.ReadDirFS  = (*commitFS)(nil)
+	_ fs.ReadFileFS = (*commitFS)(nil)
+	_ fs.StatFS     = (*commitFS)(nil)
+	_ fs.ReadLinkFS = (*commitFS)(nil)
+)
+
+// root resolves the root tree once, peeling a commit hash to its tree.
+func (f *commitFS) root() (hash.Hash, error) {
+	f.rootOnce.Do(func() {
+		w := &treeWalker{client: f.client, storage: f.storage, batchSize: defaultWalkBatchSize}
+		tree, err := w.rootTree(f.ctx, f.rootHash)
+		if err != nil {
+			f.rootErr = err
+			return
+		}
+		f.rootTree = tree.Hash
+
+		if obj, ok := f.storage.GetByType(f.rootHash, protocol.ObjectTypeCommit); ok {
+			if t, err := obj.Commit.Committer.Time(); err == nil {
+				f.modTime = t
+			}
+		}
+	})
+	return f.rootTree, f.rootErr
+}
+
+// lookup finds the tree entry for a valid fs path. The root directory is
+// returned as a synthetic tree entry named ".".
+func (f *commitFS) lookup(op, name string) (TreeEntry, error) {
+	if !fs.ValidPath(name) {
+		return TreeEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	rootTree, err := f.root()
+	if err != nil {
+		return TreeEntry{}, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	entry := TreeEntry{Name: ".", Mode: 0o40000, Hash: rootTree, Type: protocol.ObjectTypeTree}
+	if name == "." {
+		return entry, nil
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if entry.Type != protocol.ObjectTypeTree {
+			return TreeEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		tree, err := f.client.GetTree(f.ctx, entry.Hash)
+		if err != nil {
+			return TreeEntry{}, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+
+		found := false
+		for _, child := range tree.Entries {
+			if child.Name == part {
+				entry = child
+				found = true
+				break
+			}
+		}
+		if !found {
+			return TreeEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+
+	return entry, nil
+}
+
+// resolve is like lookup but follows symlinks while the entry found is
+// one, like InMemoryFS does. Errors report name, not the link targets.
+func (f *commitFS) resolve(op, name string) (TreeEntry, error) {
+	target := name
+	for range memMaxLinks {
+		entry, err := f.lookup(op, target)
+		if err != nil {
+			var pathErr *fs.PathError
+			if errors.As(err, &pathErr) {
+				pathErr.Path = name
+			}
+			return TreeEntry{}, err
+		}
+		if entry.Mode != 0o120000 {
+			return entry, nil
+		}
+
+		content, err := f.blob(op, name, entry.Hash)
+		if err != nil {
+			return TreeEntry{}, err
+		}
+		link := string(content)
+		if !path.IsAbs(link) {
+			link = path.Join(path.Dir(target), link)
+		}
+		if !fs.ValidPath(link) {
+			return TreeEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		target = link
+	}
+	return TreeEntry{}, &fs.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+}
+
+// size returns the size of a blob entry without fetching its content when
+// possible: from the storage if the blob was already fetched, or else with
+// the object-info command. Without the command the blob is fetched.
+func (f *commitFS) size(op, name string, h hash.Hash) (int64, error) {
+	if obj, ok := f.storage.GetByType(h, protocol.ObjectTypeBlob); ok {
+		return int64(len(obj.Data)), nil
+	}
+
+	f.sizesMu.Lock()
+	size, known := f.sizes[h]
+	noObjectInfo := f.noObjectInfo
+	f.sizesMu.Unlock()
+	if known {
+		return size, nil
+	}
+
+	if !noObjectInfo {
+		sizes, err := f.client.ObjectInfo(f.ctx, []hash.Hash{h})
+		switch {
+		case errors.Is(err, client.ErrObjectInfoUnsupported) || protocol.IsGitServerError(err):
+			log.FromContext(f.ctx).Debug("Object sizes not available", "error", err)
+			f.sizesMu.Lock()
+			f.noObjectInfo = true
+			f.sizesMu.Unlock()
+		case err != nil:
+			return 0, &fs.PathError{Op: op, Path: name, Err: err}
+		default:
+			if size, ok := sizes[h]; ok {
+				f.sizesMu.Lock()
+				if f.sizes == nil {
+					f.sizes = make(map[hash.Hash]int64)
+				}
+				f.sizes[h] = size
+				f.sizesMu.Unlock()
+				return size, nil
+			}
+		}
+	}
+
+	content, err := f.blob(op, name, h)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(content)), nil
+}
+
+// blob returns the content of a blob entry.
+func (f *commitFS) blob(op, name string, h hash.Hash) ([]byte, error) {
+	blob, err := f.client.GetBlob(f.ctx, h)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return blob.Content, nil
+}
+
+// Open implements fs.FS.
+func (f *commitFS) Open(name string) (fs.File, error) {
+	log.FromContext(f.ctx).Debug("Open file from commit FS",
+		"root_hash", f.rootHash.String(),
+		"path", name)
+
+	entry, err := f.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Type == protocol.ObjectTypeTree {
+		entries, err := f.readDir("open", name, entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return &commitDir{
+			info:    f.fileInfo(path.Base(name), entry, 0),
+			entries: entries,
+		}, nil
+	}
+
+	content, err := f.blob("open", name, entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &commitFile{
+		info:   f.fileInfo(path.Base(name), entry, int64(len(content))),
+		Reader: bytes.NewReader(content),
+	}, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (f *commitFS) ReadFile(name string) ([]byte, error) {
+	entry, err := f.resolve("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type == protocol.ObjectTypeTree {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+
+	content, err := f.blob("read", name, entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	// Callers may modify the returned slice, so do not hand out the stored copy.
+	return bytes.Clone(content), nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f *commitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := f.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type != protocol.ObjectTypeTree {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	return f.readDir("readdir", name, entry.Hash)
+}
+
+// readDir lists a tree as directory entries sorted by name.
+func (f *commitFS) readDir(op, name string, treeHash hash.Hash) ([]fs.DirEntry, error) {
+	tree, err := f.client.GetTree(f.ctx, treeHash)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(tree.Entries))
+	for _, child := range tree.Entries {
+		entries = append(entries, &commitDirEntry{
+			fsys:  f,
+			path:  path.Join(name, child.Name),
+			entry: child,
+		})
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// Stat implements fs.StatFS. Symlinks are followed.
+func (f *commitFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := f.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.stat("stat", name, entry)
+}
+
+// Lstat implements fs.ReadLinkFS. Unlike Stat, it describes a symlink
+// itself.
+func (f *commitFS) Lstat(name string) (fs.FileInfo, error) {
+	entry, err := f.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.stat("lstat", name, entry)
+}
+
+// stat describes the entry found at name.
+func (f *commitFS) stat(op, name string, entry TreeEntry) (fs.FileInfo, error) {
+	if entry.Type == protocol.ObjectTypeTree {
+		return f.fileInfo(path.Base(name), entry, 0), nil
+	}
+
+	size, err := f.size(op, name, entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return f.fileInfo(path.Base(name), entry, size), nil
+}
+
+// ReadLink implements fs.ReadLinkFS.
+func (f *commitFS) ReadLink(name string) (string, error) {
+	entry, err := f.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if entry.Mode != 0o120000 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	content, err := f.blob("readlink", name, entry.Hash)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (f *commitFS) fileInfo(name string, entry TreeEntry, size int64) *commitFileInfo {
+	return &commitFileInfo{
+		name:    name,
+		size:    size,
+		mode:    treeEntryFileMode(entry.Mode),
+		modTime: f.modTime,
+		entry:   entry,
+	}
+}
+
+// treeEntryFileMode maps a git tree entry mode to an fs.FileMode.
+func treeEntryFileMode(mode uint32) fs.FileMode {
+	switch mode {
+	case 0o40000:
+		return fs.ModeDir | 0o755
+	case 0o100755:
+		return 0o755
+	case 0o120000:
+		return fs.ModeSymlink | 0o777
+	default:
+		return 0o644
+	}
+}
+
+// commitFileInfo implements fs.FileInfo for tree entries.
+type commitFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	entry   TreeEntry
+}
+
+func (i *commitFileInfo) Name() string       { return i.name }
+func (i *commitFileInfo) Size() int64        { return i.size }
+func (i *commitFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *commitFileInfo) ModTime() time.Time { return i.modTime }
+func (i *commitFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *commitFileInfo) Sys() any           { return i.entry }
+
+// commitDirEntry implements fs.DirEntry. Like Lstat, Info describes
+// symlinks themselves, and it asks for the size of files only when called.
+type commitDirEntry struct {
+	fsys  *commitFS
+	path  string
+	entry TreeEntry
+}
+
+func (e *commitDirEntry) Name() string      { return e.entry.Name }
+func (e *commitDirEntry) IsDir() bool       { return e.entry.Type == protocol.ObjectTypeTree }
+func (e *commitDirEntry) Type() fs.FileMode { return treeEntryFileMode(e.entry.Mode).Type() }
+
+func (e *commitDirEntry) Info() (fs.FileInfo, error) {
+	if e.IsDir() {
+		return e.fsys.fileInfo(e.entry.Name, e.entry, 0), nil
+	}
+	size, err := e.fsys.size("stat", e.path, e.entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return e.fsys.fileInfo(e.entry.Name, e.entry, size), nil
+}
+
+// commitFile is an open blob.
+type commitFile struct {
+	*bytes.Reader
+	info *commitFileInfo
+}
+
+func (f *commitFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *commitFile) Close() error               { return nil }
+
+// commitDir is an open directory.
+type commitDir struct {
+	info    *commitFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *commitDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *commitDir) Close() error               { return nil }
+
+func (d *commitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: syscall.EISDIR}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *commitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(remaining))
+	d.offset += n
+	return remaining[:n], nil
+}
@@ -0,0 +1,219 @@
+package nanogit
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"slices"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFSTestRepo(t *testing.T) (*testRepo, hash.Hash) {
+	t.Helper()
+	repo := newTestRepo(t)
+	commit := repo.commit("initial", map[string]string{
+		"README.md":         "readme",
+		"docs/guide.md":     "guide",
+		"docs/api/index.md": "api",
+		"scripts/run.sh":    "exec:#!/bin/sh",
+		"latest":            "symlink:docs/guide.md",
+	})
+	return repo, commit
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+
+	t.Run("passes fstest", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newFSTestRepo(t)
+
+		fsys := repo.client().FS(context.Background(), commit)
+		require.NoError(t, fstest.TestFS(fsys, "README.md", "docs/guide.md", "docs/api/index.md", "scripts/run.sh"))
+	})
+
+	t.Run("reads files and directories", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newFSTestRepo(t)
+		fsys := repo.client().FS(context.Background(), commit)
+
+		content, err := fs.ReadFile(fsys, "docs/guide.md")
+		require.NoError(t, err)
+		assert.Equal(t, "guide", string(content))
+
+		entries, err := fs.ReadDir(fsys, ".")
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		assert.Equal(t, []string{"README.md", "docs", "latest", "scripts"}, names)
+
+		matches, err := fs.Glob(fsys, "docs/*.md")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"docs/guide.md"}, matches)
+	})
+
+	t.Run("reports modes, sizes and commit time", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newFSTestRepo(t)
+		fsys := repo.client().FS(context.Background(), commit)
+
+		info, err := fs.Stat(fsys, "scripts/run.sh")
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0o755), info.Mode())
+		assert.Equal(t, int64(len("#!/bin/sh")), info.Size())
+		assert.Equal(t, repo.clock, info.ModTime().Unix())
+		assert.Equal(t, uint32(0o100755), info.Sys().(TreeEntry).Mode)
+
+		info, err = fs.Stat(fsys, "README.md")
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0o644), info.Mode())
+		assert.Equal(t, int64(6), info.Size())
+
+		info, err = fs.Stat(fsys, "docs")
+		require.NoError(t, err)
+		assert.True(t, info.IsDir())
+		assert.Equal(t, fs.ModeDir|0o755, info.Mode())
+	})
+
+	t.Run("reports sizes without fetching blobs", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newFSTestRepo(t)
+		repo.objectInfo = true
+		fsys := repo.client().FS(context.Background(), commit)
+
+		info, err := fs.Stat(fsys, "README.md")
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), info.Size())
+
+		entries, err := fs.ReadDir(fsys, "docs")
+		require.NoError(t, err)
+		for _, entry := range entries {
+			_, err := entry.Info()
+			require.NoError(t, err)
+		}
+
+		for _, h := range []hash.Hash{repo.blob("readme"), repo.blob("guide")} {
+			for _, fetch := range repo.fetches {
+				assert.NotContains(t, fetch, h)
+			}
+		}
+	})
+
+	t.Run("follows symlinks except in Lstat and ReadLink", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newFSTestRepo(t)
+		fsys := repo.client().FS(context.Background(), commit)
+
+		info, err := fs.Lstat(fsys, "latest")
+		require.NoError(t, err)
+		assert.Equal(t, fs.ModeSymlink, info.Mode().Type())
+
+		target, err := fs.ReadLink(fsys, "latest")
+		require.NoError(t, err)
+		assert.Equal(t, "docs/guide.md", target)
+
+		_, err = fs.ReadLink(fsys, "README.md")
+		require.ErrorIs(t, err, fs.ErrInvalid)
+
+		info, err = fs.Stat(fsys, "latest")
+		require.NoError(t, err)
+		assert.True(t, info.Mode().IsRegular())
+		assert.Equal(t, int64(len("guide")), info.Size())
+		content, err := fs.ReadFile(fsys, "latest")
+		require.NoError(t, err)
+		assert.Equal(t, "guide", string(content))
+
+		entries, err := fs.ReadDir(fsys, ".")
+		require.NoError(t, err)
+		i := slices.IndexFunc(entries, func(e fs.DirEntry) bool { return e.Name() == "latest" })
+		require.GreaterOrEqual(t, i, 0)
+		assert.Equal(t, fs.ModeSymlink, entries[i].Type())
+
+		// Links leading outside the tree are missing, and loops are reported.
+		links := repo.commit("links", map[string]string{
+			"docs/escape": "symlink:../../outside",
+			"loop":        "symlink:loop",
+			"dir":         "symlink:docs",
+		})
+		fsys = repo.client().FS(context.Background(), links)
+		_, err = fs.ReadFile(fsys, "docs/escape")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = fs.Stat(fsys, "loop")
+		require.ErrorIs(t, err, syscall.ELOOP)
+		entries, err = fs.ReadDir(fsys, "dir")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "escape", entries[0].Name())
+	})
+
+	t.Run("accepts a tree hash", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		tree := repo.tree(map[string]string{"a.txt": "a"})
+		fsys := repo.client().FS(context.Background(), tree)
+
+		content, err := fs.ReadFile(fsys, "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "a", string(content))
+	})
+
+	t.Run("reads directories in chunks", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newFSTestRepo(t)
+		fsys := repo.client().FS(context.Background(), commit)
+
+		f, err := fsys.Open("docs")
+		require.NoError(t, err)
+		defer f.Close()
+		dir, ok := f.(fs.ReadDirFile)
+		require.True(t, ok)
+
+		first, err := dir.ReadDir(1)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+		assert.Equal(t, "api", first[0].Name())
+
+		second, err := dir.ReadDir(5)
+		require.NoError(t, err)
+		require.Len(t, second, 1)
+		assert.Equal(t, "guide.md", second[0].Name())
+
+		_, err = dir.ReadDir(1)
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newFSTestRepo(t)
+		fsys := repo.client().FS(context.Background(), commit)
+
+		_, err := fsys.Open("missing.txt")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+
+		_, err = fsys.Open("README.md/child")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+
+		_, err = fsys.Open("/README.md")
+		require.ErrorIs(t, err, fs.ErrInvalid)
+
+		var pathErr *fs.PathError
+		_, err = fs.ReadFile(fsys, "docs")
+		require.ErrorAs(t, err, &pathErr)
+		require.ErrorIs(t, err, syscall.EISDIR)
+
+		_, err = fs.ReadDir(fsys, "README.md")
+		require.ErrorIs(t, err, syscall.ENOTDIR)
+
+		missing := hash.MustFromHex("1234567890123456789012345678901234567890")
+		_, err = repo.client().FS(context.Background(), missing).Open(".")
+		require.ErrorIs(t, err, ErrObjectNotFound)
+	})
+}
@@ -3,6 +3,7 @@ package nanogit
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -48,10 +49,54 @@ var (
 	// This error should only be used with errors.Is() for comparison, not for type assertions.
 	ErrEmptyRefName = errors.New("empty ref name")
 
+	// ErrInvalidFileMode is returned when a file mode is not the mode of a regular file, an executable file or a symbolic link.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrInvalidFileMode = errors.New("invalid file mode")
+
 	// ErrInvalidAuthor is returned when author information is invalid.
 	// This error should only be used with errors.Is() for comparison, not for type assertions.
 	ErrInvalidAuthor = errors.New("invalid author information")
 
+	// ErrNoMergeBase is returned when two commits do not share any history.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrNoMergeBase = errors.New("no merge base")
+
+	// ErrInvalidRevision is returned when a revision expression is malformed or names nothing.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrInvalidRevision = errors.New("invalid revision")
+
+	// ErrAmbiguousRevision is returned when an abbreviated hash matches more than one object.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrAmbiguousRevision = errors.New("ambiguous revision")
+
+	// ErrUnsafeSymlink is returned when a clone with CloneOptions.SafeSymlinks meets a symbolic link escaping the clone.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrUnsafeSymlink = errors.New("unsafe symbolic link")
+
+	// ErrMergeConflict is returned when a merge meets changes that cannot be combined.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrMergeConflict = errors.New("merge conflict")
+
+	// ErrRebaseConflict is returned when a push cannot be rebased because the ref changed the same paths.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrRebaseConflict = errors.New("rebase conflict")
+
+	// ErrUnpushedCommitNotFound is returned when a writer has no unpushed commit to amend, squash or reset to.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrUnpushedCommitNotFound = errors.New("unpushed commit not found")
+
+	// ErrInvalidCheckpoint is returned when rolling back to a checkpoint that was discarded or taken on another writer.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+
+	// ErrInvalidPatch is returned when a patch cannot be parsed.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrInvalidPatch = errors.New("invalid patch")
+
+	// ErrPatchDoesNotApply is returned when a patch does not apply to the staged files.
+	// This error should only be used with errors.Is() for comparison, not for type assertions.
+	ErrPatchDoesNotApply = errors.New("patch does not apply")
+
 	// ErrServerUnavailable is returned when the Git server is unavailable (HTTP 5xx status codes).
 	// This error should only be used with errors.Is() for comparison, not for type assertions.
 	// It is re-exported from the protocol/client package to avoid import cycles.
@@ -250,6 +295,63 @@ func NewRefAlreadyExistsError(refName string) *RefAlreadyExistsError {
 	}
 }
 
+// InvalidRevisionError provides structured information about a revision expression that cannot be resolved.
+type InvalidRevisionError struct {
+	// Revision is the expression passed to ResolveRevision.
+	Revision string
+	// Reason describes why the expression cannot be resolved.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *InvalidRevisionError) Error() string {
+	return fmt.Sprintf("invalid revision %q: %s", e.Revision, e.Reason)
+}
+
+// Unwrap enables errors.Is() compatibility with ErrInvalidRevision
+func (e *InvalidRevisionError) Unwrap() error {
+	return ErrInvalidRevision
+}
+
+// NewInvalidRevisionError creates a new InvalidRevisionError with the specified details.
+func NewInvalidRevisionError(revision, reason string) *InvalidRevisionError {
+	return &InvalidRevisionError{
+		Revision: revision,
+		Reason:   reason,
+	}
+}
+
+// AmbiguousRevisionError provides structured information about an abbreviated hash matching several objects.
+type AmbiguousRevisionError struct {
+	// Prefix is the abbreviated hash that was looked up.
+	Prefix string
+	// Candidates are the objects whose hash starts with Prefix.
+	Candidates []hash.Hash
+}
+
+// Error implements the error interface.
+func (e *AmbiguousRevisionError) Error() string {
+	names := make([]string, 0, len(e.Candidates))
+	for _, h := range e.Candidates {
+		names = append(names, h.String())
+	}
+	sort.Strings(names)
+	return "short object ID " + e.Prefix + " is ambiguous: " + strings.Join(names, ", ")
+}
+
+// Unwrap enables errors.Is() compatibility with ErrAmbiguousRevision
+func (e *AmbiguousRevisionError) Unwrap() error {
+	return ErrAmbiguousRevision
+}
+
+// NewAmbiguousRevisionError creates a new AmbiguousRevisionError with the specified details.
+func NewAmbiguousRevisionError(prefix string, candidates []hash.Hash) *AmbiguousRevisionError {
+	return &AmbiguousRevisionError{
+		Prefix:     prefix,
+		Candidates: candidates,
+	}
+}
+
 // AuthorError provides structured information about invalid author information.
 type AuthorError struct {
 	// Field is the author field that failed validation (e.g. "name", "email").
@@ -276,6 +378,116 @@ func NewAuthorError(field, reason string) *AuthorError {
 	}
 }
 
+// UnsafeSymlinkError provides structured information about a symbolic link whose target escapes the clone.
+type UnsafeSymlinkError struct {
+	// Path is the path of the symbolic link in the repository.
+	Path string
+	// Target is the target the link points to.
+	Target string
+}
+
+// Error implements the error interface.
+func (e *UnsafeSymlinkError) Error() string {
+	return fmt.Sprintf("symbolic link %s points outside the clone: %s", e.Path, e.Target)
+}
+
+// Unwrap enables errors.Is() compatibility with ErrUnsafeSymlink
+func (e *UnsafeSymlinkError) Unwrap() error {
+	return ErrUnsafeSymlink
+}
+
+// NewUnsafeSymlinkError creates a new UnsafeSymlinkError with the specified details.
+func NewUnsafeSymlinkError(path, target string) *UnsafeSymlinkError {
+	return &UnsafeSymlinkError{
+		Path:   path,
+		Target: target,
+	}
+}
+
+// MergeConflictError provides structured information about the conflicts of a merge.
+type MergeConflictError struct {
+	// Conflicts lists the conflicting paths, sorted by path.
+	Conflicts []MergeConflict
+}
+
+// Error implements the error interface.
+func (e *MergeConflictError) Error() string {
+	paths := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		paths[i] = fmt.Sprintf("%s (%s)", conflict.Path, conflict.Reason)
+	}
+	return fmt.Sprintf("merge conflict in %d files: %s", len(e.Conflicts), strings.Join(paths, ", "))
+}
+
+// Unwrap enables errors.Is() compatibility with ErrMergeConflict
+func (e *MergeConflictError) Unwrap() error {
+	return ErrMergeConflict
+}
+
+// NewMergeConflictError creates a new MergeConflictError with the specified conflicts.
+func NewMergeConflictError(conflicts []MergeConflict) *MergeConflictError {
+	return &MergeConflictError{
+		Conflicts: conflicts,
+	}
+}
+
+// PatchApplyError provides structured information about the hunks and files of a patch that do not apply.
+type PatchApplyError struct {
+	// Failures lists what did not apply, in the order of the patch.
+	Failures []PatchFailure
+}
+
+// Error implements the error interface.
+func (e *PatchApplyError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		if failure.Hunk > 0 {
+			failures[i] = fmt.Sprintf("%s hunk #%d at line %d (%s)", failure.Path, failure.Hunk, failure.Line, failure.Reason)
+		} else {
+			failures[i] = fmt.Sprintf("%s (%s)", failure.Path, failure.Reason)
+		}
+	}
+	return fmt.Sprintf("patch does not apply: %s", strings.Join(failures, ", "))
+}
+
+// Unwrap enables errors.Is() compatibility with ErrPatchDoesNotApply
+func (e *PatchApplyError) Unwrap() error {
+	return ErrPatchDoesNotApply
+}
+
+// NewPatchApplyError creates a new PatchApplyError with the specified failures.
+func NewPatchApplyError(failures []PatchFailure) *PatchApplyError {
+	return &PatchApplyError{
+		Failures: failures,
+	}
+}
+
+// RebaseConflictError provides structured information about a push that could not be rebased.
+type RebaseConflictError struct {
+	// RefName is the reference that moved.
+	RefName string
+	// Paths lists the paths changed on the reference that the staged changes also touch.
+	Paths []string
+}
+
+// Error implements the error interface.
+func (e *RebaseConflictError) Error() string {
+	return fmt.Sprintf("cannot rebase onto %s: changed upstream: %s", e.RefName, strings.Join(e.Paths, ", "))
+}
+
+// Unwrap enables errors.Is() compatibility with ErrRebaseConflict
+func (e *RebaseConflictError) Unwrap() error {
+	return ErrRebaseConflict
+}
+
+// NewRebaseConflictError creates a new RebaseConflictError with the specified details.
+func NewRebaseConflictError(refName string, paths []string) *RebaseConflictError {
+	return &RebaseConflictError{
+		RefName: refName,
+		Paths:   paths,
+	}
+}
+
 // ServerUnavailableError provides structured information about a Git server that is unavailable.
 // It is re-exported from the protocol/client package to avoid import cycles.
 type ServerUnavailableError = client.ServerUnavailableError
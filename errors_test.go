@@ -27,6 +27,7 @@ func TestGlobalErrors(t *testing.T) {
 		{"ErrEmptyCommitMessage", ErrEmptyCommitMessage, "empty commit message"},
 		{"ErrEmptyPath", ErrEmptyPath, "empty path"},
 		{"ErrEmptyRefName", ErrEmptyRefName, "empty ref name"},
+		{"ErrInvalidFileMode", ErrInvalidFileMode, "invalid file mode"},
 		{"ErrInvalidAuthor", ErrInvalidAuthor, "invalid author information"},
 	}
 
@@ -342,6 +343,40 @@ func TestAuthorError(t *testing.T) {
 	})
 }
 
+func TestUnsafeSymlinkError(t *testing.T) {
+	t.Parallel()
+
+	err := NewUnsafeSymlinkError("docs/link", "../../etc/passwd")
+	require.Equal(t, "docs/link", err.Path)
+	require.Equal(t, "../../etc/passwd", err.Target)
+	require.Equal(t, "symbolic link docs/link points outside the clone: ../../etc/passwd", err.Error())
+	require.ErrorIs(t, err, ErrUnsafeSymlink)
+	require.NotErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestMergeConflictError(t *testing.T) {
+	t.Parallel()
+
+	err := NewMergeConflictError([]MergeConflict{
+		{Path: "README.md", Reason: MergeConflictContent},
+		{Path: "logo.png", Reason: MergeConflictBinary},
+	})
+	require.Len(t, err.Conflicts, 2)
+	require.Equal(t, "merge conflict in 2 files: README.md (content), logo.png (binary)", err.Error())
+	require.ErrorIs(t, err, ErrMergeConflict)
+	require.NotErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestRebaseConflictError(t *testing.T) {
+	t.Parallel()
+
+	err := NewRebaseConflictError("refs/heads/main", []string{"a.txt", "docs/b.md"})
+	require.Equal(t, "refs/heads/main", err.RefName)
+	require.Equal(t, "cannot rebase onto refs/heads/main: changed upstream: a.txt, docs/b.md", err.Error())
+	require.ErrorIs(t, err, ErrRebaseConflict)
+	require.NotErrorIs(t, err, ErrMergeConflict)
+}
+
 func TestErrorsChaining(t *testing.T) {
 	t.Parallel()
 
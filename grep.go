@@ -0,0 +1,282 @@
+package nanogit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// grepChunkSize is how many candidate files Grep collects during the walk
+// before fetching and searching them, so MaxMatches can stop the walk early.
+const grepChunkSize = 100
+
+// binaryCheckBytes is how much of a blob is inspected for NUL bytes to
+// decide whether it is binary, matching git's heuristic.
+const binaryCheckBytes = 8000
+
+// GrepOptions configures Grep.
+type GrepOptions struct {
+	// Pattern is the regular expression matched against each line.
+	// This field is required.
+	Pattern *regexp.Regexp
+
+	// Paths restricts the search to files matching these glob patterns.
+	// Supports the same patterns as CloneOptions.IncludePaths.
+	// If empty, all files are searched. Directories no pattern can match
+	// below are not walked, so their trees are not fetched.
+	Paths []string
+
+	// MaxMatches stops the search once this many matches have been found.
+	// If 0, there is no limit.
+	MaxMatches int
+
+	// IgnoreBinary skips binary files (files with a NUL byte in their first
+	// 8000 bytes). When false, a binary file that matches is reported once,
+	// with Binary set and no line information.
+	IgnoreBinary bool
+
+	// ContextLines is the number of lines returned before and after each
+	// matching line.
+	ContextLines int
+
+	// BatchSize specifies how many blobs to fetch in a single request.
+	// See GetBlobsOptions.BatchSize.
+	BatchSize int
+
+	// Concurrency specifies how many blob fetches to perform in parallel.
+	// See GetBlobsOptions.Concurrency.
+	Concurrency int
+}
+
+// GrepMatch is a line matching the pattern passed to Grep.
+type GrepMatch struct {
+	// Path is the path of the file, relative to the repository root.
+	Path string
+	// Hash is the hash of the file's blob.
+	Hash hash.Hash
+	// LineNumber is the 1-based number of the matching line. It is 0 for a
+	// binary file.
+	LineNumber int
+	// Line is the matching line, without its line terminator.
+	Line string
+	// Before holds up to ContextLines lines preceding the match.
+	Before []string
+	// After holds up to ContextLines lines following the match.
+	After []string
+	// Binary is true when the match is in a binary file. Only one match is
+	// reported per binary file.
+	Binary bool
+}
+
+// Grep searches the files of a commit for lines matching a regular
+// expression, like `git grep`. The tree is walked lazily in tree order;
+// candidate files are fetched in batches and searched as they arrive, and
+// the walk stops as soon as MaxMatches is reached, so a bounded search does
+// not fetch the whole repository. Matches are returned in tree order and
+// line order.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - commitHash: Hash of the commit (or tree) to search
+//   - opts: Pattern, path filters, limits and context size
+//
+// Returns:
+//   - []GrepMatch: The matching lines
+//   - error: Error if Pattern is nil or the tree or blobs cannot be fetched
+//
+// Example:
+//
+//	matches, err := client.Grep(ctx, commitHash, nanogit.GrepOptions{
+//	    Pattern:      regexp.MustCompile(`"datasource":\s*"prometheus"`),
+//	    Paths:        []string{"dashboards/**/*.json"},
+//	    IgnoreBinary: true,
+//	    ContextLines: 2,
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	for _, m := range matches {
+//	    fmt.Printf("%s:%d: %s\n", m.Path, m.LineNumber, m.Line)
+//	}
+func (c *httpClient) Grep(ctx context.Context, commitHash hash.Hash, opts GrepOptions) ([]GrepMatch, error) {
+	if opts.Pattern == nil {
+		return nil, errors.New("grep pattern is required")
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Grep commit",
+		"commit_hash", commitHash.String(),
+		"pattern", opts.Pattern.String(),
+		"paths", opts.Paths,
+		"max_matches", opts.MaxMatches)
+
+	g := &grepper{client: c, opts: opts}
+
+	var candidates []FlatTreeEntry
+	err := c.WalkTree(ctx, commitHash, func(entry FlatTreeEntry) error {
+		if entry.Type == protocol.ObjectTypeTree {
+			if len(opts.Paths) > 0 && !mayIncludeBelow(entry.Path, opts.Paths, nil) {
+				return SkipDir
+			}
+			return nil
+		}
+		if entry.Type != protocol.ObjectTypeBlob {
+			return nil
+		}
+		if len(opts.Paths) > 0 && !matchesAnyPattern(entry.Path, opts.Paths) {
+			return nil
+		}
+
+		candidates = append(candidates, entry)
+		if len(candidates) < grepChunkSize {
+			return nil
+		}
+
+		done, err := g.search(ctx, candidates)
+		candidates = candidates[:0]
+		if err != nil {
+			return err
+		}
+		if done {
+			return SkipAll
+		}
+		return nil
+	}, WalkTreeOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("walk tree: %w", err)
+	}
+
+	if !g.done() && len(candidates) > 0 {
+		if _, err := g.search(ctx, candidates); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Debug("Grep completed",
+		"commit_hash", commitHash.String(),
+		"searched_files", g.searched,
+		"match_count", len(g.matches))
+
+	return g.matches, nil
+}
+
+// grepper holds the state of a single Grep call.
+type grepper struct {
+	client   *httpClient
+	opts     GrepOptions
+	matches  []GrepMatch
+	searched int
+}
+
+// done reports whether MaxMatches has been reached.
+func (g *grepper) done() bool {
+	return g.opts.MaxMatches > 0 && len(g.matches) >= g.opts.MaxMatches
+}
+
+// search fetches the blobs of files and searches them in order. It returns
+// true once MaxMatches has been reached.
+func (g *grepper) search(ctx context.Context, files []FlatTreeEntry) (bool, error) {
+	var hashes []hash.Hash
+	contents := make(map[hash.Hash][]byte, len(files))
+	for _, entry := range files {
+		if _, ok := contents[entry.Hash]; !ok {
+			contents[entry.Hash] = nil
+			hashes = append(hashes, entry.Hash)
+		}
+	}
+
+	var mu sync.Mutex
+	err := g.client.GetBlobs(ctx, hashes, func(blob *Blob) error {
+		mu.Lock()
+		defer mu.Unlock()
+		contents[blob.Hash] = blob.Content
+		return nil
+	}, GetBlobsOptions{
+		BatchSize:   g.opts.BatchSize,
+		Concurrency: g.opts.Concurrency,
+	})
+	if err != nil {
+		return false, fmt.Errorf("fetch blobs: %w", err)
+	}
+
+	for _, entry := range files {
+		g.searchFile(entry, contents[entry.Hash])
+		if g.done() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// searchFile appends the matches found in a single file.
+func (g *grepper) searchFile(entry FlatTreeEntry, content []byte) {
+	g.searched++
+
+	if isBinary(content) {
+		if !g.opts.IgnoreBinary && g.opts.Pattern.Match(content) {
+			g.matches = append(g.matches, GrepMatch{Path: entry.Path, Hash: entry.Hash, Binary: true})
+		}
+		return
+	}
+
+	lines := splitLines(content)
+	for i, line := range lines {
+		if !g.opts.Pattern.MatchString(line) {
+			continue
+		}
+
+		match := GrepMatch{
+			Path:       entry.Path,
+			Hash:       entry.Hash,
+			LineNumber: i + 1,
+			Line:       line,
+		}
+		if n := g.opts.ContextLines; n > 0 {
+			match.Before = lines[max(0, i-n):i]
+			match.After = lines[i+1 : min(len(lines), i+1+n)]
+		}
+
+		g.matches = append(g.matches, match)
+		if g.done() {
+			return
+		}
+	}
+}
+
+// isBinary reports whether content looks binary, using git's heuristic of a
+// NUL byte in the first 8000 bytes.
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binaryCheckBytes)], 0) >= 0
+}
+
+// splitLines splits content into lines without their terminators ("\n" or
+// "\r\n"). A trailing newline does not produce an empty last line.
+func splitLines(content []byte) []string {
+	text := string(content)
+	if text == "" {
+		return nil
+	}
+
+	lines := make([]string, 0, bytes.Count(content, []byte{'\n'})+1)
+	for len(text) > 0 {
+		var line string
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			line, text = text[:i], text[i+1:]
+		} else {
+			line, text = text, ""
+		}
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
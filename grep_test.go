@@ -0,0 +1,155 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrep(t *testing.T) {
+	t.Parallel()
+
+	newGrepTestRepo := func(t *testing.T) (*testRepo, hash.Hash) {
+		t.Helper()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", map[string]string{
+			"README.md":                "# Project\nUses prometheus for metrics.\n",
+			"dashboards/a.json":        "{\n  \"title\": \"A\",\n  \"datasource\": \"prometheus\",\n  \"panels\": []\n}\n",
+			"dashboards/b.json":        "{\r\n  \"datasource\": \"loki\"\r\n}\r\n",
+			"dashboards/nested/c.json": "{\"datasource\": \"prometheus\"}",
+			"logo.png":                 "\x89PNG\x00prometheus",
+		})
+		return repo, commit
+	}
+
+	t.Run("returns matches with line numbers in tree order", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newGrepTestRepo(t)
+
+		matches, err := repo.client().Grep(context.Background(), commit, GrepOptions{
+			Pattern:      regexp.MustCompile(`prometheus`),
+			IgnoreBinary: true,
+		})
+		require.NoError(t, err)
+
+		var got []string
+		for _, m := range matches {
+			got = append(got, fmt.Sprintf("%s:%d:%s", m.Path, m.LineNumber, m.Line))
+		}
+		assert.Equal(t, []string{
+			"README.md:2:Uses prometheus for metrics.",
+			`dashboards/a.json:3:  "datasource": "prometheus",`,
+			`dashboards/nested/c.json:1:{"datasource": "prometheus"}`,
+		}, got)
+	})
+
+	t.Run("filters paths", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newGrepTestRepo(t)
+
+		matches, err := repo.client().Grep(context.Background(), commit, GrepOptions{
+			Pattern: regexp.MustCompile(`"datasource"`),
+			Paths:   []string{"dashboards/*.json"},
+		})
+		require.NoError(t, err)
+		require.Len(t, matches, 2)
+		assert.Equal(t, "dashboards/a.json", matches[0].Path)
+		assert.Equal(t, "dashboards/b.json", matches[1].Path)
+		assert.Equal(t, `  "datasource": "loki"`, matches[1].Line, "CRLF is stripped")
+	})
+
+	t.Run("does not walk directories the paths exclude", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		commit := repo.commit("initial", map[string]string{
+			"dashboards/a.json":    `{"datasource": "prometheus"}`,
+			"src/pkg/main.go":      "package main // prometheus",
+			"src/pkg/deep/deep.go": "package deep // prometheus",
+		})
+		pkg := repo.tree(map[string]string{
+			"main.go":      "package main // prometheus",
+			"deep/deep.go": "package deep // prometheus",
+		})
+
+		matches, err := repo.client().Grep(context.Background(), commit, GrepOptions{
+			Pattern: regexp.MustCompile(`prometheus`),
+			Paths:   []string{"dashboards/*.json"},
+		})
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		assert.Equal(t, "dashboards/a.json", matches[0].Path)
+
+		for _, wants := range repo.fetches {
+			assert.NotContains(t, wants, pkg, "src/pkg should not be fetched")
+		}
+	})
+
+	t.Run("context lines stop at file boundaries", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newGrepTestRepo(t)
+
+		matches, err := repo.client().Grep(context.Background(), commit, GrepOptions{
+			Pattern:      regexp.MustCompile(`"datasource": "prometheus"`),
+			Paths:        []string{"dashboards/**"},
+			ContextLines: 2,
+		})
+		require.NoError(t, err)
+		require.Len(t, matches, 2)
+		assert.Equal(t, []string{"{", `  "title": "A",`}, matches[0].Before)
+		assert.Equal(t, []string{`  "panels": []`, "}"}, matches[0].After)
+		assert.Empty(t, matches[1].Before)
+		assert.Empty(t, matches[1].After)
+	})
+
+	t.Run("reports binary files once unless ignored", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newGrepTestRepo(t)
+
+		matches, err := repo.client().Grep(context.Background(), commit, GrepOptions{
+			Pattern: regexp.MustCompile(`prometheus`),
+			Paths:   []string{"*.png"},
+		})
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		assert.True(t, matches[0].Binary)
+		assert.Equal(t, "logo.png", matches[0].Path)
+		assert.Zero(t, matches[0].LineNumber)
+	})
+
+	t.Run("stops fetching once MaxMatches is reached", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		files := make(map[string]string)
+		for i := range 2 * grepChunkSize {
+			files[fmt.Sprintf("f%03d.txt", i)] = fmt.Sprintf("match %d\n", i)
+		}
+		commit := repo.commit("many", files)
+		last := repo.blob(fmt.Sprintf("match %d\n", 2*grepChunkSize-1))
+
+		matches, err := repo.client().Grep(context.Background(), commit, GrepOptions{
+			Pattern:    regexp.MustCompile(`match`),
+			MaxMatches: 3,
+			BatchSize:  50,
+		})
+		require.NoError(t, err)
+		require.Len(t, matches, 3)
+		assert.Equal(t, "f002.txt", matches[2].Path)
+
+		for _, wants := range repo.fetches {
+			assert.NotContains(t, wants, last, "files after the limit should not be fetched")
+		}
+	})
+
+	t.Run("requires a pattern", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newGrepTestRepo(t)
+
+		_, err := repo.client().Grep(context.Background(), commit, GrepOptions{})
+		require.ErrorContains(t, err, "pattern is required")
+	})
+}
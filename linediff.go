@@ -0,0 +1,169 @@
+package nanogit
+
+import "slices"
+
+// lineOp is the kind of a lineEdit.
+type lineOp int
+
+const (
+	// lineEqual keeps line A of the old version as line B of the new one.
+	lineEqual lineOp = iota
+	// lineDelete removes line A of the old version.
+	lineDelete
+	// lineInsert adds line B of the new version.
+	lineInsert
+)
+
+// lineEdit is one step of an edit script turning one list of lines into
+// another. A is the 0-based index in the old lines (unused for inserts) and
+// B the 0-based index in the new lines (unused for deletes).
+type lineEdit struct {
+	Op lineOp
+	A  int
+	B  int
+}
+
+// diffLines returns a shortest edit script from a to b, using the
+// linear-space variant of Myers' O(ND) algorithm: each step finds the middle
+// snake of an optimal path and recurses on both halves, so memory stays
+// proportional to N+M however different the inputs are. Edits are ordered by
+// position; deletions come before insertions at the same place.
+func diffLines(a, b []string) []lineEdit {
+	size := 2*(len(a)+len(b)) + 4
+	d := &lineDiffer{
+		a:      a,
+		b:      b,
+		vf:     make([]int, size),
+		vb:     make([]int, size),
+		offset: size / 2,
+		edits:  make([]lineEdit, 0, max(len(a), len(b))),
+	}
+	d.compare(0, len(a), 0, len(b))
+	return orderChanges(d.edits)
+}
+
+// lineDiffer holds the state shared by the recursive steps of diffLines.
+// vf and vb are the forward and backward furthest reaching paths, indexed by
+// diagonal plus offset, and are reused by every step.
+type lineDiffer struct {
+	a, b   []string
+	vf, vb []int
+	offset int
+	edits  []lineEdit
+}
+
+// compare appends the edit script from a[aLo:aHi] to b[bLo:bHi].
+func (d *lineDiffer) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.edits = append(d.edits, lineEdit{Op: lineEqual, A: aLo, B: bLo})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-1-suffix] == d.b[bHi-1-suffix] {
+		suffix++
+	}
+	aHi -= suffix
+	bHi -= suffix
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.edits = append(d.edits, lineEdit{Op: lineInsert, A: aLo, B: j})
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.edits = append(d.edits, lineEdit{Op: lineDelete, A: i, B: bLo})
+		}
+	default:
+		x, y := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		d.compare(x, aHi, y, bHi)
+	}
+
+	for i := range suffix {
+		d.edits = append(d.edits, lineEdit{Op: lineEqual, A: aHi + i, B: bHi + i})
+	}
+}
+
+// middleSnake runs Myers' search from both ends of a[aLo:aHi] and
+// b[bLo:bHi] until the paths overlap, and returns a point on a shortest edit
+// path that splits it into two smaller problems. Both ranges must be non-empty
+// and differ in their first and last lines. The paths always overlap, so
+// middleSnake never gets past its loop; it panics if it does, as that can
+// only be a bug here.
+func (d *lineDiffer) middleSnake(aLo, aHi, bLo, bHi int) (int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	vf, vb, off := d.vf, d.vb, d.offset
+
+	// Forward diagonals are k = x-y from (0, 0); backward ones are numbered
+	// c = k-delta from (n, m), with vb holding the smallest x reached.
+	vf[off+1] = 0
+	vb[off+1] = n + 1
+	for step := 0; step <= (n+m+1)/2; step++ {
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || (k != step && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if c := k - delta; odd && c >= -(step-1) && c <= step-1 && vb[off+c] <= x {
+				return aLo + x, bLo + y
+			}
+		}
+		for c := -step; c <= step; c += 2 {
+			var x int
+			if c == -step || (c != step && vb[off+c+1]-1 < vb[off+c-1]) {
+				x = vb[off+c+1] - 1
+			} else {
+				x = vb[off+c-1]
+			}
+			y := x - c - delta
+			for x > 0 && y > 0 && d.a[aLo+x-1] == d.b[bLo+y-1] {
+				x--
+				y--
+			}
+			vb[off+c] = x
+			if k := c + delta; !odd && k >= -step && k <= step && vf[off+k] >= x {
+				x = vf[off+k]
+				return aLo + x, bLo + x - k
+			}
+		}
+	}
+	// Unreachable: a shortest edit path has at most D = n+m edits, and the
+	// forward and backward searches overlap once they have taken ceil(D/2)
+	// steps between them (Myers, lemma 3), which is at most (n+m+1)/2. The
+	// vectors hold every diagonal those steps reach, as diffLines sizes them
+	// for the whole input.
+	panic("diffLines: no middle snake")
+}
+
+// orderChanges moves the deletions of every run of changes before its
+// insertions, which the recursion may leave interleaved.
+func orderChanges(edits []lineEdit) []lineEdit {
+	for start := 0; start < len(edits); {
+		if edits[start].Op == lineEqual {
+			start++
+			continue
+		}
+		end := start
+		for end < len(edits) && edits[end].Op != lineEqual {
+			end++
+		}
+		// lineDelete sorts before lineInsert.
+		slices.SortStableFunc(edits[start:end], func(x, y lineEdit) int {
+			return int(x.Op) - int(y.Op)
+		})
+		start = end
+	}
+	return edits
+}
@@ -0,0 +1,135 @@
+package nanogit
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLines(t *testing.T) {
+	t.Parallel()
+
+	render := func(a, b []string, edits []lineEdit) string {
+		var sb strings.Builder
+		for _, e := range edits {
+			switch e.Op {
+			case lineEqual:
+				sb.WriteString(" " + a[e.A] + "\n")
+			case lineDelete:
+				sb.WriteString("-" + a[e.A] + "\n")
+			case lineInsert:
+				sb.WriteString("+" + b[e.B] + "\n")
+			}
+		}
+		return sb.String()
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "identical", a: "a b c", b: "a b c", want: " a\n b\n c\n"},
+		{name: "both empty", a: "", b: "", want: ""},
+		{name: "from empty", a: "", b: "a b", want: "+a\n+b\n"},
+		{name: "to empty", a: "a b", b: "", want: "-a\n-b\n"},
+		{name: "changed line", a: "a b c", b: "a x c", want: " a\n-b\n+x\n c\n"},
+		{name: "insert in the middle", a: "a c", b: "a b c", want: " a\n+b\n c\n"},
+		{
+			name: "shortest script",
+			a:    "a b c a b b a",
+			b:    "c b a b a c",
+			want: "-a\n+c\n b\n-c\n a\n b\n-b\n a\n+c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+			edits := diffLines(a, b)
+			assert.Equal(t, tt.want, render(a, b, edits))
+
+			// Every line of both sides appears exactly once, in order.
+			var nextA, nextB int
+			for _, e := range edits {
+				if e.Op != lineInsert {
+					assert.Equal(t, nextA, e.A)
+					nextA++
+				}
+				if e.Op != lineDelete {
+					assert.Equal(t, nextB, e.B)
+					nextB++
+				}
+			}
+			assert.Equal(t, len(a), nextA)
+			assert.Equal(t, len(b), nextB)
+		})
+	}
+}
+
+func TestDiffLines_Shortest(t *testing.T) {
+	t.Parallel()
+
+	// lcs is the textbook quadratic longest common subsequence, whose
+	// complement is the length of a shortest edit script.
+	lcs := func(a, b []string) int {
+		prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+		for i := range a {
+			for j := range b {
+				if a[i] == b[j] {
+					cur[j+1] = prev[j] + 1
+				} else {
+					cur[j+1] = max(prev[j+1], cur[j])
+				}
+			}
+			prev, cur = cur, prev
+		}
+		return prev[len(b)]
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	lines := func() []string {
+		out := make([]string, rng.IntN(30))
+		for i := range out {
+			out[i] = string(rune('a' + rng.IntN(4)))
+		}
+		return out
+	}
+	for range 500 {
+		a, b := lines(), lines()
+		changes := 0
+		for _, e := range diffLines(a, b) {
+			if e.Op != lineEqual {
+				changes++
+			}
+		}
+		require.Equal(t, len(a)+len(b)-2*lcs(a, b), changes, "%q -> %q", a, b)
+	}
+}
+
+// TestDiffLines_LargeRewrite is not parallel so that the memory statistics
+// only account for the diff itself.
+func TestDiffLines_LargeRewrite(t *testing.T) {
+	const n = 5000
+	a, b := make([]string, n), make([]string, n)
+	for i := range n {
+		a[i] = fmt.Sprintf("old line %d", i)
+		b[i] = fmt.Sprintf("new line %d", i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := diffLines(a, b)
+	runtime.ReadMemStats(&after)
+
+	require.Len(t, edits, 2*n)
+	// The inputs are completely different, so keeping a copy of the search
+	// state per edit step would take gigabytes.
+	require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(8<<20))
+}
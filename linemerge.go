@@ -0,0 +1,89 @@
+package nanogit
+
+import "slices"
+
+// mergeChunk is a run of lines of a three-way merge. Base, Ours and Theirs
+// are the half-open ranges of lines the chunk covers in each version.
+type mergeChunk struct {
+	Base   [2]int
+	Ours   [2]int
+	Theirs [2]int
+	// Lines is the merged content of the chunk. It is nil for a conflict.
+	Lines []string
+	// Conflict is set when both sides changed the chunk differently.
+	Conflict bool
+}
+
+// mergeLines merges the changes ours and theirs made to base, like diff3.
+// Both versions are diffed against base with diffLines; a base line kept
+// by both sides is stable, and the lines between stable ones form a chunk
+// taken from the side that changed it. When both sides changed a chunk,
+// differently, it is a conflict. As in git, changes to adjacent lines
+// conflict.
+func mergeLines(base, ours, theirs []string) []mergeChunk {
+	matchOurs := matchedLines(base, ours)
+	matchTheirs := matchedLines(base, theirs)
+
+	var chunks []mergeChunk
+	b, o, t := 0, 0, 0
+	for {
+		// Stable lines, kept by both sides at the current position.
+		start := b
+		for b < len(base) && matchOurs[b] == o && matchTheirs[b] == t {
+			b, o, t = b+1, o+1, t+1
+		}
+		if b > start {
+			chunks = append(chunks, mergeChunk{
+				Base:   [2]int{start, b},
+				Ours:   [2]int{o - (b - start), o},
+				Theirs: [2]int{t - (b - start), t},
+				Lines:  base[start:b],
+			})
+		}
+		if b == len(base) && o == len(ours) && t == len(theirs) {
+			return chunks
+		}
+
+		// The unstable chunk ends at the next base line both sides kept.
+		end := b
+		for end < len(base) && (matchOurs[end] < 0 || matchTheirs[end] < 0) {
+			end++
+		}
+		oursEnd, theirsEnd := len(ours), len(theirs)
+		if end < len(base) {
+			oursEnd, theirsEnd = matchOurs[end], matchTheirs[end]
+		}
+
+		chunk := mergeChunk{
+			Base:   [2]int{b, end},
+			Ours:   [2]int{o, oursEnd},
+			Theirs: [2]int{t, theirsEnd},
+		}
+		baseLines, oursLines, theirsLines := base[b:end], ours[o:oursEnd], theirs[t:theirsEnd]
+		switch {
+		case slices.Equal(oursLines, baseLines):
+			chunk.Lines = theirsLines
+		case slices.Equal(theirsLines, baseLines), slices.Equal(oursLines, theirsLines):
+			chunk.Lines = oursLines
+		default:
+			chunk.Conflict = true
+		}
+		chunks = append(chunks, chunk)
+		b, o, t = end, oursEnd, theirsEnd
+	}
+}
+
+// matchedLines returns, for each line of base, the index of the same line
+// in other when the diff between them keeps it, or -1.
+func matchedLines(base, other []string) []int {
+	match := make([]int, len(base))
+	for i := range match {
+		match[i] = -1
+	}
+	for _, edit := range diffLines(base, other) {
+		if edit.Op == lineEqual {
+			match[edit.A] = edit.B
+		}
+	}
+	return match
+}
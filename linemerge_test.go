@@ -0,0 +1,96 @@
+package nanogit
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeLines(t *testing.T) {
+	t.Parallel()
+
+	// render joins the merged lines, marking conflicts like git with the
+	// lines of both sides.
+	render := func(ours, theirs []string, chunks []mergeChunk) string {
+		var out []string
+		for _, c := range chunks {
+			if !c.Conflict {
+				out = append(out, c.Lines...)
+				continue
+			}
+			out = append(out, "<")
+			out = append(out, ours[c.Ours[0]:c.Ours[1]]...)
+			out = append(out, "=")
+			out = append(out, theirs[c.Theirs[0]:c.Theirs[1]]...)
+			out = append(out, ">")
+		}
+		return strings.Join(out, " ")
+	}
+
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+	}{
+		{name: "unchanged", base: "a b c", ours: "a b c", theirs: "a b c", want: "a b c"},
+		{name: "all empty", want: ""},
+		{name: "only ours changed", base: "a b c", ours: "a x c", theirs: "a b c", want: "a x c"},
+		{name: "only theirs changed", base: "a b c", ours: "a b c", theirs: "a b y", want: "a b y"},
+		{name: "distant changes", base: "a b c d e", ours: "x b c d e", theirs: "a b c d y", want: "x b c d y"},
+		{name: "same change", base: "a b c", ours: "a x c", theirs: "a x c", want: "a x c"},
+		{name: "insert and delete", base: "a b c d", ours: "n a b c d", theirs: "a b c", want: "n a b c"},
+		{name: "conflicting change", base: "a b c", ours: "a x c", theirs: "a y c", want: "a < x = y > c"},
+		{name: "change and delete", base: "a b c", ours: "a x c", theirs: "a c", want: "a < x = > c"},
+		{name: "adjacent changes conflict", base: "a b c d", ours: "a x c d", theirs: "a b y d", want: "a < x c = b y > d"},
+		{name: "both added", base: "", ours: "a b", theirs: "c", want: "< a b = c >"},
+		{name: "both appended", base: "a", ours: "a b", theirs: "a c", want: "a < b = c >"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			base, ours, theirs := strings.Fields(tt.base), strings.Fields(tt.ours), strings.Fields(tt.theirs)
+			chunks := mergeLines(base, ours, theirs)
+			assert.Equal(t, tt.want, render(ours, theirs, chunks))
+
+			// The chunks cover every line of the three versions, in order.
+			var b, o, th int
+			for _, c := range chunks {
+				assert.Equal(t, [2]int{b, c.Base[1]}, c.Base)
+				assert.Equal(t, [2]int{o, c.Ours[1]}, c.Ours)
+				assert.Equal(t, [2]int{th, c.Theirs[1]}, c.Theirs)
+				b, o, th = c.Base[1], c.Ours[1], c.Theirs[1]
+			}
+			assert.Equal(t, []int{len(base), len(ours), len(theirs)}, []int{b, o, th})
+		})
+	}
+}
+
+// TestMergeLines_LargeRewrite is not parallel so that the memory statistics
+// only account for the merge itself.
+func TestMergeLines_LargeRewrite(t *testing.T) {
+	const n = 4000
+	base, ours := make([]string, n), make([]string, n)
+	for i := range n {
+		base[i] = fmt.Sprintf("old line %d", i)
+		ours[i] = fmt.Sprintf("new line %d", i)
+	}
+	theirs := slices.Clone(base)
+	theirs[n-1] = "changed last line"
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	chunks := mergeLines(base, ours, theirs)
+	runtime.ReadMemStats(&after)
+
+	// Rewriting the whole file conflicts with any other change to it.
+	require.Len(t, chunks, 1)
+	require.True(t, chunks[0].Conflict)
+	require.Equal(t, [2]int{0, n}, chunks[0].Ours)
+	require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(8<<20))
+}
@@ -0,0 +1,663 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+)
+
+// mergeBlobBatchSize is how many blobs a merge fetches per request when
+// merging the files both sides changed.
+const mergeBlobBatchSize = 50
+
+// MergeStrategy chooses how Merge resolves conflicts.
+type MergeStrategy int
+
+const (
+	// MergeStrategyDefault resolves nothing: Merge fails with a
+	// MergeConflictError listing every conflict and creates no commit.
+	MergeStrategyDefault MergeStrategy = iota
+
+	// MergeStrategyOurs resolves conflicts in favor of the branch merged
+	// into, like `git merge -X ours`: conflicting hunks take its lines and
+	// other conflicts its version of the file. Changes from the other
+	// commit that do not conflict are still merged.
+	MergeStrategyOurs
+
+	// MergeStrategyTheirs resolves conflicts in favor of the merged commit,
+	// like `git merge -X theirs`.
+	MergeStrategyTheirs
+)
+
+// MergeConflictReason is why a path conflicts in a merge.
+type MergeConflictReason string
+
+const (
+	// MergeConflictContent is reported when both sides changed the same
+	// lines of a file, or the target of a symbolic link or the commit of a
+	// submodule, differently. A file added by both sides with different
+	// content conflicts the same way.
+	MergeConflictContent MergeConflictReason = "content"
+	// MergeConflictBinary is reported when both sides changed a binary
+	// file, which is not merged line by line.
+	MergeConflictBinary MergeConflictReason = "binary"
+	// MergeConflictModifyDelete is reported when one side deleted a file
+	// the other modified.
+	MergeConflictModifyDelete MergeConflictReason = "modify/delete"
+	// MergeConflictMode is reported when both sides changed the mode of a
+	// file differently, e.g. only one made it executable when adding it.
+	MergeConflictMode MergeConflictReason = "mode"
+	// MergeConflictFileType is reported when the sides turned a path into
+	// different kinds of entries: a file, a symbolic link or a submodule.
+	MergeConflictFileType MergeConflictReason = "file type"
+	// MergeConflictFileDirectory is reported when one side has a file at
+	// a path where the other has a directory.
+	MergeConflictFileDirectory MergeConflictReason = "file/directory"
+)
+
+// MergeOptions configures Merge.
+type MergeOptions struct {
+	// Message is the message of the merge commit. If empty, it is
+	// "Merge commit '<other>' into <branch>".
+	Message string
+
+	// Author is the author of the merge commit. It is required.
+	Author Author
+
+	// Committer is the committer of the merge commit. If its name and
+	// email are empty, the author is used.
+	Committer Committer
+
+	// Strategy chooses how conflicts are resolved. By default they are
+	// reported with a MergeConflictError.
+	Strategy MergeStrategy
+
+	// WriterOptions configure the StagedWriter that creates and pushes the
+	// merge commit, e.g. to sign it.
+	WriterOptions []WriterOption
+}
+
+// MergeResult describes a merge done by Merge.
+type MergeResult struct {
+	// Commit is the merge commit the ref now points at, or the commit it
+	// already pointed at when UpToDate is set.
+	Commit *Commit
+
+	// MergeBase is the common ancestor the changes of both sides were
+	// computed from.
+	MergeBase hash.Hash
+
+	// UpToDate is set when the merged commit was already part of the
+	// history of the ref, so nothing was done.
+	UpToDate bool
+
+	// Resolved lists the conflicts MergeOptions.Strategy resolved.
+	Resolved []MergeConflict
+}
+
+// MergeConflict describes a path both sides of a merge changed in ways
+// that cannot be combined.
+type MergeConflict struct {
+	// Path is the path of the conflicting file. For a file/directory
+	// conflict, it is the path of the file.
+	Path string
+
+	// Reason is why the path conflicts.
+	Reason MergeConflictReason
+
+	// Base, Ours and Theirs are the entries of Path in the merge base, the
+	// branch merged into and the merged commit, or nil where it is absent.
+	Base, Ours, Theirs *FlatTreeEntry
+
+	// Hunks are the conflicting hunks of a MergeConflictContent conflict
+	// in a text file. It is empty for other conflicts.
+	Hunks []MergeConflictHunk
+}
+
+// MergeConflictHunk is a range of lines both sides of a merge changed
+// differently. Lines are given without their line terminators.
+type MergeConflictHunk struct {
+	// BaseLine, OursLine and TheirsLine are the 1-based numbers of the
+	// first line of the hunk in each version. When a version has no line
+	// in the hunk, it is the number of the line that follows it.
+	BaseLine   int
+	OursLine   int
+	TheirsLine int
+
+	// Base, Ours and Theirs are the lines of the hunk in each version.
+	Base   []string
+	Ours   []string
+	Theirs []string
+}
+
+// Merge merges the commit other into the branch ref, like `git merge
+// --no-ff`, and pushes a merge commit whose parents are ref.Hash and other.
+// The merge runs on the server's objects, without a checkout: the trees of
+// both sides are compared with their merge base path by path, a path
+// changed on one side only takes that side's version, and files changed
+// on both sides are merged line by line like diff3. Renames are not
+// detected.
+//
+// When changes conflict, Merge fails with a MergeConflictError listing the
+// paths and conflicting hunks, unless MergeOptions.Strategy resolves them
+// in favor of one side. If other is already part of the history of ref,
+// nothing is pushed and the result is UpToDate. The push fails if ref was
+// updated since ref.Hash was read.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - ref: Branch to merge into, with the hash it is expected to point at
+//   - other: Hash of the commit to merge
+//   - opts: Message, author, committer and conflict strategy
+//
+// Returns:
+//   - *MergeResult: The merge commit and the conflicts the strategy resolved
+//   - error: MergeConflictError on conflicts, ErrNoMergeBase for unrelated histories, or a fetch or push error
+//
+// Example:
+//
+//	ref, err := client.GetRef(ctx, "refs/heads/production")
+//	if err != nil {
+//	    return err
+//	}
+//	result, err := client.Merge(ctx, ref, stagingHash, nanogit.MergeOptions{
+//	    Author: nanogit.Author{Name: "Bot", Email: "bot@example.com", Time: time.Now()},
+//	})
+//	var conflictErr *nanogit.MergeConflictError
+//	if errors.As(err, &conflictErr) {
+//	    for _, conflict := range conflictErr.Conflicts {
+//	        fmt.Println("conflict in", conflict.Path)
+//	    }
+//	}
+func (c *httpClient) Merge(ctx context.Context, ref Ref, other hash.Hash, opts MergeOptions) (*MergeResult, error) {
+	if opts.Author.Name == "" || opts.Author.Email == "" {
+		return nil, NewAuthorError("author", "missing name or email")
+	}
+	committer := opts.Committer
+	if committer.Name == "" && committer.Email == "" {
+		committer = Committer(opts.Author)
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Merge",
+		"ref_name", ref.Name,
+		"ref_hash", ref.Hash.String(),
+		"other_hash", other.String(),
+		"strategy", opts.Strategy)
+
+	// Share fetched commits and trees between the steps below.
+	ctx, _ = storage.FromContextOrInMemory(ctx)
+
+	mergeBase, err := c.MergeBase(ctx, ref.Hash, other)
+	if err != nil {
+		return nil, fmt.Errorf("find merge base of %s and %s: %w", ref.Hash.String(), other.String(), err)
+	}
+	if mergeBase == other {
+		commit, err := c.GetCommit(ctx, ref.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("get commit %s: %w", ref.Hash.String(), err)
+		}
+		logger.Debug("Merge already up to date",
+			"ref_name", ref.Name,
+			"other_hash", other.String())
+		return &MergeResult{Commit: commit, MergeBase: mergeBase, UpToDate: true}, nil
+	}
+
+	merge, err := c.mergeTrees(ctx, mergeBase, ref.Hash, other, opts.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	if len(merge.conflicts) > 0 && opts.Strategy == MergeStrategyDefault {
+		logger.Debug("Merge conflicts",
+			"ref_name", ref.Name,
+			"other_hash", other.String(),
+			"conflict_count", len(merge.conflicts))
+		return nil, NewMergeConflictError(merge.conflicts)
+	}
+
+	writer, err := c.NewStagedWriter(ctx, ref, opts.WriterOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("create writer for %s: %w", ref.Name, err)
+	}
+	staged := writer.(*stagedWriter)
+	defer func() { _ = staged.Cleanup(ctx) }()
+
+	if err := staged.applyMerge(ctx, merge.result); err != nil {
+		return nil, fmt.Errorf("stage merged tree: %w", err)
+	}
+
+	message := opts.Message
+	if message == "" {
+		message = fmt.Sprintf("Merge commit '%s' into %s\n", other.String(), strings.TrimPrefix(ref.Name, "refs/heads/"))
+	}
+	commit, err := staged.commit(ctx, message, opts.Author, committer, []hash.Hash{other})
+	if err != nil {
+		return nil, err
+	}
+	if err := staged.Push(ctx); err != nil {
+		return nil, fmt.Errorf("push merge commit: %w", err)
+	}
+
+	logger.Debug("Merge completed",
+		"ref_name", ref.Name,
+		"commit_hash", commit.Hash.String(),
+		"merge_base", mergeBase.String(),
+		"resolved_count", len(merge.conflicts))
+
+	return &MergeResult{Commit: commit, MergeBase: mergeBase, Resolved: merge.conflicts}, nil
+}
+
+// mergeSide is the side of a merge an entry of the result comes from.
+type mergeSide int
+
+const (
+	mergeSideBoth mergeSide = iota
+	mergeSideOurs
+	mergeSideTheirs
+)
+
+// mergedEntry is a file, symbolic link or submodule of a merged tree.
+type mergedEntry struct {
+	FlatTreeEntry
+	side mergeSide
+	// content is the content of a file merged line by line, whose blob is
+	// not stored yet and whose Hash is unset.
+	content []byte
+}
+
+// treeMerge holds the state of a three-way merge of trees.
+type treeMerge struct {
+	strategy           MergeStrategy
+	base, ours, theirs map[string]*FlatTreeEntry
+	result             map[string]*mergedEntry
+	conflicts          []MergeConflict
+}
+
+// mergeTrees merges the trees of the commits ours and theirs, whose merge
+// base is base. Conflicts are recorded and, with a strategy, resolved.
+func (c *httpClient) mergeTrees(ctx context.Context, base, ours, theirs hash.Hash, strategy MergeStrategy) (*treeMerge, error) {
+	m := &treeMerge{strategy: strategy, result: make(map[string]*mergedEntry)}
+	var err error
+	if m.base, err = c.mergeFiles(ctx, base); err != nil {
+		return nil, err
+	}
+	if m.ours, err = c.mergeFiles(ctx, ours); err != nil {
+		return nil, err
+	}
+	if m.theirs, err = c.mergeFiles(ctx, theirs); err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]*FlatTreeEntry{m.base, m.ours, m.theirs} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	if err := c.mergePaths(ctx, m, sorted); err != nil {
+		return nil, err
+	}
+	m.resolveFileDirectoryConflicts()
+
+	sort.SliceStable(m.conflicts, func(i, j int) bool { return m.conflicts[i].Path < m.conflicts[j].Path })
+	return m, nil
+}
+
+// mergePaths merges the entries of the given paths, in order, from the
+// three versions of the merge into its result.
+func (c *httpClient) mergePaths(ctx context.Context, m *treeMerge, paths []string) error {
+	// Files both sides changed are merged line by line once their content
+	// is fetched.
+	var textMerges []string
+	for _, path := range paths {
+		b, o, t := m.base[path], m.ours[path], m.theirs[path]
+		switch {
+		case sameEntry(o, t):
+			m.take(path, o, mergeSideBoth)
+		case sameEntry(o, b):
+			m.take(path, t, mergeSideTheirs)
+		case sameEntry(t, b):
+			m.take(path, o, mergeSideOurs)
+		case o == nil || t == nil:
+			m.conflict(MergeConflict{Path: path, Reason: MergeConflictModifyDelete})
+		case entryKind(o.Mode) != entryKind(t.Mode):
+			m.conflict(MergeConflict{Path: path, Reason: MergeConflictFileType})
+		case o.Mode == 0o120000 || o.Mode == 0o160000:
+			m.conflict(MergeConflict{Path: path, Reason: MergeConflictContent})
+		default:
+			textMerges = append(textMerges, path)
+		}
+	}
+
+	return c.mergeTextFiles(ctx, m, textMerges)
+}
+
+// mergeFiles returns the files, symbolic links and submodules of the tree
+// of a commit, by path.
+func (c *httpClient) mergeFiles(ctx context.Context, commit hash.Hash) (map[string]*FlatTreeEntry, error) {
+	tree, submodules, err := c.getFlatTreeWithSubmodules(ctx, commit)
+	if err != nil {
+		return nil, fmt.Errorf("get tree of commit %s: %w", commit.String(), err)
+	}
+	files := make(map[string]*FlatTreeEntry, len(tree.Entries)+len(submodules))
+	for i := range tree.Entries {
+		if tree.Entries[i].Type != protocol.ObjectTypeTree {
+			files[tree.Entries[i].Path] = &tree.Entries[i]
+		}
+	}
+	for i := range submodules {
+		files[submodules[i].Path] = &submodules[i]
+	}
+	return files, nil
+}
+
+// mergeTextFiles merges the files at paths, which both sides changed, line
+// by line.
+func (c *httpClient) mergeTextFiles(ctx context.Context, m *treeMerge, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var hashes []hash.Hash
+	for _, path := range paths {
+		for _, entry := range []*FlatTreeEntry{m.base[path], m.ours[path], m.theirs[path]} {
+			if entry != nil {
+				hashes = append(hashes, entry.Hash)
+			}
+		}
+	}
+	contents := make(map[hash.Hash][]byte, len(hashes))
+	err := c.GetBlobs(ctx, hashes, func(blob *Blob) error {
+		contents[blob.Hash] = blob.Content
+		return nil
+	}, GetBlobsOptions{BatchSize: mergeBlobBatchSize})
+	if err != nil {
+		return fmt.Errorf("get blobs to merge: %w", err)
+	}
+
+	for _, path := range paths {
+		b, o, t := m.base[path], m.ours[path], m.theirs[path]
+		var baseContent []byte
+		if b != nil {
+			baseContent = contents[b.Hash]
+		}
+		oursContent, theirsContent := contents[o.Hash], contents[t.Hash]
+
+		mode, modeMerged := mergeMode(b, o.Mode, t.Mode)
+		if !modeMerged {
+			mode = o.Mode
+			if m.strategy == MergeStrategyTheirs {
+				mode = t.Mode
+			}
+		}
+
+		if isBinary(baseContent) || isBinary(oursContent) || isBinary(theirsContent) {
+			m.conflict(MergeConflict{Path: path, Reason: MergeConflictBinary})
+			continue
+		}
+
+		merged, hunks := m.mergeText(baseContent, oursContent, theirsContent)
+		switch {
+		case len(hunks) > 0:
+			m.record(MergeConflict{Path: path, Reason: MergeConflictContent, Hunks: hunks})
+		case !modeMerged:
+			m.record(MergeConflict{Path: path, Reason: MergeConflictMode})
+		}
+		entry := *o
+		entry.Mode = mode
+		entry.Hash = hash.Zero
+		m.result[path] = &mergedEntry{FlatTreeEntry: entry, side: mergeSideBoth, content: merged}
+	}
+	return nil
+}
+
+// mergeText merges the lines of a file like diff3. Conflicting hunks are
+// returned, and resolved in the merged content according to the strategy.
+func (m *treeMerge) mergeText(base, ours, theirs []byte) ([]byte, []MergeConflictHunk) {
+	baseLines, oursLines, theirsLines := splitLinesWithEnds(base), splitLinesWithEnds(ours), splitLinesWithEnds(theirs)
+
+	var (
+		merged strings.Builder
+		hunks  []MergeConflictHunk
+	)
+	for _, chunk := range mergeLines(baseLines, oursLines, theirsLines) {
+		lines := chunk.Lines
+		if chunk.Conflict {
+			hunks = append(hunks, MergeConflictHunk{
+				BaseLine:   chunk.Base[0] + 1,
+				OursLine:   chunk.Ours[0] + 1,
+				TheirsLine: chunk.Theirs[0] + 1,
+				Base:       trimLineEnds(baseLines[chunk.Base[0]:chunk.Base[1]]),
+				Ours:       trimLineEnds(oursLines[chunk.Ours[0]:chunk.Ours[1]]),
+				Theirs:     trimLineEnds(theirsLines[chunk.Theirs[0]:chunk.Theirs[1]]),
+			})
+			lines = oursLines[chunk.Ours[0]:chunk.Ours[1]]
+			if m.strategy == MergeStrategyTheirs {
+				lines = theirsLines[chunk.Theirs[0]:chunk.Theirs[1]]
+			}
+		}
+		for _, line := range lines {
+			merged.WriteString(line)
+		}
+	}
+	return []byte(merged.String()), hunks
+}
+
+// take sets the entry of path in the result, or removes it when entry is
+// nil.
+func (m *treeMerge) take(path string, entry *FlatTreeEntry, side mergeSide) {
+	if entry == nil {
+		delete(m.result, path)
+		return
+	}
+	m.result[path] = &mergedEntry{FlatTreeEntry: *entry, side: side}
+}
+
+// conflict records a conflict on a whole path and resolves it with the
+// strategy by taking the version of one side.
+func (m *treeMerge) conflict(conflict MergeConflict) {
+	m.record(conflict)
+	switch m.strategy {
+	case MergeStrategyOurs:
+		m.take(conflict.Path, m.ours[conflict.Path], mergeSideOurs)
+	case MergeStrategyTheirs:
+		m.take(conflict.Path, m.theirs[conflict.Path], mergeSideTheirs)
+	}
+}
+
+// record adds a conflict, with the entries of its path on every side.
+func (m *treeMerge) record(conflict MergeConflict) {
+	conflict.Base, conflict.Ours, conflict.Theirs = m.base[conflict.Path], m.ours[conflict.Path], m.theirs[conflict.Path]
+	m.conflicts = append(m.conflicts, conflict)
+}
+
+// resolveFileDirectoryConflicts finds files of the result that stand where
+// other entries of the result need a directory, which happens when each
+// side added one of them. The strategy keeps the entries of its side.
+func (m *treeMerge) resolveFileDirectoryConflicts() {
+	nested := make(map[string][]string)
+	for path := range m.result {
+		for i := strings.LastIndex(path, "/"); i >= 0; i = strings.LastIndex(path[:i], "/") {
+			if _, ok := m.result[path[:i]]; ok {
+				nested[path[:i]] = append(nested[path[:i]], path)
+			}
+		}
+	}
+
+	conflicted := make(map[string]bool, len(m.conflicts))
+	for _, conflict := range m.conflicts {
+		conflicted[conflict.Path] = true
+	}
+	for file, paths := range nested {
+		// A file kept by a strategy is already reported.
+		if !conflicted[file] {
+			m.record(MergeConflict{Path: file, Reason: MergeConflictFileDirectory})
+		}
+		if m.strategy == MergeStrategyDefault {
+			continue
+		}
+		// Neither side can have both the file and the entries under it,
+		// so they come from different sides.
+		if side := m.result[file].side; side == m.winner() || side == mergeSideBoth {
+			for _, path := range paths {
+				delete(m.result, path)
+			}
+		} else {
+			delete(m.result, file)
+		}
+	}
+}
+
+// winner returns the side the strategy resolves conflicts for.
+func (m *treeMerge) winner() mergeSide {
+	if m.strategy == MergeStrategyTheirs {
+		return mergeSideTheirs
+	}
+	return mergeSideOurs
+}
+
+// applyMerge stages the changes that turn the writer's tree into the
+// merged one: files, symbolic links and submodules that are gone are
+// removed first, then the directories left empty, then the new and
+// changed entries are added.
+func (w *stagedWriter) applyMerge(ctx context.Context, result map[string]*mergedEntry) error {
+	for path, entry := range w.treeEntries {
+		if entry.Type == protocol.ObjectTypeTree {
+			continue
+		}
+		if merged, ok := result[path]; !ok || merged.Mode == 0o160000 {
+			w.deleteEntry(w.treeEntries, path)
+			if err := w.removeBlobFromTree(ctx, path); err != nil {
+				return err
+			}
+		}
+	}
+	for path := range w.submoduleEntries {
+		if merged, ok := result[path]; !ok || merged.Mode != 0o160000 {
+			w.deleteEntry(w.submoduleEntries, path)
+			if err := w.removeBlobFromTree(ctx, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	dirs := make(map[string]bool)
+	for path := range result {
+		for i := strings.LastIndex(path, "/"); i >= 0; i = strings.LastIndex(path[:i], "/") {
+			dirs[path[:i]] = true
+		}
+	}
+	var emptyDirs []string
+	for path, entry := range w.treeEntries {
+		if entry.Type == protocol.ObjectTypeTree && !dirs[path] {
+			emptyDirs = append(emptyDirs, path)
+		}
+	}
+	// Deepest first, so parents still exist when their children go.
+	sort.Slice(emptyDirs, func(i, j int) bool { return emptyDirs[i] > emptyDirs[j] })
+	for _, path := range emptyDirs {
+		w.deleteEntry(w.treeEntries, path)
+		if err := w.removeTreeFromTree(ctx, path); err != nil {
+			return err
+		}
+	}
+
+	paths := make([]string, 0, len(result))
+	for path := range result {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		merged := result[path]
+		entry := merged.FlatTreeEntry
+		if merged.content != nil {
+			blobHash, err := w.writer.AddBlob(merged.content)
+			if err != nil {
+				return fmt.Errorf("create blob at %q: %w", path, err)
+			}
+			entry.Hash = blobHash
+		}
+
+		entries := w.treeEntries
+		if entry.Mode == 0o160000 {
+			entries = w.submoduleEntries
+		}
+		if current, ok := entries[path]; ok && current.Hash == entry.Hash && current.Mode == entry.Mode {
+			continue
+		}
+		w.setEntry(entries, path, &entry)
+		if err := w.addMissingOrStaleTreeEntries(ctx, path, entry.Hash); err != nil {
+			return fmt.Errorf("update tree structure for %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// sameEntry reports whether two entries, either of which may be missing,
+// have the same content and mode.
+func sameEntry(a, b *FlatTreeEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
+
+// entryKind groups the modes of entries that can be merged together:
+// regular and executable files, symbolic links and submodules.
+func entryKind(mode uint32) uint32 {
+	if mode == 0o100755 {
+		return 0o100644
+	}
+	return mode
+}
+
+// mergeMode merges the modes of a file both sides changed. It fails when
+// both sides changed the mode differently.
+func mergeMode(base *FlatTreeEntry, ours, theirs uint32) (uint32, bool) {
+	switch {
+	case ours == theirs:
+		return ours, true
+	case base != nil && ours == base.Mode:
+		return theirs, true
+	case base != nil && theirs == base.Mode:
+		return ours, true
+	}
+	return 0, false
+}
+
+// splitLinesWithEnds splits content into lines that keep their "\n", so
+// that joining them gives back the content.
+func splitLinesWithEnds(content []byte) []string {
+	var lines []string
+	text := string(content)
+	for len(text) > 0 {
+		i := strings.IndexByte(text, '\n') + 1
+		if i == 0 {
+			i = len(text)
+		}
+		lines = append(lines, text[:i])
+		text = text[i:]
+	}
+	return lines
+}
+
+// trimLineEnds returns lines without their "\n" or "\r\n".
+func trimLineEnds(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	}
+	return trimmed
+}
@@ -0,0 +1,296 @@
+package nanogit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Merge(t *testing.T) {
+	t.Parallel()
+
+	author := Author{Name: "Merger", Email: "merger@example.com", Time: time.Unix(1700000000, 0)}
+	base := map[string]string{
+		"README.md":      "line 1\nline 2\nline 3\nline 4\nline 5\n",
+		"config.yaml":    "replicas: 1\n",
+		"run.sh":         "#!/bin/sh\n",
+		"docs/guide.md":  "guide\n",
+		"docs/old.md":    "old\n",
+		"image.png":      "\x89PNG\x00base",
+		"shared/keep.md": "keep\n",
+	}
+	// with returns a copy of files with changes applied; an empty value
+	// deletes the file.
+	with := func(files map[string]string, changes map[string]string) map[string]string {
+		out := make(map[string]string, len(files))
+		for path, content := range files {
+			out[path] = content
+		}
+		for path, content := range changes {
+			if content == "" {
+				delete(out, path)
+			} else {
+				out[path] = content
+			}
+		}
+		return out
+	}
+	// setup creates a merge base, a branch "main" with ours on top of it and
+	// a commit with theirs on top of it.
+	setup := func(t *testing.T, ours, theirs map[string]string) (*testRepo, Ref, hash.Hash) {
+		repo := newTestRepo(t)
+		root := repo.commit("base", base)
+		main := repo.commit("ours", with(base, ours), root)
+		other := repo.commit("theirs", with(base, theirs), root)
+		repo.ref("refs/heads/main", main)
+		return repo, Ref{Name: "refs/heads/main", Hash: main}, other
+	}
+	// files returns the files of a commit in the format of testRepo.tree.
+	files := func(t *testing.T, repo *testRepo, commit hash.Hash) map[string]string {
+		t.Helper()
+		tree, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		out := make(map[string]string)
+		for _, entry := range tree.Entries {
+			if entry.Mode == 0o40000 {
+				continue
+			}
+			blob, err := repo.client().GetBlob(context.Background(), entry.Hash)
+			require.NoError(t, err)
+			content := string(blob.Content)
+			switch entry.Mode {
+			case 0o100755:
+				content = "exec:" + content
+			case 0o120000:
+				content = "symlink:" + content
+			}
+			out[entry.Path] = content
+		}
+		return out
+	}
+
+	t.Run("merges changes from both sides", func(t *testing.T) {
+		t.Parallel()
+		repo, ref, other := setup(t, map[string]string{
+			"README.md":   "line 1 ours\nline 2\nline 3\nline 4\nline 5\n",
+			"docs/old.md": "",
+			"new/ours.md": "ours\n",
+			"link":        "symlink:README.md",
+		}, map[string]string{
+			"README.md":   "line 1\nline 2\nline 3\nline 4\nline 5 theirs\n",
+			"config.yaml": "replicas: 3\n",
+			"run.sh":      "exec:#!/bin/sh\n",
+			"docs/new.md": "new\n",
+			"link":        "symlink:README.md",
+		})
+
+		result, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author})
+		require.NoError(t, err)
+		require.False(t, result.UpToDate)
+		require.Empty(t, result.Resolved)
+		require.Equal(t, result.Commit.Hash, repo.refHash("refs/heads/main"))
+		require.Equal(t, []hash.Hash{ref.Hash, other}, result.Commit.Parents)
+		require.Equal(t, "Merge commit '"+other.String()+"' into main\n", result.Commit.Message)
+		require.Equal(t, author.Name, result.Commit.Committer.Name)
+
+		require.Equal(t, with(base, map[string]string{
+			"README.md":   "line 1 ours\nline 2\nline 3\nline 4\nline 5 theirs\n",
+			"config.yaml": "replicas: 3\n",
+			"run.sh":      "exec:#!/bin/sh\n",
+			"docs/old.md": "",
+			"docs/new.md": "new\n",
+			"new/ours.md": "ours\n",
+			"link":        "symlink:README.md",
+		}), files(t, repo, result.Commit.Hash))
+
+		// The merged commit is now part of the history.
+		ref.Hash = result.Commit.Hash
+		again, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author})
+		require.NoError(t, err)
+		require.True(t, again.UpToDate)
+		require.Equal(t, result.Commit.Hash, again.Commit.Hash)
+		require.Equal(t, 1, repo.pushes)
+	})
+
+	t.Run("removes directories left empty", func(t *testing.T) {
+		t.Parallel()
+		repo, ref, other := setup(t, map[string]string{
+			"shared/keep.md": "keep\nmore\n",
+		}, map[string]string{
+			"docs/guide.md": "",
+			"docs/old.md":   "",
+		})
+
+		result, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author, Message: "Sync"})
+		require.NoError(t, err)
+		require.Equal(t, "Sync", result.Commit.Message)
+		got := files(t, repo, result.Commit.Hash)
+		require.NotContains(t, got, "docs/guide.md")
+		require.Equal(t, "keep\nmore\n", got["shared/keep.md"])
+		tree, err := repo.client().GetFlatTree(context.Background(), result.Commit.Hash)
+		require.NoError(t, err)
+		for _, entry := range tree.Entries {
+			require.NotEqual(t, "docs", entry.Path)
+		}
+	})
+
+	t.Run("reports conflicts", func(t *testing.T) {
+		t.Parallel()
+		repo, ref, other := setup(t, map[string]string{
+			"README.md":      "line 1\nline 2 ours\nline 3\nline 4\nline 5\n",
+			"config.yaml":    "replicas: 2\n",
+			"image.png":      "\x89PNG\x00ours",
+			"shared/keep.md": "",
+			"added.txt":      "ours\n",
+		}, map[string]string{
+			"README.md":      "line 1\nline 2 theirs\nline 3\nline 4\nline 5 theirs\n",
+			"config.yaml":    "",
+			"image.png":      "\x89PNG\x00theirs",
+			"shared/keep.md": "exec:keep\n",
+			"added.txt":      "theirs\n",
+		})
+
+		_, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author})
+		require.ErrorIs(t, err, ErrMergeConflict)
+		var conflictErr *MergeConflictError
+		require.True(t, errors.As(err, &conflictErr))
+		reasons := make(map[string]MergeConflictReason)
+		for _, conflict := range conflictErr.Conflicts {
+			reasons[conflict.Path] = conflict.Reason
+		}
+		require.Equal(t, map[string]MergeConflictReason{
+			"README.md":      MergeConflictContent,
+			"added.txt":      MergeConflictContent,
+			"config.yaml":    MergeConflictModifyDelete,
+			"image.png":      MergeConflictBinary,
+			"shared/keep.md": MergeConflictModifyDelete,
+		}, reasons)
+
+		readme := conflictErr.Conflicts[0]
+		require.Equal(t, "README.md", readme.Path)
+		require.Equal(t, []MergeConflictHunk{{
+			BaseLine: 2, OursLine: 2, TheirsLine: 2,
+			Base: []string{"line 2"}, Ours: []string{"line 2 ours"}, Theirs: []string{"line 2 theirs"},
+		}}, readme.Hunks)
+		require.NotNil(t, readme.Base)
+		require.Nil(t, conflictAt(conflictErr.Conflicts, "config.yaml").Theirs)
+		require.Zero(t, repo.pushes)
+		require.Equal(t, ref.Hash, repo.refHash("refs/heads/main"))
+	})
+
+	for _, tt := range []struct {
+		name     string
+		strategy MergeStrategy
+		want     map[string]string
+	}{
+		{
+			name:     "ours strategy resolves conflicts",
+			strategy: MergeStrategyOurs,
+			want: map[string]string{
+				"README.md":   "line 1\nline 2 ours\nline 3\nline 4\nline 5 theirs\n",
+				"config.yaml": "replicas: 2\n",
+				// Only theirs changed the mode, which merges cleanly.
+				"run.sh":    "exec:#!/bin/sh\nours\n",
+				"image.png": "\x89PNG\x00ours",
+			},
+		},
+		{
+			name:     "theirs strategy resolves conflicts",
+			strategy: MergeStrategyTheirs,
+			want: map[string]string{
+				"README.md":   "line 1\nline 2 theirs\nline 3\nline 4\nline 5 theirs\n",
+				"config.yaml": "",
+				"run.sh":      "exec:#!/bin/sh\ntheirs\n",
+				"image.png":   "\x89PNG\x00theirs",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			repo, ref, other := setup(t, map[string]string{
+				"README.md":   "line 1\nline 2 ours\nline 3\nline 4\nline 5\n",
+				"config.yaml": "replicas: 2\n",
+				"run.sh":      "#!/bin/sh\nours\n",
+				"image.png":   "\x89PNG\x00ours",
+			}, map[string]string{
+				"README.md":   "line 1\nline 2 theirs\nline 3\nline 4\nline 5 theirs\n",
+				"config.yaml": "",
+				"run.sh":      "exec:#!/bin/sh\ntheirs\n",
+				"image.png":   "\x89PNG\x00theirs",
+			})
+
+			result, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author, Strategy: tt.strategy})
+			require.NoError(t, err)
+			require.Len(t, result.Resolved, 4)
+			require.Equal(t, with(base, tt.want), files(t, repo, result.Commit.Hash))
+			require.Len(t, result.Commit.Parents, 2)
+		})
+	}
+
+	t.Run("file/directory conflict", func(t *testing.T) {
+		t.Parallel()
+		ours := map[string]string{"build": "artifact\n"}
+		theirs := map[string]string{"build/out.txt": "out\n", "build/log.txt": "log\n"}
+
+		repo, ref, other := setup(t, ours, theirs)
+		_, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author})
+		var conflictErr *MergeConflictError
+		require.True(t, errors.As(err, &conflictErr))
+		require.Len(t, conflictErr.Conflicts, 1)
+		require.Equal(t, "build", conflictErr.Conflicts[0].Path)
+		require.Equal(t, MergeConflictFileDirectory, conflictErr.Conflicts[0].Reason)
+
+		result, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author, Strategy: MergeStrategyTheirs})
+		require.NoError(t, err)
+		require.Equal(t, with(base, theirs), files(t, repo, result.Commit.Hash))
+
+		repo, ref, other = setup(t, ours, theirs)
+		result, err = repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author, Strategy: MergeStrategyOurs})
+		require.NoError(t, err)
+		require.Equal(t, with(base, ours), files(t, repo, result.Commit.Hash))
+	})
+
+	t.Run("fails when the branch moved", func(t *testing.T) {
+		t.Parallel()
+		repo, ref, other := setup(t, map[string]string{"a.txt": "a\n"}, map[string]string{"b.txt": "b\n"})
+		moved := repo.commit("moved", with(base, map[string]string{"c.txt": "c\n"}), ref.Hash)
+		repo.refs = nil
+		repo.ref("refs/heads/main", moved)
+
+		_, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{Author: author})
+		require.Error(t, err)
+		require.Equal(t, moved, repo.refHash("refs/heads/main"))
+	})
+
+	t.Run("unrelated histories", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		main := repo.commit("main", base)
+		other := repo.commit("other", map[string]string{"x.txt": "x\n"})
+		repo.ref("refs/heads/main", main)
+
+		_, err := repo.client().Merge(context.Background(), Ref{Name: "refs/heads/main", Hash: main}, other, MergeOptions{Author: author})
+		require.ErrorIs(t, err, ErrNoMergeBase)
+	})
+
+	t.Run("requires an author", func(t *testing.T) {
+		t.Parallel()
+		repo, ref, other := setup(t, nil, nil)
+		_, err := repo.client().Merge(context.Background(), ref, other, MergeOptions{})
+		require.ErrorIs(t, err, ErrInvalidAuthor)
+	})
+}
+
+// conflictAt returns the conflict on path.
+func conflictAt(conflicts []MergeConflict, path string) MergeConflict {
+	for _, conflict := range conflicts {
+		if conflict.Path == path {
+			return conflict
+		}
+	}
+	return MergeConflict{}
+}
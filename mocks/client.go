@@ -5,13 +5,63 @@ package mocks
 
 import (
 	"context"
+	"io"
+	"io/fs"
 	"sync"
 
 	"github.com/grafana/nanogit"
+	"github.com/grafana/nanogit/protocol"
 	"github.com/grafana/nanogit/protocol/hash"
 )
 
 type FakeClient struct {
+	AheadBehindStub        func(context.Context, hash.Hash, hash.Hash) (int, int, error)
+	aheadBehindMutex       sync.RWMutex
+	aheadBehindArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 hash.Hash
+	}
+	aheadBehindReturns struct {
+		result1 int
+		result2 int
+		result3 error
+	}
+	aheadBehindReturnsOnCall map[int]struct {
+		result1 int
+		result2 int
+		result3 error
+	}
+	ArchiveStub        func(context.Context, hash.Hash, io.Writer, nanogit.ArchiveOptions) error
+	archiveMutex       sync.RWMutex
+	archiveArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 io.Writer
+		arg4 nanogit.ArchiveOptions
+	}
+	archiveReturns struct {
+		result1 error
+	}
+	archiveReturnsOnCall map[int]struct {
+		result1 error
+	}
+	BlameStub        func(context.Context, hash.Hash, string, nanogit.BlameOptions) ([]nanogit.BlameLine, error)
+	blameMutex       sync.RWMutex
+	blameArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 string
+		arg4 nanogit.BlameOptions
+	}
+	blameReturns struct {
+		result1 []nanogit.BlameLine
+		result2 error
+	}
+	blameReturnsOnCall map[int]struct {
+		result1 []nanogit.BlameLine
+		result2 error
+	}
 	CanReadStub        func(context.Context) (bool, error)
 	canReadMutex       sync.RWMutex
 	canReadArgsForCall []struct {
@@ -38,6 +88,22 @@ type FakeClient struct {
 		result1 bool
 		result2 error
 	}
+	CherryPickStub        func(context.Context, nanogit.Ref, hash.Hash, nanogit.CherryPickOptions) (*nanogit.Commit, error)
+	cherryPickMutex       sync.RWMutex
+	cherryPickArgsForCall []struct {
+		arg1 context.Context
+		arg2 nanogit.Ref
+		arg3 hash.Hash
+		arg4 nanogit.CherryPickOptions
+	}
+	cherryPickReturns struct {
+		result1 *nanogit.Commit
+		result2 error
+	}
+	cherryPickReturnsOnCall map[int]struct {
+		result1 *nanogit.Commit
+		result2 error
+	}
 	CloneStub        func(context.Context, nanogit.CloneOptions) (*nanogit.CloneResult, error)
 	cloneMutex       sync.RWMutex
 	cloneArgsForCall []struct {
@@ -92,6 +158,18 @@ type FakeClient struct {
 	deleteRefReturnsOnCall map[int]struct {
 		result1 error
 	}
+	FSStub        func(context.Context, hash.Hash) fs.FS
+	fSMutex       sync.RWMutex
+	fSArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+	}
+	fSReturns struct {
+		result1 fs.FS
+	}
+	fSReturnsOnCall map[int]struct {
+		result1 fs.FS
+	}
 	GetBlobStub        func(context.Context, hash.Hash) (*nanogit.Blob, error)
 	getBlobMutex       sync.RWMutex
 	getBlobArgsForCall []struct {
@@ -121,6 +199,20 @@ type FakeClient struct {
 		result1 *nanogit.Blob
 		result2 error
 	}
+	GetBlobsStub        func(context.Context, []hash.Hash, nanogit.BlobFunc, nanogit.GetBlobsOptions) error
+	getBlobsMutex       sync.RWMutex
+	getBlobsArgsForCall []struct {
+		arg1 context.Context
+		arg2 []hash.Hash
+		arg3 nanogit.BlobFunc
+		arg4 nanogit.GetBlobsOptions
+	}
+	getBlobsReturns struct {
+		result1 error
+	}
+	getBlobsReturnsOnCall map[int]struct {
+		result1 error
+	}
 	GetCommitStub        func(context.Context, hash.Hash) (*nanogit.Commit, error)
 	getCommitMutex       sync.RWMutex
 	getCommitArgsForCall []struct {
@@ -192,6 +284,36 @@ type FakeClient struct {
 		result1 *nanogit.Tree
 		result2 error
 	}
+	GrepStub        func(context.Context, hash.Hash, nanogit.GrepOptions) ([]nanogit.GrepMatch, error)
+	grepMutex       sync.RWMutex
+	grepArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 nanogit.GrepOptions
+	}
+	grepReturns struct {
+		result1 []nanogit.GrepMatch
+		result2 error
+	}
+	grepReturnsOnCall map[int]struct {
+		result1 []nanogit.GrepMatch
+		result2 error
+	}
+	IsAncestorStub        func(context.Context, hash.Hash, hash.Hash) (bool, error)
+	isAncestorMutex       sync.RWMutex
+	isAncestorArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 hash.Hash
+	}
+	isAncestorReturns struct {
+		result1 bool
+		result2 error
+	}
+	isAncestorReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
 	IsAuthorizedStub        func(context.Context) (bool, error)
 	isAuthorizedMutex       sync.RWMutex
 	isAuthorizedArgsForCall []struct {
@@ -246,6 +368,37 @@ type FakeClient struct {
 		result1 []nanogit.Ref
 		result2 error
 	}
+	MergeStub        func(context.Context, nanogit.Ref, hash.Hash, nanogit.MergeOptions) (*nanogit.MergeResult, error)
+	mergeMutex       sync.RWMutex
+	mergeArgsForCall []struct {
+		arg1 context.Context
+		arg2 nanogit.Ref
+		arg3 hash.Hash
+		arg4 nanogit.MergeOptions
+	}
+	mergeReturns struct {
+		result1 *nanogit.MergeResult
+		result2 error
+	}
+	mergeReturnsOnCall map[int]struct {
+		result1 *nanogit.MergeResult
+		result2 error
+	}
+	MergeBaseStub        func(context.Context, hash.Hash, hash.Hash) (hash.Hash, error)
+	mergeBaseMutex       sync.RWMutex
+	mergeBaseArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 hash.Hash
+	}
+	mergeBaseReturns struct {
+		result1 hash.Hash
+		result2 error
+	}
+	mergeBaseReturnsOnCall map[int]struct {
+		result1 hash.Hash
+		result2 error
+	}
 	NewStagedWriterStub        func(context.Context, nanogit.Ref, ...nanogit.WriterOption) (nanogit.StagedWriter, error)
 	newStagedWriterMutex       sync.RWMutex
 	newStagedWriterArgsForCall []struct {
@@ -274,6 +427,38 @@ type FakeClient struct {
 		result1 bool
 		result2 error
 	}
+	ResolveRevisionStub        func(context.Context, string) (hash.Hash, protocol.ObjectType, error)
+	resolveRevisionMutex       sync.RWMutex
+	resolveRevisionArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	resolveRevisionReturns struct {
+		result1 hash.Hash
+		result2 protocol.ObjectType
+		result3 error
+	}
+	resolveRevisionReturnsOnCall map[int]struct {
+		result1 hash.Hash
+		result2 protocol.ObjectType
+		result3 error
+	}
+	RevertStub        func(context.Context, nanogit.Ref, hash.Hash, nanogit.RevertOptions) (*nanogit.Commit, error)
+	revertMutex       sync.RWMutex
+	revertArgsForCall []struct {
+		arg1 context.Context
+		arg2 nanogit.Ref
+		arg3 hash.Hash
+		arg4 nanogit.RevertOptions
+	}
+	revertReturns struct {
+		result1 *nanogit.Commit
+		result2 error
+	}
+	revertReturnsOnCall map[int]struct {
+		result1 *nanogit.Commit
+		result2 error
+	}
 	UpdateRefStub        func(context.Context, nanogit.Ref) error
 	updateRefMutex       sync.RWMutex
 	updateRefArgsForCall []struct {
@@ -286,10 +471,224 @@ type FakeClient struct {
 	updateRefReturnsOnCall map[int]struct {
 		result1 error
 	}
+	WalkTreeStub        func(context.Context, hash.Hash, nanogit.WalkFunc, nanogit.WalkTreeOptions) error
+	walkTreeMutex       sync.RWMutex
+	walkTreeArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 nanogit.WalkFunc
+		arg4 nanogit.WalkTreeOptions
+	}
+	walkTreeReturns struct {
+		result1 error
+	}
+	walkTreeReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeClient) AheadBehind(arg1 context.Context, arg2 hash.Hash, arg3 hash.Hash) (int, int, error) {
+	fake.aheadBehindMutex.Lock()
+	ret, specificReturn := fake.aheadBehindReturnsOnCall[len(fake.aheadBehindArgsForCall)]
+	fake.aheadBehindArgsForCall = append(fake.aheadBehindArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 hash.Hash
+	}{arg1, arg2, arg3})
+	stub := fake.AheadBehindStub
+	fakeReturns := fake.aheadBehindReturns
+	fake.recordInvocation("AheadBehind", []interface{}{arg1, arg2, arg3})
+	fake.aheadBehindMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeClient) AheadBehindCallCount() int {
+	fake.aheadBehindMutex.RLock()
+	defer fake.aheadBehindMutex.RUnlock()
+	return len(fake.aheadBehindArgsForCall)
+}
+
+func (fake *FakeClient) AheadBehindCalls(stub func(context.Context, hash.Hash, hash.Hash) (int, int, error)) {
+	fake.aheadBehindMutex.Lock()
+	defer fake.aheadBehindMutex.Unlock()
+	fake.AheadBehindStub = stub
+}
+
+func (fake *FakeClient) AheadBehindArgsForCall(i int) (context.Context, hash.Hash, hash.Hash) {
+	fake.aheadBehindMutex.RLock()
+	defer fake.aheadBehindMutex.RUnlock()
+	argsForCall := fake.aheadBehindArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) AheadBehindReturns(result1 int, result2 int, result3 error) {
+	fake.aheadBehindMutex.Lock()
+	defer fake.aheadBehindMutex.Unlock()
+	fake.AheadBehindStub = nil
+	fake.aheadBehindReturns = struct {
+		result1 int
+		result2 int
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeClient) AheadBehindReturnsOnCall(i int, result1 int, result2 int, result3 error) {
+	fake.aheadBehindMutex.Lock()
+	defer fake.aheadBehindMutex.Unlock()
+	fake.AheadBehindStub = nil
+	if fake.aheadBehindReturnsOnCall == nil {
+		fake.aheadBehindReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 int
+			result3 error
+		})
+	}
+	fake.aheadBehindReturnsOnCall[i] = struct {
+		result1 int
+		result2 int
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeClient) Archive(arg1 context.Context, arg2 hash.Hash, arg3 io.Writer, arg4 nanogit.ArchiveOptions) error {
+	fake.archiveMutex.Lock()
+	ret, specificReturn := fake.archiveReturnsOnCall[len(fake.archiveArgsForCall)]
+	fake.archiveArgsForCall = append(fake.archiveArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 io.Writer
+		arg4 nanogit.ArchiveOptions
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.ArchiveStub
+	fakeReturns := fake.archiveReturns
+	fake.recordInvocation("Archive", []interface{}{arg1, arg2, arg3, arg4})
+	fake.archiveMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) ArchiveCallCount() int {
+	fake.archiveMutex.RLock()
+	defer fake.archiveMutex.RUnlock()
+	return len(fake.archiveArgsForCall)
+}
+
+func (fake *FakeClient) ArchiveCalls(stub func(context.Context, hash.Hash, io.Writer, nanogit.ArchiveOptions) error) {
+	fake.archiveMutex.Lock()
+	defer fake.archiveMutex.Unlock()
+	fake.ArchiveStub = stub
+}
+
+func (fake *FakeClient) ArchiveArgsForCall(i int) (context.Context, hash.Hash, io.Writer, nanogit.ArchiveOptions) {
+	fake.archiveMutex.RLock()
+	defer fake.archiveMutex.RUnlock()
+	argsForCall := fake.archiveArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) ArchiveReturns(result1 error) {
+	fake.archiveMutex.Lock()
+	defer fake.archiveMutex.Unlock()
+	fake.ArchiveStub = nil
+	fake.archiveReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) ArchiveReturnsOnCall(i int, result1 error) {
+	fake.archiveMutex.Lock()
+	defer fake.archiveMutex.Unlock()
+	fake.ArchiveStub = nil
+	if fake.archiveReturnsOnCall == nil {
+		fake.archiveReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.archiveReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) Blame(arg1 context.Context, arg2 hash.Hash, arg3 string, arg4 nanogit.BlameOptions) ([]nanogit.BlameLine, error) {
+	fake.blameMutex.Lock()
+	ret, specificReturn := fake.blameReturnsOnCall[len(fake.blameArgsForCall)]
+	fake.blameArgsForCall = append(fake.blameArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 string
+		arg4 nanogit.BlameOptions
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.BlameStub
+	fakeReturns := fake.blameReturns
+	fake.recordInvocation("Blame", []interface{}{arg1, arg2, arg3, arg4})
+	fake.blameMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) BlameCallCount() int {
+	fake.blameMutex.RLock()
+	defer fake.blameMutex.RUnlock()
+	return len(fake.blameArgsForCall)
+}
+
+func (fake *FakeClient) BlameCalls(stub func(context.Context, hash.Hash, string, nanogit.BlameOptions) ([]nanogit.BlameLine, error)) {
+	fake.blameMutex.Lock()
+	defer fake.blameMutex.Unlock()
+	fake.BlameStub = stub
+}
+
+func (fake *FakeClient) BlameArgsForCall(i int) (context.Context, hash.Hash, string, nanogit.BlameOptions) {
+	fake.blameMutex.RLock()
+	defer fake.blameMutex.RUnlock()
+	argsForCall := fake.blameArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) BlameReturns(result1 []nanogit.BlameLine, result2 error) {
+	fake.blameMutex.Lock()
+	defer fake.blameMutex.Unlock()
+	fake.BlameStub = nil
+	fake.blameReturns = struct {
+		result1 []nanogit.BlameLine
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) BlameReturnsOnCall(i int, result1 []nanogit.BlameLine, result2 error) {
+	fake.blameMutex.Lock()
+	defer fake.blameMutex.Unlock()
+	fake.BlameStub = nil
+	if fake.blameReturnsOnCall == nil {
+		fake.blameReturnsOnCall = make(map[int]struct {
+			result1 []nanogit.BlameLine
+			result2 error
+		})
+	}
+	fake.blameReturnsOnCall[i] = struct {
+		result1 []nanogit.BlameLine
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) CanRead(arg1 context.Context) (bool, error) {
 	fake.canReadMutex.Lock()
 	ret, specificReturn := fake.canReadReturnsOnCall[len(fake.canReadArgsForCall)]
@@ -418,6 +817,73 @@ func (fake *FakeClient) CanWriteReturnsOnCall(i int, result1 bool, result2 error
 	}{result1, result2}
 }
 
+func (fake *FakeClient) CherryPick(arg1 context.Context, arg2 nanogit.Ref, arg3 hash.Hash, arg4 nanogit.CherryPickOptions) (*nanogit.Commit, error) {
+	fake.cherryPickMutex.Lock()
+	ret, specificReturn := fake.cherryPickReturnsOnCall[len(fake.cherryPickArgsForCall)]
+	fake.cherryPickArgsForCall = append(fake.cherryPickArgsForCall, struct {
+		arg1 context.Context
+		arg2 nanogit.Ref
+		arg3 hash.Hash
+		arg4 nanogit.CherryPickOptions
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.CherryPickStub
+	fakeReturns := fake.cherryPickReturns
+	fake.recordInvocation("CherryPick", []interface{}{arg1, arg2, arg3, arg4})
+	fake.cherryPickMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) CherryPickCallCount() int {
+	fake.cherryPickMutex.RLock()
+	defer fake.cherryPickMutex.RUnlock()
+	return len(fake.cherryPickArgsForCall)
+}
+
+func (fake *FakeClient) CherryPickCalls(stub func(context.Context, nanogit.Ref, hash.Hash, nanogit.CherryPickOptions) (*nanogit.Commit, error)) {
+	fake.cherryPickMutex.Lock()
+	defer fake.cherryPickMutex.Unlock()
+	fake.CherryPickStub = stub
+}
+
+func (fake *FakeClient) CherryPickArgsForCall(i int) (context.Context, nanogit.Ref, hash.Hash, nanogit.CherryPickOptions) {
+	fake.cherryPickMutex.RLock()
+	defer fake.cherryPickMutex.RUnlock()
+	argsForCall := fake.cherryPickArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) CherryPickReturns(result1 *nanogit.Commit, result2 error) {
+	fake.cherryPickMutex.Lock()
+	defer fake.cherryPickMutex.Unlock()
+	fake.CherryPickStub = nil
+	fake.cherryPickReturns = struct {
+		result1 *nanogit.Commit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) CherryPickReturnsOnCall(i int, result1 *nanogit.Commit, result2 error) {
+	fake.cherryPickMutex.Lock()
+	defer fake.cherryPickMutex.Unlock()
+	fake.CherryPickStub = nil
+	if fake.cherryPickReturnsOnCall == nil {
+		fake.cherryPickReturnsOnCall = make(map[int]struct {
+			result1 *nanogit.Commit
+			result2 error
+		})
+	}
+	fake.cherryPickReturnsOnCall[i] = struct {
+		result1 *nanogit.Commit
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) Clone(arg1 context.Context, arg2 nanogit.CloneOptions) (*nanogit.CloneResult, error) {
 	fake.cloneMutex.Lock()
 	ret, specificReturn := fake.cloneReturnsOnCall[len(fake.cloneArgsForCall)]
@@ -674,6 +1140,68 @@ func (fake *FakeClient) DeleteRefReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeClient) FS(arg1 context.Context, arg2 hash.Hash) fs.FS {
+	fake.fSMutex.Lock()
+	ret, specificReturn := fake.fSReturnsOnCall[len(fake.fSArgsForCall)]
+	fake.fSArgsForCall = append(fake.fSArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+	}{arg1, arg2})
+	stub := fake.FSStub
+	fakeReturns := fake.fSReturns
+	fake.recordInvocation("FS", []interface{}{arg1, arg2})
+	fake.fSMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) FSCallCount() int {
+	fake.fSMutex.RLock()
+	defer fake.fSMutex.RUnlock()
+	return len(fake.fSArgsForCall)
+}
+
+func (fake *FakeClient) FSCalls(stub func(context.Context, hash.Hash) fs.FS) {
+	fake.fSMutex.Lock()
+	defer fake.fSMutex.Unlock()
+	fake.FSStub = stub
+}
+
+func (fake *FakeClient) FSArgsForCall(i int) (context.Context, hash.Hash) {
+	fake.fSMutex.RLock()
+	defer fake.fSMutex.RUnlock()
+	argsForCall := fake.fSArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeClient) FSReturns(result1 fs.FS) {
+	fake.fSMutex.Lock()
+	defer fake.fSMutex.Unlock()
+	fake.FSStub = nil
+	fake.fSReturns = struct {
+		result1 fs.FS
+	}{result1}
+}
+
+func (fake *FakeClient) FSReturnsOnCall(i int, result1 fs.FS) {
+	fake.fSMutex.Lock()
+	defer fake.fSMutex.Unlock()
+	fake.FSStub = nil
+	if fake.fSReturnsOnCall == nil {
+		fake.fSReturnsOnCall = make(map[int]struct {
+			result1 fs.FS
+		})
+	}
+	fake.fSReturnsOnCall[i] = struct {
+		result1 fs.FS
+	}{result1}
+}
+
 func (fake *FakeClient) GetBlob(arg1 context.Context, arg2 hash.Hash) (*nanogit.Blob, error) {
 	fake.getBlobMutex.Lock()
 	ret, specificReturn := fake.getBlobReturnsOnCall[len(fake.getBlobArgsForCall)]
@@ -805,6 +1333,75 @@ func (fake *FakeClient) GetBlobByPathReturnsOnCall(i int, result1 *nanogit.Blob,
 	}{result1, result2}
 }
 
+func (fake *FakeClient) GetBlobs(arg1 context.Context, arg2 []hash.Hash, arg3 nanogit.BlobFunc, arg4 nanogit.GetBlobsOptions) error {
+	var arg2Copy []hash.Hash
+	if arg2 != nil {
+		arg2Copy = make([]hash.Hash, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.getBlobsMutex.Lock()
+	ret, specificReturn := fake.getBlobsReturnsOnCall[len(fake.getBlobsArgsForCall)]
+	fake.getBlobsArgsForCall = append(fake.getBlobsArgsForCall, struct {
+		arg1 context.Context
+		arg2 []hash.Hash
+		arg3 nanogit.BlobFunc
+		arg4 nanogit.GetBlobsOptions
+	}{arg1, arg2Copy, arg3, arg4})
+	stub := fake.GetBlobsStub
+	fakeReturns := fake.getBlobsReturns
+	fake.recordInvocation("GetBlobs", []interface{}{arg1, arg2Copy, arg3, arg4})
+	fake.getBlobsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) GetBlobsCallCount() int {
+	fake.getBlobsMutex.RLock()
+	defer fake.getBlobsMutex.RUnlock()
+	return len(fake.getBlobsArgsForCall)
+}
+
+func (fake *FakeClient) GetBlobsCalls(stub func(context.Context, []hash.Hash, nanogit.BlobFunc, nanogit.GetBlobsOptions) error) {
+	fake.getBlobsMutex.Lock()
+	defer fake.getBlobsMutex.Unlock()
+	fake.GetBlobsStub = stub
+}
+
+func (fake *FakeClient) GetBlobsArgsForCall(i int) (context.Context, []hash.Hash, nanogit.BlobFunc, nanogit.GetBlobsOptions) {
+	fake.getBlobsMutex.RLock()
+	defer fake.getBlobsMutex.RUnlock()
+	argsForCall := fake.getBlobsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) GetBlobsReturns(result1 error) {
+	fake.getBlobsMutex.Lock()
+	defer fake.getBlobsMutex.Unlock()
+	fake.GetBlobsStub = nil
+	fake.getBlobsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) GetBlobsReturnsOnCall(i int, result1 error) {
+	fake.getBlobsMutex.Lock()
+	defer fake.getBlobsMutex.Unlock()
+	fake.GetBlobsStub = nil
+	if fake.getBlobsReturnsOnCall == nil {
+		fake.getBlobsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.getBlobsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeClient) GetCommit(arg1 context.Context, arg2 hash.Hash) (*nanogit.Commit, error) {
 	fake.getCommitMutex.Lock()
 	ret, specificReturn := fake.getCommitReturnsOnCall[len(fake.getCommitArgsForCall)]
@@ -1131,6 +1728,138 @@ func (fake *FakeClient) GetTreeByPathReturnsOnCall(i int, result1 *nanogit.Tree,
 	}{result1, result2}
 }
 
+func (fake *FakeClient) Grep(arg1 context.Context, arg2 hash.Hash, arg3 nanogit.GrepOptions) ([]nanogit.GrepMatch, error) {
+	fake.grepMutex.Lock()
+	ret, specificReturn := fake.grepReturnsOnCall[len(fake.grepArgsForCall)]
+	fake.grepArgsForCall = append(fake.grepArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 nanogit.GrepOptions
+	}{arg1, arg2, arg3})
+	stub := fake.GrepStub
+	fakeReturns := fake.grepReturns
+	fake.recordInvocation("Grep", []interface{}{arg1, arg2, arg3})
+	fake.grepMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) GrepCallCount() int {
+	fake.grepMutex.RLock()
+	defer fake.grepMutex.RUnlock()
+	return len(fake.grepArgsForCall)
+}
+
+func (fake *FakeClient) GrepCalls(stub func(context.Context, hash.Hash, nanogit.GrepOptions) ([]nanogit.GrepMatch, error)) {
+	fake.grepMutex.Lock()
+	defer fake.grepMutex.Unlock()
+	fake.GrepStub = stub
+}
+
+func (fake *FakeClient) GrepArgsForCall(i int) (context.Context, hash.Hash, nanogit.GrepOptions) {
+	fake.grepMutex.RLock()
+	defer fake.grepMutex.RUnlock()
+	argsForCall := fake.grepArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) GrepReturns(result1 []nanogit.GrepMatch, result2 error) {
+	fake.grepMutex.Lock()
+	defer fake.grepMutex.Unlock()
+	fake.GrepStub = nil
+	fake.grepReturns = struct {
+		result1 []nanogit.GrepMatch
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) GrepReturnsOnCall(i int, result1 []nanogit.GrepMatch, result2 error) {
+	fake.grepMutex.Lock()
+	defer fake.grepMutex.Unlock()
+	fake.GrepStub = nil
+	if fake.grepReturnsOnCall == nil {
+		fake.grepReturnsOnCall = make(map[int]struct {
+			result1 []nanogit.GrepMatch
+			result2 error
+		})
+	}
+	fake.grepReturnsOnCall[i] = struct {
+		result1 []nanogit.GrepMatch
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) IsAncestor(arg1 context.Context, arg2 hash.Hash, arg3 hash.Hash) (bool, error) {
+	fake.isAncestorMutex.Lock()
+	ret, specificReturn := fake.isAncestorReturnsOnCall[len(fake.isAncestorArgsForCall)]
+	fake.isAncestorArgsForCall = append(fake.isAncestorArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 hash.Hash
+	}{arg1, arg2, arg3})
+	stub := fake.IsAncestorStub
+	fakeReturns := fake.isAncestorReturns
+	fake.recordInvocation("IsAncestor", []interface{}{arg1, arg2, arg3})
+	fake.isAncestorMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) IsAncestorCallCount() int {
+	fake.isAncestorMutex.RLock()
+	defer fake.isAncestorMutex.RUnlock()
+	return len(fake.isAncestorArgsForCall)
+}
+
+func (fake *FakeClient) IsAncestorCalls(stub func(context.Context, hash.Hash, hash.Hash) (bool, error)) {
+	fake.isAncestorMutex.Lock()
+	defer fake.isAncestorMutex.Unlock()
+	fake.IsAncestorStub = stub
+}
+
+func (fake *FakeClient) IsAncestorArgsForCall(i int) (context.Context, hash.Hash, hash.Hash) {
+	fake.isAncestorMutex.RLock()
+	defer fake.isAncestorMutex.RUnlock()
+	argsForCall := fake.isAncestorArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) IsAncestorReturns(result1 bool, result2 error) {
+	fake.isAncestorMutex.Lock()
+	defer fake.isAncestorMutex.Unlock()
+	fake.IsAncestorStub = nil
+	fake.isAncestorReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) IsAncestorReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.isAncestorMutex.Lock()
+	defer fake.isAncestorMutex.Unlock()
+	fake.IsAncestorStub = nil
+	if fake.isAncestorReturnsOnCall == nil {
+		fake.isAncestorReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.isAncestorReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) IsAuthorized(arg1 context.Context) (bool, error) {
 	fake.isAuthorizedMutex.Lock()
 	ret, specificReturn := fake.isAuthorizedReturnsOnCall[len(fake.isAuthorizedArgsForCall)]
@@ -1389,6 +2118,139 @@ func (fake *FakeClient) ListRefsReturnsOnCall(i int, result1 []nanogit.Ref, resu
 	}{result1, result2}
 }
 
+func (fake *FakeClient) Merge(arg1 context.Context, arg2 nanogit.Ref, arg3 hash.Hash, arg4 nanogit.MergeOptions) (*nanogit.MergeResult, error) {
+	fake.mergeMutex.Lock()
+	ret, specificReturn := fake.mergeReturnsOnCall[len(fake.mergeArgsForCall)]
+	fake.mergeArgsForCall = append(fake.mergeArgsForCall, struct {
+		arg1 context.Context
+		arg2 nanogit.Ref
+		arg3 hash.Hash
+		arg4 nanogit.MergeOptions
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.MergeStub
+	fakeReturns := fake.mergeReturns
+	fake.recordInvocation("Merge", []interface{}{arg1, arg2, arg3, arg4})
+	fake.mergeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) MergeCallCount() int {
+	fake.mergeMutex.RLock()
+	defer fake.mergeMutex.RUnlock()
+	return len(fake.mergeArgsForCall)
+}
+
+func (fake *FakeClient) MergeCalls(stub func(context.Context, nanogit.Ref, hash.Hash, nanogit.MergeOptions) (*nanogit.MergeResult, error)) {
+	fake.mergeMutex.Lock()
+	defer fake.mergeMutex.Unlock()
+	fake.MergeStub = stub
+}
+
+func (fake *FakeClient) MergeArgsForCall(i int) (context.Context, nanogit.Ref, hash.Hash, nanogit.MergeOptions) {
+	fake.mergeMutex.RLock()
+	defer fake.mergeMutex.RUnlock()
+	argsForCall := fake.mergeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) MergeReturns(result1 *nanogit.MergeResult, result2 error) {
+	fake.mergeMutex.Lock()
+	defer fake.mergeMutex.Unlock()
+	fake.MergeStub = nil
+	fake.mergeReturns = struct {
+		result1 *nanogit.MergeResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) MergeReturnsOnCall(i int, result1 *nanogit.MergeResult, result2 error) {
+	fake.mergeMutex.Lock()
+	defer fake.mergeMutex.Unlock()
+	fake.MergeStub = nil
+	if fake.mergeReturnsOnCall == nil {
+		fake.mergeReturnsOnCall = make(map[int]struct {
+			result1 *nanogit.MergeResult
+			result2 error
+		})
+	}
+	fake.mergeReturnsOnCall[i] = struct {
+		result1 *nanogit.MergeResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) MergeBase(arg1 context.Context, arg2 hash.Hash, arg3 hash.Hash) (hash.Hash, error) {
+	fake.mergeBaseMutex.Lock()
+	ret, specificReturn := fake.mergeBaseReturnsOnCall[len(fake.mergeBaseArgsForCall)]
+	fake.mergeBaseArgsForCall = append(fake.mergeBaseArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 hash.Hash
+	}{arg1, arg2, arg3})
+	stub := fake.MergeBaseStub
+	fakeReturns := fake.mergeBaseReturns
+	fake.recordInvocation("MergeBase", []interface{}{arg1, arg2, arg3})
+	fake.mergeBaseMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) MergeBaseCallCount() int {
+	fake.mergeBaseMutex.RLock()
+	defer fake.mergeBaseMutex.RUnlock()
+	return len(fake.mergeBaseArgsForCall)
+}
+
+func (fake *FakeClient) MergeBaseCalls(stub func(context.Context, hash.Hash, hash.Hash) (hash.Hash, error)) {
+	fake.mergeBaseMutex.Lock()
+	defer fake.mergeBaseMutex.Unlock()
+	fake.MergeBaseStub = stub
+}
+
+func (fake *FakeClient) MergeBaseArgsForCall(i int) (context.Context, hash.Hash, hash.Hash) {
+	fake.mergeBaseMutex.RLock()
+	defer fake.mergeBaseMutex.RUnlock()
+	argsForCall := fake.mergeBaseArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) MergeBaseReturns(result1 hash.Hash, result2 error) {
+	fake.mergeBaseMutex.Lock()
+	defer fake.mergeBaseMutex.Unlock()
+	fake.MergeBaseStub = nil
+	fake.mergeBaseReturns = struct {
+		result1 hash.Hash
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) MergeBaseReturnsOnCall(i int, result1 hash.Hash, result2 error) {
+	fake.mergeBaseMutex.Lock()
+	defer fake.mergeBaseMutex.Unlock()
+	fake.MergeBaseStub = nil
+	if fake.mergeBaseReturnsOnCall == nil {
+		fake.mergeBaseReturnsOnCall = make(map[int]struct {
+			result1 hash.Hash
+			result2 error
+		})
+	}
+	fake.mergeBaseReturnsOnCall[i] = struct {
+		result1 hash.Hash
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) NewStagedWriter(arg1 context.Context, arg2 nanogit.Ref, arg3 ...nanogit.WriterOption) (nanogit.StagedWriter, error) {
 	fake.newStagedWriterMutex.Lock()
 	ret, specificReturn := fake.newStagedWriterReturnsOnCall[len(fake.newStagedWriterArgsForCall)]
@@ -1519,6 +2381,141 @@ func (fake *FakeClient) RepoExistsReturnsOnCall(i int, result1 bool, result2 err
 	}{result1, result2}
 }
 
+func (fake *FakeClient) ResolveRevision(arg1 context.Context, arg2 string) (hash.Hash, protocol.ObjectType, error) {
+	fake.resolveRevisionMutex.Lock()
+	ret, specificReturn := fake.resolveRevisionReturnsOnCall[len(fake.resolveRevisionArgsForCall)]
+	fake.resolveRevisionArgsForCall = append(fake.resolveRevisionArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.ResolveRevisionStub
+	fakeReturns := fake.resolveRevisionReturns
+	fake.recordInvocation("ResolveRevision", []interface{}{arg1, arg2})
+	fake.resolveRevisionMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeClient) ResolveRevisionCallCount() int {
+	fake.resolveRevisionMutex.RLock()
+	defer fake.resolveRevisionMutex.RUnlock()
+	return len(fake.resolveRevisionArgsForCall)
+}
+
+func (fake *FakeClient) ResolveRevisionCalls(stub func(context.Context, string) (hash.Hash, protocol.ObjectType, error)) {
+	fake.resolveRevisionMutex.Lock()
+	defer fake.resolveRevisionMutex.Unlock()
+	fake.ResolveRevisionStub = stub
+}
+
+func (fake *FakeClient) ResolveRevisionArgsForCall(i int) (context.Context, string) {
+	fake.resolveRevisionMutex.RLock()
+	defer fake.resolveRevisionMutex.RUnlock()
+	argsForCall := fake.resolveRevisionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeClient) ResolveRevisionReturns(result1 hash.Hash, result2 protocol.ObjectType, result3 error) {
+	fake.resolveRevisionMutex.Lock()
+	defer fake.resolveRevisionMutex.Unlock()
+	fake.ResolveRevisionStub = nil
+	fake.resolveRevisionReturns = struct {
+		result1 hash.Hash
+		result2 protocol.ObjectType
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeClient) ResolveRevisionReturnsOnCall(i int, result1 hash.Hash, result2 protocol.ObjectType, result3 error) {
+	fake.resolveRevisionMutex.Lock()
+	defer fake.resolveRevisionMutex.Unlock()
+	fake.ResolveRevisionStub = nil
+	if fake.resolveRevisionReturnsOnCall == nil {
+		fake.resolveRevisionReturnsOnCall = make(map[int]struct {
+			result1 hash.Hash
+			result2 protocol.ObjectType
+			result3 error
+		})
+	}
+	fake.resolveRevisionReturnsOnCall[i] = struct {
+		result1 hash.Hash
+		result2 protocol.ObjectType
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeClient) Revert(arg1 context.Context, arg2 nanogit.Ref, arg3 hash.Hash, arg4 nanogit.RevertOptions) (*nanogit.Commit, error) {
+	fake.revertMutex.Lock()
+	ret, specificReturn := fake.revertReturnsOnCall[len(fake.revertArgsForCall)]
+	fake.revertArgsForCall = append(fake.revertArgsForCall, struct {
+		arg1 context.Context
+		arg2 nanogit.Ref
+		arg3 hash.Hash
+		arg4 nanogit.RevertOptions
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.RevertStub
+	fakeReturns := fake.revertReturns
+	fake.recordInvocation("Revert", []interface{}{arg1, arg2, arg3, arg4})
+	fake.revertMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) RevertCallCount() int {
+	fake.revertMutex.RLock()
+	defer fake.revertMutex.RUnlock()
+	return len(fake.revertArgsForCall)
+}
+
+func (fake *FakeClient) RevertCalls(stub func(context.Context, nanogit.Ref, hash.Hash, nanogit.RevertOptions) (*nanogit.Commit, error)) {
+	fake.revertMutex.Lock()
+	defer fake.revertMutex.Unlock()
+	fake.RevertStub = stub
+}
+
+func (fake *FakeClient) RevertArgsForCall(i int) (context.Context, nanogit.Ref, hash.Hash, nanogit.RevertOptions) {
+	fake.revertMutex.RLock()
+	defer fake.revertMutex.RUnlock()
+	argsForCall := fake.revertArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) RevertReturns(result1 *nanogit.Commit, result2 error) {
+	fake.revertMutex.Lock()
+	defer fake.revertMutex.Unlock()
+	fake.RevertStub = nil
+	fake.revertReturns = struct {
+		result1 *nanogit.Commit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) RevertReturnsOnCall(i int, result1 *nanogit.Commit, result2 error) {
+	fake.revertMutex.Lock()
+	defer fake.revertMutex.Unlock()
+	fake.RevertStub = nil
+	if fake.revertReturnsOnCall == nil {
+		fake.revertReturnsOnCall = make(map[int]struct {
+			result1 *nanogit.Commit
+			result2 error
+		})
+	}
+	fake.revertReturnsOnCall[i] = struct {
+		result1 *nanogit.Commit
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) UpdateRef(arg1 context.Context, arg2 nanogit.Ref) error {
 	fake.updateRefMutex.Lock()
 	ret, specificReturn := fake.updateRefReturnsOnCall[len(fake.updateRefArgsForCall)]
@@ -1581,6 +2578,70 @@ func (fake *FakeClient) UpdateRefReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeClient) WalkTree(arg1 context.Context, arg2 hash.Hash, arg3 nanogit.WalkFunc, arg4 nanogit.WalkTreeOptions) error {
+	fake.walkTreeMutex.Lock()
+	ret, specificReturn := fake.walkTreeReturnsOnCall[len(fake.walkTreeArgsForCall)]
+	fake.walkTreeArgsForCall = append(fake.walkTreeArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+		arg3 nanogit.WalkFunc
+		arg4 nanogit.WalkTreeOptions
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.WalkTreeStub
+	fakeReturns := fake.walkTreeReturns
+	fake.recordInvocation("WalkTree", []interface{}{arg1, arg2, arg3, arg4})
+	fake.walkTreeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) WalkTreeCallCount() int {
+	fake.walkTreeMutex.RLock()
+	defer fake.walkTreeMutex.RUnlock()
+	return len(fake.walkTreeArgsForCall)
+}
+
+func (fake *FakeClient) WalkTreeCalls(stub func(context.Context, hash.Hash, nanogit.WalkFunc, nanogit.WalkTreeOptions) error) {
+	fake.walkTreeMutex.Lock()
+	defer fake.walkTreeMutex.Unlock()
+	fake.WalkTreeStub = stub
+}
+
+func (fake *FakeClient) WalkTreeArgsForCall(i int) (context.Context, hash.Hash, nanogit.WalkFunc, nanogit.WalkTreeOptions) {
+	fake.walkTreeMutex.RLock()
+	defer fake.walkTreeMutex.RUnlock()
+	argsForCall := fake.walkTreeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) WalkTreeReturns(result1 error) {
+	fake.walkTreeMutex.Lock()
+	defer fake.walkTreeMutex.Unlock()
+	fake.WalkTreeStub = nil
+	fake.walkTreeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) WalkTreeReturnsOnCall(i int, result1 error) {
+	fake.walkTreeMutex.Lock()
+	defer fake.walkTreeMutex.Unlock()
+	fake.WalkTreeStub = nil
+	if fake.walkTreeReturnsOnCall == nil {
+		fake.walkTreeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.walkTreeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -113,7 +113,7 @@ func TestServiceWithMockedStagedWriter(t *testing.T) {
 	assert.Equal(t, 1, mockWriter.PushCallCount())
 
 	// Verify arguments
-	_, path, actualContent := mockWriter.CreateBlobArgsForCall(0)
+	_, path, actualContent, _ := mockWriter.CreateBlobArgsForCall(0)
 	assert.Equal(t, "test.txt", path)
 	assert.Equal(t, content, actualContent)
 }
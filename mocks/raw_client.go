@@ -10,6 +10,7 @@ import (
 
 	"github.com/grafana/nanogit/protocol"
 	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
 )
 
 type FakeRawClient struct {
@@ -106,6 +107,20 @@ type FakeRawClient struct {
 		result1 []protocol.RefLine
 		result2 error
 	}
+	ObjectInfoStub        func(context.Context, []hash.Hash) (map[hash.Hash]int64, error)
+	objectInfoMutex       sync.RWMutex
+	objectInfoArgsForCall []struct {
+		arg1 context.Context
+		arg2 []hash.Hash
+	}
+	objectInfoReturns struct {
+		result1 map[hash.Hash]int64
+		result2 error
+	}
+	objectInfoReturnsOnCall map[int]struct {
+		result1 map[hash.Hash]int64
+		result2 error
+	}
 	ReceivePackStub        func(context.Context, io.Reader) error
 	receivePackMutex       sync.RWMutex
 	receivePackArgsForCall []struct {
@@ -598,6 +613,76 @@ func (fake *FakeRawClient) LsRefsReturnsOnCall(i int, result1 []protocol.RefLine
 	}{result1, result2}
 }
 
+func (fake *FakeRawClient) ObjectInfo(arg1 context.Context, arg2 []hash.Hash) (map[hash.Hash]int64, error) {
+	var arg2Copy []hash.Hash
+	if arg2 != nil {
+		arg2Copy = make([]hash.Hash, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.objectInfoMutex.Lock()
+	ret, specificReturn := fake.objectInfoReturnsOnCall[len(fake.objectInfoArgsForCall)]
+	fake.objectInfoArgsForCall = append(fake.objectInfoArgsForCall, struct {
+		arg1 context.Context
+		arg2 []hash.Hash
+	}{arg1, arg2Copy})
+	stub := fake.ObjectInfoStub
+	fakeReturns := fake.objectInfoReturns
+	fake.recordInvocation("ObjectInfo", []interface{}{arg1, arg2Copy})
+	fake.objectInfoMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRawClient) ObjectInfoCallCount() int {
+	fake.objectInfoMutex.RLock()
+	defer fake.objectInfoMutex.RUnlock()
+	return len(fake.objectInfoArgsForCall)
+}
+
+func (fake *FakeRawClient) ObjectInfoCalls(stub func(context.Context, []hash.Hash) (map[hash.Hash]int64, error)) {
+	fake.objectInfoMutex.Lock()
+	defer fake.objectInfoMutex.Unlock()
+	fake.ObjectInfoStub = stub
+}
+
+func (fake *FakeRawClient) ObjectInfoArgsForCall(i int) (context.Context, []hash.Hash) {
+	fake.objectInfoMutex.RLock()
+	defer fake.objectInfoMutex.RUnlock()
+	argsForCall := fake.objectInfoArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRawClient) ObjectInfoReturns(result1 map[hash.Hash]int64, result2 error) {
+	fake.objectInfoMutex.Lock()
+	defer fake.objectInfoMutex.Unlock()
+	fake.ObjectInfoStub = nil
+	fake.objectInfoReturns = struct {
+		result1 map[hash.Hash]int64
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRawClient) ObjectInfoReturnsOnCall(i int, result1 map[hash.Hash]int64, result2 error) {
+	fake.objectInfoMutex.Lock()
+	defer fake.objectInfoMutex.Unlock()
+	fake.ObjectInfoStub = nil
+	if fake.objectInfoReturnsOnCall == nil {
+		fake.objectInfoReturnsOnCall = make(map[int]struct {
+			result1 map[hash.Hash]int64
+			result2 error
+		})
+	}
+	fake.objectInfoReturnsOnCall[i] = struct {
+		result1 map[hash.Hash]int64
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeRawClient) ReceivePack(arg1 context.Context, arg2 io.Reader) error {
 	fake.receivePackMutex.Lock()
 	ret, specificReturn := fake.receivePackReturnsOnCall[len(fake.receivePackArgsForCall)]
@@ -5,6 +5,7 @@ package mocks
 
 import (
 	"context"
+	"io"
 	"sync"
 
 	"github.com/grafana/nanogit"
@@ -12,6 +13,51 @@ import (
 )
 
 type FakeStagedWriter struct {
+	AddSubmoduleStub        func(context.Context, string, string, hash.Hash) error
+	addSubmoduleMutex       sync.RWMutex
+	addSubmoduleArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 hash.Hash
+	}
+	addSubmoduleReturns struct {
+		result1 error
+	}
+	addSubmoduleReturnsOnCall map[int]struct {
+		result1 error
+	}
+	AmendStub        func(context.Context, string, nanogit.Author, nanogit.Committer) (*nanogit.Commit, error)
+	amendMutex       sync.RWMutex
+	amendArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 nanogit.Author
+		arg4 nanogit.Committer
+	}
+	amendReturns struct {
+		result1 *nanogit.Commit
+		result2 error
+	}
+	amendReturnsOnCall map[int]struct {
+		result1 *nanogit.Commit
+		result2 error
+	}
+	ApplyPatchStub        func(context.Context, io.Reader, nanogit.ApplyPatchOptions) ([]nanogit.AppliedPatch, error)
+	applyPatchMutex       sync.RWMutex
+	applyPatchArgsForCall []struct {
+		arg1 context.Context
+		arg2 io.Reader
+		arg3 nanogit.ApplyPatchOptions
+	}
+	applyPatchReturns struct {
+		result1 []nanogit.AppliedPatch
+		result2 error
+	}
+	applyPatchReturnsOnCall map[int]struct {
+		result1 []nanogit.AppliedPatch
+		result2 error
+	}
 	BlobExistsStub        func(context.Context, string) (bool, error)
 	blobExistsMutex       sync.RWMutex
 	blobExistsArgsForCall []struct {
@@ -26,6 +72,18 @@ type FakeStagedWriter struct {
 		result1 bool
 		result2 error
 	}
+	CheckpointStub        func() (*nanogit.WriterCheckpoint, error)
+	checkpointMutex       sync.RWMutex
+	checkpointArgsForCall []struct {
+	}
+	checkpointReturns struct {
+		result1 *nanogit.WriterCheckpoint
+		result2 error
+	}
+	checkpointReturnsOnCall map[int]struct {
+		result1 *nanogit.WriterCheckpoint
+		result2 error
+	}
 	CleanupStub        func(context.Context) error
 	cleanupMutex       sync.RWMutex
 	cleanupArgsForCall []struct {
@@ -53,12 +111,13 @@ type FakeStagedWriter struct {
 		result1 *nanogit.Commit
 		result2 error
 	}
-	CreateBlobStub        func(context.Context, string, []byte) (hash.Hash, error)
+	CreateBlobStub        func(context.Context, string, []byte, ...nanogit.BlobOption) (hash.Hash, error)
 	createBlobMutex       sync.RWMutex
 	createBlobArgsForCall []struct {
 		arg1 context.Context
 		arg2 string
 		arg3 []byte
+		arg4 []nanogit.BlobOption
 	}
 	createBlobReturns struct {
 		result1 hash.Hash
@@ -68,6 +127,23 @@ type FakeStagedWriter struct {
 		result1 hash.Hash
 		result2 error
 	}
+	CreateBlobFromReaderStub        func(context.Context, string, io.Reader, int64, ...nanogit.BlobOption) (hash.Hash, error)
+	createBlobFromReaderMutex       sync.RWMutex
+	createBlobFromReaderArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 io.Reader
+		arg4 int64
+		arg5 []nanogit.BlobOption
+	}
+	createBlobFromReaderReturns struct {
+		result1 hash.Hash
+		result2 error
+	}
+	createBlobFromReaderReturnsOnCall map[int]struct {
+		result1 hash.Hash
+		result2 error
+	}
 	DeleteBlobStub        func(context.Context, string) (hash.Hash, error)
 	deleteBlobMutex       sync.RWMutex
 	deleteBlobArgsForCall []struct {
@@ -96,6 +172,20 @@ type FakeStagedWriter struct {
 		result1 hash.Hash
 		result2 error
 	}
+	DiffStub        func(context.Context, ...nanogit.CompareCommitsOption) ([]nanogit.CommitFile, error)
+	diffMutex       sync.RWMutex
+	diffArgsForCall []struct {
+		arg1 context.Context
+		arg2 []nanogit.CompareCommitsOption
+	}
+	diffReturns struct {
+		result1 []nanogit.CommitFile
+		result2 error
+	}
+	diffReturnsOnCall map[int]struct {
+		result1 []nanogit.CommitFile
+		result2 error
+	}
 	GetTreeStub        func(context.Context, string) (*nanogit.Tree, error)
 	getTreeMutex       sync.RWMutex
 	getTreeArgsForCall []struct {
@@ -151,12 +241,76 @@ type FakeStagedWriter struct {
 	pushReturnsOnCall map[int]struct {
 		result1 error
 	}
-	UpdateBlobStub        func(context.Context, string, []byte) (hash.Hash, error)
+	ResetToStub        func(context.Context, hash.Hash) error
+	resetToMutex       sync.RWMutex
+	resetToArgsForCall []struct {
+		arg1 context.Context
+		arg2 hash.Hash
+	}
+	resetToReturns struct {
+		result1 error
+	}
+	resetToReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RollbackStub        func(*nanogit.WriterCheckpoint) error
+	rollbackMutex       sync.RWMutex
+	rollbackArgsForCall []struct {
+		arg1 *nanogit.WriterCheckpoint
+	}
+	rollbackReturns struct {
+		result1 error
+	}
+	rollbackReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SetModeStub        func(context.Context, string, uint32) error
+	setModeMutex       sync.RWMutex
+	setModeArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 uint32
+	}
+	setModeReturns struct {
+		result1 error
+	}
+	setModeReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SquashStub        func(context.Context, int, string) (*nanogit.Commit, error)
+	squashMutex       sync.RWMutex
+	squashArgsForCall []struct {
+		arg1 context.Context
+		arg2 int
+		arg3 string
+	}
+	squashReturns struct {
+		result1 *nanogit.Commit
+		result2 error
+	}
+	squashReturnsOnCall map[int]struct {
+		result1 *nanogit.Commit
+		result2 error
+	}
+	StatusStub        func() (*nanogit.WriterStatus, error)
+	statusMutex       sync.RWMutex
+	statusArgsForCall []struct {
+	}
+	statusReturns struct {
+		result1 *nanogit.WriterStatus
+		result2 error
+	}
+	statusReturnsOnCall map[int]struct {
+		result1 *nanogit.WriterStatus
+		result2 error
+	}
+	UpdateBlobStub        func(context.Context, string, []byte, ...nanogit.BlobOption) (hash.Hash, error)
 	updateBlobMutex       sync.RWMutex
 	updateBlobArgsForCall []struct {
 		arg1 context.Context
 		arg2 string
 		arg3 []byte
+		arg4 []nanogit.BlobOption
 	}
 	updateBlobReturns struct {
 		result1 hash.Hash
@@ -166,10 +320,220 @@ type FakeStagedWriter struct {
 		result1 hash.Hash
 		result2 error
 	}
+	UpdateSubmoduleStub        func(context.Context, string, hash.Hash) error
+	updateSubmoduleMutex       sync.RWMutex
+	updateSubmoduleArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 hash.Hash
+	}
+	updateSubmoduleReturns struct {
+		result1 error
+	}
+	updateSubmoduleReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeStagedWriter) AddSubmodule(arg1 context.Context, arg2 string, arg3 string, arg4 hash.Hash) error {
+	fake.addSubmoduleMutex.Lock()
+	ret, specificReturn := fake.addSubmoduleReturnsOnCall[len(fake.addSubmoduleArgsForCall)]
+	fake.addSubmoduleArgsForCall = append(fake.addSubmoduleArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 hash.Hash
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.AddSubmoduleStub
+	fakeReturns := fake.addSubmoduleReturns
+	fake.recordInvocation("AddSubmodule", []interface{}{arg1, arg2, arg3, arg4})
+	fake.addSubmoduleMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeStagedWriter) AddSubmoduleCallCount() int {
+	fake.addSubmoduleMutex.RLock()
+	defer fake.addSubmoduleMutex.RUnlock()
+	return len(fake.addSubmoduleArgsForCall)
+}
+
+func (fake *FakeStagedWriter) AddSubmoduleCalls(stub func(context.Context, string, string, hash.Hash) error) {
+	fake.addSubmoduleMutex.Lock()
+	defer fake.addSubmoduleMutex.Unlock()
+	fake.AddSubmoduleStub = stub
+}
+
+func (fake *FakeStagedWriter) AddSubmoduleArgsForCall(i int) (context.Context, string, string, hash.Hash) {
+	fake.addSubmoduleMutex.RLock()
+	defer fake.addSubmoduleMutex.RUnlock()
+	argsForCall := fake.addSubmoduleArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeStagedWriter) AddSubmoduleReturns(result1 error) {
+	fake.addSubmoduleMutex.Lock()
+	defer fake.addSubmoduleMutex.Unlock()
+	fake.AddSubmoduleStub = nil
+	fake.addSubmoduleReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) AddSubmoduleReturnsOnCall(i int, result1 error) {
+	fake.addSubmoduleMutex.Lock()
+	defer fake.addSubmoduleMutex.Unlock()
+	fake.AddSubmoduleStub = nil
+	if fake.addSubmoduleReturnsOnCall == nil {
+		fake.addSubmoduleReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.addSubmoduleReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) Amend(arg1 context.Context, arg2 string, arg3 nanogit.Author, arg4 nanogit.Committer) (*nanogit.Commit, error) {
+	fake.amendMutex.Lock()
+	ret, specificReturn := fake.amendReturnsOnCall[len(fake.amendArgsForCall)]
+	fake.amendArgsForCall = append(fake.amendArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 nanogit.Author
+		arg4 nanogit.Committer
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.AmendStub
+	fakeReturns := fake.amendReturns
+	fake.recordInvocation("Amend", []interface{}{arg1, arg2, arg3, arg4})
+	fake.amendMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStagedWriter) AmendCallCount() int {
+	fake.amendMutex.RLock()
+	defer fake.amendMutex.RUnlock()
+	return len(fake.amendArgsForCall)
+}
+
+func (fake *FakeStagedWriter) AmendCalls(stub func(context.Context, string, nanogit.Author, nanogit.Committer) (*nanogit.Commit, error)) {
+	fake.amendMutex.Lock()
+	defer fake.amendMutex.Unlock()
+	fake.AmendStub = stub
+}
+
+func (fake *FakeStagedWriter) AmendArgsForCall(i int) (context.Context, string, nanogit.Author, nanogit.Committer) {
+	fake.amendMutex.RLock()
+	defer fake.amendMutex.RUnlock()
+	argsForCall := fake.amendArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeStagedWriter) AmendReturns(result1 *nanogit.Commit, result2 error) {
+	fake.amendMutex.Lock()
+	defer fake.amendMutex.Unlock()
+	fake.AmendStub = nil
+	fake.amendReturns = struct {
+		result1 *nanogit.Commit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) AmendReturnsOnCall(i int, result1 *nanogit.Commit, result2 error) {
+	fake.amendMutex.Lock()
+	defer fake.amendMutex.Unlock()
+	fake.AmendStub = nil
+	if fake.amendReturnsOnCall == nil {
+		fake.amendReturnsOnCall = make(map[int]struct {
+			result1 *nanogit.Commit
+			result2 error
+		})
+	}
+	fake.amendReturnsOnCall[i] = struct {
+		result1 *nanogit.Commit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) ApplyPatch(arg1 context.Context, arg2 io.Reader, arg3 nanogit.ApplyPatchOptions) ([]nanogit.AppliedPatch, error) {
+	fake.applyPatchMutex.Lock()
+	ret, specificReturn := fake.applyPatchReturnsOnCall[len(fake.applyPatchArgsForCall)]
+	fake.applyPatchArgsForCall = append(fake.applyPatchArgsForCall, struct {
+		arg1 context.Context
+		arg2 io.Reader
+		arg3 nanogit.ApplyPatchOptions
+	}{arg1, arg2, arg3})
+	stub := fake.ApplyPatchStub
+	fakeReturns := fake.applyPatchReturns
+	fake.recordInvocation("ApplyPatch", []interface{}{arg1, arg2, arg3})
+	fake.applyPatchMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStagedWriter) ApplyPatchCallCount() int {
+	fake.applyPatchMutex.RLock()
+	defer fake.applyPatchMutex.RUnlock()
+	return len(fake.applyPatchArgsForCall)
+}
+
+func (fake *FakeStagedWriter) ApplyPatchCalls(stub func(context.Context, io.Reader, nanogit.ApplyPatchOptions) ([]nanogit.AppliedPatch, error)) {
+	fake.applyPatchMutex.Lock()
+	defer fake.applyPatchMutex.Unlock()
+	fake.ApplyPatchStub = stub
+}
+
+func (fake *FakeStagedWriter) ApplyPatchArgsForCall(i int) (context.Context, io.Reader, nanogit.ApplyPatchOptions) {
+	fake.applyPatchMutex.RLock()
+	defer fake.applyPatchMutex.RUnlock()
+	argsForCall := fake.applyPatchArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeStagedWriter) ApplyPatchReturns(result1 []nanogit.AppliedPatch, result2 error) {
+	fake.applyPatchMutex.Lock()
+	defer fake.applyPatchMutex.Unlock()
+	fake.ApplyPatchStub = nil
+	fake.applyPatchReturns = struct {
+		result1 []nanogit.AppliedPatch
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) ApplyPatchReturnsOnCall(i int, result1 []nanogit.AppliedPatch, result2 error) {
+	fake.applyPatchMutex.Lock()
+	defer fake.applyPatchMutex.Unlock()
+	fake.ApplyPatchStub = nil
+	if fake.applyPatchReturnsOnCall == nil {
+		fake.applyPatchReturnsOnCall = make(map[int]struct {
+			result1 []nanogit.AppliedPatch
+			result2 error
+		})
+	}
+	fake.applyPatchReturnsOnCall[i] = struct {
+		result1 []nanogit.AppliedPatch
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeStagedWriter) BlobExists(arg1 context.Context, arg2 string) (bool, error) {
 	fake.blobExistsMutex.Lock()
 	ret, specificReturn := fake.blobExistsReturnsOnCall[len(fake.blobExistsArgsForCall)]
@@ -235,6 +599,62 @@ func (fake *FakeStagedWriter) BlobExistsReturnsOnCall(i int, result1 bool, resul
 	}{result1, result2}
 }
 
+func (fake *FakeStagedWriter) Checkpoint() (*nanogit.WriterCheckpoint, error) {
+	fake.checkpointMutex.Lock()
+	ret, specificReturn := fake.checkpointReturnsOnCall[len(fake.checkpointArgsForCall)]
+	fake.checkpointArgsForCall = append(fake.checkpointArgsForCall, struct {
+	}{})
+	stub := fake.CheckpointStub
+	fakeReturns := fake.checkpointReturns
+	fake.recordInvocation("Checkpoint", []interface{}{})
+	fake.checkpointMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStagedWriter) CheckpointCallCount() int {
+	fake.checkpointMutex.RLock()
+	defer fake.checkpointMutex.RUnlock()
+	return len(fake.checkpointArgsForCall)
+}
+
+func (fake *FakeStagedWriter) CheckpointCalls(stub func() (*nanogit.WriterCheckpoint, error)) {
+	fake.checkpointMutex.Lock()
+	defer fake.checkpointMutex.Unlock()
+	fake.CheckpointStub = stub
+}
+
+func (fake *FakeStagedWriter) CheckpointReturns(result1 *nanogit.WriterCheckpoint, result2 error) {
+	fake.checkpointMutex.Lock()
+	defer fake.checkpointMutex.Unlock()
+	fake.CheckpointStub = nil
+	fake.checkpointReturns = struct {
+		result1 *nanogit.WriterCheckpoint
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) CheckpointReturnsOnCall(i int, result1 *nanogit.WriterCheckpoint, result2 error) {
+	fake.checkpointMutex.Lock()
+	defer fake.checkpointMutex.Unlock()
+	fake.CheckpointStub = nil
+	if fake.checkpointReturnsOnCall == nil {
+		fake.checkpointReturnsOnCall = make(map[int]struct {
+			result1 *nanogit.WriterCheckpoint
+			result2 error
+		})
+	}
+	fake.checkpointReturnsOnCall[i] = struct {
+		result1 *nanogit.WriterCheckpoint
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeStagedWriter) Cleanup(arg1 context.Context) error {
 	fake.cleanupMutex.Lock()
 	ret, specificReturn := fake.cleanupReturnsOnCall[len(fake.cleanupArgsForCall)]
@@ -363,7 +783,7 @@ func (fake *FakeStagedWriter) CommitReturnsOnCall(i int, result1 *nanogit.Commit
 	}{result1, result2}
 }
 
-func (fake *FakeStagedWriter) CreateBlob(arg1 context.Context, arg2 string, arg3 []byte) (hash.Hash, error) {
+func (fake *FakeStagedWriter) CreateBlob(arg1 context.Context, arg2 string, arg3 []byte, arg4 ...nanogit.BlobOption) (hash.Hash, error) {
 	var arg3Copy []byte
 	if arg3 != nil {
 		arg3Copy = make([]byte, len(arg3))
@@ -375,13 +795,14 @@ func (fake *FakeStagedWriter) CreateBlob(arg1 context.Context, arg2 string, arg3
 		arg1 context.Context
 		arg2 string
 		arg3 []byte
-	}{arg1, arg2, arg3Copy})
+		arg4 []nanogit.BlobOption
+	}{arg1, arg2, arg3Copy, arg4})
 	stub := fake.CreateBlobStub
 	fakeReturns := fake.createBlobReturns
-	fake.recordInvocation("CreateBlob", []interface{}{arg1, arg2, arg3Copy})
+	fake.recordInvocation("CreateBlob", []interface{}{arg1, arg2, arg3Copy, arg4})
 	fake.createBlobMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3)
+		return stub(arg1, arg2, arg3, arg4...)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -395,17 +816,17 @@ func (fake *FakeStagedWriter) CreateBlobCallCount() int {
 	return len(fake.createBlobArgsForCall)
 }
 
-func (fake *FakeStagedWriter) CreateBlobCalls(stub func(context.Context, string, []byte) (hash.Hash, error)) {
+func (fake *FakeStagedWriter) CreateBlobCalls(stub func(context.Context, string, []byte, ...nanogit.BlobOption) (hash.Hash, error)) {
 	fake.createBlobMutex.Lock()
 	defer fake.createBlobMutex.Unlock()
 	fake.CreateBlobStub = stub
 }
 
-func (fake *FakeStagedWriter) CreateBlobArgsForCall(i int) (context.Context, string, []byte) {
+func (fake *FakeStagedWriter) CreateBlobArgsForCall(i int) (context.Context, string, []byte, []nanogit.BlobOption) {
 	fake.createBlobMutex.RLock()
 	defer fake.createBlobMutex.RUnlock()
 	argsForCall := fake.createBlobArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *FakeStagedWriter) CreateBlobReturns(result1 hash.Hash, result2 error) {
@@ -434,6 +855,74 @@ func (fake *FakeStagedWriter) CreateBlobReturnsOnCall(i int, result1 hash.Hash,
 	}{result1, result2}
 }
 
+func (fake *FakeStagedWriter) CreateBlobFromReader(arg1 context.Context, arg2 string, arg3 io.Reader, arg4 int64, arg5 ...nanogit.BlobOption) (hash.Hash, error) {
+	fake.createBlobFromReaderMutex.Lock()
+	ret, specificReturn := fake.createBlobFromReaderReturnsOnCall[len(fake.createBlobFromReaderArgsForCall)]
+	fake.createBlobFromReaderArgsForCall = append(fake.createBlobFromReaderArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 io.Reader
+		arg4 int64
+		arg5 []nanogit.BlobOption
+	}{arg1, arg2, arg3, arg4, arg5})
+	stub := fake.CreateBlobFromReaderStub
+	fakeReturns := fake.createBlobFromReaderReturns
+	fake.recordInvocation("CreateBlobFromReader", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.createBlobFromReaderMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4, arg5...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStagedWriter) CreateBlobFromReaderCallCount() int {
+	fake.createBlobFromReaderMutex.RLock()
+	defer fake.createBlobFromReaderMutex.RUnlock()
+	return len(fake.createBlobFromReaderArgsForCall)
+}
+
+func (fake *FakeStagedWriter) CreateBlobFromReaderCalls(stub func(context.Context, string, io.Reader, int64, ...nanogit.BlobOption) (hash.Hash, error)) {
+	fake.createBlobFromReaderMutex.Lock()
+	defer fake.createBlobFromReaderMutex.Unlock()
+	fake.CreateBlobFromReaderStub = stub
+}
+
+func (fake *FakeStagedWriter) CreateBlobFromReaderArgsForCall(i int) (context.Context, string, io.Reader, int64, []nanogit.BlobOption) {
+	fake.createBlobFromReaderMutex.RLock()
+	defer fake.createBlobFromReaderMutex.RUnlock()
+	argsForCall := fake.createBlobFromReaderArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *FakeStagedWriter) CreateBlobFromReaderReturns(result1 hash.Hash, result2 error) {
+	fake.createBlobFromReaderMutex.Lock()
+	defer fake.createBlobFromReaderMutex.Unlock()
+	fake.CreateBlobFromReaderStub = nil
+	fake.createBlobFromReaderReturns = struct {
+		result1 hash.Hash
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) CreateBlobFromReaderReturnsOnCall(i int, result1 hash.Hash, result2 error) {
+	fake.createBlobFromReaderMutex.Lock()
+	defer fake.createBlobFromReaderMutex.Unlock()
+	fake.CreateBlobFromReaderStub = nil
+	if fake.createBlobFromReaderReturnsOnCall == nil {
+		fake.createBlobFromReaderReturnsOnCall = make(map[int]struct {
+			result1 hash.Hash
+			result2 error
+		})
+	}
+	fake.createBlobFromReaderReturnsOnCall[i] = struct {
+		result1 hash.Hash
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeStagedWriter) DeleteBlob(arg1 context.Context, arg2 string) (hash.Hash, error) {
 	fake.deleteBlobMutex.Lock()
 	ret, specificReturn := fake.deleteBlobReturnsOnCall[len(fake.deleteBlobArgsForCall)]
@@ -564,6 +1053,71 @@ func (fake *FakeStagedWriter) DeleteTreeReturnsOnCall(i int, result1 hash.Hash,
 	}{result1, result2}
 }
 
+func (fake *FakeStagedWriter) Diff(arg1 context.Context, arg2 ...nanogit.CompareCommitsOption) ([]nanogit.CommitFile, error) {
+	fake.diffMutex.Lock()
+	ret, specificReturn := fake.diffReturnsOnCall[len(fake.diffArgsForCall)]
+	fake.diffArgsForCall = append(fake.diffArgsForCall, struct {
+		arg1 context.Context
+		arg2 []nanogit.CompareCommitsOption
+	}{arg1, arg2})
+	stub := fake.DiffStub
+	fakeReturns := fake.diffReturns
+	fake.recordInvocation("Diff", []interface{}{arg1, arg2})
+	fake.diffMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStagedWriter) DiffCallCount() int {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	return len(fake.diffArgsForCall)
+}
+
+func (fake *FakeStagedWriter) DiffCalls(stub func(context.Context, ...nanogit.CompareCommitsOption) ([]nanogit.CommitFile, error)) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = stub
+}
+
+func (fake *FakeStagedWriter) DiffArgsForCall(i int) (context.Context, []nanogit.CompareCommitsOption) {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	argsForCall := fake.diffArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeStagedWriter) DiffReturns(result1 []nanogit.CommitFile, result2 error) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = nil
+	fake.diffReturns = struct {
+		result1 []nanogit.CommitFile
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) DiffReturnsOnCall(i int, result1 []nanogit.CommitFile, result2 error) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = nil
+	if fake.diffReturnsOnCall == nil {
+		fake.diffReturnsOnCall = make(map[int]struct {
+			result1 []nanogit.CommitFile
+			result2 error
+		})
+	}
+	fake.diffReturnsOnCall[i] = struct {
+		result1 []nanogit.CommitFile
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeStagedWriter) GetTree(arg1 context.Context, arg2 string) (*nanogit.Tree, error) {
 	fake.getTreeMutex.Lock()
 	ret, specificReturn := fake.getTreeReturnsOnCall[len(fake.getTreeArgsForCall)]
@@ -822,7 +1376,315 @@ func (fake *FakeStagedWriter) PushReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeStagedWriter) UpdateBlob(arg1 context.Context, arg2 string, arg3 []byte) (hash.Hash, error) {
+func (fake *FakeStagedWriter) ResetTo(arg1 context.Context, arg2 hash.Hash) error {
+	fake.resetToMutex.Lock()
+	ret, specificReturn := fake.resetToReturnsOnCall[len(fake.resetToArgsForCall)]
+	fake.resetToArgsForCall = append(fake.resetToArgsForCall, struct {
+		arg1 context.Context
+		arg2 hash.Hash
+	}{arg1, arg2})
+	stub := fake.ResetToStub
+	fakeReturns := fake.resetToReturns
+	fake.recordInvocation("ResetTo", []interface{}{arg1, arg2})
+	fake.resetToMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeStagedWriter) ResetToCallCount() int {
+	fake.resetToMutex.RLock()
+	defer fake.resetToMutex.RUnlock()
+	return len(fake.resetToArgsForCall)
+}
+
+func (fake *FakeStagedWriter) ResetToCalls(stub func(context.Context, hash.Hash) error) {
+	fake.resetToMutex.Lock()
+	defer fake.resetToMutex.Unlock()
+	fake.ResetToStub = stub
+}
+
+func (fake *FakeStagedWriter) ResetToArgsForCall(i int) (context.Context, hash.Hash) {
+	fake.resetToMutex.RLock()
+	defer fake.resetToMutex.RUnlock()
+	argsForCall := fake.resetToArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeStagedWriter) ResetToReturns(result1 error) {
+	fake.resetToMutex.Lock()
+	defer fake.resetToMutex.Unlock()
+	fake.ResetToStub = nil
+	fake.resetToReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) ResetToReturnsOnCall(i int, result1 error) {
+	fake.resetToMutex.Lock()
+	defer fake.resetToMutex.Unlock()
+	fake.ResetToStub = nil
+	if fake.resetToReturnsOnCall == nil {
+		fake.resetToReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.resetToReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) Rollback(arg1 *nanogit.WriterCheckpoint) error {
+	fake.rollbackMutex.Lock()
+	ret, specificReturn := fake.rollbackReturnsOnCall[len(fake.rollbackArgsForCall)]
+	fake.rollbackArgsForCall = append(fake.rollbackArgsForCall, struct {
+		arg1 *nanogit.WriterCheckpoint
+	}{arg1})
+	stub := fake.RollbackStub
+	fakeReturns := fake.rollbackReturns
+	fake.recordInvocation("Rollback", []interface{}{arg1})
+	fake.rollbackMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeStagedWriter) RollbackCallCount() int {
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	return len(fake.rollbackArgsForCall)
+}
+
+func (fake *FakeStagedWriter) RollbackCalls(stub func(*nanogit.WriterCheckpoint) error) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = stub
+}
+
+func (fake *FakeStagedWriter) RollbackArgsForCall(i int) *nanogit.WriterCheckpoint {
+	fake.rollbackMutex.RLock()
+	defer fake.rollbackMutex.RUnlock()
+	argsForCall := fake.rollbackArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeStagedWriter) RollbackReturns(result1 error) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = nil
+	fake.rollbackReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) RollbackReturnsOnCall(i int, result1 error) {
+	fake.rollbackMutex.Lock()
+	defer fake.rollbackMutex.Unlock()
+	fake.RollbackStub = nil
+	if fake.rollbackReturnsOnCall == nil {
+		fake.rollbackReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.rollbackReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) SetMode(arg1 context.Context, arg2 string, arg3 uint32) error {
+	fake.setModeMutex.Lock()
+	ret, specificReturn := fake.setModeReturnsOnCall[len(fake.setModeArgsForCall)]
+	fake.setModeArgsForCall = append(fake.setModeArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 uint32
+	}{arg1, arg2, arg3})
+	stub := fake.SetModeStub
+	fakeReturns := fake.setModeReturns
+	fake.recordInvocation("SetMode", []interface{}{arg1, arg2, arg3})
+	fake.setModeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeStagedWriter) SetModeCallCount() int {
+	fake.setModeMutex.RLock()
+	defer fake.setModeMutex.RUnlock()
+	return len(fake.setModeArgsForCall)
+}
+
+func (fake *FakeStagedWriter) SetModeCalls(stub func(context.Context, string, uint32) error) {
+	fake.setModeMutex.Lock()
+	defer fake.setModeMutex.Unlock()
+	fake.SetModeStub = stub
+}
+
+func (fake *FakeStagedWriter) SetModeArgsForCall(i int) (context.Context, string, uint32) {
+	fake.setModeMutex.RLock()
+	defer fake.setModeMutex.RUnlock()
+	argsForCall := fake.setModeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeStagedWriter) SetModeReturns(result1 error) {
+	fake.setModeMutex.Lock()
+	defer fake.setModeMutex.Unlock()
+	fake.SetModeStub = nil
+	fake.setModeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) SetModeReturnsOnCall(i int, result1 error) {
+	fake.setModeMutex.Lock()
+	defer fake.setModeMutex.Unlock()
+	fake.SetModeStub = nil
+	if fake.setModeReturnsOnCall == nil {
+		fake.setModeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setModeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) Squash(arg1 context.Context, arg2 int, arg3 string) (*nanogit.Commit, error) {
+	fake.squashMutex.Lock()
+	ret, specificReturn := fake.squashReturnsOnCall[len(fake.squashArgsForCall)]
+	fake.squashArgsForCall = append(fake.squashArgsForCall, struct {
+		arg1 context.Context
+		arg2 int
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.SquashStub
+	fakeReturns := fake.squashReturns
+	fake.recordInvocation("Squash", []interface{}{arg1, arg2, arg3})
+	fake.squashMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStagedWriter) SquashCallCount() int {
+	fake.squashMutex.RLock()
+	defer fake.squashMutex.RUnlock()
+	return len(fake.squashArgsForCall)
+}
+
+func (fake *FakeStagedWriter) SquashCalls(stub func(context.Context, int, string) (*nanogit.Commit, error)) {
+	fake.squashMutex.Lock()
+	defer fake.squashMutex.Unlock()
+	fake.SquashStub = stub
+}
+
+func (fake *FakeStagedWriter) SquashArgsForCall(i int) (context.Context, int, string) {
+	fake.squashMutex.RLock()
+	defer fake.squashMutex.RUnlock()
+	argsForCall := fake.squashArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeStagedWriter) SquashReturns(result1 *nanogit.Commit, result2 error) {
+	fake.squashMutex.Lock()
+	defer fake.squashMutex.Unlock()
+	fake.SquashStub = nil
+	fake.squashReturns = struct {
+		result1 *nanogit.Commit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) SquashReturnsOnCall(i int, result1 *nanogit.Commit, result2 error) {
+	fake.squashMutex.Lock()
+	defer fake.squashMutex.Unlock()
+	fake.SquashStub = nil
+	if fake.squashReturnsOnCall == nil {
+		fake.squashReturnsOnCall = make(map[int]struct {
+			result1 *nanogit.Commit
+			result2 error
+		})
+	}
+	fake.squashReturnsOnCall[i] = struct {
+		result1 *nanogit.Commit
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) Status() (*nanogit.WriterStatus, error) {
+	fake.statusMutex.Lock()
+	ret, specificReturn := fake.statusReturnsOnCall[len(fake.statusArgsForCall)]
+	fake.statusArgsForCall = append(fake.statusArgsForCall, struct {
+	}{})
+	stub := fake.StatusStub
+	fakeReturns := fake.statusReturns
+	fake.recordInvocation("Status", []interface{}{})
+	fake.statusMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStagedWriter) StatusCallCount() int {
+	fake.statusMutex.RLock()
+	defer fake.statusMutex.RUnlock()
+	return len(fake.statusArgsForCall)
+}
+
+func (fake *FakeStagedWriter) StatusCalls(stub func() (*nanogit.WriterStatus, error)) {
+	fake.statusMutex.Lock()
+	defer fake.statusMutex.Unlock()
+	fake.StatusStub = stub
+}
+
+func (fake *FakeStagedWriter) StatusReturns(result1 *nanogit.WriterStatus, result2 error) {
+	fake.statusMutex.Lock()
+	defer fake.statusMutex.Unlock()
+	fake.StatusStub = nil
+	fake.statusReturns = struct {
+		result1 *nanogit.WriterStatus
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) StatusReturnsOnCall(i int, result1 *nanogit.WriterStatus, result2 error) {
+	fake.statusMutex.Lock()
+	defer fake.statusMutex.Unlock()
+	fake.StatusStub = nil
+	if fake.statusReturnsOnCall == nil {
+		fake.statusReturnsOnCall = make(map[int]struct {
+			result1 *nanogit.WriterStatus
+			result2 error
+		})
+	}
+	fake.statusReturnsOnCall[i] = struct {
+		result1 *nanogit.WriterStatus
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStagedWriter) UpdateBlob(arg1 context.Context, arg2 string, arg3 []byte, arg4 ...nanogit.BlobOption) (hash.Hash, error) {
 	var arg3Copy []byte
 	if arg3 != nil {
 		arg3Copy = make([]byte, len(arg3))
@@ -834,13 +1696,14 @@ func (fake *FakeStagedWriter) UpdateBlob(arg1 context.Context, arg2 string, arg3
 		arg1 context.Context
 		arg2 string
 		arg3 []byte
-	}{arg1, arg2, arg3Copy})
+		arg4 []nanogit.BlobOption
+	}{arg1, arg2, arg3Copy, arg4})
 	stub := fake.UpdateBlobStub
 	fakeReturns := fake.updateBlobReturns
-	fake.recordInvocation("UpdateBlob", []interface{}{arg1, arg2, arg3Copy})
+	fake.recordInvocation("UpdateBlob", []interface{}{arg1, arg2, arg3Copy, arg4})
 	fake.updateBlobMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3)
+		return stub(arg1, arg2, arg3, arg4...)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -854,17 +1717,17 @@ func (fake *FakeStagedWriter) UpdateBlobCallCount() int {
 	return len(fake.updateBlobArgsForCall)
 }
 
-func (fake *FakeStagedWriter) UpdateBlobCalls(stub func(context.Context, string, []byte) (hash.Hash, error)) {
+func (fake *FakeStagedWriter) UpdateBlobCalls(stub func(context.Context, string, []byte, ...nanogit.BlobOption) (hash.Hash, error)) {
 	fake.updateBlobMutex.Lock()
 	defer fake.updateBlobMutex.Unlock()
 	fake.UpdateBlobStub = stub
 }
 
-func (fake *FakeStagedWriter) UpdateBlobArgsForCall(i int) (context.Context, string, []byte) {
+func (fake *FakeStagedWriter) UpdateBlobArgsForCall(i int) (context.Context, string, []byte, []nanogit.BlobOption) {
 	fake.updateBlobMutex.RLock()
 	defer fake.updateBlobMutex.RUnlock()
 	argsForCall := fake.updateBlobArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *FakeStagedWriter) UpdateBlobReturns(result1 hash.Hash, result2 error) {
@@ -893,6 +1756,69 @@ func (fake *FakeStagedWriter) UpdateBlobReturnsOnCall(i int, result1 hash.Hash,
 	}{result1, result2}
 }
 
+func (fake *FakeStagedWriter) UpdateSubmodule(arg1 context.Context, arg2 string, arg3 hash.Hash) error {
+	fake.updateSubmoduleMutex.Lock()
+	ret, specificReturn := fake.updateSubmoduleReturnsOnCall[len(fake.updateSubmoduleArgsForCall)]
+	fake.updateSubmoduleArgsForCall = append(fake.updateSubmoduleArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 hash.Hash
+	}{arg1, arg2, arg3})
+	stub := fake.UpdateSubmoduleStub
+	fakeReturns := fake.updateSubmoduleReturns
+	fake.recordInvocation("UpdateSubmodule", []interface{}{arg1, arg2, arg3})
+	fake.updateSubmoduleMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeStagedWriter) UpdateSubmoduleCallCount() int {
+	fake.updateSubmoduleMutex.RLock()
+	defer fake.updateSubmoduleMutex.RUnlock()
+	return len(fake.updateSubmoduleArgsForCall)
+}
+
+func (fake *FakeStagedWriter) UpdateSubmoduleCalls(stub func(context.Context, string, hash.Hash) error) {
+	fake.updateSubmoduleMutex.Lock()
+	defer fake.updateSubmoduleMutex.Unlock()
+	fake.UpdateSubmoduleStub = stub
+}
+
+func (fake *FakeStagedWriter) UpdateSubmoduleArgsForCall(i int) (context.Context, string, hash.Hash) {
+	fake.updateSubmoduleMutex.RLock()
+	defer fake.updateSubmoduleMutex.RUnlock()
+	argsForCall := fake.updateSubmoduleArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeStagedWriter) UpdateSubmoduleReturns(result1 error) {
+	fake.updateSubmoduleMutex.Lock()
+	defer fake.updateSubmoduleMutex.Unlock()
+	fake.UpdateSubmoduleStub = nil
+	fake.updateSubmoduleReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStagedWriter) UpdateSubmoduleReturnsOnCall(i int, result1 error) {
+	fake.updateSubmoduleMutex.Lock()
+	defer fake.updateSubmoduleMutex.Unlock()
+	fake.UpdateSubmoduleStub = nil
+	if fake.updateSubmoduleReturnsOnCall == nil {
+		fake.updateSubmoduleReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.updateSubmoduleReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeStagedWriter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -75,7 +75,8 @@ type Limits struct {
 	MultiObjectFetchMaxBytes int64
 	// RefsMetadataMaxBytes caps ref-listing and protocol-detection
 	// responses, which also ride git-upload-pack (ls-refs command) and the
-	// smart-info / capability advertisement (ListRefs, GetRef).
+	// smart-info / capability advertisement (ListRefs, GetRef), and the
+	// object sizes a Clone reporting progress asks for (object-info command).
 	RefsMetadataMaxBytes int64
 	// ReceivePackResponseMaxBytes caps the git-receive-pack reply to a
 	// push (CreateRef, UpdateRef, DeleteRef, staged Push).
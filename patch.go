@@ -0,0 +1,281 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// patchContextLines is how many unchanged lines surround the changes of a
+// hunk, as with git's default.
+const patchContextLines = 3
+
+// patchBlobBatchSize is how many blobs are fetched per request to make
+// patches.
+const patchBlobBatchSize = 50
+
+// blobReader returns the contents of the blobs with the given hashes.
+type blobReader func(ctx context.Context, hashes []hash.Hash) (map[hash.Hash][]byte, error)
+
+// readBlob returns the content of a blob fetched from the remote.
+func (c *httpClient) readBlob(ctx context.Context, h hash.Hash) ([]byte, error) {
+	blob, err := c.GetBlob(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return blob.Content, nil
+}
+
+// readBlobs is a blobReader fetching blobs from the remote in batches.
+func (c *httpClient) readBlobs(ctx context.Context, hashes []hash.Hash) (map[hash.Hash][]byte, error) {
+	contents := make(map[hash.Hash][]byte, len(hashes))
+	if len(hashes) == 0 {
+		return contents, nil
+	}
+	err := c.GetBlobs(ctx, hashes, func(blob *Blob) error {
+		contents[blob.Hash] = blob.Content
+		return nil
+	}, GetBlobsOptions{BatchSize: patchBlobBatchSize})
+	if err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// patchSide is one side of the change of a file.
+type patchSide struct {
+	exists  bool
+	path    string
+	mode    uint32
+	hash    hash.Hash
+	content []byte
+}
+
+// addPatches sets the Patch of every change but directories, reading the
+// contents with a single call to read.
+func addPatches(ctx context.Context, changes []CommitFile, read blobReader) error {
+	type filePatch struct {
+		change    int
+		old, head patchSide
+	}
+	var (
+		patches []filePatch
+		hashes  []hash.Hash
+	)
+	for i, change := range changes {
+		oldMode := change.OldMode
+		if change.Status == protocol.FileStatusDeleted {
+			// Deleted files carry their mode in Mode.
+			oldMode = change.Mode
+		}
+		oldPath := change.Path
+		if change.OldPath != "" {
+			oldPath = change.OldPath
+		}
+		old := patchSide{
+			exists: change.Status != protocol.FileStatusAdded && change.OldType != protocol.ObjectTypeTree,
+			path:   oldPath,
+			mode:   oldMode,
+			hash:   change.OldHash,
+		}
+		head := patchSide{
+			exists: change.Status != protocol.FileStatusDeleted && change.Type != protocol.ObjectTypeTree,
+			path:   change.Path,
+			mode:   change.Mode,
+			hash:   change.Hash,
+		}
+		if !old.exists && !head.exists {
+			continue
+		}
+
+		patches = append(patches, filePatch{change: i, old: old, head: head})
+		if old.exists && head.exists && old.hash == head.hash {
+			continue
+		}
+		for _, side := range []patchSide{old, head} {
+			if side.exists && side.mode != 0o160000 {
+				hashes = append(hashes, side.hash)
+			}
+		}
+	}
+
+	contents, err := read(ctx, hashes)
+	if err != nil {
+		return fmt.Errorf("read changed files: %w", err)
+	}
+	for _, p := range patches {
+		unchanged := p.old.exists && p.head.exists && p.old.hash == p.head.hash
+		for _, side := range []*patchSide{&p.old, &p.head} {
+			if !side.exists || unchanged {
+				continue
+			}
+			content, err := patchContent(*side, contents)
+			if err != nil {
+				return err
+			}
+			side.content = content
+		}
+		changes[p.change].Patch = formatPatch(p.old, p.head)
+	}
+	return nil
+}
+
+// patchContent returns the content of a side of a change from the contents
+// read. A submodule is shown as the commit it is pinned to, like git does.
+func patchContent(side patchSide, contents map[hash.Hash][]byte) ([]byte, error) {
+	if side.mode == 0o160000 {
+		return []byte("Subproject commit " + side.hash.String() + "\n"), nil
+	}
+	content, ok := contents[side.hash]
+	if !ok {
+		return nil, fmt.Errorf("read %s of %q: %w", side.hash.String(), side.path, NewObjectNotFoundError(side.hash))
+	}
+	return content, nil
+}
+
+// formatPatch returns the change from old to head in the format of
+// `git diff`.
+func formatPatch(old, head patchSide) string {
+	aPath, bPath := old.path, head.path
+	if !old.exists {
+		aPath = head.path
+	}
+	if !head.exists {
+		bPath = old.path
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", aPath, bPath)
+	switch {
+	case !old.exists:
+		fmt.Fprintf(&b, "new file mode %o\n", head.mode)
+	case !head.exists:
+		fmt.Fprintf(&b, "deleted file mode %o\n", old.mode)
+	default:
+		if old.mode != head.mode {
+			fmt.Fprintf(&b, "old mode %o\nnew mode %o\n", old.mode, head.mode)
+		}
+		if aPath != bPath {
+			if old.hash == head.hash {
+				b.WriteString("similarity index 100%\n")
+			}
+			fmt.Fprintf(&b, "rename from %s\nrename to %s\n", aPath, bPath)
+		}
+	}
+	if old.exists && head.exists && old.hash == head.hash {
+		return b.String()
+	}
+
+	oldHash, headHash := hash.Zero, hash.Zero
+	if old.exists {
+		oldHash = old.hash
+	}
+	if head.exists {
+		headHash = head.hash
+	}
+	fmt.Fprintf(&b, "index %s..%s", oldHash.String()[:7], headHash.String()[:7])
+	if old.exists && head.exists && old.mode == head.mode {
+		fmt.Fprintf(&b, " %o", head.mode)
+	}
+	b.WriteString("\n")
+
+	aName, bName := "a/"+aPath, "b/"+bPath
+	if !old.exists {
+		aName = "/dev/null"
+	}
+	if !head.exists {
+		bName = "/dev/null"
+	}
+	if isBinary(old.content) || isBinary(head.content) {
+		fmt.Fprintf(&b, "Binary files %s and %s differ\n", aName, bName)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", aName, bName)
+	writeHunks(&b, splitLinesWithEnds(old.content), splitLinesWithEnds(head.content), patchContextLines)
+	return b.String()
+}
+
+// writeHunks writes the unified diff hunks turning lines a into lines b,
+// with context unchanged lines around each change. Lines keep their line
+// endings; a last line without one is followed by git's "\ No newline at
+// end of file" marker.
+func writeHunks(b *strings.Builder, a, bLines []string, context int) {
+	edits := diffLines(a, bLines)
+
+	// oldLine and newLine count the lines of each side before edits[pos],
+	// to number the hunks.
+	pos, oldLine, newLine := 0, 0, 0
+	advance := func(to int) {
+		for ; pos < to; pos++ {
+			if edits[pos].Op != lineInsert {
+				oldLine++
+			}
+			if edits[pos].Op != lineDelete {
+				newLine++
+			}
+		}
+	}
+
+	for start := 0; start < len(edits); {
+		// Find the next change and the end of the hunk it starts: changes
+		// separated by at most twice the context share a hunk.
+		first := start
+		for first < len(edits) && edits[first].Op == lineEqual {
+			first++
+		}
+		if first == len(edits) {
+			return
+		}
+		last := first
+		for i := first + 1; i < len(edits) && i-last-1 <= 2*context; i++ {
+			if edits[i].Op != lineEqual {
+				last = i
+			}
+		}
+		from := max(first-context, start)
+		to := min(last+context+1, len(edits))
+
+		advance(from)
+		oldStart, newStart := oldLine, newLine
+		advance(to)
+		oldCount, newCount := oldLine-oldStart, newLine-newStart
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+
+		for _, edit := range edits[from:to] {
+			switch edit.Op {
+			case lineEqual:
+				writeHunkLine(b, ' ', a[edit.A])
+			case lineDelete:
+				writeHunkLine(b, '-', a[edit.A])
+			case lineInsert:
+				writeHunkLine(b, '+', bLines[edit.B])
+			}
+		}
+		start = to
+	}
+}
+
+// hunkRange formats the start and length of a hunk side. An empty side is
+// numbered after the line it follows, and a length of one is left out.
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, count)
+	}
+}
+
+// writeHunkLine writes a line of a hunk with its prefix.
+func writeHunkLine(b *strings.Builder, prefix byte, line string) {
+	b.WriteByte(prefix)
+	b.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		b.WriteString("\n\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,166 @@
+package nanogit
+
+import (
+	"context"
+	"crypto"
+	"strings"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatPatch(t *testing.T) {
+	t.Parallel()
+
+	oldHash := hash.MustFromHex("1111111111111111111111111111111111111111")
+	newHash := hash.MustFromHex("2222222222222222222222222222222222222222")
+
+	for _, tt := range []struct {
+		name      string
+		old, head patchSide
+		want      string
+	}{
+		{
+			name: "modified file",
+			old:  patchSide{exists: true, path: "a.txt", mode: 0o100644, hash: oldHash, content: []byte("one\ntwo\nthree\n")},
+			head: patchSide{exists: true, path: "a.txt", mode: 0o100644, hash: newHash, content: []byte("one\n2\nthree\n")},
+			want: "diff --git a/a.txt b/a.txt\n" +
+				"index 1111111..2222222 100644\n" +
+				"--- a/a.txt\n" +
+				"+++ b/a.txt\n" +
+				"@@ -1,3 +1,3 @@\n" +
+				" one\n" +
+				"-two\n" +
+				"+2\n" +
+				" three\n",
+		},
+		{
+			name: "new file without trailing newline",
+			head: patchSide{exists: true, path: "new.txt", mode: 0o100755, hash: newHash, content: []byte("run")},
+			want: "diff --git a/new.txt b/new.txt\n" +
+				"new file mode 100755\n" +
+				"index 0000000..2222222\n" +
+				"--- /dev/null\n" +
+				"+++ b/new.txt\n" +
+				"@@ -0,0 +1 @@\n" +
+				"+run\n" +
+				"\\ No newline at end of file\n",
+		},
+		{
+			name: "deleted file",
+			old:  patchSide{exists: true, path: "gone.txt", mode: 0o100644, hash: oldHash, content: []byte("a\nb\n")},
+			want: "diff --git a/gone.txt b/gone.txt\n" +
+				"deleted file mode 100644\n" +
+				"index 1111111..0000000\n" +
+				"--- a/gone.txt\n" +
+				"+++ /dev/null\n" +
+				"@@ -1,2 +0,0 @@\n" +
+				"-a\n" +
+				"-b\n",
+		},
+		{
+			name: "pure rename",
+			old:  patchSide{exists: true, path: "old.txt", mode: 0o100644, hash: oldHash},
+			head: patchSide{exists: true, path: "new.txt", mode: 0o100644, hash: oldHash},
+			want: "diff --git a/old.txt b/new.txt\n" +
+				"similarity index 100%\n" +
+				"rename from old.txt\n" +
+				"rename to new.txt\n",
+		},
+		{
+			name: "mode change only",
+			old:  patchSide{exists: true, path: "run.sh", mode: 0o100644, hash: oldHash},
+			head: patchSide{exists: true, path: "run.sh", mode: 0o100755, hash: oldHash},
+			want: "diff --git a/run.sh b/run.sh\n" +
+				"old mode 100644\n" +
+				"new mode 100755\n",
+		},
+		{
+			name: "binary",
+			old:  patchSide{exists: true, path: "logo.png", mode: 0o100644, hash: oldHash, content: []byte("\x89PNG\x00\x01")},
+			head: patchSide{exists: true, path: "logo.png", mode: 0o100644, hash: newHash, content: []byte("\x89PNG\x00\x02")},
+			want: "diff --git a/logo.png b/logo.png\n" +
+				"index 1111111..2222222 100644\n" +
+				"Binary files a/logo.png and b/logo.png differ\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, formatPatch(tt.old, tt.head))
+		})
+	}
+}
+
+func TestWriteHunks(t *testing.T) {
+	t.Parallel()
+
+	lines := func(n int, change map[int]string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = string(rune('a'+i%26)) + "\n"
+			if line, ok := change[i]; ok {
+				out[i] = line + "\n"
+			}
+		}
+		return out
+	}
+
+	t.Run("distant changes get their own hunks", func(t *testing.T) {
+		t.Parallel()
+		var b strings.Builder
+		writeHunks(&b, lines(20, nil), lines(20, map[int]string{1: "B", 17: "R"}), 3)
+		require.Equal(t, "@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n"+
+			"@@ -15,6 +15,6 @@\n o\n p\n q\n-r\n+R\n s\n t\n", b.String())
+	})
+
+	t.Run("close changes share a hunk", func(t *testing.T) {
+		t.Parallel()
+		var b strings.Builder
+		writeHunks(&b, lines(12, nil), lines(12, map[int]string{2: "C", 8: "I"}), 3)
+		require.Equal(t, "@@ -1,12 +1,12 @@\n a\n b\n-c\n+C\n d\n e\n f\n g\n h\n-i\n+I\n j\n k\n l\n", b.String())
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		t.Parallel()
+		var b strings.Builder
+		writeHunks(&b, lines(5, nil), lines(5, nil), 3)
+		require.Empty(t, b.String())
+	})
+}
+
+func TestAddPatches(t *testing.T) {
+	t.Parallel()
+
+	contents := map[string]string{}
+	add := func(content string) hash.Hash {
+		h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, []byte(content))
+		require.NoError(t, err)
+		contents[h.String()] = content
+		return h
+	}
+	var reads [][]hash.Hash
+	read := func(_ context.Context, hashes []hash.Hash) (map[hash.Hash][]byte, error) {
+		reads = append(reads, hashes)
+		read := make(map[hash.Hash][]byte, len(hashes))
+		for _, h := range hashes {
+			read[h] = []byte(contents[h.String()])
+		}
+		return read, nil
+	}
+	oldHash, newHash := add("v1\n"), add("v2\n")
+	sub := hash.MustFromHex("3333333333333333333333333333333333333333")
+
+	changes := []CommitFile{
+		{Path: "dir", Status: protocol.FileStatusAdded, Type: protocol.ObjectTypeTree, Mode: 0o40000},
+		{Path: "file.txt", Status: protocol.FileStatusModified, Type: protocol.ObjectTypeBlob, Mode: 0o100644, Hash: newHash, OldType: protocol.ObjectTypeBlob, OldMode: 0o100644, OldHash: oldHash},
+		{Path: "lib", Status: protocol.FileStatusDeleted, Type: protocol.ObjectTypeCommit, Mode: 0o160000, Hash: sub, OldType: protocol.ObjectTypeCommit, OldHash: sub},
+	}
+	require.NoError(t, addPatches(context.Background(), changes, read))
+	require.Empty(t, changes[0].Patch)
+	require.Contains(t, changes[1].Patch, "@@ -1 +1 @@\n-v1\n+v2\n")
+	require.Contains(t, changes[2].Patch, "deleted file mode 160000\n")
+	require.Contains(t, changes[2].Patch, "-Subproject commit "+sub.String()+"\n")
+	require.Equal(t, [][]hash.Hash{{oldHash, newHash}}, reads, "contents are read in one call")
+}
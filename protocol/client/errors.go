@@ -23,6 +23,12 @@ var ErrPermissionDenied = errors.New("permission denied")
 // This error should only be used with errors.Is() for comparison, not for type assertions.
 var ErrRepositoryNotFound = errors.New("repository not found")
 
+// ErrObjectInfoUnsupported is returned by ObjectInfo when the server does not
+// answer the object-info command, which servers only support when configured
+// to (transfer.advertiseObjectInfo in Git).
+// This error should only be used with errors.Is() for comparison, not for type assertions.
+var ErrObjectInfoUnsupported = errors.New("object-info not supported")
+
 // ServerUnavailableError provides structured information about a Git server that is unavailable.
 type ServerUnavailableError struct {
 	// StatusCode is the HTTP status code (5xx)
@@ -21,6 +21,10 @@ type FetchOptions struct {
 	NoCache      bool
 	NoProgress   bool
 	NoBlobFilter bool
+	// NoTreeFilter sends the tree:0 filter: the server only returns the
+	// wanted objects themselves, none of the trees and blobs they reference.
+	// It takes precedence over NoBlobFilter.
+	NoTreeFilter bool
 	Want         []hash.Hash
 	Done         bool // not sure why we need this one
 	Deepen       int
@@ -130,7 +134,10 @@ func (c *rawClient) buildBasicPacks(opts FetchOptions) []protocol.Pack {
 		packs = append(packs, protocol.PackLine("no-progress\n"))
 	}
 
-	if opts.NoBlobFilter {
+	switch {
+	case opts.NoTreeFilter:
+		packs = append(packs, protocol.PackLine("filter tree:0\n"))
+	case opts.NoBlobFilter:
 		packs = append(packs, protocol.PackLine("filter blob:none\n"))
 	}
 
@@ -174,6 +181,7 @@ func (c *rawClient) logFetchRequest(logger log.Logger, pkt []byte, opts FetchOpt
 		"options", map[string]interface{}{
 			"noProgress":     opts.NoProgress,
 			"noBlobFilter":   opts.NoBlobFilter,
+			"noTreeFilter":   opts.NoTreeFilter,
 			"deepen":         opts.Deepen,
 			"shallow":        opts.Shallow,
 			"done":           opts.Done,
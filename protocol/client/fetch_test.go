@@ -205,3 +205,31 @@ func TestFetch_CorruptPackfile(t *testing.T) {
 	require.ErrorContains(t, err, "reading packfile object 1")
 	require.ErrorContains(t, err, "zlib: invalid header")
 }
+
+func TestBuildFetchRequest_Filters(t *testing.T) {
+	t.Parallel()
+
+	c := &rawClient{}
+	for _, tt := range []struct {
+		name string
+		opts FetchOptions
+		want string
+	}{
+		{name: "no filter", opts: FetchOptions{}, want: ""},
+		{name: "blob filter", opts: FetchOptions{NoBlobFilter: true}, want: "filter blob:none\n"},
+		{name: "tree filter", opts: FetchOptions{NoTreeFilter: true}, want: "filter tree:0\n"},
+		{name: "tree filter wins", opts: FetchOptions{NoBlobFilter: true, NoTreeFilter: true}, want: "filter tree:0\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pkt, err := c.buildFetchRequest(tt.opts)
+			require.NoError(t, err)
+			if tt.want == "" {
+				require.NotContains(t, string(pkt), "filter ")
+				return
+			}
+			require.Contains(t, string(pkt), tt.want)
+			require.Equal(t, 1, bytes.Count(pkt, []byte("filter ")))
+		})
+	}
+}
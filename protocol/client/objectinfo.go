@@ -0,0 +1,54 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+func (c *rawClient) ObjectInfo(ctx context.Context, hashes []hash.Hash) (sizes map[hash.Hash]int64, err error) {
+	logger := log.FromContext(ctx)
+	logger.Debug("Object-info", "object_count", len(hashes))
+
+	packs := make([]protocol.Pack, 0, len(hashes)+5)
+	packs = append(packs,
+		protocol.PackLine("command=object-info\n"),
+		protocol.PackLine("object-format=sha1\n"),
+		protocol.DelimeterPacket,
+		protocol.PackLine("size\n"),
+	)
+	for _, h := range hashes {
+		packs = append(packs, protocol.PackLine(fmt.Sprintf("oid %s\n", h.String())))
+	}
+	packs = append(packs, protocol.FlushPacket)
+	pkt, err := protocol.FormatPacks(packs...)
+	if err != nil {
+		return nil, fmt.Errorf("format object-info command: %w", err)
+	}
+
+	reader, err := c.UploadPack(ctx, bytes.NewReader(pkt))
+	if err != nil {
+		return nil, fmt.Errorf("send object-info command: %w", err)
+	}
+	reader = newLimitedReadCloser(reader, c.limits.RefsMetadataMaxBytes, "object-info")
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing object-info reader: %w", closeErr)
+		}
+	}()
+
+	sizes, err = protocol.ParseObjectInfoResponse(ctx, reader)
+	if err != nil {
+		return nil, fmt.Errorf("parse object-info response: %w", err)
+	}
+	if sizes == nil {
+		return nil, ErrObjectInfoUnsupported
+	}
+
+	logger.Debug("Object-info completed", "object_count", len(sizes))
+	return sizes, nil
+}
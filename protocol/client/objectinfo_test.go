@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectInfo(t *testing.T) {
+	t.Parallel()
+
+	known := hash.MustFromHex("1111111111111111111111111111111111111111")
+	unknown := hash.MustFromHex("2222222222222222222222222222222222222222")
+
+	for _, tt := range []struct {
+		name    string
+		packs   []protocol.Pack
+		want    map[hash.Hash]int64
+		wantErr error
+	}{
+		{
+			name: "sizes",
+			packs: []protocol.Pack{
+				protocol.PackLine("size\n"),
+				protocol.PackLine(known.String() + " 1234\n"),
+				protocol.PackLine(unknown.String() + " \n"),
+				protocol.FlushPacket,
+			},
+			want: map[hash.Hash]int64{known: 1234},
+		},
+		{
+			name:    "unsupported",
+			wantErr: ErrObjectInfoUnsupported,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var body []byte
+			if len(tt.packs) > 0 {
+				var err error
+				body, err = protocol.FormatPacks(tt.packs...)
+				require.NoError(t, err)
+			}
+
+			var request string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				request = string(data)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(body)
+			}))
+			defer server.Close()
+
+			client, err := NewRawClient(server.URL + "/repo")
+			require.NoError(t, err)
+
+			sizes, err := client.ObjectInfo(context.Background(), []hash.Hash{known, unknown})
+			require.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, sizes)
+			assert.Contains(t, request, "command=object-info\n")
+			assert.Contains(t, request, "oid "+unknown.String()+"\n")
+		})
+	}
+}
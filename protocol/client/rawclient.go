@@ -18,6 +18,7 @@ import (
 
 	"github.com/grafana/nanogit/options"
 	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
 	"github.com/grafana/nanogit/retry"
 )
 
@@ -57,6 +58,11 @@ type RawClient interface {
 	// LsRefs lists the server's refs via the ls-refs command, optionally
 	// filtered by opts.Prefix.
 	LsRefs(ctx context.Context, opts LsRefsOptions) ([]protocol.RefLine, error)
+	// ObjectInfo returns the sizes of the objects with the given hashes via
+	// the object-info command, without fetching them. Objects the server
+	// does not know are left out. It returns ErrObjectInfoUnsupported when
+	// the server does not support the command.
+	ObjectInfo(ctx context.Context, hashes []hash.Hash) (map[hash.Hash]int64, error)
 }
 
 type rawClient struct {
@@ -69,6 +69,12 @@ type DeltaChange struct {
 	SourceOffset uint64
 }
 
+// ParseDelta parses a delta in git's delta format, such as the data of a
+// "delta" block of a git binary patch, for ApplyDelta.
+func ParseDelta(payload []byte) (*Delta, error) {
+	return parseDelta("", payload)
+}
+
 // parseDelta parses a delta payload into a Delta struct.
 //
 // The delta format consists of:
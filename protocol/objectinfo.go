@@ -0,0 +1,64 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// ParseObjectInfoResponse parses the response to an object-info command
+// asking for sizes: a "size" line, then an "<oid> <size>" line per object.
+// Objects the server does not know have an empty size and are left out.
+// An empty response, which servers that do not support the command send,
+// yields a nil map.
+func ParseObjectInfoResponse(ctx context.Context, reader io.Reader) (map[hash.Hash]int64, error) {
+	logger := log.FromContext(ctx)
+	parser := NewParser(reader)
+
+	line, err := parser.Next()
+	if errors.Is(err, io.EOF) {
+		logger.Debug("Empty object-info response")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read object-info attributes: %w", err)
+	}
+	if string(bytes.TrimSuffix(line, []byte("\n"))) != "size" {
+		return nil, NewPackParseError(line, errors.New("expected the size attribute"))
+	}
+
+	sizes := make(map[hash.Hash]int64)
+	for {
+		line, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			logger.Debug("Parsed object-info response", "object_count", len(sizes))
+			return sizes, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read object-info line: %w", err)
+		}
+
+		oid, size, ok := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+		if !ok {
+			return nil, NewPackParseError(line, errors.New("missing object size"))
+		}
+		h, err := hash.FromHex(string(oid))
+		if err != nil {
+			return nil, NewPackParseError(line, fmt.Errorf("parse object id: %w", err))
+		}
+		if len(size) == 0 {
+			continue
+		}
+		n, err := strconv.ParseInt(string(size), 10, 64)
+		if err != nil || n < 0 {
+			return nil, NewPackParseError(line, fmt.Errorf("invalid object size %q", size))
+		}
+		sizes[h] = n
+	}
+}
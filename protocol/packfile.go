@@ -11,6 +11,7 @@ import (
 	"fmt"
 	stdhash "hash"
 	"io"
+	"math"
 	"os"
 	"slices"
 	"sort"
@@ -357,11 +358,19 @@ func (e *PackfileObject) parseAuthor(data string) error {
 	return nil
 }
 
-// parseParent parses the parent field
+// parseParent parses a parent field. Merge commits carry one parent line
+// per parent; all of them are kept in Parents, in order, and Parent is the
+// first one.
 func (e *PackfileObject) parseParent(data string) error {
-	var err error
-	e.Commit.Parent, err = hash.FromHex(data)
-	return err
+	parent, err := hash.FromHex(data)
+	if err != nil {
+		return err
+	}
+	if len(e.Commit.Parents) == 0 {
+		e.Commit.Parent = parent
+	}
+	e.Commit.Parents = append(e.Commit.Parents, parent)
+	return nil
 }
 
 // parseCustomField stores custom fields in the Fields map
@@ -410,8 +419,13 @@ type PackfileCommit struct {
 	Tree      hash.Hash
 	Author    *Identity
 	Committer *Identity
-	Parent    hash.Hash
-	Message   string
+	// Parent is the first parent of the commit, or hash.Zero for a root commit.
+	Parent hash.Hash
+	// Parents lists every parent in order. It is populated when parsing and,
+	// when non-empty, takes precedence over Parent when building, which is how
+	// merge commits with more than one parent are written.
+	Parents []hash.Hash
+	Message string
 	// Signature, when non-empty, is an armored block embedded as the gpgsig header.
 	Signature string
 	// Fields contains any fields beyond the fields that are statically defined.
@@ -434,8 +448,8 @@ func (c *PackfileCommit) BuildUnsigned() []byte {
 func (c *PackfileCommit) build(includeSig bool) []byte {
 	var data bytes.Buffer
 	fmt.Fprintf(&data, "tree %s\n", c.Tree.String())
-	if !c.Parent.Is(hash.Zero) {
-		fmt.Fprintf(&data, "parent %s\n", c.Parent.String())
+	for _, parent := range c.parents() {
+		fmt.Fprintf(&data, "parent %s\n", parent.String())
 	}
 	fmt.Fprintf(&data, "author %s\n", c.Author.String())
 	fmt.Fprintf(&data, "committer %s\n", c.Committer.String())
@@ -450,6 +464,18 @@ func (c *PackfileCommit) build(includeSig bool) []byte {
 	return data.Bytes()
 }
 
+// parents returns the parent hashes to write, preferring Parents over the
+// single Parent field.
+func (c *PackfileCommit) parents() []hash.Hash {
+	if len(c.Parents) > 0 {
+		return c.Parents
+	}
+	if c.Parent.Is(hash.Zero) {
+		return nil
+	}
+	return []hash.Hash{c.Parent}
+}
+
 type PackfileTrailer struct {
 	// TODO: Checksum here. Are there multiple??
 }
@@ -839,6 +865,13 @@ type PackfileWriter struct {
 	memoryObjects []PackfileObject
 	// Disk storage: temporary file for streaming packfile data
 	tempFile *os.File
+	// Where each object is stored: its index in memoryObjects, or its
+	// offset in tempFile once objects are written to disk
+	objectPositions map[string]int64
+	// Objects in the order they were added, for Rollback and Prune
+	objectOrder []orderedObject
+	// Sequence number of the next object added
+	nextSeq int
 	// Track if we have any commit (required for push)
 	hasCommit bool
 	// Track the last commit hash for reference updates
@@ -874,11 +907,12 @@ func NewPackfileWriter(algo crypto.Hash, storageMode PackfileStorageMode, caps .
 		capsCopy = append([]Capability(nil), caps...)
 	}
 	return &PackfileWriter{
-		objectHashes:  make(map[string]bool),
-		memoryObjects: make([]PackfileObject, 0),
-		storageMode:   storageMode,
-		algo:          algo,
-		capabilities:  capsCopy,
+		objectHashes:    make(map[string]bool),
+		memoryObjects:   make([]PackfileObject, 0),
+		objectPositions: make(map[string]int64),
+		storageMode:     storageMode,
+		algo:            algo,
+		capabilities:    capsCopy,
 	}
 }
 
@@ -916,6 +950,9 @@ func (w *PackfileWriter) Cleanup() error {
 	// Clear all memory state
 	w.objectHashes = make(map[string]bool)
 	w.memoryObjects = nil
+	w.objectPositions = make(map[string]int64)
+	w.objectOrder = nil
+	w.nextSeq = 0
 	w.hasCommit = false
 	w.lastCommitHash = hash.Hash{}
 	w.totalBytes = 0
@@ -957,9 +994,403 @@ func (w *PackfileWriter) AddBlob(data []byte) (hash.Hash, error) {
 	}
 
 	w.objectHashes[h.String()] = true
+	w.track(h)
+	return h, nil
+}
+
+// AddBlobFromReader adds a blob of size bytes read from r to the packfile.
+// With disk storage, and with auto storage which switches to disk for it,
+// the content is hashed and compressed into the temporary file in a single
+// pass, so the blob is never held in memory. With memory storage it is read
+// into memory. Reading fewer or more than size bytes from r is an error.
+func (w *PackfileWriter) AddBlobFromReader(r io.Reader, size int64) (hash.Hash, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return hash.Hash{}, err
+	}
+
+	if size < 0 {
+		return hash.Hash{}, fmt.Errorf("invalid blob size: %d", size)
+	}
+
+	switch w.storageMode {
+	case PackfileStorageMemory:
+		data, err := readExactly(r, size)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		return w.AddBlob(data)
+	case PackfileStorageAuto:
+		if w.tempFile == nil {
+			if err := w.migrateToFile(); err != nil {
+				return hash.Hash{}, fmt.Errorf("migrating to file storage: %w", err)
+			}
+		}
+	case PackfileStorageDisk:
+		if err := w.ensureTempFile(); err != nil {
+			return hash.Hash{}, fmt.Errorf("creating temp file: %w", err)
+		}
+	default:
+		return hash.Hash{}, fmt.Errorf("unknown storage mode: %v", w.storageMode)
+	}
+
+	// The hash is only known once the object is written, so remember where
+	// it starts to drop it again if it is a duplicate or fails.
+	start, err := w.tempFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return hash.Hash{}, fmt.Errorf("seeking temp file: %w", err)
+	}
+
+	h, err := w.streamBlobToFile(r, size)
+	if err == nil && !w.objectHashes[h.String()] {
+		w.objectHashes[h.String()] = true
+		w.track(h)
+		w.objectPositions[h.String()] = start
+		w.totalBytes += int(size)
+		return h, nil
+	}
+
+	if truncErr := w.tempFile.Truncate(start); truncErr != nil && err == nil {
+		err = fmt.Errorf("truncating temp file: %w", truncErr)
+	}
+	if _, seekErr := w.tempFile.Seek(start, io.SeekStart); seekErr != nil && err == nil {
+		err = fmt.Errorf("seeking temp file: %w", seekErr)
+	}
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return h, nil
+}
+
+// streamBlobToFile writes a blob object of size bytes read from r to the
+// temporary file, hashing its content on the way.
+func (w *PackfileWriter) streamBlobToFile(r io.Reader, size int64) (hash.Hash, error) {
+	hasher, err := NewHasher(w.algo, ObjectTypeBlob, size)
+	if err != nil {
+		return hash.Hash{}, fmt.Errorf("computing blob hash: %w", err)
+	}
+
+	if err := writePackObjectHeader(w.tempFile, ObjectTypeBlob, size); err != nil {
+		return hash.Hash{}, err
+	}
+
+	zw := getPooledZlibWriter(w.tempFile)
+	defer returnPooledZlibWriter(zw)
+
+	n, err := io.CopyN(io.MultiWriter(zw, hasher), r, size)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return hash.Hash{}, fmt.Errorf("reading blob content: %w", err)
+	}
+	if n < size {
+		return hash.Hash{}, fmt.Errorf("blob content is %d bytes, want %d", n, size)
+	}
+	if extra, _ := r.Read(make([]byte, 1)); extra > 0 {
+		return hash.Hash{}, fmt.Errorf("blob content is longer than %d bytes", size)
+	}
+	if err := zw.Close(); err != nil {
+		return hash.Hash{}, fmt.Errorf("closing zlib writer: %w", err)
+	}
+
+	var h hash.Hash
+	copy(h[:], hasher.Sum(nil))
 	return h, nil
 }
 
+// readExactly reads size bytes from r, failing if it holds fewer or more.
+func readExactly(r io.Reader, size int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, size+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading blob content: %w", err)
+	}
+	if int64(len(data)) > size {
+		return nil, fmt.Errorf("blob content is longer than %d bytes", size)
+	}
+	if int64(len(data)) < size {
+		return nil, fmt.Errorf("blob content is %d bytes, want %d", len(data), size)
+	}
+	return data, nil
+}
+
+// GetObject returns an object added to the packfile, reading it back from
+// the temporary file with disk storage. It returns false when no object
+// with the hash was added.
+func (w *PackfileWriter) GetObject(h hash.Hash) (*PackfileObject, bool, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return nil, false, err
+	}
+
+	position, ok := w.objectPositions[h.String()]
+	if !ok {
+		return nil, false, nil
+	}
+	if w.tempFile == nil {
+		obj := w.memoryObjects[position]
+		return &obj, true, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(w.tempFile, position, math.MaxInt64-position))
+	objType, size, err := readPackObjectHeader(reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading object %s: %w", h.String(), err)
+	}
+	zr, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading object %s: %w", h.String(), err)
+	}
+	defer func() { _ = zr.Close() }()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, false, fmt.Errorf("reading object %s: %w", h.String(), err)
+	}
+
+	obj := &PackfileObject{Type: objType, Data: data, Hash: h}
+	switch objType {
+	case ObjectTypeTree:
+		err = obj.parseTree()
+	case ObjectTypeCommit:
+		err = obj.parseCommit()
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("parsing object %s: %w", h.String(), err)
+	}
+	return obj, true, nil
+}
+
+// readPackObjectHeader reads the variable length type and size header
+// written by writePackObjectHeader.
+func readPackObjectHeader(reader io.ByteReader) (ObjectType, int64, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	objType := ObjectType((b >> 4) & 0x07)
+	size := int64(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, 0, err
+		}
+		size |= int64(b&0x7f) << shift
+	}
+	return objType, size, nil
+}
+
+// orderedObject is an object of a PackfileWriter with the sequence number
+// it was added with. Sequence numbers only grow, so they still order the
+// objects after others were dropped.
+type orderedObject struct {
+	hash hash.Hash
+	seq  int
+}
+
+// track records an object as added after all others.
+func (w *PackfileWriter) track(h hash.Hash) {
+	w.objectOrder = append(w.objectOrder, orderedObject{hash: h, seq: w.nextSeq})
+	w.nextSeq++
+}
+
+// PackfileCheckpoint marks the objects added to a PackfileWriter up to a
+// point, so that Rollback can drop the objects added after it. The zero
+// value marks an empty writer.
+type PackfileCheckpoint struct {
+	seq            int
+	totalBytes     int
+	hasCommit      bool
+	lastCommitHash hash.Hash
+}
+
+// Checkpoint marks the objects added so far.
+func (w *PackfileWriter) Checkpoint() PackfileCheckpoint {
+	return PackfileCheckpoint{
+		seq:            w.nextSeq,
+		totalBytes:     w.totalBytes,
+		hasCommit:      w.hasCommit,
+		lastCommitHash: w.lastCommitHash,
+	}
+}
+
+// Rollback drops the objects added after the checkpoint was taken, and
+// truncates the temporary file with disk storage. The last commit is the
+// one of the checkpoint again. Checkpoints taken after this one must not
+// be rolled back to anymore.
+func (w *PackfileWriter) Rollback(checkpoint PackfileCheckpoint) error {
+	if err := w.checkCleanupState(); err != nil {
+		return err
+	}
+	if checkpoint.seq > w.nextSeq {
+		return fmt.Errorf("checkpoint at object %d is ahead of the %d objects added", checkpoint.seq, w.nextSeq)
+	}
+
+	keep := sort.Search(len(w.objectOrder), func(i int) bool {
+		return w.objectOrder[i].seq >= checkpoint.seq
+	})
+	dropped := w.objectOrder[keep:]
+	if len(dropped) > 0 {
+		if w.tempFile != nil {
+			// Objects are written in order, so the first dropped one starts
+			// where the kept ones end.
+			offset := w.objectPositions[dropped[0].hash.String()]
+			if err := w.tempFile.Truncate(offset); err != nil {
+				return fmt.Errorf("truncating temp file: %w", err)
+			}
+			if _, err := w.tempFile.Seek(offset, io.SeekStart); err != nil {
+				return fmt.Errorf("seeking temp file: %w", err)
+			}
+		} else {
+			clear(w.memoryObjects[keep:])
+			w.memoryObjects = w.memoryObjects[:keep]
+		}
+	}
+	for _, obj := range dropped {
+		delete(w.objectHashes, obj.hash.String())
+		delete(w.objectPositions, obj.hash.String())
+	}
+
+	w.objectOrder = w.objectOrder[:keep]
+	w.totalBytes = min(w.totalBytes, checkpoint.totalBytes)
+	w.hasCommit = checkpoint.hasCommit
+	w.lastCommitHash = checkpoint.lastCommitHash
+	return nil
+}
+
+// Pruned returns a writer holding only the objects reachable from roots
+// through the objects of the packfile: the tree and parents of a commit,
+// and the entries of a tree. Submodule commits are not followed. Only
+// commits and trees are read back. It also returns the number of objects
+// left out. w is left unchanged; when nothing is left out, Pruned returns
+// w itself, and otherwise a new writer the caller must clean up.
+func (w *PackfileWriter) Pruned(roots ...hash.Hash) (*PackfileWriter, int, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return nil, 0, err
+	}
+
+	reachable := make(map[string]bool, len(w.objectOrder))
+	queue := slices.Clone(roots)
+	for len(queue) > 0 {
+		h := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if reachable[h.String()] || !w.objectHashes[h.String()] {
+			continue
+		}
+		reachable[h.String()] = true
+
+		objType, err := w.objectType(h)
+		if err != nil {
+			return nil, 0, err
+		}
+		if objType != ObjectTypeCommit && objType != ObjectTypeTree {
+			continue
+		}
+		obj, _, err := w.GetObject(h)
+		if err != nil {
+			return nil, 0, err
+		}
+		if objType == ObjectTypeCommit {
+			// Parents is only set for merge commits added to this writer.
+			queue = append(queue, obj.Commit.Tree, obj.Commit.Parent)
+			queue = append(queue, obj.Commit.Parents...)
+			continue
+		}
+		for _, entry := range obj.Tree {
+			if entry.FileMode == 0o160000 {
+				continue
+			}
+			child, err := hash.FromHex(entry.Hash)
+			if err != nil {
+				return nil, 0, fmt.Errorf("parsing entry %q of tree %s: %w", entry.FileName, h.String(), err)
+			}
+			queue = append(queue, child)
+		}
+	}
+
+	kept := make([]orderedObject, 0, len(reachable))
+	for _, obj := range w.objectOrder {
+		if reachable[obj.hash.String()] {
+			kept = append(kept, obj)
+		}
+	}
+	pruned := len(w.objectOrder) - len(kept)
+	if pruned == 0 {
+		return w, 0, nil
+	}
+
+	p := NewPackfileWriter(w.algo, w.storageMode, w.capabilities...)
+	p.objectOrder = kept
+	p.nextSeq = w.nextSeq
+	p.totalBytes = w.totalBytes
+	if w.hasCommit && reachable[w.lastCommitHash.String()] {
+		p.hasCommit = true
+		p.lastCommitHash = w.lastCommitHash
+	}
+	for _, obj := range kept {
+		p.objectHashes[obj.hash.String()] = true
+	}
+	if w.tempFile != nil {
+		file, positions, err := w.copyTempFile(kept)
+		if err != nil {
+			return nil, 0, err
+		}
+		p.tempFile = file
+		p.objectPositions = positions
+	} else {
+		p.memoryObjects = make([]PackfileObject, 0, len(kept))
+		for i, obj := range kept {
+			p.memoryObjects = append(p.memoryObjects, w.memoryObjects[w.objectPositions[obj.hash.String()]])
+			p.objectPositions[obj.hash.String()] = int64(i)
+		}
+	}
+	return p, pruned, nil
+}
+
+// objectType returns the type of an object of the packfile, reading only
+// its header with disk storage.
+func (w *PackfileWriter) objectType(h hash.Hash) (ObjectType, error) {
+	position := w.objectPositions[h.String()]
+	if w.tempFile == nil {
+		return w.memoryObjects[position].Type, nil
+	}
+	reader := bufio.NewReader(io.NewSectionReader(w.tempFile, position, math.MaxInt64-position))
+	objType, _, err := readPackObjectHeader(reader)
+	if err != nil {
+		return 0, fmt.Errorf("reading object %s: %w", h.String(), err)
+	}
+	return objType, nil
+}
+
+// copyTempFile returns a new temporary file holding only the kept objects,
+// copied in order, and the offsets of the objects in it.
+func (w *PackfileWriter) copyTempFile(kept []orderedObject) (*os.File, map[string]int64, error) {
+	end, err := w.tempFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("seeking temp file: %w", err)
+	}
+	// Each object runs up to the next one in the file.
+	next := make(map[string]int64, len(w.objectOrder))
+	for i, obj := range w.objectOrder {
+		next[obj.hash.String()] = end
+		if i+1 < len(w.objectOrder) {
+			next[obj.hash.String()] = w.objectPositions[w.objectOrder[i+1].hash.String()]
+		}
+	}
+
+	file, err := os.CreateTemp("", "nanogit-packfile-*.tmp")
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+	positions := make(map[string]int64, len(kept))
+	var offset int64
+	for _, obj := range kept {
+		start := w.objectPositions[obj.hash.String()]
+		n, err := io.Copy(file, io.NewSectionReader(w.tempFile, start, next[obj.hash.String()]-start))
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+			return nil, nil, fmt.Errorf("copying object %s: %w", obj.hash.String(), err)
+		}
+		positions[obj.hash.String()] = offset
+		offset += n
+	}
+	return file, positions, nil
+}
+
 // BuildTreeObject builds a tree object from a list of entries.
 // The tree represents a directory structure with file modes and hashes.
 func BuildTreeObject(algo crypto.Hash, entries []PackfileTreeEntry) (PackfileObject, error) {
@@ -1033,6 +1464,7 @@ func (w *PackfileWriter) AddObject(obj PackfileObject) {
 	}
 
 	w.objectHashes[obj.Hash.String()] = true
+	w.track(obj.Hash)
 }
 
 // HasObjects returns true if the writer has any objects staged for writing.
@@ -1045,17 +1477,32 @@ func (w *PackfileWriter) HasObjects() bool {
 
 // AddCommit adds a commit object to the packfile.
 func (w *PackfileWriter) AddCommit(tree, parent hash.Hash, author, committer *Identity, message string, signer signing.Signer) (hash.Hash, error) {
+	var parents []hash.Hash
+	if !parent.Is(hash.Zero) {
+		parents = []hash.Hash{parent}
+	}
+	return w.AddCommitWithParents(tree, parents, author, committer, message, signer)
+}
+
+// AddCommitWithParents adds a commit object with any number of parents to
+// the packfile, such as a merge commit with two.
+func (w *PackfileWriter) AddCommitWithParents(tree hash.Hash, parents []hash.Hash, author, committer *Identity, message string, signer signing.Signer) (hash.Hash, error) {
 	if err := w.checkCleanupState(); err != nil {
 		return hash.Hash{}, err
 	}
 
 	c := &PackfileCommit{
 		Tree:      tree,
-		Parent:    parent,
 		Author:    author,
 		Committer: committer,
 		Message:   message,
 	}
+	if len(parents) > 0 {
+		c.Parent = parents[0]
+	}
+	if len(parents) > 1 {
+		c.Parents = parents
+	}
 	if signer != nil && c.Signature == "" {
 		unsignedBytes := c.BuildUnsigned()
 		sig, err := signer.Sign(unsignedBytes)
@@ -1091,6 +1538,7 @@ func (w *PackfileWriter) AddCommit(tree, parent hash.Hash, author, committer *Id
 	}
 
 	w.objectHashes[h.String()] = true
+	w.track(h)
 	w.hasCommit = true
 	w.lastCommitHash = h
 
@@ -1145,20 +1593,18 @@ func (pw *PackfileWriter) validateWriteState() error {
 
 // writeRefUpdate writes the reference update command and flush packet
 func (pw *PackfileWriter) writeRefUpdate(writer io.Writer, refName string, oldRefHash hash.Hash) error {
-	capabilities, err := FormatCapabilities(pw.capabilities)
+	// A ref that does not exist yet is created, e.g. the branch of an
+	// orphan commit or the first branch of an empty repository.
+	request := NewUpdateRefRequest(oldRefHash, pw.lastCommitHash, refName, pw.capabilities...)
+	if oldRefHash == hash.Zero {
+		request = NewCreateRefRequest(refName, pw.lastCommitHash, pw.capabilities...)
+	}
+	refUpdateLine, err := request.command()
 	if err != nil {
-		return fmt.Errorf("capabilities: %w", err)
+		return err
 	}
-	refUpdate := fmt.Sprintf("%s %s %s\000%s\n",
-		oldRefHash.String(),
-		pw.lastCommitHash.String(),
-		refName,
-		capabilities)
 
-	refUpdateLen := len(refUpdate) + 4
-	refUpdateLine := fmt.Sprintf("%04x%s", refUpdateLen, refUpdate)
-
-	if _, err := writer.Write([]byte(refUpdateLine)); err != nil {
+	if _, err := writer.Write(refUpdateLine); err != nil {
 		return fmt.Errorf("writing ref update line: %w", err)
 	}
 
@@ -1236,33 +1682,20 @@ func (pw *PackfileWriter) writeFromTempFile(hashWriter io.Writer) error {
 // writeFromMemory writes objects from memory storage
 func (pw *PackfileWriter) writeFromMemory(hashWriter io.Writer) error {
 	for _, obj := range pw.memoryObjects {
-		if err := pw.writeObjectToWriter(hashWriter, obj); err != nil {
+		if err := writePackObject(hashWriter, obj); err != nil {
 			return fmt.Errorf("writing memory object: %w", err)
 		}
 	}
 	return nil
 }
 
-// writeObjectToWriter writes a single object to the specified writer.
+// writePackObject writes a single undeltified object in packfile format.
 // The object format is:
 // - Type and size (variable length)
 // - Compressed object data
-func (pw *PackfileWriter) writeObjectToWriter(writer io.Writer, obj PackfileObject) error {
-	// Write type and size
-	size := len(obj.Data)
-	firstByte := byte(obj.Type)<<4 | byte(size&0x0f)
-	size >>= 4
-
-	for size > 0 {
-		firstByte |= 0x80
-		if _, err := writer.Write([]byte{firstByte}); err != nil {
-			return fmt.Errorf("writing object header: %w", err)
-		}
-		firstByte = byte(size & 0x7f)
-		size >>= 7
-	}
-	if _, err := writer.Write([]byte{firstByte}); err != nil {
-		return fmt.Errorf("writing object header: %w", err)
+func writePackObject(writer io.Writer, obj PackfileObject) error {
+	if err := writePackObjectHeader(writer, obj.Type, int64(len(obj.Data))); err != nil {
+		return err
 	}
 
 	// Compress and write data using pooled zlib writer
@@ -1280,11 +1713,32 @@ func (pw *PackfileWriter) writeObjectToWriter(writer io.Writer, obj PackfileObje
 	return nil
 }
 
+// writePackObjectHeader writes the variable length type and size header of
+// a packfile object.
+func writePackObjectHeader(writer io.Writer, objType ObjectType, size int64) error {
+	firstByte := byte(objType)<<4 | byte(size&0x0f)
+	size >>= 4
+
+	for size > 0 {
+		firstByte |= 0x80
+		if _, err := writer.Write([]byte{firstByte}); err != nil {
+			return fmt.Errorf("writing object header: %w", err)
+		}
+		firstByte = byte(size & 0x7f)
+		size >>= 7
+	}
+	if _, err := writer.Write([]byte{firstByte}); err != nil {
+		return fmt.Errorf("writing object header: %w", err)
+	}
+	return nil
+}
+
 // addObject adds an object using the appropriate storage method based on the storage mode.
 func (pw *PackfileWriter) addObject(obj PackfileObject) error {
 	switch pw.storageMode {
 	case PackfileStorageMemory:
 		// Always use memory storage
+		pw.objectPositions[obj.Hash.String()] = int64(len(pw.memoryObjects))
 		pw.memoryObjects = append(pw.memoryObjects, obj)
 		pw.totalBytes += len(obj.Data)
 		return nil
@@ -1304,6 +1758,7 @@ func (pw *PackfileWriter) addObject(obj PackfileObject) error {
 		// Auto mode: use memory for small operations, file for bulk operations
 		// Check both object count and total byte size thresholds
 		if len(pw.objectHashes) < MemoryThreshold && pw.totalBytes < MemoryBytesThreshold && pw.tempFile == nil {
+			pw.objectPositions[obj.Hash.String()] = int64(len(pw.memoryObjects))
 			pw.memoryObjects = append(pw.memoryObjects, obj)
 			pw.totalBytes += len(obj.Data)
 			return nil
@@ -1362,34 +1817,10 @@ func (w *PackfileWriter) ensureTempFile() error {
 
 // writeObjectToFile writes a single object to the temporary file.
 func (w *PackfileWriter) writeObjectToFile(obj PackfileObject) error {
-	// Write type and size
-	size := len(obj.Data)
-	firstByte := byte(obj.Type)<<4 | byte(size&0x0f)
-	size >>= 4
-
-	for size > 0 {
-		firstByte |= 0x80
-		if _, err := w.tempFile.Write([]byte{firstByte}); err != nil {
-			return fmt.Errorf("writing object header: %w", err)
-		}
-		firstByte = byte(size & 0x7f)
-		size >>= 7
-	}
-	if _, err := w.tempFile.Write([]byte{firstByte}); err != nil {
-		return fmt.Errorf("writing object header: %w", err)
-	}
-
-	// Compress and write data using pooled zlib writer
-	// This ensures proper zlib stream boundaries for each Git object
-	zw := getPooledZlibWriter(w.tempFile)
-	defer returnPooledZlibWriter(zw)
-
-	if _, err := zw.Write(obj.Data); err != nil {
-		return fmt.Errorf("compressing object data: %w", err)
-	}
-	if err := zw.Close(); err != nil {
-		return fmt.Errorf("closing zlib writer: %w", err)
+	offset, err := w.tempFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seeking temp file: %w", err)
 	}
-
-	return nil
+	w.objectPositions[obj.Hash.String()] = offset
+	return writePackObject(w.tempFile, obj)
 }
@@ -55,6 +55,34 @@ func TestNewPackfileWriter_Capabilities(t *testing.T) {
 	})
 }
 
+func TestPackfileWriter_RefUpdateCommand(t *testing.T) {
+	writeOnto := func(oldHash hash.Hash) (string, hash.Hash) {
+		writer := NewPackfileWriter(crypto.SHA1, PackfileStorageMemory)
+		treeObj, err := BuildTreeObject(crypto.SHA1, []PackfileTreeEntry{})
+		require.NoError(t, err)
+		writer.AddObject(treeObj)
+		author := &Identity{Name: "a", Email: "a@b", Timestamp: 0, Timezone: "+0000"}
+		commitHash, err := writer.AddCommitWithParents(treeObj.Hash, nil, author, author, "msg", nil)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, writer.WritePackfile(&buf, "refs/heads/main", oldHash))
+		return buf.String(), commitHash
+	}
+
+	t.Run("zero old hash creates the ref", func(t *testing.T) {
+		out, commitHash := writeOnto(hash.Zero)
+		assert.Contains(t, out, ZeroHash+" "+commitHash.String()+" refs/heads/main\000")
+	})
+
+	t.Run("old hash updates the ref", func(t *testing.T) {
+		oldHash, err := hash.FromHex("1234567890123456789012345678901234567890")
+		require.NoError(t, err)
+		out, commitHash := writeOnto(oldHash)
+		assert.Contains(t, out, oldHash.String()+" "+commitHash.String()+" refs/heads/main\000")
+	})
+}
+
 func TestPackfileWriterCleanup(t *testing.T) {
 	t.Run("cleanup prevents further operations", func(t *testing.T) {
 		writer := NewPackfileWriter(crypto.SHA1, PackfileStorageMemory)
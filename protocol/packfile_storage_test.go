@@ -1,8 +1,11 @@
 package protocol
 
 import (
+	"bytes"
+	"context"
 	"crypto"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/grafana/nanogit/protocol/hash"
@@ -182,3 +185,227 @@ func TestPackfileWriter_Cleanup(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestPackfileWriter_AddBlobFromReader(t *testing.T) {
+	content := bytes.Repeat([]byte("generated artifact\n"), 1000)
+	want, err := Object(crypto.SHA1, ObjectTypeBlob, content)
+	require.NoError(t, err)
+
+	for _, mode := range []PackfileStorageMode{PackfileStorageAuto, PackfileStorageMemory, PackfileStorageDisk} {
+		writer := NewPackfileWriter(crypto.SHA1, mode)
+		defer func() { _ = writer.Cleanup() }()
+
+		small, err := writer.AddBlob([]byte("small"))
+		require.NoError(t, err)
+
+		h, err := writer.AddBlobFromReader(bytes.NewReader(content), int64(len(content)))
+		require.NoError(t, err)
+		require.Equal(t, want, h)
+		if mode != PackfileStorageMemory {
+			require.NotNil(t, writer.tempFile)
+			require.Empty(t, writer.memoryObjects)
+		}
+
+		// Duplicates and readers of the wrong size leave the pack as it was.
+		h, err = writer.AddBlobFromReader(bytes.NewReader(content), int64(len(content)))
+		require.NoError(t, err)
+		require.Equal(t, want, h)
+		_, err = writer.AddBlobFromReader(bytes.NewReader(content[:10]), int64(len(content)))
+		require.ErrorContains(t, err, "want")
+		_, err = writer.AddBlobFromReader(bytes.NewReader(content), 10)
+		require.ErrorContains(t, err, "longer than")
+		require.Len(t, writer.objectHashes, 2)
+
+		var buf bytes.Buffer
+		require.NoError(t, writer.writePackfileData(&buf))
+		pack, err := ParsePackfile(context.Background(), &buf)
+		require.NoError(t, err)
+		got := make(map[hash.Hash][]byte)
+		for {
+			entry, err := pack.ReadObject(context.Background())
+			require.NoError(t, err)
+			if entry.Trailer != nil {
+				break
+			}
+			got[entry.Object.Hash] = entry.Object.Data
+		}
+		require.Equal(t, map[hash.Hash][]byte{small: []byte("small"), want: content}, got)
+	}
+}
+
+func TestPackfileWriter_GetObject(t *testing.T) {
+	for _, mode := range []PackfileStorageMode{PackfileStorageAuto, PackfileStorageMemory, PackfileStorageDisk} {
+		writer := NewPackfileWriter(crypto.SHA1, mode)
+		defer func() { _ = writer.Cleanup() }()
+
+		// Enough blobs for auto storage to move them to disk.
+		blobs := make(map[hash.Hash][]byte)
+		for i := range MemoryThreshold + 2 {
+			content := bytes.Repeat([]byte{byte('a' + i)}, 100*i)
+			h, err := writer.AddBlob(content)
+			require.NoError(t, err)
+			blobs[h] = content
+		}
+		streamed := []byte("streamed content")
+		h, err := writer.AddBlobFromReader(bytes.NewReader(streamed), int64(len(streamed)))
+		require.NoError(t, err)
+		blobs[h] = streamed
+		tree, err := BuildTreeObject(crypto.SHA1, []PackfileTreeEntry{{FileName: "streamed", FileMode: 0o100644, Hash: h.String()}})
+		require.NoError(t, err)
+		writer.AddObject(tree)
+
+		for h, content := range blobs {
+			obj, ok, err := writer.GetObject(h)
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, ObjectTypeBlob, obj.Type)
+			require.Equal(t, content, obj.Data)
+		}
+		obj, ok, err := writer.GetObject(tree.Hash)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, tree.Tree, obj.Tree)
+
+		_, ok, err = writer.GetObject(hash.Zero)
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+}
+
+func TestPackfileWriter_Rollback(t *testing.T) {
+	for _, mode := range []PackfileStorageMode{PackfileStorageAuto, PackfileStorageMemory, PackfileStorageDisk} {
+		writer := NewPackfileWriter(crypto.SHA1, mode)
+		defer func() { _ = writer.Cleanup() }()
+
+		kept, err := writer.AddBlob([]byte("kept"))
+		require.NoError(t, err)
+		commit, err := writer.AddCommit(hash.Zero, hash.Zero, &Identity{Name: "A", Email: "a@example.com"}, &Identity{Name: "A", Email: "a@example.com"}, "first", nil)
+		require.NoError(t, err)
+		checkpoint := writer.Checkpoint()
+
+		// Enough blobs for auto storage to move all of them to disk.
+		var dropped []hash.Hash
+		for i := range MemoryThreshold + 2 {
+			h, err := writer.AddBlob(bytes.Repeat([]byte{byte('a' + i)}, 100*i+1))
+			require.NoError(t, err)
+			dropped = append(dropped, h)
+		}
+		_, err = writer.AddCommit(hash.Zero, commit, &Identity{Name: "A", Email: "a@example.com"}, &Identity{Name: "A", Email: "a@example.com"}, "second", nil)
+		require.NoError(t, err)
+
+		require.NoError(t, writer.Rollback(checkpoint))
+		for _, h := range dropped {
+			_, ok, err := writer.GetObject(h)
+			require.NoError(t, err)
+			require.False(t, ok)
+		}
+		obj, ok, err := writer.GetObject(kept)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, []byte("kept"), obj.Data)
+
+		// Dropped objects can be added again, after the kept ones.
+		again, err := writer.AddBlob([]byte("again"))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, writer.WritePackfile(&buf, "refs/heads/main", hash.Zero))
+		require.Contains(t, buf.String(), commit.String())
+
+		require.NoError(t, writer.Rollback(PackfileCheckpoint{}))
+		require.False(t, writer.HasObjects())
+		_, ok, err = writer.GetObject(again)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		// A checkpoint of another writer with more objects is rejected.
+		other := NewPackfileWriter(crypto.SHA1, mode)
+		defer func() { _ = other.Cleanup() }()
+		for i := range MemoryThreshold + 20 {
+			_, err := other.AddBlob([]byte{byte(i)})
+			require.NoError(t, err)
+		}
+		require.Error(t, writer.Rollback(other.Checkpoint()))
+	}
+}
+
+func TestPackfileWriter_Pruned(t *testing.T) {
+	identity := &Identity{Name: "A", Email: "a@example.com", Timestamp: 1700000000, Timezone: "+0000"}
+	for _, mode := range []PackfileStorageMode{PackfileStorageAuto, PackfileStorageMemory, PackfileStorageDisk} {
+		writer := NewPackfileWriter(crypto.SHA1, mode)
+		defer func() { _ = writer.Cleanup() }()
+
+		// tree adds a blob and a tree holding it, in a subdirectory.
+		tree := func(content string) (hash.Hash, hash.Hash) {
+			blob, err := writer.AddBlob([]byte(content))
+			require.NoError(t, err)
+			sub, err := BuildTreeObject(crypto.SHA1, []PackfileTreeEntry{{FileName: "file", FileMode: 0o100644, Hash: blob.String()}})
+			require.NoError(t, err)
+			writer.AddObject(sub)
+			root, err := BuildTreeObject(crypto.SHA1, []PackfileTreeEntry{
+				{FileName: "dir", FileMode: 0o40000, Hash: sub.Hash.String()},
+				{FileName: "lib", FileMode: 0o160000, Hash: "1111111111111111111111111111111111111111"},
+			})
+			require.NoError(t, err)
+			writer.AddObject(root)
+			return blob, root.Hash
+		}
+
+		firstBlob, firstTree := tree("first")
+		first, err := writer.AddCommit(firstTree, hash.Zero, identity, identity, "first", nil)
+		require.NoError(t, err)
+		// An amended commit: unreachable with its own tree and blob.
+		amendedBlob, amendedTree := tree(strings.Repeat("amended", 1000))
+		amended, err := writer.AddCommit(amendedTree, first, identity, identity, "amended", nil)
+		require.NoError(t, err)
+		secondBlob, secondTree := tree("second")
+		second, err := writer.AddCommit(secondTree, first, identity, identity, "second", nil)
+		require.NoError(t, err)
+		staged, err := writer.AddBlob([]byte("staged"))
+		require.NoError(t, err)
+		overwritten, err := writer.AddBlob([]byte("overwritten"))
+		require.NoError(t, err)
+		checkpoint := writer.Checkpoint()
+		later, err := writer.AddBlob([]byte("later"))
+		require.NoError(t, err)
+
+		p, pruned, err := writer.Pruned(second, staged, later)
+		require.NoError(t, err)
+		defer func() { _ = p.Cleanup() }()
+		require.Equal(t, 5, pruned)
+		for h, want := range map[hash.Hash]bool{
+			first: true, firstBlob: true, firstTree: true,
+			second: true, secondBlob: true, secondTree: true,
+			staged: true, later: true,
+			amendedBlob: false, amendedTree: false, overwritten: false,
+		} {
+			_, ok, err := p.GetObject(h)
+			require.NoError(t, err)
+			require.Equal(t, want, ok, h.String())
+			// The writer pruned from keeps every object.
+			_, ok, err = writer.GetObject(h)
+			require.NoError(t, err)
+			require.True(t, ok, h.String())
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, p.WritePackfile(&buf, "refs/heads/main", hash.Zero))
+		require.Contains(t, buf.String(), second.String())
+		require.Contains(t, buf.String(), "PACK\x00\x00\x00\x02\x00\x00\x00\x0a")
+
+		// Checkpoints of the writer still roll back by order.
+		require.NoError(t, writer.Rollback(checkpoint))
+		_, ok, err := writer.GetObject(later)
+		require.NoError(t, err)
+		require.False(t, ok)
+		obj, ok, err := writer.GetObject(overwritten)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, []byte("overwritten"), obj.Data)
+
+		// Nothing to leave out returns the writer itself.
+		same, pruned, err := writer.Pruned(second, staged, overwritten, amended)
+		require.NoError(t, err)
+		require.Zero(t, pruned)
+		require.Same(t, writer, same)
+	}
+}
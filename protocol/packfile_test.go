@@ -511,6 +511,31 @@ func TestAddCommit_Signing(t *testing.T) {
 	})
 }
 
+func TestAddCommitWithParents(t *testing.T) {
+	t.Parallel()
+
+	ident := &protocol.Identity{Name: "A", Email: "a@b", Timestamp: 1234567890, Timezone: "+0000"}
+	first := hash.MustFromHex("1111111111111111111111111111111111111111")
+	second := hash.MustFromHex("2222222222222222222222222222222222222222")
+
+	w := protocol.NewPackfileWriter(crypto.SHA1, protocol.PackfileStorageMemory)
+	h, err := w.AddCommitWithParents(hash.Zero, []hash.Hash{first, second}, ident, ident, "merge\n", nil)
+	require.NoError(t, err)
+
+	want := &protocol.PackfileCommit{Tree: hash.Zero, Parents: []hash.Hash{first, second}, Author: ident, Committer: ident, Message: "merge\n"}
+	require.Contains(t, string(want.Build()), "parent "+first.String()+"\nparent "+second.String()+"\n")
+	wantHash, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeCommit, want.Build())
+	require.NoError(t, err)
+	require.Equal(t, wantHash, h)
+
+	// A single parent builds the same commit as AddCommit.
+	single, err := w.AddCommitWithParents(hash.Zero, []hash.Hash{first}, ident, ident, "m\n", nil)
+	require.NoError(t, err)
+	viaAddCommit, err := w.AddCommit(hash.Zero, first, ident, ident, "m\n", nil)
+	require.NoError(t, err)
+	require.Equal(t, viaAddCommit, single)
+}
+
 var errFakeSign = errors.New("fake sign error")
 
 type fakeSigner struct {
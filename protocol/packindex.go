@@ -0,0 +1,185 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	stdhash "hash"
+	"hash/crc32"
+	"io"
+	"slices"
+
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// ErrPackEncoderObjectCount is returned when the number of objects written to a
+// PackEncoder does not match the count announced in the pack header.
+var ErrPackEncoderObjectCount = errors.New("packfile object count mismatch")
+
+// PackEncoder writes a version 2 packfile, such as the ones stored under
+// .git/objects/pack, and records the offset and CRC32 of every object so a
+// matching version 2 pack index can be written with WriteIndex.
+//
+// Objects are written undeltified. The number of objects must be known up
+// front because it is part of the pack header.
+type PackEncoder struct {
+	w        io.Writer
+	algo     crypto.Hash
+	checksum stdhash.Hash
+	offset   uint64
+	count    uint32
+	entries  []packIndexEntry
+	trailer  hash.Hash
+	closed   bool
+}
+
+// packIndexEntry is what the pack index records about one object.
+type packIndexEntry struct {
+	hash   hash.Hash
+	offset uint64
+	crc    uint32
+}
+
+// NewPackEncoder writes the header of a packfile holding count objects to w
+// and returns an encoder for its objects.
+func NewPackEncoder(w io.Writer, algo crypto.Hash, count uint32) (*PackEncoder, error) {
+	e := &PackEncoder{
+		algo:     algo,
+		checksum: algo.New(),
+		count:    count,
+		entries:  make([]packIndexEntry, 0, count),
+	}
+	e.w = io.MultiWriter(w, e.checksum)
+
+	var header [12]byte
+	copy(header[:4], "PACK")
+	binary.BigEndian.PutUint32(header[4:8], 2)
+	binary.BigEndian.PutUint32(header[8:12], count)
+	if _, err := e.w.Write(header[:]); err != nil {
+		return nil, fmt.Errorf("writing packfile header: %w", err)
+	}
+	e.offset = uint64(len(header))
+	return e, nil
+}
+
+// Encode appends an object to the packfile. The object's Hash must be set.
+func (e *PackEncoder) Encode(obj PackfileObject) error {
+	if e.closed {
+		return errors.New("pack encoder is closed")
+	}
+	if uint32(len(e.entries)) == e.count {
+		return fmt.Errorf("%w: more than %d objects", ErrPackEncoderObjectCount, e.count)
+	}
+	if obj.Hash == hash.Zero {
+		return errors.New("packfile object has no hash")
+	}
+
+	crc := crc32.NewIEEE()
+	counter := &countingWriter{w: io.MultiWriter(e.w, crc)}
+	if err := writePackObject(counter, obj); err != nil {
+		return fmt.Errorf("writing object %s: %w", obj.Hash.String(), err)
+	}
+
+	e.entries = append(e.entries, packIndexEntry{hash: obj.Hash, offset: e.offset, crc: crc.Sum32()})
+	e.offset += counter.n
+	return nil
+}
+
+// Close writes the packfile trailer and returns the packfile checksum, which
+// also names the pack on disk (pack-<checksum>.pack).
+func (e *PackEncoder) Close() (hash.Hash, error) {
+	if e.closed {
+		return e.trailer, nil
+	}
+	if uint32(len(e.entries)) != e.count {
+		return hash.Zero, fmt.Errorf("%w: wrote %d of %d objects", ErrPackEncoderObjectCount, len(e.entries), e.count)
+	}
+
+	sum := e.checksum.Sum(nil)
+	if _, err := e.w.Write(sum); err != nil {
+		return hash.Zero, fmt.Errorf("writing pack hash: %w", err)
+	}
+	copy(e.trailer[:], sum)
+	e.closed = true
+	return e.trailer, nil
+}
+
+// WriteIndex writes the version 2 pack index (.idx) of the packfile to w.
+// It must be called after Close.
+//
+// The index format is:
+// - 4-byte magic number (\377tOc) and 4-byte version (2)
+// - 256-entry fan-out table of cumulative object counts by first hash byte
+// - Sorted object hashes, their CRC32s and their 4-byte offsets
+// - 8-byte offsets for objects beyond 2GB
+// - The packfile checksum, then the checksum of the index itself
+func (e *PackEncoder) WriteIndex(w io.Writer) error {
+	if !e.closed {
+		return errors.New("pack encoder must be closed before writing the index")
+	}
+
+	entries := slices.Clone(e.entries)
+	slices.SortFunc(entries, func(a, b packIndexEntry) int {
+		return bytes.Compare(a.hash[:], b.hash[:])
+	})
+
+	checksum := e.algo.New()
+	out := io.MultiWriter(w, checksum)
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0xff, 't', 'O', 'c'})
+	_ = binary.Write(&buf, binary.BigEndian, uint32(2))
+
+	var fanout [256]uint32
+	for _, entry := range entries {
+		fanout[entry.hash[0]]++
+	}
+	var total uint32
+	for i := range fanout {
+		total += fanout[i]
+		_ = binary.Write(&buf, binary.BigEndian, total)
+	}
+
+	for _, entry := range entries {
+		buf.Write(entry.hash[:])
+	}
+	for _, entry := range entries {
+		_ = binary.Write(&buf, binary.BigEndian, entry.crc)
+	}
+
+	var large []uint64
+	for _, entry := range entries {
+		if entry.offset < 0x80000000 {
+			_ = binary.Write(&buf, binary.BigEndian, uint32(entry.offset))
+			continue
+		}
+		_ = binary.Write(&buf, binary.BigEndian, uint32(len(large))|0x80000000)
+		large = append(large, entry.offset)
+	}
+	for _, offset := range large {
+		_ = binary.Write(&buf, binary.BigEndian, offset)
+	}
+	buf.Write(e.trailer[:])
+
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("writing pack index: %w", err)
+	}
+	if _, err := w.Write(checksum.Sum(nil)); err != nil {
+		return fmt.Errorf("writing pack index hash: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	return n, err
+}
@@ -0,0 +1,102 @@
+package protocol_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+func TestPackEncoder(t *testing.T) {
+	t.Parallel()
+
+	var objects []protocol.PackfileObject
+	for _, content := range []string{"hello", "", string(bytes.Repeat([]byte("large "), 1000))} {
+		h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, []byte(content))
+		require.NoError(t, err)
+		objects = append(objects, protocol.PackfileObject{Type: protocol.ObjectTypeBlob, Data: []byte(content), Hash: h})
+	}
+	tree, err := protocol.BuildTreeObject(crypto.SHA1, []protocol.PackfileTreeEntry{
+		{FileMode: 0o100644, FileName: "hello.txt", Hash: objects[0].Hash.String()},
+	})
+	require.NoError(t, err)
+	objects = append(objects, tree)
+
+	var pack bytes.Buffer
+	encoder, err := protocol.NewPackEncoder(&pack, crypto.SHA1, uint32(len(objects)))
+	require.NoError(t, err)
+	for _, obj := range objects {
+		require.NoError(t, encoder.Encode(obj))
+	}
+
+	err = encoder.WriteIndex(io.Discard)
+	require.Error(t, err, "the index needs the pack checksum")
+
+	checksum, err := encoder.Close()
+	require.NoError(t, err)
+	require.Equal(t, pack.Bytes()[pack.Len()-20:], checksum[:])
+
+	var idx bytes.Buffer
+	require.NoError(t, encoder.WriteIndex(&idx))
+
+	t.Run("pack can be parsed back", func(t *testing.T) {
+		t.Parallel()
+		reader, err := protocol.ParsePackfile(context.Background(), bytes.NewReader(pack.Bytes()))
+		require.NoError(t, err)
+
+		var got []hash.Hash
+		for {
+			entry, err := reader.ReadObject(context.Background())
+			if errors.Is(err, io.EOF) || (err == nil && entry.Object == nil) {
+				break
+			}
+			require.NoError(t, err)
+			got = append(got, entry.Object.Hash)
+		}
+		var want []hash.Hash
+		for _, obj := range objects {
+			want = append(want, obj.Hash)
+		}
+		require.Equal(t, want, got)
+	})
+
+	t.Run("index matches git index-pack", func(t *testing.T) {
+		t.Parallel()
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git binary not available")
+		}
+
+		dir := t.TempDir()
+		packPath := filepath.Join(dir, "pack-"+checksum.String()+".pack")
+		require.NoError(t, os.WriteFile(packPath, pack.Bytes(), 0644))
+
+		out, err := exec.Command("git", "index-pack", "--index-version=2", packPath).CombinedOutput()
+		require.NoError(t, err, string(out))
+
+		expected, err := os.ReadFile(filepath.Join(dir, "pack-"+checksum.String()+".idx"))
+		require.NoError(t, err)
+		require.Equal(t, expected, idx.Bytes())
+	})
+
+	t.Run("object count is enforced", func(t *testing.T) {
+		t.Parallel()
+		encoder, err := protocol.NewPackEncoder(io.Discard, crypto.SHA1, 1)
+		require.NoError(t, err)
+
+		_, err = encoder.Close()
+		require.ErrorIs(t, err, protocol.ErrPackEncoderObjectCount)
+
+		require.NoError(t, encoder.Encode(objects[0]))
+		require.ErrorIs(t, encoder.Encode(objects[1]), protocol.ErrPackEncoderObjectCount)
+	})
+}
@@ -280,6 +280,25 @@ func NewDeleteRefRequest(oldRef hash.Hash, refName string, caps ...Capability) R
 //	Delete refs/heads/main:
 //	"1234... 0000... refs/heads/main\000report-status-v2 side-band-64k quiet object-format=sha1 agent=nanogit\n"
 func (r RefUpdateRequest) Format() ([]byte, error) {
+	pkt, err := r.command()
+	if err != nil {
+		return nil, err
+	}
+	pkt = append(pkt, FlushPacket...)
+
+	// Send pack file as raw data (not as a pkt-line)
+	// It seems we need to send the empty pack even if it's not needed.
+	pkt = append(pkt, EmptyPack...)
+
+	// Add final flush packet
+	pkt = append(pkt, FlushPacket...)
+
+	return pkt, nil
+}
+
+// command formats the ref update command as a pkt-line, without the flush
+// packet and the pack that follow it.
+func (r RefUpdateRequest) command() ([]byte, error) {
 	// Validate hash lengths
 	if len(r.OldRef) != 40 && r.OldRef != ZeroHash {
 		return nil, fmt.Errorf("invalid old ref hash length: got %d, want 40", len(r.OldRef))
@@ -300,16 +319,7 @@ func (r RefUpdateRequest) Format() ([]byte, error) {
 	// Calculate the correct length (including the 4 bytes of the length field)
 	lineLen := len(refLine) + 4
 	pkt := make([]byte, 0, lineLen+4)
-	pkt = fmt.Appendf(pkt, "%04x%s0000", lineLen, refLine)
-
-	// Send pack file as raw data (not as a pkt-line)
-	// It seems we need to send the empty pack even if it's not needed.
-	pkt = append(pkt, EmptyPack...)
-
-	// Add final flush packet
-	pkt = append(pkt, FlushPacket...)
-
-	return pkt, nil
+	return fmt.Appendf(pkt, "%04x%s", lineLen, refLine), nil
 }
 
 type RefLine struct {
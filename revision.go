@@ -0,0 +1,495 @@
+package nanogit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+)
+
+const (
+	// fullHashLength is the length of a hex-encoded SHA-1 object name.
+	fullHashLength = 40
+	// minAbbreviatedHashLength is the shortest hex prefix accepted as an
+	// abbreviated object name, matching git's minimum.
+	minAbbreviatedHashLength = 4
+)
+
+// ResolveRevision resolves a git revision expression to an object hash and
+// its type, like `git rev-parse`. The supported syntax is:
+//
+//   - Full ref names ("refs/heads/main") and short names ("main", "v1.0"),
+//     looked up with git's precedence: <name>, refs/<name>, refs/tags/<name>,
+//     refs/heads/<name>, refs/remotes/<name>, refs/remotes/<name>/HEAD.
+//     "@" is a shortcut for HEAD.
+//   - Full and abbreviated object hashes (at least 4 hex characters). A ref
+//     with the same name as an abbreviated hash takes precedence.
+//   - <rev>~<n>: the n-th first-parent ancestor (~ alone means ~1).
+//   - <rev>^<n>: the n-th parent (^ alone means ^1, ^0 peels to the commit).
+//   - <rev>^{commit}, <rev>^{tree}, <rev>^{blob}, <rev>^{tag} and <rev>^{}:
+//     peel annotated tags and commits to the requested type.
+//   - <rev>:<path>: the tree or blob at path in the tree of <rev>.
+//
+// Suffixes can be chained ("main~2^2~1"). Reflog forms (@{...}) and the
+// index form (":path") are not supported because they only exist in a local
+// repository.
+//
+// Abbreviated hashes are matched against the objects already in the context
+// storage, the advertised ref tips and every commit reachable from HEAD and
+// the branches. That walk fetches the branch histories (without trees), so
+// prefer full hashes on large repositories or share a storage across calls.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - expr: Revision expression to resolve
+//
+// Returns:
+//   - hash.Hash: Hash of the object the expression names
+//   - protocol.ObjectType: Type of that object
+//   - error: ErrInvalidRevision for malformed or unknown expressions,
+//     ErrAmbiguousRevision when an abbreviated hash matches several objects,
+//     or an error if objects cannot be fetched
+//
+// Example:
+//
+//	h, typ, err := client.ResolveRevision(ctx, "main~3:docs/README.md")
+//	if err != nil {
+//	    return err
+//	}
+//	if typ == protocol.ObjectTypeBlob {
+//	    blob, err := client.GetBlob(ctx, h)
+//	    // ...
+//	}
+func (c *httpClient) ResolveRevision(ctx context.Context, expr string) (hash.Hash, protocol.ObjectType, error) {
+	logger := log.FromContext(ctx)
+	logger.Debug("Resolve revision",
+		"revision", expr)
+
+	if expr == "" {
+		return hash.Zero, protocol.ObjectTypeInvalid, NewInvalidRevisionError(expr, "empty revision")
+	}
+
+	ctx, allObjects := storage.FromContextOrInMemory(ctx)
+	r := &revisionResolver{
+		client:     c,
+		storage:    allObjects,
+		graph:      newCommitGraph(c, allObjects),
+		expression: expr,
+	}
+
+	rev, path, hasPath := strings.Cut(expr, ":")
+	if hasPath && rev == "" {
+		return hash.Zero, protocol.ObjectTypeInvalid, NewInvalidRevisionError(expr, "index paths are not supported")
+	}
+
+	h, typ, err := r.resolve(ctx, rev)
+	if err != nil {
+		return hash.Zero, protocol.ObjectTypeInvalid, err
+	}
+
+	if hasPath {
+		h, typ, err = r.resolvePath(ctx, h, typ, path)
+		if err != nil {
+			return hash.Zero, protocol.ObjectTypeInvalid, err
+		}
+	}
+
+	logger.Debug("Revision resolved",
+		"revision", expr,
+		"object_hash", h.String(),
+		"object_type", typ.String())
+	return h, typ, nil
+}
+
+// revisionResolver holds the state shared by the steps of one
+// ResolveRevision call: the advertised refs (listed at most once) and the
+// commit graph used for parent navigation.
+type revisionResolver struct {
+	client     *httpClient
+	storage    storage.PackfileStorage
+	graph      *commitGraph
+	expression string
+	refs       map[string]hash.Hash
+}
+
+// resolve evaluates a revision without the ":path" part: a base name
+// followed by any number of ~ and ^ suffixes.
+func (r *revisionResolver) resolve(ctx context.Context, rev string) (hash.Hash, protocol.ObjectType, error) {
+	end := strings.IndexAny(rev, "~^")
+	if end < 0 {
+		end = len(rev)
+	}
+	base, suffixes := rev[:end], rev[end:]
+	if base == "" {
+		return hash.Zero, protocol.ObjectTypeInvalid, NewInvalidRevisionError(r.expression, "missing revision before suffix")
+	}
+
+	h, typ, err := r.resolveBase(ctx, base)
+	if err != nil {
+		return hash.Zero, protocol.ObjectTypeInvalid, err
+	}
+
+	for suffixes != "" {
+		op := suffixes[0]
+		suffixes = suffixes[1:]
+
+		if op == '^' && strings.HasPrefix(suffixes, "{") {
+			closing := strings.IndexByte(suffixes, '}')
+			if closing < 0 {
+				return hash.Zero, protocol.ObjectTypeInvalid, NewInvalidRevisionError(r.expression, "unterminated ^{")
+			}
+			target := suffixes[1:closing]
+			suffixes = suffixes[closing+1:]
+
+			h, typ, err = r.peelTo(ctx, h, typ, target)
+			if err != nil {
+				return hash.Zero, protocol.ObjectTypeInvalid, err
+			}
+			continue
+		}
+
+		digits := len(suffixes) - len(strings.TrimLeft(suffixes, "0123456789"))
+		n := 1
+		if digits > 0 {
+			n, err = strconv.Atoi(suffixes[:digits])
+			if err != nil {
+				return hash.Zero, protocol.ObjectTypeInvalid, NewInvalidRevisionError(r.expression, "invalid number "+suffixes[:digits])
+			}
+			suffixes = suffixes[digits:]
+		}
+
+		h, typ, err = r.peel(ctx, h, typ, protocol.ObjectTypeCommit)
+		if err != nil {
+			return hash.Zero, protocol.ObjectTypeInvalid, err
+		}
+
+		if op == '~' {
+			for range n {
+				h, err = r.parent(ctx, h, 1)
+				if err != nil {
+					return hash.Zero, protocol.ObjectTypeInvalid, err
+				}
+			}
+		} else if n > 0 {
+			h, err = r.parent(ctx, h, n)
+			if err != nil {
+				return hash.Zero, protocol.ObjectTypeInvalid, err
+			}
+		}
+	}
+
+	return h, typ, nil
+}
+
+// resolveBase resolves a ref name, full hash or abbreviated hash.
+func (r *revisionResolver) resolveBase(ctx context.Context, name string) (hash.Hash, protocol.ObjectType, error) {
+	if name == "@" {
+		name = "HEAD"
+	}
+
+	if len(name) == fullHashLength && isHex(name) {
+		h, err := hash.FromHex(name)
+		if err != nil {
+			return hash.Zero, protocol.ObjectTypeInvalid, NewInvalidRevisionError(r.expression, err.Error())
+		}
+		typ, err := r.objectType(ctx, h)
+		if err != nil {
+			return hash.Zero, protocol.ObjectTypeInvalid, err
+		}
+		return h, typ, nil
+	}
+
+	if err := r.loadRefs(ctx); err != nil {
+		return hash.Zero, protocol.ObjectTypeInvalid, err
+	}
+
+	candidates := []string{
+		name,
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	}
+	for _, refName := range candidates {
+		h, ok := r.refs[refName]
+		if !ok {
+			continue
+		}
+
+		log.FromContext(ctx).Debug("Revision matched ref",
+			"revision", name,
+			"ref_name", refName,
+			"ref_hash", h.String())
+
+		typ, err := r.objectType(ctx, h)
+		if err != nil {
+			return hash.Zero, protocol.ObjectTypeInvalid, err
+		}
+		return h, typ, nil
+	}
+
+	if len(name) >= minAbbreviatedHashLength && len(name) < fullHashLength && isHex(name) {
+		h, err := r.resolveAbbreviated(ctx, strings.ToLower(name))
+		if err != nil {
+			return hash.Zero, protocol.ObjectTypeInvalid, err
+		}
+		typ, err := r.objectType(ctx, h)
+		if err != nil {
+			return hash.Zero, protocol.ObjectTypeInvalid, err
+		}
+		return h, typ, nil
+	}
+
+	return hash.Zero, protocol.ObjectTypeInvalid, NewInvalidRevisionError(r.expression, "unknown revision "+name)
+}
+
+func (r *revisionResolver) loadRefs(ctx context.Context) error {
+	if r.refs != nil {
+		return nil
+	}
+
+	lines, err := r.client.LsRefs(ctx, client.LsRefsOptions{})
+	if err != nil {
+		return fmt.Errorf("list refs: %w", err)
+	}
+
+	r.refs = make(map[string]hash.Hash, len(lines))
+	for _, line := range lines {
+		r.refs[line.RefName] = line.Hash
+	}
+	return nil
+}
+
+// resolveAbbreviated finds the single object whose hash starts with prefix.
+func (r *revisionResolver) resolveAbbreviated(ctx context.Context, prefix string) (hash.Hash, error) {
+	logger := log.FromContext(ctx)
+	matches := make(map[hash.Hash]struct{})
+
+	for _, h := range r.storage.GetAllKeys() {
+		if strings.HasPrefix(h.String(), prefix) {
+			matches[h] = struct{}{}
+		}
+	}
+	for _, h := range r.refs {
+		if strings.HasPrefix(h.String(), prefix) {
+			matches[h] = struct{}{}
+		}
+	}
+
+	logger.Debug("Search history for abbreviated hash",
+		"prefix", prefix)
+
+	var tips []hash.Hash
+	for name, h := range r.refs {
+		if name == "HEAD" || strings.HasPrefix(name, "refs/heads/") {
+			tips = append(tips, h)
+		}
+	}
+
+	err := r.graph.walk(ctx, tips, func(n *commitNode) {
+		if strings.HasPrefix(n.hash.String(), prefix) {
+			matches[n.hash] = struct{}{}
+		}
+	})
+	if err != nil {
+		return hash.Zero, fmt.Errorf("search history for %s: %w", prefix, err)
+	}
+
+	switch len(matches) {
+	case 0:
+		return hash.Zero, NewInvalidRevisionError(r.expression, "unknown revision "+prefix)
+	case 1:
+		for h := range matches {
+			return h, nil
+		}
+	}
+
+	candidates := make([]hash.Hash, 0, len(matches))
+	for h := range matches {
+		candidates = append(candidates, h)
+	}
+	return hash.Zero, NewAmbiguousRevisionError(prefix, candidates)
+}
+
+// parent returns the n-th (1-based) parent of a commit.
+func (r *revisionResolver) parent(ctx context.Context, commitHash hash.Hash, n int) (hash.Hash, error) {
+	node, err := r.graph.node(ctx, commitHash)
+	if err != nil {
+		return hash.Zero, fmt.Errorf("load commit %s: %w", commitHash.String(), err)
+	}
+	if n > len(node.parents) {
+		return hash.Zero, NewInvalidRevisionError(r.expression, fmt.Sprintf("commit %s has no parent %d", commitHash.String(), n))
+	}
+	return node.parents[n-1], nil
+}
+
+// peelTo implements the ^{type} suffix. An empty type peels annotated tags
+// down to the first non-tag object.
+func (r *revisionResolver) peelTo(ctx context.Context, h hash.Hash, typ protocol.ObjectType, target string) (hash.Hash, protocol.ObjectType, error) {
+	switch target {
+	case "":
+		for typ == protocol.ObjectTypeTag {
+			var err error
+			h, typ, err = r.tagTarget(ctx, h)
+			if err != nil {
+				return hash.Zero, protocol.ObjectTypeInvalid, err
+			}
+		}
+		return h, typ, nil
+	case "commit":
+		return r.peel(ctx, h, typ, protocol.ObjectTypeCommit)
+	case "tree":
+		return r.peel(ctx, h, typ, protocol.ObjectTypeTree)
+	case "blob":
+		return r.peel(ctx, h, typ, protocol.ObjectTypeBlob)
+	case "tag":
+		if typ != protocol.ObjectTypeTag {
+			return hash.Zero, protocol.ObjectTypeInvalid, NewUnexpectedObjectTypeError(h, protocol.ObjectTypeTag, typ)
+		}
+		return h, typ, nil
+	default:
+		return hash.Zero, protocol.ObjectTypeInvalid, NewInvalidRevisionError(r.expression, "unsupported peel target ^{"+target+"}")
+	}
+}
+
+// peel follows annotated tags, and commits to their tree, until it reaches
+// an object of the target type.
+func (r *revisionResolver) peel(ctx context.Context, h hash.Hash, typ protocol.ObjectType, target protocol.ObjectType) (hash.Hash, protocol.ObjectType, error) {
+	for typ != target {
+		switch {
+		case typ == protocol.ObjectTypeTag:
+			var err error
+			h, typ, err = r.tagTarget(ctx, h)
+			if err != nil {
+				return hash.Zero, protocol.ObjectTypeInvalid, err
+			}
+		case typ == protocol.ObjectTypeCommit && target == protocol.ObjectTypeTree:
+			obj, err := r.object(ctx, h)
+			if err != nil {
+				return hash.Zero, protocol.ObjectTypeInvalid, err
+			}
+			h, typ = obj.Commit.Tree, protocol.ObjectTypeTree
+		default:
+			return hash.Zero, protocol.ObjectTypeInvalid, NewUnexpectedObjectTypeError(h, target, typ)
+		}
+	}
+	return h, typ, nil
+}
+
+// tagTarget reads the object an annotated tag points to from its header.
+func (r *revisionResolver) tagTarget(ctx context.Context, tagHash hash.Hash) (hash.Hash, protocol.ObjectType, error) {
+	obj, err := r.object(ctx, tagHash)
+	if err != nil {
+		return hash.Zero, protocol.ObjectTypeInvalid, err
+	}
+
+	var target hash.Hash
+	targetType := protocol.ObjectTypeInvalid
+	for _, line := range bytes.Split(obj.Data, []byte("\n")) {
+		if len(line) == 0 {
+			break
+		}
+		key, value, _ := bytes.Cut(line, []byte(" "))
+		switch string(key) {
+		case "object":
+			target, err = hash.FromHex(string(value))
+			if err != nil {
+				return hash.Zero, protocol.ObjectTypeInvalid, fmt.Errorf("parse tag %s: %w", tagHash.String(), err)
+			}
+		case "type":
+			for _, t := range []protocol.ObjectType{protocol.ObjectTypeCommit, protocol.ObjectTypeTree, protocol.ObjectTypeBlob, protocol.ObjectTypeTag} {
+				if bytes.Equal(value, t.Bytes()) {
+					targetType = t
+				}
+			}
+		}
+	}
+
+	if target.Is(hash.Zero) || targetType == protocol.ObjectTypeInvalid {
+		return hash.Zero, protocol.ObjectTypeInvalid, fmt.Errorf("parse tag %s: missing object or type header", tagHash.String())
+	}
+	return target, targetType, nil
+}
+
+// resolvePath implements the <rev>:<path> form.
+func (r *revisionResolver) resolvePath(ctx context.Context, h hash.Hash, typ protocol.ObjectType, path string) (hash.Hash, protocol.ObjectType, error) {
+	treeHash, _, err := r.peel(ctx, h, typ, protocol.ObjectTypeTree)
+	if err != nil {
+		return hash.Zero, protocol.ObjectTypeInvalid, err
+	}
+
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return treeHash, protocol.ObjectTypeTree, nil
+	}
+
+	dir, name := "", path
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		dir, name = path[:i], path[i+1:]
+	}
+
+	tree, err := r.client.GetTreeByPath(ctx, treeHash, dir)
+	if err != nil {
+		return hash.Zero, protocol.ObjectTypeInvalid, err
+	}
+
+	for _, entry := range tree.Entries {
+		if entry.Name == name {
+			return entry.Hash, entry.Type, nil
+		}
+	}
+	return hash.Zero, protocol.ObjectTypeInvalid, NewPathNotFoundError(path)
+}
+
+// objectType returns the type of the object with the given hash.
+func (r *revisionResolver) objectType(ctx context.Context, h hash.Hash) (protocol.ObjectType, error) {
+	obj, err := r.object(ctx, h)
+	if err != nil {
+		return protocol.ObjectTypeInvalid, err
+	}
+	return obj.Type, nil
+}
+
+// object returns a single object from storage, fetching it alone if needed.
+func (r *revisionResolver) object(ctx context.Context, h hash.Hash) (*protocol.PackfileObject, error) {
+	if obj, ok := r.storage.Get(h); ok {
+		return obj, nil
+	}
+
+	objects, err := r.client.Fetch(ctx, client.FetchOptions{
+		NoProgress:       true,
+		Want:             []hash.Hash{h},
+		Done:             true,
+		NoExtraObjects:   true,
+		MaxResponseBytes: r.client.limits.SingleObjectFetchMaxBytes,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not our ref") {
+			return nil, NewObjectNotFoundError(h)
+		}
+		return nil, fmt.Errorf("fetch object %s: %w", h.String(), err)
+	}
+
+	obj, ok := objects[h.String()]
+	if !ok {
+		return nil, NewObjectNotFoundError(h)
+	}
+	return obj, nil
+}
+
+func isHex(s string) bool {
+	for _, ch := range s {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') && (ch < 'A' || ch > 'F') {
+			return false
+		}
+	}
+	return true
+}
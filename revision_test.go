@@ -0,0 +1,194 @@
+package nanogit
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRevision(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepo(t)
+	c1 := repo.commit("c1", map[string]string{"README.md": "v1", "docs/guide.md": "guide"})
+	c2 := repo.commit("c2", map[string]string{"README.md": "v2", "docs/guide.md": "guide"}, c1)
+	side := repo.commit("side", map[string]string{"README.md": "side", "docs/guide.md": "guide"}, c1)
+	merge := repo.commit("merge", map[string]string{"README.md": "v3", "docs/guide.md": "guide"}, c2, side)
+	annotated := repo.tag("v1.0", c2, protocol.ObjectTypeCommit)
+
+	repo.ref("HEAD", merge)
+	repo.ref("refs/heads/main", merge)
+	repo.ref("refs/heads/feature", side)
+	repo.ref("refs/tags/v1.0", annotated)
+	// A tag and a branch sharing a name: git prefers the tag.
+	repo.ref("refs/heads/release", side)
+	repo.ref("refs/tags/release", c1)
+
+	mergeObj := repo.objects[merge.String()]
+	mergeTree := mergeObj.Commit.Tree
+	c2Tree := repo.objects[c2.String()].Commit.Tree
+
+	mergeTreeObj := repo.objects[mergeTree.String()]
+	var docsTree, readmeBlob hash.Hash
+	for _, e := range mergeTreeObj.Tree {
+		switch e.FileName {
+		case "docs":
+			docsTree = hash.MustFromHex(e.Hash)
+		case "README.md":
+			readmeBlob = hash.MustFromHex(e.Hash)
+		}
+	}
+	guideBlob := repo.blob("guide")
+
+	tests := []struct {
+		expr     string
+		wantHash hash.Hash
+		wantType protocol.ObjectType
+	}{
+		{expr: "main", wantHash: merge, wantType: protocol.ObjectTypeCommit},
+		{expr: "HEAD", wantHash: merge, wantType: protocol.ObjectTypeCommit},
+		{expr: "@", wantHash: merge, wantType: protocol.ObjectTypeCommit},
+		{expr: "refs/heads/feature", wantHash: side, wantType: protocol.ObjectTypeCommit},
+		{expr: "heads/feature", wantHash: side, wantType: protocol.ObjectTypeCommit},
+		{expr: "release", wantHash: c1, wantType: protocol.ObjectTypeCommit},
+		{expr: "v1.0", wantHash: annotated, wantType: protocol.ObjectTypeTag},
+		{expr: "v1.0^{}", wantHash: c2, wantType: protocol.ObjectTypeCommit},
+		{expr: "v1.0^{commit}", wantHash: c2, wantType: protocol.ObjectTypeCommit},
+		{expr: "v1.0^{tree}", wantHash: c2Tree, wantType: protocol.ObjectTypeTree},
+		{expr: "v1.0~1", wantHash: c1, wantType: protocol.ObjectTypeCommit},
+		{expr: merge.String(), wantHash: merge, wantType: protocol.ObjectTypeCommit},
+		{expr: merge.String()[:10], wantHash: merge, wantType: protocol.ObjectTypeCommit},
+		{expr: c1.String()[:8] + "^{tree}", wantHash: repo.objects[c1.String()].Commit.Tree, wantType: protocol.ObjectTypeTree},
+		{expr: "main~", wantHash: c2, wantType: protocol.ObjectTypeCommit},
+		{expr: "main~2", wantHash: c1, wantType: protocol.ObjectTypeCommit},
+		{expr: "main^", wantHash: c2, wantType: protocol.ObjectTypeCommit},
+		{expr: "main^2", wantHash: side, wantType: protocol.ObjectTypeCommit},
+		{expr: "main^2~1", wantHash: c1, wantType: protocol.ObjectTypeCommit},
+		{expr: "main^0", wantHash: merge, wantType: protocol.ObjectTypeCommit},
+		{expr: "main^{tree}", wantHash: mergeTree, wantType: protocol.ObjectTypeTree},
+		{expr: "main:", wantHash: mergeTree, wantType: protocol.ObjectTypeTree},
+		{expr: "main:docs", wantHash: docsTree, wantType: protocol.ObjectTypeTree},
+		{expr: "main:README.md", wantHash: readmeBlob, wantType: protocol.ObjectTypeBlob},
+		{expr: "main~1:docs/guide.md", wantHash: guideBlob, wantType: protocol.ObjectTypeBlob},
+		{expr: "main:docs/guide.md^{blob}", wantHash: hash.Zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			t.Parallel()
+			h, typ, err := repo.client().ResolveRevision(context.Background(), tt.expr)
+			if tt.wantHash.Is(hash.Zero) {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantHash.String(), h.String())
+			assert.Equal(t, tt.wantType, typ)
+		})
+	}
+}
+
+func TestResolveRevision_Errors(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepo(t)
+	c1 := repo.commit("c1", map[string]string{"README.md": "v1"})
+	c2 := repo.commit("c2", map[string]string{"README.md": "v2"}, c1)
+	repo.ref("refs/heads/main", c2)
+
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr error
+	}{
+		{name: "empty", expr: "", wantErr: ErrInvalidRevision},
+		{name: "unknown name", expr: "nope", wantErr: ErrInvalidRevision},
+		{name: "index path", expr: ":README.md", wantErr: ErrInvalidRevision},
+		{name: "suffix without base", expr: "~1", wantErr: ErrInvalidRevision},
+		{name: "unterminated peel", expr: "main^{tree", wantErr: ErrInvalidRevision},
+		{name: "unsupported peel", expr: "main^{object}", wantErr: ErrInvalidRevision},
+		{name: "past the root", expr: "main~2", wantErr: ErrInvalidRevision},
+		{name: "missing second parent", expr: "main^2", wantErr: ErrInvalidRevision},
+		{name: "missing path", expr: "main:nope.txt", wantErr: ErrObjectNotFound},
+		{name: "peel tree to commit", expr: "main^{tree}^{commit}", wantErr: ErrUnexpectedObjectType},
+		{name: "unknown full hash", expr: "1234567890123456789012345678901234567890", wantErr: ErrObjectNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := repo.client().ResolveRevision(context.Background(), tt.expr)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestResolveRevision_AmbiguousAbbreviation(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepo(t)
+	// Create commits until two of them share the first four hex characters.
+	seen := make(map[string]hash.Hash)
+	var prefix string
+	parent := hash.Zero
+	for i := 0; prefix == "" && i < 100000; i++ {
+		var parents []hash.Hash
+		if !parent.Is(hash.Zero) {
+			parents = []hash.Hash{parent}
+		}
+		h := repo.commit("commit", map[string]string{"n.txt": string(rune('a' + i%26))}, parents...)
+		if _, ok := seen[h.String()[:4]]; ok {
+			prefix = h.String()[:4]
+		}
+		seen[h.String()[:4]] = h
+		parent = h
+	}
+	require.NotEmpty(t, prefix)
+	repo.ref("refs/heads/main", parent)
+
+	_, _, err := repo.client().ResolveRevision(context.Background(), prefix)
+	require.ErrorIs(t, err, ErrAmbiguousRevision)
+
+	var ambiguous *AmbiguousRevisionError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Len(t, ambiguous.Candidates, 2)
+}
+
+func TestResolveRevision_AbbreviationFromStorage(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepo(t)
+	c1 := repo.commit("c1", map[string]string{"README.md": "v1"})
+	c2 := repo.commit("c2", map[string]string{"README.md": "v2"}, c1)
+	repo.ref("refs/heads/main", c2)
+
+	// A commit found both in storage and in history is a single match.
+	ctx, store := storage.FromContextOrInMemory(context.Background())
+	store.Add(repo.objects[c1.String()])
+	h, _, err := repo.client().ResolveRevision(ctx, c1.String()[:8])
+	require.NoError(t, err)
+	assert.Equal(t, c1, h)
+
+	// A stored blob sharing its prefix with a commit in history is ambiguous.
+	var blob *protocol.PackfileObject
+	for i := 0; blob == nil && i < 1000000; i++ {
+		data := []byte(fmt.Sprintf("blob %d\n", i))
+		h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, data)
+		require.NoError(t, err)
+		if h.String()[:4] == c1.String()[:4] {
+			blob = &protocol.PackfileObject{Type: protocol.ObjectTypeBlob, Data: data, Hash: h}
+		}
+	}
+	require.NotNil(t, blob)
+	ctx, store = storage.FromContextOrInMemory(context.Background())
+	store.Add(blob)
+	_, _, err = repo.client().ResolveRevision(ctx, c1.String()[:4])
+	require.ErrorIs(t, err, ErrAmbiguousRevision)
+}
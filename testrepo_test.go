@@ -0,0 +1,387 @@
+package nanogit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// testRepo is an in-memory object database that answers client.RawClient
+// fetches the way an upload-pack server with blob:none support would. It
+// lets the higher-level read paths (history walks, tree walks, blob batches)
+// be unit-tested without a containerized Git server.
+type testRepo struct {
+	*mockRawClient
+	mu      sync.Mutex
+	t       *testing.T
+	objects map[string]*protocol.PackfileObject
+	refs    []protocol.RefLine
+	// fetches records the Want list of every Fetch call that reached the
+	// "server", i.e. was not fully served from the context storage.
+	fetches [][]hash.Hash
+	// clock hands out increasing commit timestamps.
+	clock int64
+	// fetchHook, when set, runs before every Fetch that reaches the "server"
+	// and can fail it to simulate server errors or response limits.
+	fetchHook func(opts client.FetchOptions) error
+	// pushes counts the pushes ReceivePack accepted.
+	pushes int
+	// objectInfo, when set, makes ObjectInfo answer like a server
+	// supporting the object-info command.
+	objectInfo bool
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	return &testRepo{
+		mockRawClient: &mockRawClient{},
+		t:             t,
+		objects:       make(map[string]*protocol.PackfileObject),
+		clock:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+	}
+}
+
+// client returns an httpClient backed by the repository.
+func (r *testRepo) client() *httpClient {
+	return &httpClient{RawClient: r}
+}
+
+func (r *testRepo) add(obj *protocol.PackfileObject) hash.Hash {
+	r.objects[obj.Hash.String()] = obj
+	return obj.Hash
+}
+
+// blob stores a blob and returns its hash.
+func (r *testRepo) blob(content string) hash.Hash {
+	r.t.Helper()
+	h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, []byte(content))
+	require.NoError(r.t, err)
+	return r.add(&protocol.PackfileObject{Type: protocol.ObjectTypeBlob, Data: []byte(content), Hash: h})
+}
+
+// tree builds the nested tree objects for a path -> content map and returns
+// the root tree hash. A value prefixed with "symlink:" becomes a symlink, a
+// value prefixed with "exec:" becomes an executable file, and a value
+// prefixed with "submodule:" becomes a gitlink to the commit hash after it.
+func (r *testRepo) tree(files map[string]string) hash.Hash {
+	r.t.Helper()
+	type dir struct {
+		entries map[string]protocol.PackfileTreeEntry
+		subdirs map[string]*dir
+	}
+	newDir := func() *dir {
+		return &dir{entries: map[string]protocol.PackfileTreeEntry{}, subdirs: map[string]*dir{}}
+	}
+	root := newDir()
+	for path, content := range files {
+		parts := strings.Split(path, "/")
+		d := root
+		for _, part := range parts[:len(parts)-1] {
+			if d.subdirs[part] == nil {
+				d.subdirs[part] = newDir()
+			}
+			d = d.subdirs[part]
+		}
+		mode := uint32(0o100644)
+		switch {
+		case strings.HasPrefix(content, "symlink:"):
+			mode, content = 0o120000, strings.TrimPrefix(content, "symlink:")
+		case strings.HasPrefix(content, "exec:"):
+			mode, content = 0o100755, strings.TrimPrefix(content, "exec:")
+		}
+		name := parts[len(parts)-1]
+		if commit, ok := strings.CutPrefix(content, "submodule:"); ok {
+			d.entries[name] = protocol.PackfileTreeEntry{FileMode: 0o160000, FileName: name, Hash: commit}
+			continue
+		}
+		d.entries[name] = protocol.PackfileTreeEntry{FileMode: mode, FileName: name, Hash: r.blob(content).String()}
+	}
+
+	var build func(d *dir) hash.Hash
+	build = func(d *dir) hash.Hash {
+		entries := make([]protocol.PackfileTreeEntry, 0, len(d.entries)+len(d.subdirs))
+		for _, e := range d.entries {
+			entries = append(entries, e)
+		}
+		for name, sub := range d.subdirs {
+			entries = append(entries, protocol.PackfileTreeEntry{FileMode: 0o40000, FileName: name, Hash: build(sub).String()})
+		}
+		obj, err := protocol.BuildTreeObject(crypto.SHA1, entries)
+		require.NoError(r.t, err)
+		return r.add(&obj)
+	}
+	return build(root)
+}
+
+// commit stores a commit with the given tree contents and parents and returns
+// its hash. Each call advances the commit clock by one minute.
+func (r *testRepo) commit(message string, files map[string]string, parents ...hash.Hash) hash.Hash {
+	r.t.Helper()
+	r.clock += 60
+	ident := &protocol.Identity{Name: "Test", Email: "test@example.com", Timestamp: r.clock, Timezone: "+0000"}
+	c := &protocol.PackfileCommit{
+		Tree:      r.tree(files),
+		Parents:   parents,
+		Author:    ident,
+		Committer: ident,
+		Message:   message + "\n",
+	}
+	if len(parents) > 0 {
+		c.Parent = parents[0]
+	}
+	data := c.Build()
+	h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeCommit, data)
+	require.NoError(r.t, err)
+	return r.add(&protocol.PackfileObject{Type: protocol.ObjectTypeCommit, Data: data, Hash: h, Commit: c})
+}
+
+// tag stores an annotated tag pointing at target and returns its hash.
+func (r *testRepo) tag(name string, target hash.Hash, targetType protocol.ObjectType) hash.Hash {
+	r.t.Helper()
+	data := []byte(fmt.Sprintf("object %s\ntype %s\ntag %s\ntagger Test <test@example.com> %d +0000\n\n%s\n",
+		target.String(), targetType.Bytes(), name, r.clock, name))
+	h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeTag, data)
+	require.NoError(r.t, err)
+	return r.add(&protocol.PackfileObject{Type: protocol.ObjectTypeTag, Data: data, Hash: h})
+}
+
+// ref advertises a reference.
+func (r *testRepo) ref(name string, h hash.Hash) {
+	r.refs = append(r.refs, protocol.RefLine{RefName: name, Hash: h})
+}
+
+// setRef points a reference at h, advertising it if needed.
+func (r *testRepo) setRef(name string, h hash.Hash) {
+	r.refs = slices.DeleteFunc(r.refs, func(ref protocol.RefLine) bool { return ref.RefName == name })
+	r.ref(name, h)
+}
+
+// refHash returns the hash a reference points at, or hash.Zero.
+func (r *testRepo) refHash(name string) hash.Hash {
+	for _, ref := range r.refs {
+		if ref.RefName == name {
+			return ref.Hash
+		}
+	}
+	return hash.Zero
+}
+
+// ReceivePack applies a push like receive-pack: each ref update is rejected
+// unless the ref still points at its old hash, and the objects of the
+// packfile are stored.
+func (r *testRepo) ReceivePack(ctx context.Context, data io.Reader) error {
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+
+	type update struct {
+		line     []byte
+		old, new hash.Hash
+		name     string
+	}
+	var updates []update
+	for {
+		if len(body) < 4 {
+			return errors.New("truncated pkt-line")
+		}
+		size, err := strconv.ParseUint(string(body[:4]), 16, 16)
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			body = body[4:]
+			break
+		}
+		line := body[4:size]
+		body = body[size:]
+		command, _, _ := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte{0})
+		fields := strings.Fields(string(command))
+		require.Len(r.t, fields, 3)
+		old, err := hash.FromHex(fields[0])
+		require.NoError(r.t, err)
+		updated, err := hash.FromHex(fields[1])
+		require.NoError(r.t, err)
+		updates = append(updates, update{line: line, old: old, new: updated, name: fields[2]})
+	}
+
+	var objects []*protocol.PackfileObject
+	pack, err := protocol.ParsePackfile(ctx, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for {
+		entry, err := pack.ReadObject(ctx)
+		if err != nil {
+			return err
+		}
+		if entry.Trailer != nil {
+			break
+		}
+		objects = append(objects, entry.Object)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range updates {
+		if r.refHash(u.name) != u.old {
+			return protocol.NewGitReferenceUpdateError(u.line, u.name, "failed to update ref")
+		}
+	}
+	for _, obj := range objects {
+		r.objects[obj.Hash.String()] = obj
+	}
+	for _, u := range updates {
+		r.setRef(u.name, u.new)
+	}
+	r.pushes++
+	return nil
+}
+
+// Fetch serves wanted objects plus whatever a blob:none upload-pack would
+// send along: ancestor commits up to Deepen, and the trees (and, without the
+// blob filter, blobs) reachable from every commit or tree sent. With the
+// tree:0 filter no trees or blobs are sent along, only the wanted objects
+// and the ancestor commits up to Deepen.
+func (r *testRepo) Fetch(ctx context.Context, opts client.FetchOptions) (map[string]*protocol.PackfileObject, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make(map[string]*protocol.PackfileObject)
+	store := storage.FromContext(ctx)
+
+	var pending []hash.Hash
+	for _, want := range opts.Want {
+		if store != nil && !opts.NoCache {
+			if obj, ok := store.Get(want); ok {
+				out[want.String()] = obj
+				continue
+			}
+		}
+		pending = append(pending, want)
+	}
+	if len(pending) == 0 {
+		return out, nil
+	}
+	r.fetches = append(r.fetches, pending)
+	if r.fetchHook != nil {
+		if err := r.fetchHook(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	var addReachable func(h hash.Hash)
+	addReachable = func(h hash.Hash) {
+		obj, ok := r.objects[h.String()]
+		if !ok || out[h.String()] != nil {
+			return
+		}
+		if obj.Type == protocol.ObjectTypeBlob && opts.NoBlobFilter {
+			return
+		}
+		out[h.String()] = obj
+		if opts.NoTreeFilter {
+			return
+		}
+		switch obj.Type {
+		case protocol.ObjectTypeCommit:
+			addReachable(obj.Commit.Tree)
+		case protocol.ObjectTypeTree:
+			for _, e := range obj.Tree {
+				if e.FileMode == 0o160000 {
+					continue
+				}
+				eh, err := hash.FromHex(e.Hash)
+				require.NoError(r.t, err)
+				addReachable(eh)
+			}
+		}
+	}
+
+	for _, want := range pending {
+		obj, ok := r.objects[want.String()]
+		if !ok {
+			return nil, fmt.Errorf("ERR not our ref %s", want.String())
+		}
+		if opts.NoExtraObjects || (opts.NoTreeFilter && obj.Type != protocol.ObjectTypeCommit) {
+			out[want.String()] = obj
+			continue
+		}
+		if obj.Type != protocol.ObjectTypeCommit {
+			addReachable(want)
+			continue
+		}
+
+		depth := opts.Deepen
+		if depth <= 0 {
+			depth = 1 << 30
+		}
+		level := []hash.Hash{want}
+		seen := map[string]bool{}
+		for d := 0; d < depth && len(level) > 0; d++ {
+			var next []hash.Hash
+			for _, h := range level {
+				if seen[h.String()] {
+					continue
+				}
+				seen[h.String()] = true
+				addReachable(h)
+				if c := r.objects[h.String()]; c != nil && c.Commit != nil {
+					next = append(next, c.Commit.Parents...)
+				}
+			}
+			level = next
+		}
+	}
+
+	if store != nil {
+		for _, obj := range out {
+			store.Add(obj)
+		}
+	}
+	return out, nil
+}
+
+// ObjectInfo serves the sizes of the known objects when objectInfo is set.
+func (r *testRepo) ObjectInfo(ctx context.Context, hashes []hash.Hash) (map[hash.Hash]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.objectInfo {
+		return nil, client.ErrObjectInfoUnsupported
+	}
+	sizes := make(map[hash.Hash]int64, len(hashes))
+	for _, h := range hashes {
+		if obj, ok := r.objects[h.String()]; ok {
+			sizes[h] = int64(len(obj.Data))
+		}
+	}
+	return sizes, nil
+}
+
+// LsRefs serves the advertised references filtered by prefix.
+func (r *testRepo) LsRefs(ctx context.Context, opts client.LsRefsOptions) ([]protocol.RefLine, error) {
+	var lines []protocol.RefLine
+	for _, ref := range r.refs {
+		if strings.HasPrefix(ref.RefName, opts.Prefix) {
+			lines = append(lines, ref)
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].RefName < lines[j].RefName })
+	return lines, nil
+}
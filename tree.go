@@ -672,10 +672,26 @@ func (c *httpClient) findRootTree(ctx context.Context, commitHash hash.Hash, all
 		return nil, fmt.Errorf("parsing tree hash: %w", err)
 	}
 
-	// Check if the tree object is already in our available objects
+	// Check if the tree object is already in our available objects. A
+	// commit stored by a history walk, which fetches no trees, comes
+	// without it.
 	treeObj, exists := allObjects.GetByType(treeHash, protocol.ObjectTypeTree)
 	if !exists {
-		return nil, NewObjectNotFoundError(treeHash)
+		logger.Debug("Fetch root tree of stored commit",
+			"commit_hash", commitHash.String(),
+			"tree_hash", treeHash.String())
+		if _, err := c.Fetch(ctx, client.FetchOptions{
+			NoProgress:       true,
+			NoBlobFilter:     true,
+			Want:             []hash.Hash{treeHash},
+			Done:             true,
+			MaxResponseBytes: c.limits.MultiObjectFetchMaxBytes,
+		}); err != nil {
+			return nil, fmt.Errorf("fetch tree %s: %w", treeHash.String(), err)
+		}
+		if treeObj, exists = allObjects.GetByType(treeHash, protocol.ObjectTypeTree); !exists {
+			return nil, NewObjectNotFoundError(treeHash)
+		}
 	}
 
 	logger.Debug("resolved commit to tree",
@@ -0,0 +1,309 @@
+package nanogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/client"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/grafana/nanogit/storage"
+)
+
+// defaultWalkBatchSize is how many tree objects WalkTree requests at once
+// when it has to fetch a directory, matching GetFlatTree's batch size.
+const defaultWalkBatchSize = 10
+
+var (
+	// SkipDir can be returned by a WalkFunc. When returned for a directory,
+	// WalkTree does not descend into it; when returned for a file, WalkTree
+	// skips the remaining entries of the directory containing it.
+	// It is the same value as fs.SkipDir.
+	SkipDir = fs.SkipDir
+
+	// SkipAll can be returned by a WalkFunc to stop the walk. WalkTree then
+	// returns nil. It is the same value as fs.SkipAll.
+	SkipAll = fs.SkipAll
+)
+
+// WalkFunc is the callback WalkTree invokes for every entry it visits. The
+// entry's Path is relative to the repository root, even when the walk
+// starts at a subdirectory. Returning SkipDir or SkipAll changes the walk as
+// documented on those values; any other error stops the walk and is
+// returned by WalkTree.
+type WalkFunc func(entry FlatTreeEntry) error
+
+// WalkTreeOptions configures WalkTree.
+type WalkTreeOptions struct {
+	// Path is the directory to start the walk from, relative to the root tree.
+	// If empty, the walk starts at the root.
+	Path string
+	// MaxDepth limits how many directory levels below Path are visited.
+	// 1 visits only the direct children of Path. If 0, there is no limit.
+	MaxDepth int
+	// BatchSize is the maximum number of tree objects fetched in a single
+	// request. If 0, defaults to 10.
+	BatchSize int
+}
+
+// WalkTree walks the tree of a commit (or a tree hash directly) depth-first,
+// calling fn for every file and directory in tree order. Unlike GetFlatTree,
+// directories are fetched only when the walk enters them, so skipping a
+// subtree with SkipDir, stopping with SkipAll, starting at a subdirectory or
+// limiting the depth avoids fetching the rest of the repository.
+//
+// When the walk enters a directory that is not in storage yet, the next
+// sibling directories are fetched in the same request (up to BatchSize), as
+// they are usually visited next. Submodule entries are skipped, as in
+// GetTree. All fetched trees are kept in the storage from the context.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - rootHash: Hash of the commit or root tree to walk
+//   - fn: Callback invoked for every entry
+//   - opts: Start path, depth limit and batch size
+//
+// Returns:
+//   - error: The first error returned by fn (other than SkipDir/SkipAll), or an error if a tree cannot be fetched
+//
+// Example:
+//
+//	// Find all Go files under pkg/, without descending into testdata directories
+//	err := client.WalkTree(ctx, commitHash, func(entry nanogit.FlatTreeEntry) error {
+//	    if entry.Type == protocol.ObjectTypeTree && entry.Name == "testdata" {
+//	        return nanogit.SkipDir
+//	    }
+//	    if strings.HasSuffix(entry.Path, ".go") {
+//	        fmt.Println(entry.Path)
+//	    }
+//	    return nil
+//	}, nanogit.WalkTreeOptions{Path: "pkg"})
+func (c *httpClient) WalkTree(ctx context.Context, rootHash hash.Hash, fn WalkFunc, opts WalkTreeOptions) error {
+	logger := log.FromContext(ctx)
+	logger.Debug("Walk tree",
+		"root_hash", rootHash.String(),
+		"start_path", opts.Path,
+		"max_depth", opts.MaxDepth)
+
+	ctx, allObjects := storage.FromContextOrInMemory(ctx)
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultWalkBatchSize
+	}
+
+	w := &treeWalker{
+		client:    c,
+		storage:   allObjects,
+		fn:        fn,
+		maxDepth:  opts.MaxDepth,
+		batchSize: batchSize,
+	}
+
+	rootTree, err := w.rootTree(ctx, rootHash)
+	if err != nil {
+		return err
+	}
+
+	startPath := strings.Trim(opts.Path, "/")
+	if startPath == "." {
+		startPath = ""
+	}
+
+	start := rootTree
+	if startPath != "" {
+		tree, err := c.GetTreeByPath(ctx, rootTree.Hash, startPath)
+		if err != nil {
+			return fmt.Errorf("get start tree %q: %w", startPath, err)
+		}
+		start, err = w.tree(ctx, tree.Hash, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.walkDir(ctx, start, startPath, 1)
+	if errors.Is(err, SkipAll) || errors.Is(err, SkipDir) {
+		err = nil
+	}
+
+	logger.Debug("Tree walk completed",
+		"root_hash", rootHash.String(),
+		"visited_count", w.visited,
+		"fetch_count", w.fetches)
+	return err
+}
+
+// treeWalker holds the state of a single WalkTree call.
+type treeWalker struct {
+	client    *httpClient
+	storage   storage.PackfileStorage
+	fn        WalkFunc
+	maxDepth  int
+	batchSize int
+	visited   int
+	fetches   int
+}
+
+// rootTree returns the tree to walk for a commit or tree hash.
+func (w *treeWalker) rootTree(ctx context.Context, rootHash hash.Hash) (*protocol.PackfileObject, error) {
+	obj, ok := w.storage.Get(rootHash)
+	if !ok {
+		objects, err := w.client.Fetch(ctx, client.FetchOptions{
+			NoProgress:       true,
+			NoBlobFilter:     true,
+			Want:             []hash.Hash{rootHash},
+			Done:             true,
+			NoExtraObjects:   true,
+			MaxResponseBytes: w.client.limits.SingleObjectFetchMaxBytes,
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "not our ref") {
+				return nil, NewObjectNotFoundError(rootHash)
+			}
+			return nil, fmt.Errorf("fetch root %s: %w", rootHash.String(), err)
+		}
+		w.fetches++
+
+		obj, ok = objects[rootHash.String()]
+		if !ok {
+			return nil, NewObjectNotFoundError(rootHash)
+		}
+	}
+
+	switch obj.Type {
+	case protocol.ObjectTypeTree:
+		return obj, nil
+	case protocol.ObjectTypeCommit:
+		return w.tree(ctx, obj.Commit.Tree, nil)
+	default:
+		return nil, NewUnexpectedObjectTypeError(rootHash, protocol.ObjectTypeTree, obj.Type)
+	}
+}
+
+// walkDir visits the entries of tree, whose path is dirPath, and recurses
+// into subdirectories. depth is the depth of the entries being visited.
+func (w *treeWalker) walkDir(ctx context.Context, tree *protocol.PackfileObject, dirPath string, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i, e := range tree.Tree {
+		if e.FileMode == 0o160000 {
+			continue
+		}
+
+		entryHash, err := hash.FromHex(e.Hash)
+		if err != nil {
+			return fmt.Errorf("parse hash of %s: %w", path.Join(dirPath, e.FileName), err)
+		}
+
+		entry := FlatTreeEntry{
+			Name: e.FileName,
+			Path: path.Join(dirPath, e.FileName),
+			Mode: e.FileMode,
+			Hash: entryHash,
+			Type: protocol.ObjectTypeBlob,
+		}
+		if e.FileMode == 0o40000 {
+			entry.Type = protocol.ObjectTypeTree
+		}
+
+		w.visited++
+		if err := w.fn(entry); err != nil {
+			if errors.Is(err, SkipDir) {
+				if entry.Type == protocol.ObjectTypeTree {
+					continue
+				}
+				return nil
+			}
+			return err
+		}
+
+		if entry.Type != protocol.ObjectTypeTree || (w.maxDepth > 0 && depth >= w.maxDepth) {
+			continue
+		}
+
+		subtree, err := w.tree(ctx, entryHash, tree.Tree[i+1:])
+		if err != nil {
+			return fmt.Errorf("get tree %q: %w", entry.Path, err)
+		}
+
+		if err := w.walkDir(ctx, subtree, entry.Path, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tree returns a tree object from storage or fetches it, together with the
+// missing directories among siblings, which the walk is likely to enter next.
+func (w *treeWalker) tree(ctx context.Context, treeHash hash.Hash, siblings []protocol.PackfileTreeEntry) (*protocol.PackfileObject, error) {
+	if obj, ok := w.storage.GetByType(treeHash, protocol.ObjectTypeTree); ok {
+		return obj, nil
+	}
+
+	batch := []hash.Hash{treeHash}
+	for _, s := range siblings {
+		if len(batch) >= w.batchSize {
+			break
+		}
+		if s.FileMode != 0o40000 {
+			continue
+		}
+		h, err := hash.FromHex(s.Hash)
+		if err != nil {
+			continue
+		}
+		if _, ok := w.storage.Get(h); !ok {
+			batch = append(batch, h)
+		}
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Fetch tree batch for walk",
+		"tree_hash", treeHash.String(),
+		"batch_size", len(batch))
+
+	objects, err := w.client.Fetch(ctx, client.FetchOptions{
+		NoProgress:       true,
+		NoBlobFilter:     true,
+		Want:             batch,
+		Done:             true,
+		NoExtraObjects:   true,
+		MaxResponseBytes: w.client.limits.MultiObjectFetchMaxBytes,
+	})
+	w.fetches++
+	if err != nil {
+		if len(batch) == 1 {
+			if strings.Contains(err.Error(), "not our ref") {
+				return nil, NewObjectNotFoundError(treeHash)
+			}
+			return nil, fmt.Errorf("fetch tree %s: %w", treeHash.String(), err)
+		}
+
+		// Fall back to fetching only the tree we need so a problem with a
+		// speculative sibling does not fail the walk.
+		logger.Debug("Tree batch failed, fetching individually",
+			"tree_hash", treeHash.String(),
+			"error", err)
+		w.fetches++
+		return w.client.getTree(ctx, treeHash)
+	}
+
+	obj, ok := objects[treeHash.String()]
+	if !ok {
+		return nil, NewObjectNotFoundError(treeHash)
+	}
+	if obj.Type != protocol.ObjectTypeTree {
+		return nil, NewUnexpectedObjectTypeError(treeHash, protocol.ObjectTypeTree, obj.Type)
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,202 @@
+package nanogit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWalkTestRepo(t *testing.T) (*testRepo, hash.Hash) {
+	t.Helper()
+	repo := newTestRepo(t)
+	commit := repo.commit("initial", map[string]string{
+		"README.md":            "readme",
+		"docs/guide.md":        "guide",
+		"docs/api/index.md":    "api",
+		"pkg/a/a.go":           "package a",
+		"pkg/a/testdata/x.txt": "x",
+		"pkg/b/b.go":           "package b",
+		"scripts/run.sh":       "exec:#!/bin/sh",
+	})
+	return repo, commit
+}
+
+func collectWalk(t *testing.T, repo *testRepo, root hash.Hash, opts WalkTreeOptions, fn func(FlatTreeEntry) error) []string {
+	t.Helper()
+	var paths []string
+	err := repo.client().WalkTree(context.Background(), root, func(entry FlatTreeEntry) error {
+		paths = append(paths, entry.Path)
+		if fn != nil {
+			return fn(entry)
+		}
+		return nil
+	}, opts)
+	require.NoError(t, err)
+	return paths
+}
+
+func TestWalkTree(t *testing.T) {
+	t.Parallel()
+
+	t.Run("visits every entry depth-first in tree order", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+
+		paths := collectWalk(t, repo, commit, WalkTreeOptions{}, nil)
+		assert.Equal(t, []string{
+			"README.md",
+			"docs",
+			"docs/api",
+			"docs/api/index.md",
+			"docs/guide.md",
+			"pkg",
+			"pkg/a",
+			"pkg/a/a.go",
+			"pkg/a/testdata",
+			"pkg/a/testdata/x.txt",
+			"pkg/b",
+			"pkg/b/b.go",
+			"scripts",
+			"scripts/run.sh",
+		}, paths)
+	})
+
+	t.Run("accepts a tree hash", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+		treeHash := repo.objects[commit.String()].Commit.Tree
+
+		paths := collectWalk(t, repo, treeHash, WalkTreeOptions{MaxDepth: 1}, nil)
+		assert.Equal(t, []string{"README.md", "docs", "pkg", "scripts"}, paths)
+	})
+
+	t.Run("reports entry details", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+
+		entries := map[string]FlatTreeEntry{}
+		err := repo.client().WalkTree(context.Background(), commit, func(entry FlatTreeEntry) error {
+			entries[entry.Path] = entry
+			return nil
+		}, WalkTreeOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, "run.sh", entries["scripts/run.sh"].Name)
+		assert.Equal(t, uint32(0o100755), entries["scripts/run.sh"].Mode)
+		assert.Equal(t, protocol.ObjectTypeBlob, entries["scripts/run.sh"].Type)
+		assert.Equal(t, repo.blob("#!/bin/sh"), entries["scripts/run.sh"].Hash)
+		assert.Equal(t, protocol.ObjectTypeTree, entries["docs"].Type)
+		assert.Equal(t, uint32(0o40000), entries["docs"].Mode)
+	})
+
+	t.Run("SkipDir on a directory skips its subtree without fetching it", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+		testdataHash := ""
+
+		paths := collectWalk(t, repo, commit, WalkTreeOptions{BatchSize: 1}, func(entry FlatTreeEntry) error {
+			if entry.Type == protocol.ObjectTypeTree && entry.Name == "testdata" {
+				testdataHash = entry.Hash.String()
+				return SkipDir
+			}
+			return nil
+		})
+		assert.Contains(t, paths, "pkg/a/testdata")
+		assert.NotContains(t, paths, "pkg/a/testdata/x.txt")
+		assert.Contains(t, paths, "pkg/b/b.go")
+
+		for _, wants := range repo.fetches {
+			for _, want := range wants {
+				assert.NotEqual(t, testdataHash, want.String(), "skipped directory should not be fetched")
+			}
+		}
+	})
+
+	t.Run("SkipDir on a file skips the rest of its directory", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+
+		paths := collectWalk(t, repo, commit, WalkTreeOptions{}, func(entry FlatTreeEntry) error {
+			if entry.Path == "docs/api/index.md" {
+				return SkipDir
+			}
+			return nil
+		})
+		assert.Contains(t, paths, "docs/api/index.md")
+		assert.Contains(t, paths, "docs/guide.md")
+	})
+
+	t.Run("SkipAll stops the walk", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+
+		paths := collectWalk(t, repo, commit, WalkTreeOptions{}, func(entry FlatTreeEntry) error {
+			if entry.Path == "docs/guide.md" {
+				return SkipAll
+			}
+			return nil
+		})
+		assert.Equal(t, "docs/guide.md", paths[len(paths)-1])
+		assert.NotContains(t, paths, "pkg")
+	})
+
+	t.Run("starts at a subdirectory", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+
+		paths := collectWalk(t, repo, commit, WalkTreeOptions{Path: "pkg/"}, nil)
+		assert.Equal(t, []string{
+			"pkg/a",
+			"pkg/a/a.go",
+			"pkg/a/testdata",
+			"pkg/a/testdata/x.txt",
+			"pkg/b",
+			"pkg/b/b.go",
+		}, paths)
+	})
+
+	t.Run("limits the depth", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+
+		paths := collectWalk(t, repo, commit, WalkTreeOptions{Path: "pkg", MaxDepth: 2}, nil)
+		assert.Equal(t, []string{"pkg/a", "pkg/a/a.go", "pkg/a/testdata", "pkg/b", "pkg/b/b.go"}, paths)
+	})
+
+	t.Run("fetches sibling directories together", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+
+		collectWalk(t, repo, commit, WalkTreeOptions{MaxDepth: 2}, nil)
+		// commit, root tree, then docs+pkg+scripts in one batch.
+		require.Len(t, repo.fetches, 3)
+		assert.Len(t, repo.fetches[2], 3)
+	})
+
+	t.Run("returns callback errors", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+		boom := errors.New("boom")
+
+		err := repo.client().WalkTree(context.Background(), commit, func(entry FlatTreeEntry) error {
+			if entry.Path == "pkg/a/a.go" {
+				return boom
+			}
+			return nil
+		}, WalkTreeOptions{})
+		require.ErrorIs(t, err, boom)
+	})
+
+	t.Run("missing start path", func(t *testing.T) {
+		t.Parallel()
+		repo, commit := newWalkTestRepo(t)
+
+		err := repo.client().WalkTree(context.Background(), commit, func(FlatTreeEntry) error { return nil }, WalkTreeOptions{Path: "nope"})
+		require.ErrorIs(t, err, ErrObjectNotFound)
+	})
+}
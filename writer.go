@@ -27,6 +27,11 @@ var ErrWriterCleanedUp = errors.New("writer has been cleaned up and can no longe
 // The writer maintains an in-memory representation of the repository state and
 // tracks all changes until they are committed and pushed.
 //
+// A ref with a zero hash, such as the first branch of an empty repository,
+// starts from an empty tree, and its first commit has no parent; Push then
+// creates the ref. WithOrphan together with WithReplaceHistory does the
+// same for a ref that exists, whose history Push replaces.
+//
 // Example usage:
 //
 //	writer, err := client.NewStagedWriter(ctx, ref)
@@ -62,24 +67,81 @@ func (c *httpClient) NewStagedWriter(ctx context.Context, ref Ref, options ...Wr
 
 	ctx, objStorage := storage.FromContextOrInMemory(ctx)
 
+	w := &stagedWriter{
+		client:     c,
+		ref:        ref,
+		objStorage: objStorage,
+		signer:     opts.signer,
+		autoRebase: opts.AutoRebaseAttempts,
+	}
+	base := ref.Hash
+	if opts.Orphan {
+		if ref.Hash != hash.Zero && !opts.ReplaceHistory {
+			return nil, fmt.Errorf("orphan writer would replace the history of the ref: %w", NewRefAlreadyExistsError(ref.Name))
+		}
+		base = hash.Zero
+	}
+	if err := w.reset(ctx, base); err != nil {
+		return nil, err
+	}
+
+	logger.Debug("Staged writer ready",
+		"ref_name", ref.Name,
+		"commit_hash", w.lastCommit.Hash.String(),
+		"tree_hash", w.lastTree.Hash.String(),
+		"tree_entries", len(w.treeEntries),
+		"submodule_entries", len(w.submoduleEntries))
+
+	// Convert writer storage mode to protocol storage mode
+	var protocolStorageMode protocol.PackfileStorageMode
+	switch opts.StorageMode {
+	case PackfileStorageMemory:
+		protocolStorageMode = protocol.PackfileStorageMemory
+	case PackfileStorageDisk:
+		protocolStorageMode = protocol.PackfileStorageDisk
+	case PackfileStorageAuto:
+		protocolStorageMode = protocol.PackfileStorageAuto
+	default:
+		protocolStorageMode = protocol.PackfileStorageAuto
+	}
+
+	caps, err := c.effectiveReceivePackCapabilities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolve receive-pack capabilities: %w", err)
+	}
+	w.storageMode = protocolStorageMode
+	w.writer = protocol.NewPackfileWriter(crypto.SHA1, protocolStorageMode, caps...)
+	return w, nil
+}
+
+// reset loads the commit at commitHash and its tree as the state the
+// writer stages changes on top of, dropping staged changes that were not
+// committed. Objects already added to the packfile are kept. A zero hash
+// starts from an empty tree with no commit, so that the next commit has no
+// parent.
+func (w *stagedWriter) reset(ctx context.Context, commitHash hash.Hash) error {
+	if commitHash == hash.Zero {
+		return w.resetEmpty()
+	}
+
 	// Get essential objects - fetch commit, root tree, and flat tree
-	commit, err := c.getCommit(ctx, ref.Hash, false)
+	commit, err := w.client.getCommit(ctx, commitHash, false)
 	if err != nil {
-		return nil, fmt.Errorf("get commit %s: %w", ref.Hash.String(), err)
+		return fmt.Errorf("get commit %s: %w", commitHash.String(), err)
 	}
 
-	treeObj, err := c.getTree(ctx, commit.Tree)
+	treeObj, err := w.client.getTree(ctx, commit.Tree)
 	if err != nil {
-		return nil, fmt.Errorf("get tree %s: %w", commit.Tree.String(), err)
+		return fmt.Errorf("get tree %s: %w", commit.Tree.String(), err)
 	}
 
 	// Get the flat tree representation for efficient path-based operations.
 	// We use the internal variant so we also receive the submodule (gitlink)
 	// entries that GetFlatTree filters out — without them the writer would
 	// drop submodules from any rebuilt parent tree (grafana/grafana#123891).
-	currentTree, submoduleList, err := c.getFlatTreeWithSubmodules(ctx, commit.Hash)
+	currentTree, submoduleList, err := w.client.getFlatTreeWithSubmodules(ctx, commit.Hash)
 	if err != nil {
-		return nil, fmt.Errorf("get flat tree for commit %s: %w", commit.Hash.String(), err)
+		return fmt.Errorf("get flat tree for commit %s: %w", commit.Hash.String(), err)
 	}
 
 	// Build tree entries map from flat tree. Index over the slice so the
@@ -101,44 +163,38 @@ func (c *httpClient) NewStagedWriter(ctx context.Context, ref Ref, options ...Wr
 		submodules[submoduleList[i].Path] = &submoduleList[i]
 	}
 
-	logger.Debug("Staged writer ready",
-		"ref_name", ref.Name,
-		"commit_hash", commit.Hash.String(),
-		"tree_hash", treeObj.Hash.String(),
-		"tree_entries", len(entries),
-		"submodule_entries", len(submodules))
+	w.lastCommit = commit
+	w.lastTree = treeObj
+	w.treeEntries = entries
+	w.submoduleEntries = submodules
+	w.dirtyPaths = make(map[string]bool) // Initialize dirty paths tracking for deferred tree building
+	w.staged = nil
+	w.base = w.files()
+	w.discardCheckpoints()
+	return nil
+}
 
-	// Convert writer storage mode to protocol storage mode
-	var protocolStorageMode protocol.PackfileStorageMode
-	switch opts.StorageMode {
-	case PackfileStorageMemory:
-		protocolStorageMode = protocol.PackfileStorageMemory
-	case PackfileStorageDisk:
-		protocolStorageMode = protocol.PackfileStorageDisk
-	case PackfileStorageAuto:
-		protocolStorageMode = protocol.PackfileStorageAuto
-	default:
-		protocolStorageMode = protocol.PackfileStorageAuto
+// resetEmpty starts the writer from an empty tree with no commit, as on an
+// empty repository or a new orphan branch.
+func (w *stagedWriter) resetEmpty() error {
+	emptyHash, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeTree, []byte{})
+	if err != nil {
+		return fmt.Errorf("create empty tree: %w", err)
 	}
 
-	caps, err := c.effectiveReceivePackCapabilities(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("resolve receive-pack capabilities: %w", err)
+	w.lastCommit = &Commit{Tree: emptyHash}
+	w.lastTree = &protocol.PackfileObject{
+		Hash: emptyHash,
+		Type: protocol.ObjectTypeTree,
+		Tree: []protocol.PackfileTreeEntry{},
 	}
-	writer := protocol.NewPackfileWriter(crypto.SHA1, protocolStorageMode, caps...)
-	return &stagedWriter{
-		client:           c,
-		ref:              ref,
-		writer:           writer,
-		lastCommit:       commit,
-		lastTree:         treeObj,
-		objStorage:       objStorage,
-		treeEntries:      entries,
-		submoduleEntries: submodules,
-		storageMode:      protocolStorageMode,
-		dirtyPaths:       make(map[string]bool), // Initialize dirty paths tracking for deferred tree building
-		signer:           opts.signer,
-	}, nil
+	w.treeEntries = make(map[string]*FlatTreeEntry)
+	w.submoduleEntries = make(map[string]*FlatTreeEntry)
+	w.dirtyPaths = make(map[string]bool)
+	w.staged = nil
+	w.base = nil
+	w.discardCheckpoints()
+	return nil
 }
 
 // stagedWriter implements the StagedWriter interface.
@@ -179,6 +235,21 @@ type stagedWriter struct {
 	// Deferred tree building optimization: track which directory paths need tree rebuilding
 	dirtyPaths map[string]bool
 	signer     signing.Signer
+	// Number of times Push rebases onto a moved ref and retries, see WithAutoRebase
+	autoRebase int
+	// Operations staged since the last commit, and the commits not pushed yet,
+	// recorded so that they can be replayed on top of a moved ref
+	staged   []writerOp
+	unpushed []stagedCommit
+	// Files and submodules of the commit the ref points at, which Status
+	// and Diff compare the staged state with
+	base []FlatTreeEntry
+	// Ids of the checkpoints Rollback accepts, oldest first, and the id of
+	// the last checkpoint taken
+	checkpoints   []int
+	checkpointSeq int
+	// Changes made since the oldest checkpoint, for Rollback to undo
+	undo []writerUndo
 }
 
 // checkCleanupState returns an error if the writer has been cleaned up.
@@ -235,6 +306,7 @@ func (w *stagedWriter) BlobExists(ctx context.Context, path string) (bool, error
 //   - ctx: Context for the operation
 //   - path: File path where the blob should be created (e.g., "docs/readme.md")
 //   - content: Raw content of the file as bytes
+//   - options: Options such as WithExecutable or WithSymlink; the default is a regular file
 //
 // Returns:
 //   - hash.Hash: The SHA-1 hash of the created blob object
@@ -243,7 +315,8 @@ func (w *stagedWriter) BlobExists(ctx context.Context, path string) (bool, error
 // Example:
 //
 //	hash, err := writer.CreateBlob(ctx, "src/main.go", []byte("package main\n"))
-func (w *stagedWriter) CreateBlob(ctx context.Context, path string, content []byte) (hash.Hash, error) {
+//	hash, err = writer.CreateBlob(ctx, "scripts/build.sh", script, nanogit.WithExecutable())
+func (w *stagedWriter) CreateBlob(ctx context.Context, path string, content []byte, options ...BlobOption) (hash.Hash, error) {
 	if err := w.checkCleanupState(); err != nil {
 		return hash.Zero, err
 	}
@@ -252,6 +325,15 @@ func (w *stagedWriter) CreateBlob(ctx context.Context, path string, content []by
 		return hash.Zero, ErrEmptyPath
 	}
 
+	opts, err := applyBlobOptions(options)
+	if err != nil {
+		return hash.Zero, err
+	}
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0o100644
+	}
+
 	logger := log.FromContext(ctx)
 	logger.Debug("Create blob",
 		"path", path,
@@ -266,15 +348,83 @@ func (w *stagedWriter) CreateBlob(ctx context.Context, path string, content []by
 		return hash.Zero, fmt.Errorf("create blob at %q: %w", path, err)
 	}
 
-	w.treeEntries[path] = &FlatTreeEntry{
-		Path: path,
-		Hash: blobHash,
-		Type: protocol.ObjectTypeBlob,
-		Mode: 0o100644,
+	if err := w.stageBlob(ctx, writerOp{kind: writerOpCreateBlob, path: path, hash: blobHash, mode: mode}); err != nil {
+		return hash.Zero, err
 	}
 
-	if err := w.addMissingOrStaleTreeEntries(ctx, path, blobHash); err != nil {
-		return hash.Zero, fmt.Errorf("update tree structure for %q: %w", path, err)
+	logger.Debug("Blob created",
+		"path", path,
+		"blob_hash", blobHash.String())
+
+	return blobHash, nil
+}
+
+// CreateBlobFromReader creates a new blob object at the specified path with
+// size bytes of content read from r. Unlike CreateBlob, the content is not
+// held in memory: with disk storage (WithDiskStorage) or the default auto
+// storage, it is hashed and compressed into the packfile's temporary file
+// as it is read. With memory storage it is read into memory.
+//
+// This operation stages the blob creation but does not immediately commit it.
+// You must call Commit() and Push() to persist the changes.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - path: File path where the blob should be created (e.g., "dist/app.tar.gz")
+//   - r: Reader of the content, which must hold exactly size bytes
+//   - size: Size of the content in bytes
+//   - options: Options such as WithExecutable or WithSymlink; the default is a regular file
+//
+// Returns:
+//   - hash.Hash: The SHA-1 hash of the created blob object
+//   - error: Error if the path already exists, if r does not hold size bytes or if blob creation fails
+//
+// Example:
+//
+//	f, err := os.Open("dist/app.tar.gz")
+//	if err != nil {
+//	    return err
+//	}
+//	defer f.Close()
+//	info, err := f.Stat()
+//	if err != nil {
+//	    return err
+//	}
+//	hash, err := writer.CreateBlobFromReader(ctx, "dist/app.tar.gz", f, info.Size())
+func (w *stagedWriter) CreateBlobFromReader(ctx context.Context, path string, r io.Reader, size int64, options ...BlobOption) (hash.Hash, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return hash.Zero, err
+	}
+
+	if path == "" {
+		return hash.Zero, ErrEmptyPath
+	}
+
+	opts, err := applyBlobOptions(options)
+	if err != nil {
+		return hash.Zero, err
+	}
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0o100644
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Create blob from reader",
+		"path", path,
+		"content_size", size)
+
+	if obj, ok := w.treeEntries[path]; ok {
+		return hash.Zero, NewObjectAlreadyExistsError(obj.Hash)
+	}
+
+	blobHash, err := w.writer.AddBlobFromReader(r, size)
+	if err != nil {
+		return hash.Zero, fmt.Errorf("create blob at %q: %w", path, err)
+	}
+
+	if err := w.stageBlob(ctx, writerOp{kind: writerOpCreateBlob, path: path, hash: blobHash, mode: mode}); err != nil {
+		return hash.Zero, err
 	}
 
 	logger.Debug("Blob created",
@@ -294,6 +444,7 @@ func (w *stagedWriter) CreateBlob(ctx context.Context, path string, content []by
 //   - ctx: Context for the operation
 //   - path: File path of the existing blob to update
 //   - content: New content for the file as bytes
+//   - options: Options such as WithExecutable or WithSymlink; the default keeps the mode of the file
 //
 // Returns:
 //   - hash.Hash: The SHA-1 hash of the updated blob object
@@ -302,7 +453,7 @@ func (w *stagedWriter) CreateBlob(ctx context.Context, path string, content []by
 // Example:
 //
 //	hash, err := writer.UpdateBlob(ctx, "README.md", []byte("Updated content"))
-func (w *stagedWriter) UpdateBlob(ctx context.Context, path string, content []byte) (hash.Hash, error) {
+func (w *stagedWriter) UpdateBlob(ctx context.Context, path string, content []byte, options ...BlobOption) (hash.Hash, error) {
 	if err := w.checkCleanupState(); err != nil {
 		return hash.Zero, err
 	}
@@ -311,29 +462,35 @@ func (w *stagedWriter) UpdateBlob(ctx context.Context, path string, content []by
 		return hash.Zero, ErrEmptyPath
 	}
 
+	opts, err := applyBlobOptions(options)
+	if err != nil {
+		return hash.Zero, err
+	}
+
 	logger := log.FromContext(ctx)
 	logger.Debug("Update blob",
 		"path", path,
 		"content_size", len(content))
 
-	if w.treeEntries[path] == nil {
+	existing := w.treeEntries[path]
+	if existing == nil {
 		return hash.Zero, NewPathNotFoundError(path)
 	}
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0o100644
+		if existing.Type == protocol.ObjectTypeBlob {
+			mode = existing.Mode
+		}
+	}
 
 	blobHash, err := w.writer.AddBlob(content)
 	if err != nil {
 		return hash.Zero, fmt.Errorf("create blob at %q: %w", path, err)
 	}
 
-	w.treeEntries[path] = &FlatTreeEntry{
-		Path: path,
-		Hash: blobHash,
-		Type: protocol.ObjectTypeBlob,
-		Mode: 0o100644,
-	}
-
-	if err := w.addMissingOrStaleTreeEntries(ctx, path, blobHash); err != nil {
-		return hash.Zero, fmt.Errorf("update tree structure for %q: %w", path, err)
+	if err := w.stageBlob(ctx, writerOp{kind: writerOpUpdateBlob, path: path, hash: blobHash, mode: mode}); err != nil {
+		return hash.Zero, err
 	}
 
 	logger.Debug("Blob updated",
@@ -343,6 +500,58 @@ func (w *stagedWriter) UpdateBlob(ctx context.Context, path string, content []by
 	return blobHash, nil
 }
 
+// SetMode changes the mode of the file at the specified path, keeping its
+// content. A symbolic link's content is the path it points to, so turning a
+// file into a link (or back) reinterprets the same bytes.
+//
+// This operation stages the mode change but does not immediately commit it.
+// You must call Commit() and Push() to persist the changes.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - path: File path of the existing blob
+//   - mode: 0o100644 for a regular file, 0o100755 for an executable file or 0o120000 for a symbolic link
+//
+// Returns:
+//   - error: ErrInvalidFileMode for another mode, or an error if the path is not a file
+//
+// Example:
+//
+//	err := writer.SetMode(ctx, "scripts/build.sh", 0o100755)
+func (w *stagedWriter) SetMode(ctx context.Context, path string, mode uint32) error {
+	if err := w.checkCleanupState(); err != nil {
+		return err
+	}
+
+	if path == "" {
+		return ErrEmptyPath
+	}
+
+	if err := validateBlobMode(mode); err != nil {
+		return err
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Set mode",
+		"path", path,
+		"mode", fmt.Sprintf("%o", mode))
+
+	entry, ok := w.treeEntries[path]
+	if !ok {
+		return NewPathNotFoundError(path)
+	}
+
+	if entry.Type != protocol.ObjectTypeBlob {
+		return NewUnexpectedObjectTypeError(entry.Hash, protocol.ObjectTypeBlob, entry.Type)
+	}
+
+	if entry.Mode == mode {
+		return nil
+	}
+
+	return w.stageBlob(ctx, writerOp{kind: writerOpUpdateBlob, path: path, hash: entry.Hash, mode: mode})
+}
+
 // DeleteBlob removes a blob (file) at the specified path from the repository.
 // The blob must exist and must be a file (not a directory), otherwise an error is returned.
 // If removing the blob leaves empty parent directories, those directories will also be removed.
@@ -384,11 +593,12 @@ func (w *stagedWriter) DeleteBlob(ctx context.Context, path string) (hash.Hash,
 	}
 
 	blobHash := existing.Hash
-	delete(w.treeEntries, path)
+	w.deleteEntry(w.treeEntries, path)
 
 	if err := w.removeBlobFromTree(ctx, path); err != nil {
 		return hash.Zero, fmt.Errorf("remove blob from tree at %q: %w", path, err)
 	}
+	w.staged = append(w.staged, writerOp{kind: writerOpDeleteBlob, path: path})
 
 	logger.Debug("Blob deleted",
 		"path", path,
@@ -458,12 +668,12 @@ func (w *stagedWriter) MoveBlob(ctx context.Context, srcPath, destPath string) (
 	blobHash := srcEntry.Hash
 
 	// Create the blob at the destination path
-	w.treeEntries[destPath] = &FlatTreeEntry{
+	w.setEntry(w.treeEntries, destPath, &FlatTreeEntry{
 		Path: destPath,
 		Hash: blobHash,
 		Type: protocol.ObjectTypeBlob,
 		Mode: srcEntry.Mode,
-	}
+	})
 
 	// Update tree structure for destination
 	if err := w.addMissingOrStaleTreeEntries(ctx, destPath, blobHash); err != nil {
@@ -471,12 +681,13 @@ func (w *stagedWriter) MoveBlob(ctx context.Context, srcPath, destPath string) (
 	}
 
 	// Remove the blob from the source path
-	delete(w.treeEntries, srcPath)
+	w.deleteEntry(w.treeEntries, srcPath)
 
 	// Update tree structure for source removal
 	if err := w.removeBlobFromTree(ctx, srcPath); err != nil {
 		return hash.Zero, fmt.Errorf("remove blob from tree at source %q: %w", srcPath, err)
 	}
+	w.staged = append(w.staged, writerOp{kind: writerOpMoveBlob, path: srcPath, dest: destPath})
 
 	logger.Debug("Blob moved",
 		"src_path", srcPath,
@@ -600,16 +811,20 @@ func (w *stagedWriter) DeleteTree(ctx context.Context, path string) (hash.Hash,
 		// entries and silently re-emit the content (and gitlinks) the
 		// wipe was supposed to remove. dirtyPaths is also dropped — any
 		// previously staged paths are now invalid.
-		clear(w.treeEntries)
-		clear(w.submoduleEntries)
-		clear(w.dirtyPaths)
-		w.treeEntries[""] = &FlatTreeEntry{
+		for _, entries := range []map[string]*FlatTreeEntry{w.treeEntries, w.submoduleEntries} {
+			for entryPath := range entries {
+				w.deleteEntry(entries, entryPath)
+			}
+		}
+		w.clearDirty()
+		w.setEntry(w.treeEntries, "", &FlatTreeEntry{
 			Path: "",
 			Hash: emptyHash,
 			Type: protocol.ObjectTypeTree,
 			Mode: 0o40000,
-		}
+		})
 		w.lastTree = &emptyTree
+		w.staged = append(w.staged, writerOp{kind: writerOpDeleteTree, path: ""})
 
 		return emptyHash, nil
 	}
@@ -639,7 +854,7 @@ func (w *stagedWriter) DeleteTree(ctx context.Context, path string) (hash.Hash,
 	// Remove all entries under this tree
 	for _, entryPath := range entriesToDelete {
 		logger.Debug("removing entry", "path", entryPath)
-		delete(w.treeEntries, entryPath)
+		w.deleteEntry(w.treeEntries, entryPath)
 	}
 
 	// Drop any cached submodule entries that lived under this tree.
@@ -650,7 +865,7 @@ func (w *stagedWriter) DeleteTree(ctx context.Context, path string) (hash.Hash,
 	// runs after, so a single-commit sequence isn't covered by it alone).
 	for entryPath := range w.submoduleEntries {
 		if entryPath == path || strings.HasPrefix(entryPath, pathPrefix) {
-			delete(w.submoduleEntries, entryPath)
+			w.deleteEntry(w.submoduleEntries, entryPath)
 		}
 	}
 
@@ -658,6 +873,7 @@ func (w *stagedWriter) DeleteTree(ctx context.Context, path string) (hash.Hash,
 	if err := w.removeTreeFromTree(ctx, path); err != nil {
 		return hash.Zero, fmt.Errorf("remove tree from entire tree: %w", err)
 	}
+	w.staged = append(w.staged, writerOp{kind: writerOpDeleteTree, path: path})
 
 	return treeHash, nil
 }
@@ -718,6 +934,7 @@ func (w *stagedWriter) MoveTree(ctx context.Context, srcPath, destPath string) (
 	if err := w.updateTreeStructuresForMove(ctx, srcPath, destPath, treeHash); err != nil {
 		return hash.Zero, err
 	}
+	w.staged = append(w.staged, writerOp{kind: writerOpMoveTree, path: srcPath, dest: destPath})
 
 	logger.Debug("Tree moved",
 		"src_path", srcPath,
@@ -783,19 +1000,19 @@ func (w *stagedWriter) moveTreeEntries(ctx context.Context, srcPath, destPath st
 		entry := w.treeEntries[entryPath]
 		newPath := w.calculateNewTreeEntryPath(srcPath, destPath, entryPath)
 
-		w.treeEntries[newPath] = &FlatTreeEntry{
+		w.setEntry(w.treeEntries, newPath, &FlatTreeEntry{
 			Path: newPath,
 			Hash: entry.Hash,
 			Type: entry.Type,
 			Mode: entry.Mode,
-		}
+		})
 
 		logger.Debug("Moved entry", "from", entryPath, "to", newPath, "type", entry.Type)
 	}
 
 	// Remove all entries from their original locations
 	for _, entryPath := range entriesToMove {
-		delete(w.treeEntries, entryPath)
+		w.deleteEntry(w.treeEntries, entryPath)
 	}
 
 	return nil
@@ -830,10 +1047,10 @@ func (w *stagedWriter) reparentSubmodules(srcPath, destPath string) {
 		}
 	}
 	for _, r := range renames {
-		entry := w.submoduleEntries[r.from]
-		delete(w.submoduleEntries, r.from)
+		entry := *w.submoduleEntries[r.from]
+		w.deleteEntry(w.submoduleEntries, r.from)
 		entry.Path = r.to
-		w.submoduleEntries[r.to] = entry
+		w.setEntry(w.submoduleEntries, r.to, &entry)
 	}
 }
 
@@ -878,6 +1095,13 @@ func (w *stagedWriter) updateTreeStructuresForMove(ctx context.Context, srcPath,
 //	}
 //	commit, err := writer.Commit(ctx, "Add new features", author, author)
 func (w *stagedWriter) Commit(ctx context.Context, message string, author Author, committer Committer) (*Commit, error) {
+	return w.commit(ctx, message, author, committer, nil)
+}
+
+// commit creates a commit of the staged changes whose first parent is the
+// last commit, followed by mergeParents. A merge commit is created even
+// when nothing is staged, since its tree may equal the last commit's.
+func (w *stagedWriter) commit(ctx context.Context, message string, author Author, committer Committer, mergeParents []hash.Hash) (*Commit, error) {
 	if err := w.checkCleanupState(); err != nil {
 		return nil, err
 	}
@@ -906,7 +1130,7 @@ func (w *stagedWriter) Commit(ctx context.Context, message string, author Author
 		return nil, fmt.Errorf("build pending trees: %w", err)
 	}
 
-	if !w.writer.HasObjects() {
+	if !w.writer.HasObjects() && len(mergeParents) == 0 {
 		return nil, ErrNothingToCommit
 	}
 
@@ -924,7 +1148,13 @@ func (w *stagedWriter) Commit(ctx context.Context, message string, author Author
 		Timezone:  committer.Time.Format("-0700"),
 	}
 
-	commitHash, err := w.writer.AddCommit(w.lastTree.Hash, w.lastCommit.Hash, &authorIdentity, &committerIdentity, message, w.signer)
+	// The first commit on an empty tree has no parent.
+	var parents []hash.Hash
+	if w.lastCommit.Hash != hash.Zero {
+		parents = append(parents, w.lastCommit.Hash)
+	}
+	parents = append(parents, mergeParents...)
+	commitHash, err := w.writer.AddCommitWithParents(w.lastTree.Hash, parents, &authorIdentity, &committerIdentity, message, w.signer)
 	if err != nil {
 		return nil, fmt.Errorf("create commit object: %w", err)
 	}
@@ -933,10 +1163,22 @@ func (w *stagedWriter) Commit(ctx context.Context, message string, author Author
 		Hash:      commitHash,
 		Tree:      w.lastTree.Hash,
 		Parent:    w.lastCommit.Hash,
+		Parents:   parents,
 		Author:    author,
 		Committer: committer,
 		Message:   message,
 	}
+	w.unpushed = append(w.unpushed, stagedCommit{
+		ops:          w.staged,
+		root:         len(parents) == 0,
+		message:      message,
+		author:       author,
+		committer:    committer,
+		mergeParents: mergeParents,
+		commit:       w.lastCommit,
+		checkpoint:   w.writer.Checkpoint(),
+	})
+	w.staged = nil
 
 	logger.Debug("Commit created",
 		"commit_hash", commitHash.String(),
@@ -970,6 +1212,27 @@ func (w *stagedWriter) Push(ctx context.Context) error {
 		return err
 	}
 
+	err := w.push(ctx)
+	for attempt := 1; attempt <= w.autoRebase && protocol.IsGitReferenceUpdateError(err); attempt++ {
+		log.FromContext(ctx).Debug("Push rejected, rebasing",
+			"ref_name", w.ref.Name,
+			"attempt", attempt,
+			"error", err)
+		rebased, rebaseErr := w.rebase(ctx)
+		if rebaseErr != nil {
+			return rebaseErr
+		}
+		if !rebased {
+			// The ref did not move, so the push was rejected for another reason.
+			break
+		}
+		err = w.push(ctx)
+	}
+	return err
+}
+
+// push sends the staged objects and points the ref at the last commit.
+func (w *stagedWriter) push(ctx context.Context) error {
 	logger := log.FromContext(ctx)
 	logger.Debug("Push changes",
 		"ref_name", w.ref.Name,
@@ -980,6 +1243,27 @@ func (w *stagedWriter) Push(ctx context.Context) error {
 		return ErrNothingToPush
 	}
 
+	// Leave out the objects neither the commits nor the staged changes refer
+	// to, such as those of amended commits or overwritten blobs. The writer
+	// keeps them, so that checkpoints still roll back if the push fails.
+	roots := []hash.Hash{w.lastCommit.Hash}
+	if w.lastTree != nil {
+		roots = append(roots, w.lastTree.Hash)
+	}
+	for _, entry := range w.treeEntries {
+		roots = append(roots, entry.Hash)
+	}
+	pack, pruned, err := w.writer.Pruned(roots...)
+	if err != nil {
+		return fmt.Errorf("prune unreferenced objects: %w", err)
+	}
+	if pruned > 0 {
+		logger.Debug("Pruned unreferenced objects",
+			"ref_name", w.ref.Name,
+			"object_count", pruned)
+		defer func() { _ = pack.Cleanup() }()
+	}
+
 	// Create a pipe to stream packfile data directly from WritePackfile to ReceivePack
 	pipeReader, pipeWriter := io.Pipe()
 
@@ -991,12 +1275,12 @@ func (w *stagedWriter) Push(ctx context.Context) error {
 		defer func() {
 			_ = pipeWriter.Close() // Best effort close in goroutine
 		}()
-		err := w.writer.WritePackfile(pipeWriter, w.ref.Name, w.ref.Hash)
+		err := pack.WritePackfile(pipeWriter, w.ref.Name, w.ref.Hash)
 		writeErrChan <- err
 	}()
 
 	// Call ReceivePack with the pipe reader (this will stream the data and parse the response)
-	err := w.client.ReceivePack(ctx, pipeReader)
+	err = w.client.ReceivePack(ctx, pipeReader)
 	if err != nil {
 		_ = pipeReader.Close() // Best effort close since we're already handling an error
 
@@ -1024,7 +1308,9 @@ func (w *stagedWriter) Push(ctx context.Context) error {
 
 	// Success! Clean up the writer (removes temp files, clears objects) and reset for next operation.
 	// Always reset the writer and update the ref even if cleanup fails, to maintain consistency
-	// with the successful push that already happened on the server.
+	// with the successful push that already happened on the server. Checkpoints
+	// refer to the objects of the writer, so they are discarded.
+	w.discardCheckpoints()
 	cleanupErr := w.writer.Cleanup()
 	// Successful capability negotiation is cached on the client (guarded by
 	// negotiateMu; failures are never cached); by the time we reach this
@@ -1034,12 +1320,16 @@ func (w *stagedWriter) Push(ctx context.Context) error {
 	caps, capsErr := w.client.effectiveReceivePackCapabilities(ctx)
 	if capsErr != nil {
 		w.ref.Hash = w.lastCommit.Hash
+		w.unpushed = nil
+		w.base = w.files()
 		return fmt.Errorf("resolve receive-pack capabilities after push: %w", capsErr)
 	}
 	w.writer = protocol.NewPackfileWriter(crypto.SHA1, w.storageMode, caps...)
 	w.ref.Hash = w.lastCommit.Hash
+	w.unpushed = nil
 
 	w.pruneSubmoduleEntriesAfterPush()
+	w.base = w.files()
 
 	logger.Debug("Push completed",
 		"ref_name", w.ref.Name,
@@ -1077,21 +1367,27 @@ func (w *stagedWriter) Push(ctx context.Context) error {
 // implicit root) and whose own path is unshadowed are kept.
 func (w *stagedWriter) pruneSubmoduleEntriesAfterPush() {
 	for path := range w.submoduleEntries {
-		if _, shadowed := w.treeEntries[path]; shadowed {
-			delete(w.submoduleEntries, path)
-			continue
+		if !w.submoduleInTree(path) {
+			w.deleteEntry(w.submoduleEntries, path)
 		}
-		for parent := path; ; {
-			slash := strings.LastIndex(parent, "/")
-			if slash == -1 {
-				break // reached root — implicit, always present
-			}
-			parent = parent[:slash]
-			ancestor, exists := w.treeEntries[parent]
-			if !exists || ancestor.Type != protocol.ObjectTypeTree {
-				delete(w.submoduleEntries, path)
-				break
-			}
+	}
+}
+
+// submoduleInTree reports whether the cached submodule at path is part of
+// the staged tree: its path is unshadowed and every ancestor is a tree.
+func (w *stagedWriter) submoduleInTree(path string) bool {
+	if _, shadowed := w.treeEntries[path]; shadowed {
+		return false
+	}
+	for parent := path; ; {
+		slash := strings.LastIndex(parent, "/")
+		if slash == -1 {
+			return true // reached root — implicit, always present
+		}
+		parent = parent[:slash]
+		ancestor, exists := w.treeEntries[parent]
+		if !exists || ancestor.Type != protocol.ObjectTypeTree {
+			return false
 		}
 	}
 }
@@ -1123,27 +1419,27 @@ func (w *stagedWriter) addMissingOrStaleTreeEntries(ctx context.Context, path st
 
 		// Create directory entry if it doesn't exist
 		if !exists {
-			w.treeEntries[currentPath] = &FlatTreeEntry{
+			w.setEntry(w.treeEntries, currentPath, &FlatTreeEntry{
 				Path: currentPath,
 				Hash: hash.Zero, // Will be calculated during tree building
 				Type: protocol.ObjectTypeTree,
 				Mode: 0o40000,
-			}
+			})
 			logger.Debug("created directory entry", "path", currentPath)
 		}
 
 		// Mark this directory path as dirty
-		w.dirtyPaths[currentPath] = true
+		w.markDirty(currentPath)
 		logger.Debug("marked path as dirty", "path", currentPath)
 	}
 
 	// Mark root as dirty if file is in root directory
 	if len(dirParts) == 0 {
-		w.dirtyPaths[""] = true
+		w.markDirty("")
 		logger.Debug("marked root as dirty for root-level file")
 	} else {
 		// Always mark root as dirty when any nested directory changes
-		w.dirtyPaths[""] = true
+		w.markDirty("")
 		logger.Debug("marked root as dirty for nested file")
 	}
 
@@ -1183,12 +1479,12 @@ func (w *stagedWriter) removeBlobFromTree(ctx context.Context, path string) erro
 		}
 
 		// Mark this directory path as dirty
-		w.dirtyPaths[currentPath] = true
+		w.markDirty(currentPath)
 		logger.Debug("marked path as dirty for blob removal", "path", currentPath)
 	}
 
 	// Always mark root as dirty when any file is removed
-	w.dirtyPaths[""] = true
+	w.markDirty("")
 	logger.Debug("marked root as dirty for blob removal")
 
 	return nil
@@ -1222,12 +1518,12 @@ func (w *stagedWriter) removeTreeFromTree(ctx context.Context, path string) erro
 		}
 
 		// Mark this directory path as dirty
-		w.dirtyPaths[currentPath] = true
+		w.markDirty(currentPath)
 		logger.Debug("marked path as dirty for tree removal", "path", currentPath)
 	}
 
 	// Always mark root as dirty when any directory is removed
-	w.dirtyPaths[""] = true
+	w.markDirty("")
 	logger.Debug("marked root as dirty for tree removal")
 
 	return nil
@@ -1283,7 +1579,7 @@ func (w *stagedWriter) buildPendingTrees(ctx context.Context) error {
 	}
 
 	// Step 3: Clear dirty paths since all trees have been built
-	w.dirtyPaths = make(map[string]bool)
+	w.clearDirty()
 	logger.Debug("Finished building pending trees")
 
 	return nil
@@ -1379,6 +1675,7 @@ func (w *stagedWriter) buildTreeObject(ctx context.Context, dirPath string, entr
 	} else {
 		// Update the directory entry
 		if dirEntry, exists := w.treeEntries[dirPath]; exists {
+			w.recordEntry(w.treeEntries, dirPath)
 			dirEntry.Hash = treeObj.Hash
 		}
 		logger.Debug("Built directory tree", "path", dirPath, "hash", treeObj.Hash.String(), "entry_count", len(entries))
@@ -1433,6 +1730,8 @@ func (w *stagedWriter) Cleanup(ctx context.Context) error {
 	w.treeEntries = make(map[string]*FlatTreeEntry)
 	w.dirtyPaths = make(map[string]bool)
 	w.submoduleEntries = make(map[string]*FlatTreeEntry)
+	w.base = nil
+	w.discardCheckpoints()
 
 	// Reset writer state. Successful capability negotiation, if enabled, is
 	// cached on the client (guarded by negotiateMu; failures are never
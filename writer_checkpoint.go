@@ -0,0 +1,204 @@
+package nanogit
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/grafana/nanogit/protocol"
+)
+
+// WriterCheckpoint is the state of a StagedWriter at a point, returned by
+// Checkpoint for Rollback to restore.
+type WriterCheckpoint struct {
+	owner *stagedWriter
+	id    int
+	// undo is the length of the undo log when the checkpoint was taken.
+	undo       int
+	lastCommit *Commit
+	lastTree   *protocol.PackfileObject
+	staged     []writerOp
+	unpushed   int
+	packfile   protocol.PackfileCheckpoint
+}
+
+// writerUndo is an entry of the undo log: the state of a path of
+// treeEntries, submoduleEntries or dirtyPaths before a change made while a
+// checkpoint was open.
+type writerUndo struct {
+	// entries is treeEntries or submoduleEntries, or nil for dirtyPaths.
+	entries map[string]*FlatTreeEntry
+	path    string
+	// entry is a copy of the previous entry, or nil if there was none.
+	entry *FlatTreeEntry
+	// dirty is whether the path was dirty, for dirtyPaths.
+	dirty bool
+}
+
+// Checkpoint marks the staged changes and commits of the writer, so that
+// Rollback can undo what is staged after it, like a savepoint. Rolling back
+// to a checkpoint discards the checkpoints taken after it. A successful
+// Push, Amend, Squash and ResetTo discard them all, since they rebuild the
+// staged state from a commit, as does the rebase of a Push with
+// WithAutoRebase. A failed Push keeps them.
+//
+// Taking a checkpoint does not copy the staged state: while one is open, the
+// writer records the entries its changes touch, so that Rollback can put
+// them back.
+//
+// Returns:
+//   - *WriterCheckpoint: The token to pass to Rollback
+//   - error: Error if the writer has been cleaned up
+//
+// Example:
+//
+//	for _, record := range records {
+//	    checkpoint, err := writer.Checkpoint()
+//	    if err != nil {
+//	        return err
+//	    }
+//	    if err := stage(ctx, writer, record); err != nil {
+//	        if err := writer.Rollback(checkpoint); err != nil {
+//	            return err
+//	        }
+//	    }
+//	}
+func (w *stagedWriter) Checkpoint() (*WriterCheckpoint, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return nil, err
+	}
+
+	w.checkpointSeq++
+	w.checkpoints = append(w.checkpoints, w.checkpointSeq)
+	return &WriterCheckpoint{
+		owner:      w,
+		id:         w.checkpointSeq,
+		undo:       len(w.undo),
+		lastCommit: w.lastCommit,
+		lastTree:   w.lastTree,
+		staged:     slices.Clip(w.staged),
+		unpushed:   len(w.unpushed),
+		packfile:   w.writer.Checkpoint(),
+	}, nil
+}
+
+// Rollback restores the writer to a checkpoint: the changes staged and the
+// commits created after it are dropped, along with the objects they added
+// to the packfile. The checkpoint can be rolled back to again.
+//
+// A checkpoint taken before Push, Amend, Squash or ResetTo can no longer be
+// rolled back to.
+//
+// Parameters:
+//   - checkpoint: A checkpoint taken on this writer
+//
+// Returns:
+//   - error: ErrInvalidCheckpoint if the checkpoint was discarded or taken on another writer
+//
+// Example:
+//
+//	if err := writer.Rollback(checkpoint); err != nil {
+//	    return err
+//	}
+func (w *stagedWriter) Rollback(checkpoint *WriterCheckpoint) error {
+	if err := w.checkCleanupState(); err != nil {
+		return err
+	}
+
+	if checkpoint == nil || checkpoint.owner != w {
+		return ErrInvalidCheckpoint
+	}
+	position := slices.Index(w.checkpoints, checkpoint.id)
+	if position < 0 || checkpoint.unpushed > len(w.unpushed) {
+		return ErrInvalidCheckpoint
+	}
+
+	if err := w.writer.Rollback(checkpoint.packfile); err != nil {
+		return fmt.Errorf("drop objects after checkpoint: %w", err)
+	}
+	w.checkpoints = w.checkpoints[:position+1]
+	for i := len(w.undo) - 1; i >= checkpoint.undo; i-- {
+		undo := w.undo[i]
+		switch {
+		case undo.entries == nil && undo.dirty:
+			w.dirtyPaths[undo.path] = true
+		case undo.entries == nil:
+			delete(w.dirtyPaths, undo.path)
+		case undo.entry == nil:
+			delete(undo.entries, undo.path)
+		default:
+			entry := *undo.entry
+			undo.entries[undo.path] = &entry
+		}
+	}
+	w.undo = slices.Clip(w.undo[:checkpoint.undo])
+	w.lastCommit = checkpoint.lastCommit
+	w.lastTree = checkpoint.lastTree
+	w.staged = checkpoint.staged
+	w.unpushed = slices.Clip(w.unpushed[:checkpoint.unpushed])
+	return nil
+}
+
+// releaseCheckpoint discards a checkpoint that is no longer needed, keeping
+// the ones taken before it.
+func (w *stagedWriter) releaseCheckpoint(checkpoint *WriterCheckpoint) {
+	w.checkpoints = slices.DeleteFunc(w.checkpoints, func(id int) bool {
+		return id == checkpoint.id
+	})
+	if len(w.checkpoints) == 0 {
+		w.undo = nil
+	}
+}
+
+// discardCheckpoints invalidates every checkpoint, when the staged state is
+// rebuilt or pushed.
+func (w *stagedWriter) discardCheckpoints() {
+	w.checkpoints = nil
+	w.undo = nil
+}
+
+// setEntry stores entry at path in entries, which is treeEntries or
+// submoduleEntries, recording the previous entry for Rollback.
+func (w *stagedWriter) setEntry(entries map[string]*FlatTreeEntry, path string, entry *FlatTreeEntry) {
+	w.recordEntry(entries, path)
+	entries[path] = entry
+}
+
+// deleteEntry removes the entry at path from entries, which is treeEntries
+// or submoduleEntries, recording it for Rollback.
+func (w *stagedWriter) deleteEntry(entries map[string]*FlatTreeEntry, path string) {
+	w.recordEntry(entries, path)
+	delete(entries, path)
+}
+
+// recordEntry adds the entry at path in entries to the undo log when a
+// checkpoint is open. It must be called before the entry is changed.
+func (w *stagedWriter) recordEntry(entries map[string]*FlatTreeEntry, path string) {
+	if len(w.checkpoints) == 0 {
+		return
+	}
+	undo := writerUndo{entries: entries, path: path}
+	if entry, ok := entries[path]; ok {
+		previous := *entry
+		undo.entry = &previous
+	}
+	w.undo = append(w.undo, undo)
+}
+
+// markDirty marks the tree at path for rebuilding, recording it for
+// Rollback.
+func (w *stagedWriter) markDirty(path string) {
+	if len(w.checkpoints) > 0 && !w.dirtyPaths[path] {
+		w.undo = append(w.undo, writerUndo{path: path})
+	}
+	w.dirtyPaths[path] = true
+}
+
+// clearDirty unmarks every dirty tree, recording them for Rollback.
+func (w *stagedWriter) clearDirty() {
+	if len(w.checkpoints) > 0 {
+		for path := range w.dirtyPaths {
+			w.undo = append(w.undo, writerUndo{path: path, dirty: true})
+		}
+	}
+	clear(w.dirtyPaths)
+}
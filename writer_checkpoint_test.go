@@ -0,0 +1,329 @@
+package nanogit
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagedWriter_Checkpoint(t *testing.T) {
+	t.Parallel()
+
+	author := Author{Name: "Importer", Email: "importer@example.com", Time: time.Unix(1700000000, 0)}
+	committer := Committer{Name: "Importer", Email: "importer@example.com", Time: time.Unix(1700000100, 0)}
+	setup := func(t *testing.T, opts ...WriterOption) (*testRepo, StagedWriter) {
+		repo := newTestRepo(t)
+		main := repo.commit("base", map[string]string{"README.md": "readme", "data/existing.json": "{}"})
+		repo.ref("refs/heads/main", main)
+		writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main", Hash: main}, opts...)
+		require.NoError(t, err)
+		return repo, writer
+	}
+	blobHash := func(t *testing.T, content string) string {
+		h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, []byte(content))
+		require.NoError(t, err)
+		return h.String()
+	}
+	files := func(t *testing.T, repo *testRepo, commit hash.Hash) map[string]string {
+		t.Helper()
+		tree, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		out := make(map[string]string)
+		for _, entry := range tree.Entries {
+			if entry.Type != protocol.ObjectTypeBlob {
+				continue
+			}
+			blob, err := repo.client().GetBlob(context.Background(), entry.Hash)
+			require.NoError(t, err)
+			out[entry.Path] = string(blob.Content)
+		}
+		return out
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts []WriterOption
+	}{
+		{name: "memory storage", opts: []WriterOption{WithMemoryStorage()}},
+		{name: "disk storage", opts: []WriterOption{WithDiskStorage()}},
+	} {
+		t.Run("skips a bad record with "+tt.name, func(t *testing.T) {
+			t.Parallel()
+			repo, writer := setup(t, tt.opts...)
+			ctx := context.Background()
+
+			records := []map[string]string{
+				{"data/a.json": "a", "data/a.meta": "a meta"},
+				// Fails halfway: the second file already exists.
+				{"data/b.json": "b", "data/existing.json": "b meta"},
+				{"data/c.json": "c"},
+			}
+			paths := [][]string{{"data/a.json", "data/a.meta"}, {"data/b.json", "data/existing.json"}, {"data/c.json"}}
+			for i, record := range records {
+				checkpoint, err := writer.Checkpoint()
+				require.NoError(t, err)
+				for _, path := range paths[i] {
+					if _, err = writer.CreateBlob(ctx, path, []byte(record[path])); err != nil {
+						break
+					}
+				}
+				if err != nil {
+					require.ErrorIs(t, err, ErrObjectAlreadyExists)
+					require.NoError(t, writer.Rollback(checkpoint))
+				}
+			}
+
+			status, err := writer.Status()
+			require.NoError(t, err)
+			require.Equal(t, []string{"data/a.json", "data/a.meta", "data/c.json"}, status.Added)
+			commit, err := writer.Commit(ctx, "Import records", author, committer)
+			require.NoError(t, err)
+			require.NoError(t, writer.Push(ctx))
+
+			require.Equal(t, map[string]string{
+				"README.md":          "readme",
+				"data/existing.json": "{}",
+				"data/a.json":        "a",
+				"data/a.meta":        "a meta",
+				"data/c.json":        "c",
+			}, files(t, repo, commit.Hash))
+			require.NotContains(t, repo.objects, blobHash(t, "b"))
+		})
+	}
+
+	t.Run("rolls back commits", func(t *testing.T) {
+		t.Parallel()
+		repo, writer := setup(t)
+		ctx := context.Background()
+
+		_, err := writer.CreateBlob(ctx, "data/a.json", []byte("a"))
+		require.NoError(t, err)
+		first, err := writer.Commit(ctx, "Add a", author, committer)
+		require.NoError(t, err)
+		checkpoint, err := writer.Checkpoint()
+		require.NoError(t, err)
+
+		_, err = writer.CreateBlob(ctx, "data/b.json", []byte("b"))
+		require.NoError(t, err)
+		_, err = writer.Commit(ctx, "Add b", author, committer)
+		require.NoError(t, err)
+		_, err = writer.CreateBlob(ctx, "data/c.json", []byte("c"))
+		require.NoError(t, err)
+
+		require.NoError(t, writer.Rollback(checkpoint))
+		_, err = writer.CreateBlob(ctx, "data/d.json", []byte("d"))
+		require.NoError(t, err)
+		second, err := writer.Commit(ctx, "Add d", author, committer)
+		require.NoError(t, err)
+		require.Equal(t, first.Hash, second.Parent)
+		require.NoError(t, writer.Push(ctx))
+
+		require.Equal(t, map[string]string{
+			"README.md":          "readme",
+			"data/existing.json": "{}",
+			"data/a.json":        "a",
+			"data/d.json":        "d",
+		}, files(t, repo, second.Hash))
+	})
+
+	t.Run("rolling back discards later checkpoints", func(t *testing.T) {
+		t.Parallel()
+		_, writer := setup(t)
+		ctx := context.Background()
+
+		outer, err := writer.Checkpoint()
+		require.NoError(t, err)
+		_, err = writer.CreateBlob(ctx, "a.txt", []byte("a"))
+		require.NoError(t, err)
+		inner, err := writer.Checkpoint()
+		require.NoError(t, err)
+		_, err = writer.CreateBlob(ctx, "b.txt", []byte("b"))
+		require.NoError(t, err)
+
+		require.NoError(t, writer.Rollback(outer))
+		require.ErrorIs(t, writer.Rollback(inner), ErrInvalidCheckpoint)
+
+		// The same checkpoint can be rolled back to again.
+		_, err = writer.CreateBlob(ctx, "c.txt", []byte("c"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Rollback(outer))
+		status, err := writer.Status()
+		require.NoError(t, err)
+		require.True(t, status.IsClean())
+
+		_, other := setup(t)
+		foreign, err := other.Checkpoint()
+		require.NoError(t, err)
+		require.ErrorIs(t, writer.Rollback(foreign), ErrInvalidCheckpoint)
+	})
+
+	t.Run("push prunes unreferenced objects and discards checkpoints", func(t *testing.T) {
+		t.Parallel()
+		repo, writer := setup(t)
+		ctx := context.Background()
+
+		checkpoint, err := writer.Checkpoint()
+		require.NoError(t, err)
+		_, err = writer.CreateBlob(ctx, "a.txt", []byte("draft"))
+		require.NoError(t, err)
+		_, err = writer.UpdateBlob(ctx, "a.txt", []byte("final"))
+		require.NoError(t, err)
+		amended, err := writer.Commit(ctx, "Add a", author, committer)
+		require.NoError(t, err)
+		_, err = writer.CreateBlob(ctx, "b.txt", []byte("b"))
+		require.NoError(t, err)
+		commit, err := writer.Amend(ctx, "", author, committer)
+		require.NoError(t, err)
+		// Staged after the last commit, and kept for the next one.
+		_, err = writer.CreateBlob(ctx, "c.txt", []byte("c"))
+		require.NoError(t, err)
+
+		require.NoError(t, writer.Push(ctx))
+		require.Equal(t, commit.Hash, repo.refHash("refs/heads/main"))
+		require.NotContains(t, repo.objects, blobHash(t, "draft"))
+		require.NotContains(t, repo.objects, amended.Hash.String())
+		require.Contains(t, repo.objects, blobHash(t, "final"))
+		require.ErrorIs(t, writer.Rollback(checkpoint), ErrInvalidCheckpoint)
+	})
+
+	t.Run("a failed push keeps checkpoints and objects", func(t *testing.T) {
+		t.Parallel()
+		for _, tt := range []struct {
+			name string
+			opts []WriterOption
+		}{
+			{name: "memory storage", opts: []WriterOption{WithMemoryStorage()}},
+			{name: "disk storage", opts: []WriterOption{WithDiskStorage()}},
+		} {
+			repo, writer := setup(t, tt.opts...)
+			ctx := context.Background()
+			main := repo.refHash("refs/heads/main")
+
+			_, err := writer.CreateBlob(ctx, "a.txt", []byte("draft"))
+			require.NoError(t, err)
+			checkpoint, err := writer.Checkpoint()
+			require.NoError(t, err)
+			// The draft blob is unreferenced once overwritten.
+			_, err = writer.UpdateBlob(ctx, "a.txt", []byte("final"))
+			require.NoError(t, err)
+			_, err = writer.Commit(ctx, "Add a", author, committer)
+			require.NoError(t, err)
+
+			// Another writer moved the branch, so the push is rejected.
+			repo.setRef("refs/heads/main", repo.commit("other", map[string]string{"README.md": "other"}, main))
+			require.Error(t, writer.Push(ctx), tt.name)
+
+			require.NoError(t, writer.Rollback(checkpoint), tt.name)
+
+			repo.setRef("refs/heads/main", main)
+			commit, err := writer.Commit(ctx, "Add draft", author, committer)
+			require.NoError(t, err)
+			require.NoError(t, writer.Push(ctx), tt.name)
+			require.Equal(t, "draft", files(t, repo, commit.Hash)["a.txt"], tt.name)
+		}
+	})
+
+	t.Run("restores the entries changed after it", func(t *testing.T) {
+		t.Parallel()
+		repo, writer := setup(t)
+		ctx := context.Background()
+
+		before, err := writer.GetTree(ctx, "data")
+		require.NoError(t, err)
+		checkpoint, err := writer.Checkpoint()
+		require.NoError(t, err)
+
+		_, err = writer.UpdateBlob(ctx, "data/existing.json", []byte("[]"))
+		require.NoError(t, err)
+		_, err = writer.MoveTree(ctx, "data", "moved")
+		require.NoError(t, err)
+		_, err = writer.Commit(ctx, "Move data", author, committer)
+		require.NoError(t, err)
+		_, err = writer.DeleteTree(ctx, "")
+		require.NoError(t, err)
+		_, err = writer.CreateBlob(ctx, "data/other.json", []byte("other"))
+		require.NoError(t, err)
+
+		require.NoError(t, writer.Rollback(checkpoint))
+		after, err := writer.GetTree(ctx, "data")
+		require.NoError(t, err)
+		require.Equal(t, before.Hash, after.Hash)
+		status, err := writer.Status()
+		require.NoError(t, err)
+		require.True(t, status.IsClean())
+
+		_, err = writer.CreateBlob(ctx, "data/new.json", []byte("new"))
+		require.NoError(t, err)
+		commit, err := writer.Commit(ctx, "Add new", author, committer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Push(ctx))
+		require.Equal(t, map[string]string{
+			"README.md":          "readme",
+			"data/existing.json": "{}",
+			"data/new.json":      "new",
+		}, files(t, repo, commit.Hash))
+	})
+
+	for _, tt := range []struct {
+		name    string
+		rewrite func(ctx context.Context, writer StagedWriter, first *Commit) error
+	}{
+		{name: "amend", rewrite: func(ctx context.Context, writer StagedWriter, _ *Commit) error {
+			_, err := writer.Amend(ctx, "", author, committer)
+			return err
+		}},
+		{name: "squash", rewrite: func(ctx context.Context, writer StagedWriter, _ *Commit) error {
+			_, err := writer.Squash(ctx, 2, "")
+			return err
+		}},
+		{name: "reset", rewrite: func(ctx context.Context, writer StagedWriter, first *Commit) error {
+			return writer.ResetTo(ctx, first.Hash)
+		}},
+	} {
+		t.Run(tt.name+" discards checkpoints", func(t *testing.T) {
+			t.Parallel()
+			_, writer := setup(t)
+			ctx := context.Background()
+
+			_, err := writer.CreateBlob(ctx, "a.txt", []byte("a"))
+			require.NoError(t, err)
+			first, err := writer.Commit(ctx, "Add a", author, committer)
+			require.NoError(t, err)
+			checkpoint, err := writer.Checkpoint()
+			require.NoError(t, err)
+			_, err = writer.CreateBlob(ctx, "b.txt", []byte("b"))
+			require.NoError(t, err)
+			_, err = writer.Commit(ctx, "Add b", author, committer)
+			require.NoError(t, err)
+
+			require.NoError(t, tt.rewrite(ctx, writer, first))
+			require.ErrorIs(t, writer.Rollback(checkpoint), ErrInvalidCheckpoint)
+		})
+	}
+}
+
+// TestStagedWriter_CheckpointAllocs is not parallel, as AllocsPerRun
+// requires.
+func TestStagedWriter_CheckpointAllocs(t *testing.T) {
+	repo := newTestRepo(t)
+	files := make(map[string]string)
+	for i := range 2000 {
+		files[fmt.Sprintf("dir%d/file%d.txt", i%20, i)] = fmt.Sprint(i)
+	}
+	main := repo.commit("base", files)
+	writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main", Hash: main})
+	require.NoError(t, err)
+
+	// Taking a checkpoint does not copy the staged state.
+	allocs := testing.AllocsPerRun(100, func() {
+		_, err := writer.Checkpoint()
+		require.NoError(t, err)
+	})
+	require.LessOrEqual(t, allocs, 5.0)
+}
@@ -1,6 +1,10 @@
 package nanogit
 
-import "github.com/grafana/nanogit/protocol/signing"
+import (
+	"fmt"
+
+	"github.com/grafana/nanogit/protocol/signing"
+)
 
 // PackfileStorageMode defines how packfile objects are stored during staging.
 type PackfileStorageMode int
@@ -25,6 +29,19 @@ type WriterOptions struct {
 	// Default is PackfileStorageAuto.
 	StorageMode PackfileStorageMode
 
+	// AutoRebaseAttempts is how many times Push rebases the staged commits
+	// onto the new tip of the ref and retries when the ref moved.
+	// Default is 0, which returns the rejection.
+	AutoRebaseAttempts int
+
+	// Orphan starts the writer from an empty tree instead of the commit the
+	// ref points at, so that its first commit has no parent.
+	Orphan bool
+
+	// ReplaceHistory allows an Orphan writer on a ref that has a commit,
+	// whose history Push then replaces.
+	ReplaceHistory bool
+
 	signer signing.Signer
 }
 
@@ -94,6 +111,109 @@ func WithSMIMESigner(privateKey, certificate []byte) WriterOption {
 	}
 }
 
+// WithAutoRebase makes Push recover from losing a race with another writer.
+// When the server rejects the push because the ref moved, Push fetches the
+// new tip, replays the staged operations of every commit not pushed yet on
+// top of it, commits them again with the same messages, authors and
+// committers, and pushes again, up to maxAttempts times.
+//
+// Push fails with a RebaseConflictError, leaving the writer unchanged, if
+// the ref changed any path the staged operations touch. Merge commits are
+// not rebased.
+func WithAutoRebase(maxAttempts int) WriterOption {
+	return func(opts *WriterOptions) error {
+		if maxAttempts < 1 {
+			return fmt.Errorf("auto rebase attempts must be positive, got %d", maxAttempts)
+		}
+		opts.AutoRebaseAttempts = maxAttempts
+		return nil
+	}
+}
+
+// WithOrphan starts the writer from an empty tree instead of the commit the
+// ref points at, like `git checkout --orphan`. The first commit has no
+// parent, and Push creates the ref with it. On a ref that has a commit,
+// NewStagedWriter fails with a RefAlreadyExistsError unless
+// WithReplaceHistory is also given.
+func WithOrphan() WriterOption {
+	return func(opts *WriterOptions) error {
+		opts.Orphan = true
+		return nil
+	}
+}
+
+// WithReplaceHistory lets WithOrphan start a writer on a ref that has a
+// commit. Push then points the ref at the new root commit, dropping the
+// history it had, like a force push.
+func WithReplaceHistory() WriterOption {
+	return func(opts *WriterOptions) error {
+		opts.ReplaceHistory = true
+		return nil
+	}
+}
+
+// BlobOptions holds configuration options for a blob staged with
+// CreateBlob or UpdateBlob.
+type BlobOptions struct {
+	// Mode is the file mode of the blob: 0o100644 for a regular file,
+	// 0o100755 for an executable file or 0o120000 for a symbolic link,
+	// whose content is the link target.
+	// Default is 0, which creates a regular file and keeps the mode of an
+	// updated file.
+	Mode uint32
+}
+
+// BlobOption is a function type for configuring BlobOptions.
+type BlobOption func(*BlobOptions) error
+
+// WithExecutable stages the blob as an executable file (mode 0o100755).
+func WithExecutable() BlobOption {
+	return WithFileMode(0o100755)
+}
+
+// WithSymlink stages the blob as a symbolic link (mode 0o120000). The
+// content of the blob is the target of the link.
+func WithSymlink() BlobOption {
+	return WithFileMode(0o120000)
+}
+
+// WithFileMode stages the blob with mode, which must be 0o100644,
+// 0o100755 or 0o120000.
+func WithFileMode(mode uint32) BlobOption {
+	return func(opts *BlobOptions) error {
+		if err := validateBlobMode(mode); err != nil {
+			return err
+		}
+		opts.Mode = mode
+		return nil
+	}
+}
+
+// validateBlobMode checks that mode is the mode of a regular file, an
+// executable file or a symbolic link.
+func validateBlobMode(mode uint32) error {
+	switch mode {
+	case 0o100644, 0o100755, 0o120000:
+		return nil
+	default:
+		return fmt.Errorf("%w: %o", ErrInvalidFileMode, mode)
+	}
+}
+
+// applyBlobOptions applies a list of BlobOption functions to BlobOptions.
+func applyBlobOptions(options []BlobOption) (*BlobOptions, error) {
+	opts := &BlobOptions{}
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		if err := option(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
 // defaultWriterOptions returns the default configuration for StagedWriter.
 func defaultWriterOptions() *WriterOptions {
 	return &WriterOptions{
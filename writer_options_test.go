@@ -50,6 +50,43 @@ func TestWithAutoStorage(t *testing.T) {
 	assert.Equal(t, PackfileStorageAuto, opts.StorageMode)
 }
 
+func TestWithAutoRebase(t *testing.T) {
+	opts, err := applyWriterOptions([]WriterOption{WithAutoRebase(3)})
+	require.NoError(t, err)
+	assert.Equal(t, 3, opts.AutoRebaseAttempts)
+
+	_, err = applyWriterOptions([]WriterOption{WithAutoRebase(0)})
+	require.Error(t, err)
+}
+
+func TestWithOrphan(t *testing.T) {
+	opts, err := applyWriterOptions([]WriterOption{WithOrphan()})
+	require.NoError(t, err)
+	assert.True(t, opts.Orphan)
+	assert.False(t, opts.ReplaceHistory)
+
+	opts, err = applyWriterOptions([]WriterOption{WithOrphan(), WithReplaceHistory()})
+	require.NoError(t, err)
+	assert.True(t, opts.ReplaceHistory)
+}
+
+func TestBlobOptions(t *testing.T) {
+	opts, err := applyBlobOptions(nil)
+	require.NoError(t, err)
+	assert.Zero(t, opts.Mode)
+
+	opts, err = applyBlobOptions([]BlobOption{WithExecutable()})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0o100755), opts.Mode)
+
+	opts, err = applyBlobOptions([]BlobOption{WithSymlink()})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0o120000), opts.Mode)
+
+	_, err = applyBlobOptions([]BlobOption{WithFileMode(0o100600)})
+	require.ErrorIs(t, err, ErrInvalidFileMode)
+}
+
 func TestMultipleOptions(t *testing.T) {
 	t.Run("last option wins", func(t *testing.T) {
 		opts, err := applyWriterOptions([]WriterOption{
@@ -0,0 +1,363 @@
+package nanogit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// PatchFailureReason is why a hunk or file of a patch does not apply.
+type PatchFailureReason string
+
+const (
+	// PatchFailureContext is reported when the lines a hunk changes, or
+	// enough of its context lines, are not found in the file. A patch
+	// deleting a file fails the same way when lines are left after its
+	// hunks.
+	PatchFailureContext PatchFailureReason = "context mismatch"
+	// PatchFailureMissing is reported when the file a patch changes,
+	// deletes or renames does not exist.
+	PatchFailureMissing PatchFailureReason = "does not exist"
+	// PatchFailureExists is reported when the file a patch adds, or renames
+	// or copies a file to, already exists.
+	PatchFailureExists PatchFailureReason = "already exists"
+	// PatchFailureIndex is reported when a binary patch was made against
+	// other content than the file has.
+	PatchFailureIndex PatchFailureReason = "index mismatch"
+	// PatchFailureUnsupported is reported for changes that cannot be
+	// applied: a binary change without its data, like "Binary files
+	// differ", and adding, deleting or renaming a submodule.
+	PatchFailureUnsupported PatchFailureReason = "not supported"
+)
+
+// PatchFailure is a hunk or file of a patch that does not apply.
+type PatchFailure struct {
+	// Path is the path of the file, the new one for a rename or copy.
+	Path string
+
+	// Hunk is the number of the hunk in the diff of the file, starting at
+	// 1, or 0 when the whole file fails.
+	Hunk int
+
+	// Line is the line of the file the hunk was made against.
+	Line int
+
+	// Reason is why the hunk or file does not apply.
+	Reason PatchFailureReason
+}
+
+// ApplyPatchOptions configures ApplyPatch.
+type ApplyPatchOptions struct {
+	// Fuzz is the number of context lines at the start and at the end of
+	// a hunk that may differ from the file, like `patch --fuzz`. With zero
+	// all of them must match. A hunk is looked for at other lines than the
+	// patch says either way.
+	Fuzz int
+
+	// Commit commits each patch of a git format-patch series with the
+	// author, date and message of its email. Without it, or for a plain
+	// diff, the changes stay staged.
+	Commit bool
+
+	// Committer is the committer of the commits. It defaults to the author
+	// of each patch.
+	Committer Committer
+}
+
+// AppliedPatch is a patch applied by ApplyPatch.
+type AppliedPatch struct {
+	// Author is the author of a git format-patch email, with its date, or
+	// nil for a plain diff.
+	Author *Author
+
+	// Message is the subject and body of a git format-patch email, without
+	// its "[PATCH]" prefix.
+	Message string
+
+	// Files lists the paths the patch changed, the new ones for renames
+	// and copies.
+	Files []string
+
+	// Commit is the commit of the patch when ApplyPatchOptions.Commit is
+	// set and the patch is an email.
+	Commit *Commit
+}
+
+// ApplyPatch stages the changes of a unified diff, a git diff or a series
+// of git format-patch emails, like `git apply` and `git am` without a
+// checkout. New, deleted, renamed and copied files, mode changes and git
+// binary patches are applied, as are submodule updates. Hunks whose lines
+// moved are looked for elsewhere in the file, and with opts.Fuzz, without
+// some of their context lines.
+//
+// The patches are applied in order, and the first one that does not apply
+// stops the series: nothing of the input is staged then, and the error
+// lists each of its hunks and files that failed.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - patch: The patch to read
+//   - opts: Fuzz, and whether to commit the patches of a series
+//
+// Returns:
+//   - []AppliedPatch: The patches applied, in order
+//   - error: ErrInvalidPatch if the patch cannot be parsed, or a PatchApplyError if it does not apply
+//
+// Example:
+//
+//	patches, err := writer.ApplyPatch(ctx, r, nanogit.ApplyPatchOptions{Commit: true})
+//	var applyErr *nanogit.PatchApplyError
+//	if errors.As(err, &applyErr) {
+//	    for _, failure := range applyErr.Failures {
+//	        fmt.Printf("%s: hunk #%d %s\n", failure.Path, failure.Hunk, failure.Reason)
+//	    }
+//	}
+func (w *stagedWriter) ApplyPatch(ctx context.Context, patch io.Reader, opts ApplyPatchOptions) ([]AppliedPatch, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(patch)
+	if err != nil {
+		return nil, fmt.Errorf("read patch: %w", err)
+	}
+	patches, err := parsePatches(data)
+	if err != nil {
+		return nil, err
+	}
+
+	log.FromContext(ctx).Debug("Apply patch",
+		"patch_count", len(patches),
+		"fuzz", opts.Fuzz)
+
+	checkpoint, err := w.Checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := w.applyPatches(ctx, patches, opts)
+	if err != nil {
+		if rollbackErr := w.Rollback(checkpoint); rollbackErr != nil {
+			return nil, fmt.Errorf("%w (roll back: %v)", err, rollbackErr)
+		}
+		w.releaseCheckpoint(checkpoint)
+		return nil, err
+	}
+	w.releaseCheckpoint(checkpoint)
+	return applied, nil
+}
+
+// applyPatches stages the patches in order, and commits those of emails
+// with opts.Commit.
+func (w *stagedWriter) applyPatches(ctx context.Context, patches []*parsedPatch, opts ApplyPatchOptions) ([]AppliedPatch, error) {
+	applied := make([]AppliedPatch, 0, len(patches))
+	for _, patch := range patches {
+		var failures []PatchFailure
+		result := AppliedPatch{}
+		// The files after one that fails are still applied, to report all
+		// the failures of the patch; ApplyPatch then rolls them all back.
+		for _, file := range patch.files {
+			fileFailures, err := w.applyPatchFile(ctx, file, opts.Fuzz)
+			if err != nil {
+				return nil, fmt.Errorf("apply patch to %s: %w", file.path(), err)
+			}
+			failures = append(failures, fileFailures...)
+			result.Files = append(result.Files, file.path())
+		}
+		if len(failures) > 0 {
+			return nil, NewPatchApplyError(failures)
+		}
+
+		if patch.mail != nil {
+			author := patch.mail.author
+			result.Author = &author
+			result.Message = patch.mail.message
+			if opts.Commit {
+				committer := opts.Committer
+				if committer.Name == "" && committer.Email == "" {
+					committer = Committer{Name: author.Name, Email: author.Email, Time: author.Time}
+				}
+				if author.Time.IsZero() {
+					author.Time = committer.Time
+				}
+				commit, err := w.Commit(ctx, patch.mail.message, author, committer)
+				if err != nil {
+					return nil, fmt.Errorf("commit patch %q: %w", strings.SplitN(patch.mail.message, "\n", 2)[0], err)
+				}
+				result.Commit = commit
+			}
+		}
+		applied = append(applied, result)
+	}
+	return applied, nil
+}
+
+// path returns the path a change of a file is reported at.
+func (f *patchFile) path() string {
+	if f.newPath != "" {
+		return f.newPath
+	}
+	return f.oldPath
+}
+
+// applyPatchFile stages the change of a file. A change that does not apply
+// is returned as failures, with nothing staged.
+func (w *stagedWriter) applyPatchFile(ctx context.Context, file *patchFile, fuzz int) ([]PatchFailure, error) {
+	fail := func(reason PatchFailureReason) ([]PatchFailure, error) {
+		return []PatchFailure{{Path: file.path(), Reason: reason}}, nil
+	}
+
+	var old *FlatTreeEntry
+	if !file.isNew {
+		old = w.patchEntry(file.oldPath)
+		if old == nil {
+			return fail(PatchFailureMissing)
+		}
+	}
+	if (file.isNew || file.isRename || file.isCopy) && w.patchEntry(file.newPath) != nil {
+		return fail(PatchFailureExists)
+	}
+
+	if (old != nil && old.Mode == 0o160000) || file.oldMode == 0o160000 || file.newMode == 0o160000 {
+		return w.applySubmodulePatch(ctx, file, old, fuzz)
+	}
+
+	var content []byte
+	if old != nil {
+		var err error
+		if content, err = w.readBlob(ctx, old.Hash); err != nil {
+			return nil, fmt.Errorf("read %s: %w", file.oldPath, err)
+		}
+	}
+	updated, failures, err := applyPatchContent(content, file, fuzz)
+	if err != nil || len(failures) > 0 {
+		return failures, err
+	}
+
+	if file.isDeleted {
+		// A binary deletion without data keeps the content it cannot check.
+		if len(updated) > 0 && (!file.binary || file.binaryData != nil) {
+			return fail(PatchFailureContext)
+		}
+		if _, err := w.DeleteBlob(ctx, file.oldPath); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	mode := uint32(0o100644)
+	if old != nil {
+		mode = old.Mode
+	}
+	if file.newMode != 0 {
+		mode = file.newMode
+	}
+	if validateBlobMode(mode) != nil {
+		return fail(PatchFailureUnsupported)
+	}
+
+	switch {
+	case file.isNew || file.isCopy:
+		_, err = w.CreateBlob(ctx, file.newPath, updated, WithFileMode(mode))
+	case file.isRename:
+		if _, err = w.DeleteBlob(ctx, file.oldPath); err == nil {
+			_, err = w.CreateBlob(ctx, file.newPath, updated, WithFileMode(mode))
+		}
+	case !bytes.Equal(updated, content):
+		_, err = w.UpdateBlob(ctx, file.newPath, updated, WithFileMode(mode))
+	case mode != old.Mode:
+		err = w.SetMode(ctx, file.newPath, mode)
+	}
+	return nil, err
+}
+
+// patchEntry returns the entry at a path for a patch, a file or a
+// submodule, or nil.
+func (w *stagedWriter) patchEntry(path string) *FlatTreeEntry {
+	if entry, ok := w.treeEntries[path]; ok {
+		return entry
+	}
+	return w.submoduleEntries[path]
+}
+
+// applyPatchContent returns the content of a file after its hunks, or its
+// binary patch, are applied.
+func applyPatchContent(content []byte, file *patchFile, fuzz int) ([]byte, []PatchFailure, error) {
+	fail := func(reason PatchFailureReason) ([]byte, []PatchFailure, error) {
+		return nil, []PatchFailure{{Path: file.path(), Reason: reason}}, nil
+	}
+
+	if !file.binary {
+		updated, failed := applyHunks(content, file.hunks, fuzz)
+		var failures []PatchFailure
+		for _, failure := range failed {
+			failures = append(failures, PatchFailure{
+				Path:   file.path(),
+				Hunk:   failure.hunk,
+				Line:   failure.line,
+				Reason: PatchFailureContext,
+			})
+		}
+		return updated, failures, nil
+	}
+
+	if file.binaryData == nil {
+		// Without the data, only changes keeping the content apply.
+		if file.isDeleted || (file.oldIndex != "" && file.oldIndex == file.newIndex) {
+			return content, nil, nil
+		}
+		return fail(PatchFailureUnsupported)
+	}
+	if !file.isNew && strings.Trim(file.oldIndex, "0") != "" {
+		h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("hash %s: %w", file.oldPath, err)
+		}
+		if !strings.HasPrefix(h.String(), file.oldIndex) {
+			return fail(PatchFailureIndex)
+		}
+	}
+	if !file.binaryData.delta {
+		return file.binaryData.data, nil, nil
+	}
+	delta, err := protocol.ParseDelta(file.binaryData.data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: binary delta of %s: %v", ErrInvalidPatch, file.path(), err)
+	}
+	updated, err := protocol.ApplyDelta(content, delta)
+	if err != nil {
+		return fail(PatchFailureIndex)
+	}
+	return updated, nil, nil
+}
+
+// applySubmodulePatch stages the change of a submodule, whose diff is that
+// of its "Subproject commit" line. Only updates of a submodule are
+// supported, since adding one needs its URL in .gitmodules.
+func (w *stagedWriter) applySubmodulePatch(ctx context.Context, file *patchFile, old *FlatTreeEntry, fuzz int) ([]PatchFailure, error) {
+	if file.isNew || file.isDeleted || file.isRename || file.isCopy ||
+		old.Mode != 0o160000 || (file.newMode != 0 && file.newMode != 0o160000) {
+		return []PatchFailure{{Path: file.path(), Reason: PatchFailureUnsupported}}, nil
+	}
+
+	content := []byte("Subproject commit " + old.Hash.String() + "\n")
+	updated, failures, err := applyPatchContent(content, file, fuzz)
+	if err != nil || len(failures) > 0 {
+		return failures, err
+	}
+	line, ok := strings.CutPrefix(strings.TrimSpace(string(updated)), "Subproject commit ")
+	if !ok {
+		return nil, fmt.Errorf("%w: submodule %s is not changed to a commit", ErrInvalidPatch, file.path())
+	}
+	commit, err := hash.FromHex(line)
+	if err != nil {
+		return nil, fmt.Errorf("%w: submodule %s: %v", ErrInvalidPatch, file.path(), err)
+	}
+	return nil, w.UpdateSubmodule(ctx, file.path(), commit)
+}
@@ -0,0 +1,332 @@
+package nanogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/require"
+)
+
+// formatPatchSeries is the output of `git format-patch -M --binary` for two
+// commits on top of patchBase.
+const formatPatchSeries = `From 04dec2e3016e1d685eda7706bdbb345f3df84f5f Mon Sep 17 00:00:00 2001
+From: Alice Example <alice@example.com>
+Date: Tue, 14 Nov 2023 22:13:20 +0100
+Subject: [PATCH 1/2] Update notes and add new file
+
+Also makes the build script executable.
+---
+ build.sh  | 0
+ new.txt   | 1 +
+ notes.txt | 4 ++--
+ old.txt   | 1 -
+ 4 files changed, 3 insertions(+), 3 deletions(-)
+ mode change 100644 => 100755 build.sh
+ create mode 100644 new.txt
+ delete mode 100644 old.txt
+
+diff --git a/build.sh b/build.sh
+old mode 100644
+new mode 100755
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..92d5444
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++fresh
+diff --git a/notes.txt b/notes.txt
+index fa2da6e..d9966b8 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1,10 +1,10 @@
+ line 1
+-line 2
++line two
+ line 3
+ line 4
+ line 5
+ line 6
+ line 7
+ line 8
+-line 9
++line nine
+ line 10
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+index 3367afd..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-old
+--
+2.39.5
+
+
+From 5fbf9590976b5bf159e36bd6acfde737ad948baa Mon Sep 17 00:00:00 2001
+From: Bob <bob@example.com>
+Date: Wed, 15 Nov 2023 08:00:00 +0000
+Subject: [PATCH 2/2] Rename docs and update logo
+
+---
+ docs.md => guide.md |   2 +-
+ logo.bin            | Bin 9 -> 10 bytes
+ 2 files changed, 1 insertion(+), 1 deletion(-)
+ rename docs.md => guide.md (60%)
+
+diff --git a/docs.md b/guide.md
+similarity index 60%
+rename from docs.md
+rename to guide.md
+index 32b445c..0168ba8 100644
+--- a/docs.md
++++ b/guide.md
+@@ -1,3 +1,3 @@
+ moved content
+ stays the same
+-across the rename
++across the rename!
+diff --git a/logo.bin b/logo.bin
+index 0f49c4ae77b43dff338093c78e009676e7e308ba..84c636943ec7f78db527a11e78ce6b39922495c0 100644
+GIT binary patch
+literal 10
+RcmZQzWODNKa}0|74*&?*0>}UW
+
+literal 9
+QcmZQzWJ=1+ODw7c00^)Gi2wiq
+
+--
+2.39.5
+`
+
+// patchBase is the tree formatPatchSeries was made against.
+var patchBase = map[string]string{
+	"notes.txt": "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10\n",
+	"build.sh":  "#!/bin/sh\necho build\n",
+	"old.txt":   "old\n",
+	"docs.md":   "moved content\nstays the same\nacross the rename\n",
+	"logo.bin":  "\x00\x01\x02binary",
+}
+
+func TestStagedWriter_ApplyPatch(t *testing.T) {
+	t.Parallel()
+
+	committer := Committer{Name: "Reviewer", Email: "reviewer@example.com", Time: time.Unix(1700000100, 0)}
+	setup := func(t *testing.T, files map[string]string) (*testRepo, StagedWriter) {
+		repo := newTestRepo(t)
+		main := repo.commit("base", files)
+		repo.ref("refs/heads/main", main)
+		writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main", Hash: main})
+		require.NoError(t, err)
+		return repo, writer
+	}
+	files := func(t *testing.T, repo *testRepo, commit hash.Hash) map[string]string {
+		t.Helper()
+		tree, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		out := make(map[string]string)
+		for _, entry := range tree.Entries {
+			if entry.Type != protocol.ObjectTypeBlob {
+				continue
+			}
+			blob, err := repo.client().GetBlob(context.Background(), entry.Hash)
+			require.NoError(t, err)
+			out[entry.Path] = string(blob.Content)
+		}
+		return out
+	}
+
+	t.Run("commits a format-patch series", func(t *testing.T) {
+		t.Parallel()
+		repo, writer := setup(t, patchBase)
+		ctx := context.Background()
+
+		applied, err := writer.ApplyPatch(ctx, strings.NewReader(formatPatchSeries), ApplyPatchOptions{Commit: true, Committer: committer})
+		require.NoError(t, err)
+		require.Len(t, applied, 2)
+		require.Equal(t, "Update notes and add new file\n\nAlso makes the build script executable.\n", applied[0].Message)
+		require.Equal(t, []string{"build.sh", "new.txt", "notes.txt", "old.txt"}, applied[0].Files)
+		require.Equal(t, "Alice Example", applied[0].Author.Name)
+		require.Equal(t, "alice@example.com", applied[0].Author.Email)
+		require.Equal(t, time.Date(2023, 11, 14, 21, 13, 20, 0, time.UTC), applied[0].Author.Time.UTC())
+		require.Equal(t, "Rename docs and update logo\n", applied[1].Message)
+		require.Equal(t, []string{"guide.md", "logo.bin"}, applied[1].Files)
+		require.Equal(t, applied[0].Commit.Hash, applied[1].Commit.Parent)
+		require.NoError(t, writer.Push(ctx))
+
+		// The trees are the ones git made the patches from.
+		require.Equal(t, "15049a83982dd02ee1501e75d37ef7f79a7d0106", applied[1].Commit.Tree.String())
+		got, err := repo.client().GetCommit(ctx, repo.refHash("refs/heads/main"))
+		require.NoError(t, err)
+		require.Equal(t, "Bob", got.Author.Name)
+		require.Equal(t, "Reviewer", got.Committer.Name)
+		require.Equal(t, map[string]string{
+			"notes.txt": "line 1\nline two\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline nine\nline 10\n",
+			"build.sh":  "#!/bin/sh\necho build\n",
+			"new.txt":   "fresh\n",
+			"guide.md":  "moved content\nstays the same\nacross the rename!\n",
+			"logo.bin":  "\x00\x01\x02BINARY\xff",
+		}, files(t, repo, got.Hash))
+	})
+
+	t.Run("stages a plain diff with offset and fuzz", func(t *testing.T) {
+		t.Parallel()
+		repo, writer := setup(t, map[string]string{
+			"app/config.yaml": "# added on top\nname: app\nreplicas: 1\nimage: app:v1\nport: 8080\nlocal: edit\n",
+		})
+		ctx := context.Background()
+		// Made before the comment was added on top and the last line
+		// changed.
+		patch := "--- a/app/config.yaml\t2024-01-01 00:00:00\n" +
+			"+++ b/app/config.yaml\t2024-01-02 00:00:00\n" +
+			"@@ -1,5 +1,5 @@\n" +
+			" name: app\n" +
+			" replicas: 1\n" +
+			"-image: app:v1\n" +
+			"+image: app:v2\n" +
+			" port: 8080\n" +
+			" debug: false\n"
+
+		_, err := writer.ApplyPatch(ctx, strings.NewReader(patch), ApplyPatchOptions{})
+		var applyErr *PatchApplyError
+		require.ErrorAs(t, err, &applyErr)
+		require.Equal(t, []PatchFailure{{Path: "app/config.yaml", Hunk: 1, Line: 1, Reason: PatchFailureContext}}, applyErr.Failures)
+
+		applied, err := writer.ApplyPatch(ctx, strings.NewReader(patch), ApplyPatchOptions{Fuzz: 1})
+		require.NoError(t, err)
+		require.Nil(t, applied[0].Author)
+		require.Nil(t, applied[0].Commit)
+		commit, err := writer.Commit(ctx, "Bump image", Author{Name: "Reviewer", Email: "reviewer@example.com", Time: committer.Time}, committer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Push(ctx))
+		require.Equal(t, map[string]string{
+			"app/config.yaml": "# added on top\nname: app\nreplicas: 1\nimage: app:v2\nport: 8080\nlocal: edit\n",
+		}, files(t, repo, commit.Hash))
+	})
+
+	t.Run("reports failures and stages nothing", func(t *testing.T) {
+		t.Parallel()
+		base := map[string]string{}
+		for path, content := range patchBase {
+			base[path] = content
+		}
+		base["notes.txt"] = strings.Replace(base["notes.txt"], "line 9", "line 9 edited", 1)
+		base["new.txt"] = "already here\n"
+		_, writer := setup(t, base)
+		ctx := context.Background()
+		_, err := writer.CreateBlob(ctx, "staged.txt", []byte("staged before"))
+		require.NoError(t, err)
+
+		_, err = writer.ApplyPatch(ctx, strings.NewReader(formatPatchSeries), ApplyPatchOptions{Commit: true})
+		require.ErrorIs(t, err, ErrPatchDoesNotApply)
+		var applyErr *PatchApplyError
+		require.True(t, errors.As(err, &applyErr))
+		require.Equal(t, []PatchFailure{
+			{Path: "new.txt", Reason: PatchFailureExists},
+			{Path: "notes.txt", Hunk: 1, Line: 1, Reason: PatchFailureContext},
+		}, applyErr.Failures)
+
+		status, err := writer.Status()
+		require.NoError(t, err)
+		require.Equal(t, []string{"staged.txt"}, status.Added)
+		require.Empty(t, status.Modified)
+		require.Empty(t, status.Deleted)
+		_, err = writer.Amend(ctx, "", Author{Name: "a", Email: "a@example.com"}, committer)
+		require.ErrorIs(t, err, ErrUnpushedCommitNotFound)
+	})
+
+	t.Run("applies a binary delta", func(t *testing.T) {
+		t.Parallel()
+		var content []byte
+		for i := range 300 {
+			content = append(content, fmt.Sprintf("\x00chunk %04d ", i)...)
+		}
+		_, writer := setup(t, map[string]string{"data.bin": string(content)})
+		ctx := context.Background()
+		patch := "diff --git a/data.bin b/data.bin\n" +
+			"index 0b8688ae76e3b17820e8672499db5f65d653df16..fd7dffc3b23543f93b93252ce2e78cca49d73367 100644\n" +
+			"GIT binary patch\n" +
+			"delta 17\n" +
+			"YcmbOrGeKs<3uYEq7tfH*Z<tlM06BmK?f?J)\n" +
+			"\n" +
+			"delta 17\n" +
+			"YcmbOrGeKs<3uczQYz2eOZ<tlM06H=S@c;k-\n" +
+			"\n"
+
+		_, err := writer.ApplyPatch(ctx, strings.NewReader(patch), ApplyPatchOptions{})
+		require.NoError(t, err)
+		changes, err := writer.Diff(ctx)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, "fd7dffc3b23543f93b93252ce2e78cca49d73367", changes[0].Hash.String())
+
+		// The delta does not apply to other content.
+		_, err = writer.ApplyPatch(ctx, strings.NewReader(patch), ApplyPatchOptions{})
+		require.Equal(t, NewPatchApplyError([]PatchFailure{{Path: "data.bin", Reason: PatchFailureIndex}}), err)
+	})
+
+	t.Run("updates a submodule", func(t *testing.T) {
+		t.Parallel()
+		repo, writer := setup(t, map[string]string{
+			"vendor/lib": "submodule:" + strings.Repeat("a", 40),
+			"README.md":  "readme\n",
+		})
+		ctx := context.Background()
+		patch := "diff --git a/vendor/lib b/vendor/lib\n" +
+			"index aaaaaaa..bbbbbbb 160000\n" +
+			"--- a/vendor/lib\n" +
+			"+++ b/vendor/lib\n" +
+			"@@ -1 +1 @@\n" +
+			"-Subproject commit " + strings.Repeat("a", 40) + "\n" +
+			"+Subproject commit " + strings.Repeat("b", 40) + "\n"
+
+		_, err := writer.ApplyPatch(ctx, strings.NewReader(patch), ApplyPatchOptions{})
+		require.NoError(t, err)
+		commit, err := writer.Commit(ctx, "Update lib", Author{Name: "a", Email: "a@example.com", Time: committer.Time}, committer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Push(ctx))
+		tree, err := repo.client().GetFlatTree(ctx, commit.Hash)
+		require.NoError(t, err)
+		for _, entry := range tree.Entries {
+			if entry.Path == "vendor/lib" {
+				require.Equal(t, strings.Repeat("b", 40), entry.Hash.String())
+			}
+		}
+	})
+
+	t.Run("rejects binary changes without data", func(t *testing.T) {
+		t.Parallel()
+		_, writer := setup(t, patchBase)
+		patch := "diff --git a/logo.bin b/logo.bin\n" +
+			"index 0f49c4a..84c6369 100644\n" +
+			"Binary files a/logo.bin and b/logo.bin differ\n"
+
+		_, err := writer.ApplyPatch(context.Background(), strings.NewReader(patch), ApplyPatchOptions{})
+		require.Equal(t, NewPatchApplyError([]PatchFailure{{Path: "logo.bin", Reason: PatchFailureUnsupported}}), err)
+
+		_, err = writer.ApplyPatch(context.Background(), strings.NewReader("no diff here\n"), ApplyPatchOptions{})
+		require.ErrorIs(t, err, ErrInvalidPatch)
+	})
+
+	t.Run("deletes a binary file without data", func(t *testing.T) {
+		t.Parallel()
+		_, writer := setup(t, patchBase)
+		ctx := context.Background()
+		patch := "diff --git a/logo.bin b/logo.bin\n" +
+			"deleted file mode 100644\n" +
+			"index 0f49c4a..0000000\n" +
+			"Binary files a/logo.bin and /dev/null differ\n"
+
+		_, err := writer.ApplyPatch(ctx, strings.NewReader(patch), ApplyPatchOptions{})
+		require.NoError(t, err)
+		exists, err := writer.BlobExists(ctx, "logo.bin")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+}
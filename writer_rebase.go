@@ -0,0 +1,218 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// writerOpKind is the kind of a path-level operation staged on a writer.
+type writerOpKind int
+
+const (
+	writerOpCreateBlob writerOpKind = iota
+	writerOpUpdateBlob
+	writerOpDeleteBlob
+	writerOpMoveBlob
+	writerOpDeleteTree
+	writerOpMoveTree
+	writerOpAddSubmodule
+	writerOpUpdateSubmodule
+)
+
+// writerOp is a path-level operation staged on a writer, recorded so that
+// it can be replayed on top of another commit.
+type writerOp struct {
+	kind writerOpKind
+	// path is the path the operation applies to, or its source for a move.
+	path string
+	// dest is the destination of a move.
+	dest string
+	// hash and mode are the blob and mode written by a create or update,
+	// or the commit a submodule is pinned to.
+	hash hash.Hash
+	mode uint32
+	// url is the URL of an added submodule.
+	url string
+}
+
+// paths returns the paths the operation reads or writes.
+func (op writerOp) paths() []string {
+	if op.kind == writerOpMoveBlob || op.kind == writerOpMoveTree {
+		return []string{op.path, op.dest}
+	}
+	if op.kind == writerOpAddSubmodule {
+		return []string{op.path, gitmodulesPath}
+	}
+	return []string{op.path}
+}
+
+// stagedCommit is a commit created by a writer and not pushed yet, with the
+// operations staged for it.
+type stagedCommit struct {
+	ops []writerOp
+	// root is set for a commit without parents, the first commit of an
+	// empty repository or an orphan branch.
+	root         bool
+	message      string
+	author       Author
+	committer    Committer
+	mergeParents []hash.Hash
+	// commit is the commit created, and checkpoint marks the objects of
+	// the packfile up to it.
+	commit     *Commit
+	checkpoint protocol.PackfileCheckpoint
+}
+
+// stageBlob stages the blob of a create or update operation at its path and
+// records the operation.
+func (w *stagedWriter) stageBlob(ctx context.Context, op writerOp) error {
+	if err := w.setBlob(ctx, op.path, op.hash, op.mode); err != nil {
+		return err
+	}
+	w.staged = append(w.staged, op)
+	return nil
+}
+
+// setBlob stages blobHash with mode at path without recording an operation.
+func (w *stagedWriter) setBlob(ctx context.Context, path string, blobHash hash.Hash, mode uint32) error {
+	w.setEntry(w.treeEntries, path, &FlatTreeEntry{
+		Path: path,
+		Hash: blobHash,
+		Type: protocol.ObjectTypeBlob,
+		Mode: mode,
+	})
+
+	if err := w.addMissingOrStaleTreeEntries(ctx, path, blobHash); err != nil {
+		return fmt.Errorf("update tree structure for %q: %w", path, err)
+	}
+	return nil
+}
+
+// rebase moves the commits not pushed yet, and the operations staged since,
+// on top of the commit the ref points at now, like `git pull --rebase`. It
+// returns false when the ref has not moved. Before anything is changed,
+// the paths changed upstream are checked against the paths the operations
+// touch; an overlap fails the rebase with a RebaseConflictError and leaves
+// the writer as it was.
+func (w *stagedWriter) rebase(ctx context.Context) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	var ops []writerOp
+	for _, commit := range w.unpushed {
+		if len(commit.mergeParents) > 0 || commit.root {
+			// The tree of a merge commit is not made of recorded operations,
+			// and a root commit must not gain a parent.
+			return false, nil
+		}
+		ops = append(ops, commit.ops...)
+	}
+	ops = append(ops, w.staged...)
+
+	tip, err := w.client.GetRef(ctx, w.ref.Name)
+	if err != nil {
+		return false, fmt.Errorf("get ref %s: %w", w.ref.Name, err)
+	}
+	if tip.Hash == w.ref.Hash {
+		return false, nil
+	}
+
+	changes, err := w.client.CompareCommits(ctx, w.ref.Hash, tip.Hash)
+	if err != nil {
+		return false, fmt.Errorf("compare %s with %s: %w", w.ref.Hash.String(), tip.Hash.String(), err)
+	}
+	if conflicts := rebaseConflicts(changes, ops); len(conflicts) > 0 {
+		return false, NewRebaseConflictError(w.ref.Name, conflicts)
+	}
+
+	logger.Debug("Rebase staged commits",
+		"ref_name", w.ref.Name,
+		"from_hash", w.ref.Hash.String(),
+		"onto_hash", tip.Hash.String(),
+		"commit_count", len(w.unpushed),
+		"operation_count", len(ops))
+
+	commits, staged := w.unpushed, w.staged
+	if err := w.reset(ctx, tip.Hash); err != nil {
+		return false, fmt.Errorf("reset onto %s: %w", tip.Hash.String(), err)
+	}
+	w.ref.Hash = tip.Hash
+	w.unpushed = nil
+
+	for _, commit := range commits {
+		if err := w.replay(ctx, commit.ops); err != nil {
+			return false, err
+		}
+		if _, err := w.commit(ctx, commit.message, commit.author, commit.committer, nil); err != nil {
+			return false, fmt.Errorf("commit rebased changes: %w", err)
+		}
+	}
+	if err := w.replay(ctx, staged); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// replay stages operations again, recording them as it goes.
+func (w *stagedWriter) replay(ctx context.Context, ops []writerOp) error {
+	for _, op := range ops {
+		var err error
+		switch op.kind {
+		case writerOpCreateBlob, writerOpUpdateBlob:
+			err = w.stageBlob(ctx, op)
+		case writerOpDeleteBlob:
+			_, err = w.DeleteBlob(ctx, op.path)
+		case writerOpMoveBlob:
+			_, err = w.MoveBlob(ctx, op.path, op.dest)
+		case writerOpDeleteTree:
+			_, err = w.DeleteTree(ctx, op.path)
+		case writerOpMoveTree:
+			_, err = w.MoveTree(ctx, op.path, op.dest)
+		case writerOpAddSubmodule:
+			err = w.AddSubmodule(ctx, op.path, op.url, op.hash)
+		case writerOpUpdateSubmodule:
+			err = w.UpdateSubmodule(ctx, op.path, op.hash)
+		}
+		if err != nil {
+			return fmt.Errorf("replay staged change to %q: %w", op.path, err)
+		}
+	}
+	return nil
+}
+
+// rebaseConflicts returns the paths changed upstream that staged operations
+// also touch: the same path, a file inside a directory the operations
+// delete or move, or a file standing where they need a directory.
+func rebaseConflicts(changes []CommitFile, ops []writerOp) []string {
+	var conflicts []string
+	for _, change := range changes {
+		if change.Type == protocol.ObjectTypeTree {
+			continue
+		}
+		for _, changed := range []string{change.Path, change.OldPath} {
+			if changed != "" && touchesPath(ops, changed) {
+				conflicts = append(conflicts, changed)
+			}
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// touchesPath reports whether an operation touches path, a directory
+// containing it or a path inside it.
+func touchesPath(ops []writerOp, path string) bool {
+	for _, op := range ops {
+		for _, p := range op.paths() {
+			if p == "" || p == path || strings.HasPrefix(path, p+"/") || strings.HasPrefix(p, path+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package nanogit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+// racingRepo is a testRepo where another writer moves the ref right before
+// each of the first races pushes reaches the server.
+type racingRepo struct {
+	*testRepo
+	races int
+	// upstream returns the files of the next commit of the other writer.
+	upstream func(race int) map[string]string
+	// declined rejects every push, like a pre-receive hook.
+	declined bool
+	// attempts counts the pushes that reached the server.
+	attempts int
+}
+
+func (r *racingRepo) ReceivePack(ctx context.Context, data io.Reader) error {
+	r.attempts++
+	if r.declined {
+		return protocol.NewGitReferenceUpdateError(nil, "refs/heads/main", "pre-receive hook declined")
+	}
+	if r.races > 0 {
+		r.races--
+		tip := r.refHash("refs/heads/main")
+		r.setRef("refs/heads/main", r.commit("upstream", r.upstream(r.races), tip))
+	}
+	return r.testRepo.ReceivePack(ctx, data)
+}
+
+func TestStagedWriter_AutoRebase(t *testing.T) {
+	t.Parallel()
+
+	author := Author{Name: "Writer", Email: "writer@example.com", Time: time.Unix(1700000000, 0)}
+	committer := Committer{Name: "Bot", Email: "bot@example.com", Time: time.Unix(1700000100, 0)}
+	base := map[string]string{
+		"README.md":     "readme",
+		"config.yaml":   "replicas: 1",
+		"docs/guide.md": "guide",
+	}
+	// setup returns a repository with main at a commit of base whose pushes
+	// race with a writer that changes files.
+	setup := func(t *testing.T, races int, files map[string]string) (*racingRepo, Ref) {
+		repo := &racingRepo{testRepo: newTestRepo(t), races: races}
+		main := repo.commit("base", base)
+		repo.ref("refs/heads/main", main)
+		repo.upstream = func(race int) map[string]string {
+			upstream := make(map[string]string, len(base)+len(files))
+			for path, content := range base {
+				upstream[path] = content
+			}
+			for path, content := range files {
+				upstream[path] = content + string(rune('a'+race))
+			}
+			return upstream
+		}
+		return repo, Ref{Name: "refs/heads/main", Hash: main}
+	}
+	// stage commits two changes on a writer.
+	stage := func(t *testing.T, writer StagedWriter) {
+		ctx := context.Background()
+		_, err := writer.CreateBlob(ctx, "docs/new.md", []byte("new"))
+		require.NoError(t, err)
+		_, err = writer.Commit(ctx, "Add new page", author, committer)
+		require.NoError(t, err)
+		_, err = writer.UpdateBlob(ctx, "config.yaml", []byte("replicas: 3"))
+		require.NoError(t, err)
+		_, err = writer.MoveBlob(ctx, "docs/guide.md", "guide.md")
+		require.NoError(t, err)
+		_, err = writer.Commit(ctx, "Scale up", author, committer)
+		require.NoError(t, err)
+	}
+
+	t.Run("replays commits on the new tip", func(t *testing.T) {
+		t.Parallel()
+		repo, ref := setup(t, 1, map[string]string{"README.md": "readme "})
+		client := &httpClient{RawClient: repo}
+		writer, err := client.NewStagedWriter(context.Background(), ref, WithAutoRebase(1))
+		require.NoError(t, err)
+		stage(t, writer)
+
+		require.NoError(t, writer.Push(context.Background()))
+		require.Equal(t, 1, repo.pushes)
+
+		tip := repo.refHash("refs/heads/main")
+		last, err := client.GetCommit(context.Background(), tip)
+		require.NoError(t, err)
+		require.Equal(t, "Scale up", last.Message)
+		require.Equal(t, author.Name, last.Author.Name)
+		require.Equal(t, committer.Name, last.Committer.Name)
+		require.Equal(t, committer.Time.Unix(), last.Committer.Time.Unix())
+		first, err := client.GetCommit(context.Background(), last.Parent)
+		require.NoError(t, err)
+		require.Equal(t, "Add new page", first.Message)
+		upstream, err := client.GetCommit(context.Background(), first.Parent)
+		require.NoError(t, err)
+		require.Equal(t, "upstream", upstream.Message)
+		require.Equal(t, ref.Hash, upstream.Parent)
+
+		got := map[string]string{}
+		tree, err := client.GetFlatTree(context.Background(), tip)
+		require.NoError(t, err)
+		for _, entry := range tree.Entries {
+			if entry.Type == protocol.ObjectTypeBlob {
+				blob, err := client.GetBlob(context.Background(), entry.Hash)
+				require.NoError(t, err)
+				got[entry.Path] = string(blob.Content)
+			}
+		}
+		require.Equal(t, map[string]string{
+			"README.md":   "readme a",
+			"config.yaml": "replicas: 3",
+			"guide.md":    "guide",
+			"docs/new.md": "new",
+		}, got)
+
+		// The writer continues from the pushed commit.
+		_, err = writer.CreateBlob(context.Background(), "later.txt", []byte("later"))
+		require.NoError(t, err)
+		_, err = writer.Commit(context.Background(), "Later", author, committer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Push(context.Background()))
+		require.Equal(t, 2, repo.pushes)
+	})
+
+	t.Run("fails on a path changed upstream", func(t *testing.T) {
+		t.Parallel()
+		repo, ref := setup(t, 1, map[string]string{"config.yaml": "replicas: 2", "docs/guide.md": "changed"})
+		client := &httpClient{RawClient: repo}
+		writer, err := client.NewStagedWriter(context.Background(), ref, WithAutoRebase(3))
+		require.NoError(t, err)
+		stage(t, writer)
+
+		err = writer.Push(context.Background())
+		require.ErrorIs(t, err, ErrRebaseConflict)
+		var conflictErr *RebaseConflictError
+		require.True(t, errors.As(err, &conflictErr))
+		require.Equal(t, "refs/heads/main", conflictErr.RefName)
+		require.Equal(t, []string{"config.yaml", "docs/guide.md"}, conflictErr.Paths)
+		require.Zero(t, repo.pushes)
+
+		// The writer is left as it was.
+		exists, err := writer.BlobExists(context.Background(), "guide.md")
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		t.Parallel()
+		repo, ref := setup(t, 3, map[string]string{"README.md": "readme "})
+		client := &httpClient{RawClient: repo}
+		writer, err := client.NewStagedWriter(context.Background(), ref, WithAutoRebase(2))
+		require.NoError(t, err)
+		stage(t, writer)
+
+		err = writer.Push(context.Background())
+		require.True(t, protocol.IsGitReferenceUpdateError(err))
+		require.Zero(t, repo.pushes)
+		require.Equal(t, 3, repo.attempts)
+
+		// Once the other writer stops, pushing again rebases and succeeds.
+		require.NoError(t, writer.Push(context.Background()))
+		require.Equal(t, 1, repo.pushes)
+	})
+
+	t.Run("returns the rejection without the option", func(t *testing.T) {
+		t.Parallel()
+		repo, ref := setup(t, 1, map[string]string{"README.md": "readme "})
+		client := &httpClient{RawClient: repo}
+		writer, err := client.NewStagedWriter(context.Background(), ref)
+		require.NoError(t, err)
+		stage(t, writer)
+
+		err = writer.Push(context.Background())
+		require.True(t, protocol.IsGitReferenceUpdateError(err))
+		require.Zero(t, repo.pushes)
+		require.NotEqual(t, ref.Hash, repo.refHash("refs/heads/main"))
+	})
+
+	t.Run("does not retry when the ref did not move", func(t *testing.T) {
+		t.Parallel()
+		repo, ref := setup(t, 0, nil)
+		client := &httpClient{RawClient: repo}
+		repo.declined = true
+		writer, err := client.NewStagedWriter(context.Background(), ref, WithAutoRebase(3))
+		require.NoError(t, err)
+		stage(t, writer)
+
+		err = writer.Push(context.Background())
+		require.ErrorContains(t, err, "pre-receive hook declined")
+		require.Equal(t, 1, repo.attempts)
+	})
+}
@@ -0,0 +1,214 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// Amend replaces the last commit not pushed yet with a commit of its
+// changes and the changes staged since, like `git commit --amend`.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - message: Message of the new commit; empty keeps the message of the amended commit
+//   - author: Author of the new commit
+//   - committer: Committer of the new commit
+//
+// Returns:
+//   - *Commit: The commit that replaces the last one
+//   - error: ErrUnpushedCommitNotFound if every commit has been pushed
+//
+// Example:
+//
+//	writer.UpdateBlob(ctx, "config.yaml", []byte("replicas: 3"))
+//	commit, err := writer.Amend(ctx, "", author, committer)
+func (w *stagedWriter) Amend(ctx context.Context, message string, author Author, committer Committer) (*Commit, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return nil, err
+	}
+	if len(w.unpushed) == 0 {
+		return nil, fmt.Errorf("amend: %w", ErrUnpushedCommitNotFound)
+	}
+
+	last := w.unpushed[len(w.unpushed)-1]
+	if message == "" {
+		message = last.message
+	}
+	ops := append(slices.Clone(last.ops), w.staged...)
+
+	log.FromContext(ctx).Debug("Amend commit",
+		"commit_hash", last.commit.Hash.String(),
+		"operation_count", len(ops))
+
+	if err := w.rewind(ctx, len(w.unpushed)-1); err != nil {
+		return nil, err
+	}
+	if err := w.replay(ctx, ops); err != nil {
+		return nil, err
+	}
+	return w.commit(ctx, message, author, committer, nil)
+}
+
+// Squash replaces the last n commits not pushed yet with a single commit
+// of all their changes. The new commit has the author of the first of them
+// and the committer of the last. Changes staged since the last commit stay
+// staged.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - n: Number of commits to squash, at most the number of unpushed commits
+//   - message: Message of the new commit; empty joins the messages of the squashed commits
+//
+// Returns:
+//   - *Commit: The commit that replaces the squashed ones
+//   - error: ErrUnpushedCommitNotFound if fewer than n commits are unpushed
+//
+// Example:
+//
+//	commit, err := writer.Squash(ctx, 3, "Update dashboards")
+func (w *stagedWriter) Squash(ctx context.Context, n int, message string) (*Commit, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(w.unpushed) {
+		return nil, fmt.Errorf("squash %d of %d commits: %w", n, len(w.unpushed), ErrUnpushedCommitNotFound)
+	}
+
+	keep := len(w.unpushed) - n
+	squashed := slices.Clone(w.unpushed[keep:])
+	staged := w.staged
+
+	var ops []writerOp
+	messages := make([]string, 0, n)
+	for _, commit := range squashed {
+		ops = append(ops, commit.ops...)
+		messages = append(messages, strings.TrimRight(commit.message, "\n"))
+	}
+	if message == "" {
+		message = strings.Join(messages, "\n\n")
+	}
+
+	log.FromContext(ctx).Debug("Squash commits",
+		"commit_count", n,
+		"operation_count", len(ops))
+
+	if err := w.rewind(ctx, keep); err != nil {
+		return nil, err
+	}
+	if err := w.replay(ctx, ops); err != nil {
+		return nil, err
+	}
+	commit, err := w.commit(ctx, message, squashed[0].author, squashed[n-1].committer, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.replay(ctx, staged); err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+// ResetTo drops the commits not pushed yet that come after commit, and
+// every staged change, like `git reset --hard`. The objects they added are
+// removed from the packfile, so Push no longer sends them. Resetting to the
+// commit the writer started from, or last pushed, drops all of them.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - commit: Hash of an unpushed commit, or of the commit the unpushed ones start from
+//
+// Returns:
+//   - error: ErrUnpushedCommitNotFound if commit is neither
+//
+// Example:
+//
+//	first, _ := writer.Commit(ctx, "Step 1", author, committer)
+//	writer.Commit(ctx, "Step 2", author, committer)
+//	err := writer.ResetTo(ctx, first.Hash)
+func (w *stagedWriter) ResetTo(ctx context.Context, commit hash.Hash) error {
+	if err := w.checkCleanupState(); err != nil {
+		return err
+	}
+
+	keep := -1
+	if commit == w.rewindBase() {
+		keep = 0
+	}
+	for i, unpushed := range w.unpushed {
+		if unpushed.commit.Hash == commit {
+			keep = i + 1
+		}
+	}
+	if keep < 0 {
+		return fmt.Errorf("reset to %s: %w", commit.String(), ErrUnpushedCommitNotFound)
+	}
+
+	log.FromContext(ctx).Debug("Reset writer",
+		"commit_hash", commit.String(),
+		"dropped_commits", len(w.unpushed)-keep)
+
+	var checkpoint protocol.PackfileCheckpoint
+	if keep > 0 {
+		checkpoint = w.unpushed[keep-1].checkpoint
+	}
+	if err := w.rewind(ctx, keep); err != nil {
+		return err
+	}
+	if err := w.writer.Rollback(checkpoint); err != nil {
+		return fmt.Errorf("drop objects after %s: %w", commit.String(), err)
+	}
+	return nil
+}
+
+// rewindBase returns the commit the unpushed commits start from.
+func (w *stagedWriter) rewindBase() hash.Hash {
+	if len(w.unpushed) == 0 {
+		return w.lastCommit.Hash
+	}
+	return w.unpushed[0].commit.Parent
+}
+
+// rewind drops the unpushed commits after the first keep ones and the
+// changes staged since. The state of the last kept commit is rebuilt by
+// replaying the operations of the kept commits on the commit they start
+// from, as rebase does. Objects already added to the packfile are kept.
+func (w *stagedWriter) rewind(ctx context.Context, keep int) error {
+	for _, commit := range w.unpushed {
+		if len(commit.mergeParents) > 0 {
+			// The tree of a merge commit is not made of recorded operations.
+			return fmt.Errorf("rewrite merge commit %s: not supported", commit.commit.Hash.String())
+		}
+	}
+
+	commits := slices.Clip(w.unpushed[:keep])
+	base := w.rewindBase()
+	if err := w.reset(ctx, base); err != nil {
+		return fmt.Errorf("reset to %s: %w", base.String(), err)
+	}
+	w.unpushed = nil
+
+	for _, commit := range commits {
+		if err := w.replay(ctx, commit.ops); err != nil {
+			return err
+		}
+	}
+	if keep > 0 {
+		if err := w.buildPendingTrees(ctx); err != nil {
+			return fmt.Errorf("build pending trees: %w", err)
+		}
+		last := commits[keep-1].commit
+		if w.lastTree.Hash != last.Tree {
+			return fmt.Errorf("rebuilt tree %s differs from tree %s of commit %s", w.lastTree.Hash.String(), last.Tree.String(), last.Hash.String())
+		}
+		w.lastCommit = last
+	}
+	w.unpushed = commits
+	w.staged = nil
+	return nil
+}
@@ -0,0 +1,175 @@
+package nanogit
+
+import (
+	"context"
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagedWriter_Rewrite(t *testing.T) {
+	t.Parallel()
+
+	author := func(name string) Author {
+		return Author{Name: name, Email: name + "@example.com", Time: time.Unix(1700000000, 0)}
+	}
+	committer := func(name string) Committer {
+		return Committer{Name: name, Email: name + "@example.com", Time: time.Unix(1700000100, 0)}
+	}
+	base := map[string]string{
+		"README.md":   "readme",
+		"config.yaml": "replicas: 1",
+	}
+	setup := func(t *testing.T, opts ...WriterOption) (*testRepo, StagedWriter, hash.Hash) {
+		repo := newTestRepo(t)
+		main := repo.commit("base", base)
+		repo.ref("refs/heads/main", main)
+		writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main", Hash: main}, opts...)
+		require.NoError(t, err)
+		return repo, writer, main
+	}
+	// step stages a file and commits it.
+	step := func(t *testing.T, writer StagedWriter, path, content, name string) *Commit {
+		_, err := writer.CreateBlob(context.Background(), path, []byte(content))
+		require.NoError(t, err)
+		commit, err := writer.Commit(context.Background(), "Add "+path, author(name), committer(name))
+		require.NoError(t, err)
+		return commit
+	}
+	files := func(t *testing.T, repo *testRepo, commit hash.Hash) map[string]string {
+		t.Helper()
+		tree, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		out := make(map[string]string)
+		for _, entry := range tree.Entries {
+			if entry.Type != protocol.ObjectTypeBlob {
+				continue
+			}
+			blob, err := repo.client().GetBlob(context.Background(), entry.Hash)
+			require.NoError(t, err)
+			out[entry.Path] = string(blob.Content)
+		}
+		return out
+	}
+
+	t.Run("amend adds staged changes to the last commit", func(t *testing.T) {
+		t.Parallel()
+		repo, writer, main := setup(t)
+		ctx := context.Background()
+		first := step(t, writer, "a.txt", "a", "alice")
+		step(t, writer, "b.txt", "b", "alice")
+		_, err := writer.UpdateBlob(ctx, "b.txt", []byte("b fixed"))
+		require.NoError(t, err)
+
+		amended, err := writer.Amend(ctx, "", author("bob"), committer("bob"))
+		require.NoError(t, err)
+		require.Equal(t, first.Hash, amended.Parent)
+		require.Equal(t, "Add b.txt", amended.Message)
+		require.NoError(t, writer.Push(ctx))
+
+		require.Equal(t, amended.Hash, repo.refHash("refs/heads/main"))
+		got, err := repo.client().GetCommit(ctx, amended.Hash)
+		require.NoError(t, err)
+		require.Equal(t, "bob", got.Author.Name)
+		require.Equal(t, map[string]string{"README.md": "readme", "config.yaml": "replicas: 1", "a.txt": "a", "b.txt": "b fixed"}, files(t, repo, amended.Hash))
+		parent, err := repo.client().GetCommit(ctx, first.Hash)
+		require.NoError(t, err)
+		require.Equal(t, main, parent.Parent)
+
+		_, err = writer.Amend(ctx, "Nothing to amend", author("bob"), committer("bob"))
+		require.ErrorIs(t, err, ErrUnpushedCommitNotFound)
+	})
+
+	t.Run("amend keeps a root commit without parent", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		ctx := context.Background()
+		writer, err := repo.client().NewStagedWriter(ctx, Ref{Name: "refs/heads/main"})
+		require.NoError(t, err)
+		step(t, writer, "a.txt", "a", "alice")
+
+		amended, err := writer.Amend(ctx, "Initial commit", author("alice"), committer("alice"))
+		require.NoError(t, err)
+		require.Empty(t, amended.Parents)
+		require.NoError(t, writer.Push(ctx))
+		require.Equal(t, map[string]string{"a.txt": "a"}, files(t, repo, repo.refHash("refs/heads/main")))
+	})
+
+	t.Run("squash collapses the last commits", func(t *testing.T) {
+		t.Parallel()
+		repo, writer, _ := setup(t)
+		ctx := context.Background()
+		first := step(t, writer, "a.txt", "a", "alice")
+		step(t, writer, "b.txt", "b", "bob")
+		step(t, writer, "c.txt", "c", "carol")
+		_, err := writer.DeleteBlob(ctx, "README.md")
+		require.NoError(t, err)
+
+		squashed, err := writer.Squash(ctx, 2, "")
+		require.NoError(t, err)
+		require.Equal(t, first.Hash, squashed.Parent)
+		require.Equal(t, "Add b.txt\n\nAdd c.txt", squashed.Message)
+		require.Equal(t, "bob", squashed.Author.Name)
+		require.Equal(t, "carol", squashed.Committer.Name)
+
+		// The staged deletion is still staged.
+		status, err := writer.Status()
+		require.NoError(t, err)
+		require.Equal(t, []string{"README.md"}, status.Deleted)
+		last, err := writer.Commit(ctx, "Remove readme", author("dave"), committer("dave"))
+		require.NoError(t, err)
+		require.Equal(t, squashed.Hash, last.Parent)
+		require.NoError(t, writer.Push(ctx))
+
+		require.Equal(t, map[string]string{"config.yaml": "replicas: 1", "a.txt": "a", "b.txt": "b", "c.txt": "c"}, files(t, repo, last.Hash))
+		require.Equal(t, map[string]string{"README.md": "readme", "config.yaml": "replicas: 1", "a.txt": "a", "b.txt": "b", "c.txt": "c"}, files(t, repo, squashed.Hash))
+
+		_, err = writer.Squash(ctx, 1, "Nothing to squash")
+		require.ErrorIs(t, err, ErrUnpushedCommitNotFound)
+	})
+
+	for _, tt := range []struct {
+		name string
+		opts []WriterOption
+	}{
+		{name: "memory storage", opts: []WriterOption{WithMemoryStorage()}},
+		{name: "disk storage", opts: []WriterOption{WithDiskStorage()}},
+	} {
+		t.Run("reset drops later commits and their objects with "+tt.name, func(t *testing.T) {
+			t.Parallel()
+			repo, writer, main := setup(t, tt.opts...)
+			ctx := context.Background()
+			first := step(t, writer, "a.txt", "a", "alice")
+			step(t, writer, "b.txt", "b", "alice")
+			_, err := writer.CreateBlob(ctx, "c.txt", []byte("c"))
+			require.NoError(t, err)
+
+			require.NoError(t, writer.ResetTo(ctx, first.Hash))
+			exists, err := writer.BlobExists(ctx, "b.txt")
+			require.NoError(t, err)
+			require.False(t, exists)
+
+			// The writer continues from the commit it was reset to.
+			second := step(t, writer, "d.txt", "d", "alice")
+			require.Equal(t, first.Hash, second.Parent)
+			require.NoError(t, writer.Push(ctx))
+			require.Equal(t, map[string]string{"README.md": "readme", "config.yaml": "replicas: 1", "a.txt": "a", "d.txt": "d"}, files(t, repo, second.Hash))
+
+			// The dropped blobs were not pushed.
+			for _, content := range []string{"b", "c"} {
+				h, err := protocol.Object(crypto.SHA1, protocol.ObjectTypeBlob, []byte(content))
+				require.NoError(t, err)
+				require.NotContains(t, repo.objects, h.String())
+			}
+
+			step(t, writer, "e.txt", "e", "alice")
+			require.NoError(t, writer.ResetTo(ctx, second.Hash))
+			require.ErrorIs(t, writer.Push(ctx), ErrNothingToPush)
+			require.ErrorIs(t, writer.ResetTo(ctx, main), ErrUnpushedCommitNotFound)
+		})
+	}
+}
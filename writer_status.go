@@ -0,0 +1,191 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// WriterStatus lists the paths a StagedWriter changes relative to the
+// commit its reference points at, like `git status`. Each list is sorted.
+type WriterStatus struct {
+	// Added are the paths of new files and submodules.
+	Added []string
+	// Modified are the paths whose content or mode changed.
+	Modified []string
+	// Deleted are the paths of removed files and submodules.
+	Deleted []string
+	// Renamed are the files moved without changing their content.
+	Renamed []RenamedPath
+}
+
+// RenamedPath is a file moved from OldPath to Path.
+type RenamedPath struct {
+	OldPath string
+	Path    string
+}
+
+// IsClean reports whether the writer has nothing to push.
+func (s *WriterStatus) IsClean() bool {
+	return len(s.Added) == 0 && len(s.Modified) == 0 && len(s.Deleted) == 0 && len(s.Renamed) == 0
+}
+
+// Status returns the paths the writer changes relative to the commit its
+// reference points at. Both staged changes and commits not pushed yet are
+// included; after Push the status is clean again. Moves are reported as
+// renames when the content is unchanged.
+//
+// Returns:
+//   - *WriterStatus: The added, modified, deleted and renamed paths
+//   - error: Error if the writer has been cleaned up
+//
+// Example:
+//
+//	status, err := writer.Status()
+//	if err != nil {
+//	    return err
+//	}
+//	for _, path := range status.Modified {
+//	    fmt.Printf("M %s\n", path)
+//	}
+func (w *stagedWriter) Status() (*WriterStatus, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return nil, err
+	}
+
+	status := &WriterStatus{}
+	for _, change := range w.client.compareTrees(&FlatTree{Entries: w.base}, &FlatTree{Entries: w.files()}, &CompareCommitsOptions{DetectRenames: true}) {
+		switch change.Status {
+		case protocol.FileStatusAdded:
+			status.Added = append(status.Added, change.Path)
+		case protocol.FileStatusDeleted:
+			status.Deleted = append(status.Deleted, change.Path)
+		case protocol.FileStatusRenamed:
+			status.Renamed = append(status.Renamed, RenamedPath{OldPath: change.OldPath, Path: change.Path})
+		default:
+			status.Modified = append(status.Modified, change.Path)
+		}
+	}
+	return status, nil
+}
+
+// Diff returns the files the writer changes relative to the commit its
+// reference points at, as CompareCommits does between two commits. Both
+// staged changes and commits not pushed yet are included. With
+// WithPatches, the content of new files is read from the objects staged
+// in the writer.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - options: WithRenameDetection and WithPatches, as for CompareCommits
+//
+// Returns:
+//   - []CommitFile: The changed files, sorted by path
+//   - error: Error if the writer has been cleaned up or a blob can't be read
+//
+// Example:
+//
+//	changes, err := writer.Diff(ctx, nanogit.WithPatches())
+//	if err != nil {
+//	    return err
+//	}
+//	for _, change := range changes {
+//	    fmt.Print(change.Patch)
+//	}
+func (w *stagedWriter) Diff(ctx context.Context, opts ...CompareCommitsOption) ([]CommitFile, error) {
+	if err := w.checkCleanupState(); err != nil {
+		return nil, err
+	}
+
+	options := defaultCompareCommitsOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	changes := w.client.compareTrees(&FlatTree{Entries: w.base}, &FlatTree{Entries: w.files()}, options)
+	log.FromContext(ctx).Debug("Diff staged changes",
+		"ref_name", w.ref.Name,
+		"changes", len(changes))
+
+	if options.Patches {
+		if err := addPatches(ctx, changes, w.readBlobs); err != nil {
+			return nil, fmt.Errorf("add patches: %w", err)
+		}
+	}
+	return changes, nil
+}
+
+// files returns the files and submodules of the staged tree, sorted by
+// path. Directories are left out: they change only through their files.
+func (w *stagedWriter) files() []FlatTreeEntry {
+	files := make([]FlatTreeEntry, 0, len(w.treeEntries)+len(w.submoduleEntries))
+	for _, entry := range w.treeEntries {
+		if entry.Type != protocol.ObjectTypeTree {
+			files = append(files, *entry)
+		}
+	}
+	for path, entry := range w.submoduleEntries {
+		if w.submoduleInTree(path) {
+			files = append(files, *entry)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// readBlobs is a blobReader looking for the blobs among the objects staged
+// in the writer, and fetching the others from the remote.
+func (w *stagedWriter) readBlobs(ctx context.Context, hashes []hash.Hash) (map[hash.Hash][]byte, error) {
+	contents := make(map[hash.Hash][]byte, len(hashes))
+	var missing []hash.Hash
+	for _, h := range hashes {
+		content, ok, err := w.stagedBlob(h)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			contents[h] = content
+		} else {
+			missing = append(missing, h)
+		}
+	}
+	fetched, err := w.client.readBlobs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(contents, fetched)
+	return contents, nil
+}
+
+// readBlob returns the content of a blob, looking for it among the objects
+// staged in the writer before fetching it from the remote.
+func (w *stagedWriter) readBlob(ctx context.Context, h hash.Hash) ([]byte, error) {
+	content, ok, err := w.stagedBlob(h)
+	if err != nil || ok {
+		return content, err
+	}
+	return w.client.readBlob(ctx, h)
+}
+
+// stagedBlob returns the content of a blob staged in the writer or found in
+// its object storage.
+func (w *stagedWriter) stagedBlob(h hash.Hash) ([]byte, bool, error) {
+	obj, ok, err := w.writer.GetObject(h)
+	if err != nil {
+		return nil, false, fmt.Errorf("read staged object: %w", err)
+	}
+	if ok {
+		return obj.Data, true, nil
+	}
+	if obj, ok := w.objStorage.GetByType(h, protocol.ObjectTypeBlob); ok {
+		return obj.Data, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,138 @@
+package nanogit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/nanogit/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagedWriter_StatusAndDiff(t *testing.T) {
+	t.Parallel()
+
+	author := Author{Name: "Writer", Email: "writer@example.com", Time: time.Unix(1700000000, 0)}
+	committer := Committer{Name: "Bot", Email: "bot@example.com", Time: time.Unix(1700000100, 0)}
+	base := map[string]string{
+		"README.md":     "readme\n",
+		"config.yaml":   "replicas: 1\ntimeout: 10\n",
+		"docs/guide.md": "guide\n",
+		"old.txt":       "old\n",
+		"run.sh":        "echo hi\n",
+	}
+	setup := func(t *testing.T, opts ...WriterOption) (*testRepo, StagedWriter) {
+		repo := newTestRepo(t)
+		main := repo.commit("base", base)
+		repo.ref("refs/heads/main", main)
+		writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main", Hash: main}, opts...)
+		require.NoError(t, err)
+		return repo, writer
+	}
+	stage := func(t *testing.T, writer StagedWriter) {
+		ctx := context.Background()
+		_, err := writer.CreateBlob(ctx, "docs/new.md", []byte("new\n"))
+		require.NoError(t, err)
+		_, err = writer.UpdateBlob(ctx, "config.yaml", []byte("replicas: 3\ntimeout: 10\n"))
+		require.NoError(t, err)
+		_, err = writer.DeleteBlob(ctx, "old.txt")
+		require.NoError(t, err)
+		_, err = writer.MoveBlob(ctx, "docs/guide.md", "guide.md")
+		require.NoError(t, err)
+		require.NoError(t, writer.SetMode(ctx, "run.sh", 0o100755))
+	}
+	wantStatus := &WriterStatus{
+		Added:    []string{"docs/new.md"},
+		Modified: []string{"config.yaml", "run.sh"},
+		Deleted:  []string{"old.txt"},
+		Renamed:  []RenamedPath{{OldPath: "docs/guide.md", Path: "guide.md"}},
+	}
+
+	t.Run("reports staged and committed changes until push", func(t *testing.T) {
+		t.Parallel()
+		_, writer := setup(t)
+		ctx := context.Background()
+
+		status, err := writer.Status()
+		require.NoError(t, err)
+		require.True(t, status.IsClean())
+
+		stage(t, writer)
+		status, err = writer.Status()
+		require.NoError(t, err)
+		require.Equal(t, wantStatus, status)
+
+		_, err = writer.Commit(ctx, "Change things", author, committer)
+		require.NoError(t, err)
+		status, err = writer.Status()
+		require.NoError(t, err)
+		require.Equal(t, wantStatus, status)
+
+		// A second commit adds to the pending change set.
+		_, err = writer.DeleteBlob(ctx, "docs/new.md")
+		require.NoError(t, err)
+		_, err = writer.Commit(ctx, "Drop new page", author, committer)
+		require.NoError(t, err)
+		status, err = writer.Status()
+		require.NoError(t, err)
+		require.Empty(t, status.Added)
+		require.Equal(t, wantStatus.Modified, status.Modified)
+
+		require.NoError(t, writer.Push(ctx))
+		status, err = writer.Status()
+		require.NoError(t, err)
+		require.True(t, status.IsClean())
+		changes, err := writer.Diff(ctx)
+		require.NoError(t, err)
+		require.Empty(t, changes)
+	})
+
+	for _, tt := range []struct {
+		name string
+		opts []WriterOption
+	}{
+		{name: "memory storage", opts: []WriterOption{WithMemoryStorage()}},
+		{name: "disk storage", opts: []WriterOption{WithDiskStorage()}},
+	} {
+		t.Run("diff with patches in "+tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, writer := setup(t, tt.opts...)
+			ctx := context.Background()
+			stage(t, writer)
+			_, err := writer.Commit(ctx, "Change things", author, committer)
+			require.NoError(t, err)
+
+			changes, err := writer.Diff(ctx, WithRenameDetection(), WithPatches())
+			require.NoError(t, err)
+			patches := make(map[string]string, len(changes))
+			statuses := make(map[string]protocol.FileStatus, len(changes))
+			for _, change := range changes {
+				patches[change.Path] = change.Patch
+				statuses[change.Path] = change.Status
+			}
+			require.Equal(t, map[string]protocol.FileStatus{
+				"config.yaml": protocol.FileStatusModified,
+				"docs/new.md": protocol.FileStatusAdded,
+				"guide.md":    protocol.FileStatusRenamed,
+				"old.txt":     protocol.FileStatusDeleted,
+				"run.sh":      protocol.FileStatusModified,
+			}, statuses)
+			require.Contains(t, patches["config.yaml"], "@@ -1,2 +1,2 @@\n-replicas: 1\n+replicas: 3\n timeout: 10\n")
+			require.Contains(t, patches["docs/new.md"], "new file mode 100644\n")
+			require.Contains(t, patches["docs/new.md"], "@@ -0,0 +1 @@\n+new\n")
+			require.Contains(t, patches["old.txt"], "@@ -1 +0,0 @@\n-old\n")
+			require.Equal(t, "diff --git a/docs/guide.md b/guide.md\nsimilarity index 100%\nrename from docs/guide.md\nrename to guide.md\n", patches["guide.md"])
+			require.Equal(t, "diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n", patches["run.sh"])
+		})
+	}
+
+	t.Run("fails after cleanup", func(t *testing.T) {
+		t.Parallel()
+		_, writer := setup(t)
+		require.NoError(t, writer.Cleanup(context.Background()))
+		_, err := writer.Status()
+		require.ErrorIs(t, err, ErrWriterCleanedUp)
+		_, err = writer.Diff(context.Background())
+		require.ErrorIs(t, err, ErrWriterCleanedUp)
+	})
+}
@@ -0,0 +1,233 @@
+package nanogit
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/grafana/nanogit/log"
+	"github.com/grafana/nanogit/protocol"
+	"github.com/grafana/nanogit/protocol/hash"
+)
+
+// gitmodulesPath is the path of the file that maps submodule paths to the
+// URLs of their repositories.
+const gitmodulesPath = ".gitmodules"
+
+// AddSubmodule stages a submodule (gitlink) at the specified path, pinned to
+// a commit of the repository at url, and adds its section to .gitmodules.
+// A section of .gitmodules that already names the path has its URL
+// replaced. The commit is not fetched: it lives in the other repository.
+//
+// This operation stages the submodule but does not immediately commit it.
+// You must call Commit() and Push() to persist the changes.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - path: Path of the submodule (e.g., "vendor/lib")
+//   - url: URL of the submodule repository
+//   - commit: Hash of the commit the submodule is pinned to
+//
+// Returns:
+//   - error: Error if the path already exists or if staging fails
+//
+// Example:
+//
+//	err := writer.AddSubmodule(ctx, "vendor/lib", "https://github.com/org/lib.git", commitHash)
+func (w *stagedWriter) AddSubmodule(ctx context.Context, path, url string, commit hash.Hash) error {
+	if err := w.checkCleanupState(); err != nil {
+		return err
+	}
+
+	if path == "" {
+		return ErrEmptyPath
+	}
+
+	if url == "" {
+		return fmt.Errorf("add submodule %q: empty url", path)
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Add submodule",
+		"path", path,
+		"url", url,
+		"commit_hash", commit.String())
+
+	if obj, ok := w.treeEntries[path]; ok {
+		return NewObjectAlreadyExistsError(obj.Hash)
+	}
+	if sub, ok := w.submoduleEntries[path]; ok {
+		return NewObjectAlreadyExistsError(sub.Hash)
+	}
+
+	content, mode, err := w.gitmodules(ctx)
+	if err != nil {
+		return err
+	}
+	content = setGitmodulesURL(content, path, url)
+
+	blobHash, err := w.writer.AddBlob(content)
+	if err != nil {
+		return fmt.Errorf("create blob at %q: %w", gitmodulesPath, err)
+	}
+	// Keep the content at hand for the next submodule added before a push.
+	w.objStorage.Add(&protocol.PackfileObject{Hash: blobHash, Type: protocol.ObjectTypeBlob, Data: content})
+	if err := w.setBlob(ctx, gitmodulesPath, blobHash, mode); err != nil {
+		return err
+	}
+
+	if err := w.setGitlink(ctx, path, commit); err != nil {
+		return err
+	}
+	w.staged = append(w.staged, writerOp{kind: writerOpAddSubmodule, path: path, hash: commit, url: url})
+
+	logger.Debug("Submodule added",
+		"path", path,
+		"gitmodules_hash", blobHash.String())
+
+	return nil
+}
+
+// UpdateSubmodule pins the submodule at the specified path to another
+// commit, like committing after `git submodule update --remote`. The
+// section of the submodule in .gitmodules is left as it is.
+//
+// This operation stages the change but does not immediately commit it.
+// You must call Commit() and Push() to persist the changes.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - path: Path of the existing submodule
+//   - commit: Hash of the commit the submodule is pinned to
+//
+// Returns:
+//   - error: Error if there is no submodule at the path
+//
+// Example:
+//
+//	err := writer.UpdateSubmodule(ctx, "vendor/lib", newCommitHash)
+func (w *stagedWriter) UpdateSubmodule(ctx context.Context, path string, commit hash.Hash) error {
+	if err := w.checkCleanupState(); err != nil {
+		return err
+	}
+
+	if path == "" {
+		return ErrEmptyPath
+	}
+
+	logger := log.FromContext(ctx)
+	logger.Debug("Update submodule",
+		"path", path,
+		"commit_hash", commit.String())
+
+	sub, ok := w.submoduleEntries[path]
+	if _, shadowed := w.treeEntries[path]; !ok || shadowed {
+		return NewPathNotFoundError(path)
+	}
+	if sub.Hash == commit {
+		return nil
+	}
+
+	if err := w.setGitlink(ctx, path, commit); err != nil {
+		return err
+	}
+	w.staged = append(w.staged, writerOp{kind: writerOpUpdateSubmodule, path: path, hash: commit})
+	return nil
+}
+
+// setGitlink stages a gitlink to commit at path. Submodules are kept out of
+// treeEntries and merged back when the parent tree is built.
+func (w *stagedWriter) setGitlink(ctx context.Context, path string, commit hash.Hash) error {
+	w.setEntry(w.submoduleEntries, path, &FlatTreeEntry{
+		Name: path[strings.LastIndex(path, "/")+1:],
+		Path: path,
+		Hash: commit,
+		Type: protocol.ObjectTypeCommit,
+		Mode: 0o160000,
+	})
+
+	if err := w.addMissingOrStaleTreeEntries(ctx, path, commit); err != nil {
+		return fmt.Errorf("update tree structure for %q: %w", path, err)
+	}
+	return nil
+}
+
+// gitmodules returns the staged content and mode of .gitmodules, or no
+// content and a regular file mode when there is none.
+func (w *stagedWriter) gitmodules(ctx context.Context) ([]byte, uint32, error) {
+	entry, ok := w.treeEntries[gitmodulesPath]
+	if !ok {
+		return nil, 0o100644, nil
+	}
+	if entry.Type != protocol.ObjectTypeBlob {
+		return nil, 0, NewUnexpectedObjectTypeError(entry.Hash, protocol.ObjectTypeBlob, entry.Type)
+	}
+
+	if obj, ok := w.objStorage.GetByType(entry.Hash, protocol.ObjectTypeBlob); ok {
+		return obj.Data, entry.Mode, nil
+	}
+	blob, err := w.client.GetBlob(ctx, entry.Hash)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get %s: %w", gitmodulesPath, err)
+	}
+	return blob.Content, entry.Mode, nil
+}
+
+// setGitmodulesURL returns the content of a .gitmodules file with url set
+// for the submodule at path. The section whose path key names the
+// submodule gets the URL; without one, a section named after the path is
+// appended.
+func setGitmodulesURL(content []byte, path, url string) []byte {
+	text := string(content)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	lines := strings.SplitAfter(text, "\n")
+	lines = lines[:len(lines)-1]
+	urlLine := "\turl = " + url + "\n"
+
+	// Each section runs from its header to the next one.
+	var headers []int
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			headers = append(headers, i)
+		}
+	}
+	for n, start := range headers {
+		end := len(lines)
+		if n+1 < len(headers) {
+			end = headers[n+1]
+		}
+		matched, urlAt := false, -1
+		for i := start + 1; i < end; i++ {
+			key, value, ok := gitmodulesKey(lines[i])
+			switch {
+			case ok && key == "path" && value == path:
+				matched = true
+			case ok && key == "url":
+				urlAt = i
+			}
+		}
+		if !matched {
+			continue
+		}
+		if urlAt >= 0 {
+			lines[urlAt] = urlLine
+		} else {
+			lines = slices.Insert(lines, end, urlLine)
+		}
+		return []byte(strings.Join(lines, ""))
+	}
+
+	return fmt.Appendf([]byte(text), "[submodule %q]\n\tpath = %s\n%s", path, path, urlLine)
+}
+
+// gitmodulesKey parses a "key = value" line of a .gitmodules file.
+func gitmodulesKey(line string) (string, string, bool) {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value), true
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto"
 	"testing"
+	"time"
 
 	"github.com/grafana/nanogit/protocol"
 	"github.com/grafana/nanogit/protocol/hash"
@@ -324,3 +325,117 @@ func TestCleanup_ResetsSubmoduleEntries(t *testing.T) {
 		"Cleanup must drop submoduleEntries for GC; otherwise long-lived "+
 			"writers retain references to the repo's initial submodule list")
 }
+
+func TestStagedWriter_Submodules(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	author := Author{Name: "A", Email: "a@example.com", Time: time.Unix(1700000000, 0)}
+	committer := Committer{Name: "C", Email: "c@example.com", Time: time.Unix(1700000000, 0)}
+	pin := hashOrFail(t, "1111111111111111111111111111111111111111")
+	bump := hashOrFail(t, "2222222222222222222222222222222222222222")
+	// gitlinks returns the submodules of a commit and the content of its
+	// .gitmodules.
+	gitlinks := func(t *testing.T, repo *testRepo, commit hash.Hash) (map[string]hash.Hash, string) {
+		t.Helper()
+		client := repo.client()
+		tree, submodules, err := client.getFlatTreeWithSubmodules(ctx, commit)
+		require.NoError(t, err)
+		links := make(map[string]hash.Hash)
+		for _, entry := range submodules {
+			require.Equal(t, uint32(0o160000), entry.Mode)
+			links[entry.Path] = entry.Hash
+		}
+		for _, entry := range tree.Entries {
+			if entry.Path == gitmodulesPath {
+				blob, err := client.GetBlob(ctx, entry.Hash)
+				require.NoError(t, err)
+				return links, string(blob.Content)
+			}
+		}
+		return links, ""
+	}
+
+	t.Run("adds submodules and their sections", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		main := repo.commit("main", map[string]string{"README.md": "readme"})
+		repo.ref("refs/heads/main", main)
+
+		writer, err := repo.client().NewStagedWriter(ctx, Ref{Name: "refs/heads/main", Hash: main})
+		require.NoError(t, err)
+		require.NoError(t, writer.AddSubmodule(ctx, "vendor/lib", "https://example.com/lib.git", pin))
+		require.NoError(t, writer.AddSubmodule(ctx, "tools", "https://example.com/tools.git", bump))
+		require.ErrorIs(t, writer.AddSubmodule(ctx, "tools", "https://example.com/other.git", pin), ErrObjectAlreadyExists)
+		require.ErrorIs(t, writer.AddSubmodule(ctx, "README.md", "https://example.com/other.git", pin), ErrObjectAlreadyExists)
+		commit, err := writer.Commit(ctx, "Add submodules", author, committer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Push(ctx))
+
+		links, gitmodules := gitlinks(t, repo, commit.Hash)
+		require.Equal(t, map[string]hash.Hash{"vendor/lib": pin, "tools": bump}, links)
+		require.Equal(t, "[submodule \"vendor/lib\"]\n\tpath = vendor/lib\n\turl = https://example.com/lib.git\n"+
+			"[submodule \"tools\"]\n\tpath = tools\n\turl = https://example.com/tools.git\n", gitmodules)
+	})
+
+	t.Run("bumps the pin of a submodule", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		const gitmodules = "[submodule \"lib\"]\n\tpath = vendor/lib\n\turl = https://example.com/lib.git\n"
+		main := repo.commit("main", map[string]string{
+			".gitmodules": gitmodules,
+			"vendor/lib":  "submodule:" + pin.String(),
+			"vendor/a.go": "package vendor",
+		})
+		repo.ref("refs/heads/main", main)
+
+		writer, err := repo.client().NewStagedWriter(ctx, Ref{Name: "refs/heads/main", Hash: main})
+		require.NoError(t, err)
+		require.NoError(t, writer.UpdateSubmodule(ctx, "vendor/lib", bump))
+		require.ErrorIs(t, writer.UpdateSubmodule(ctx, "vendor/other", bump), ErrObjectNotFound)
+		require.ErrorIs(t, writer.AddSubmodule(ctx, "vendor/lib", "https://example.com/lib.git", bump), ErrObjectAlreadyExists)
+		commit, err := writer.Commit(ctx, "Bump lib", author, committer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Push(ctx))
+
+		links, got := gitlinks(t, repo, commit.Hash)
+		require.Equal(t, map[string]hash.Hash{"vendor/lib": bump}, links)
+		require.Equal(t, gitmodules, got)
+	})
+}
+
+func TestSetGitmodulesURL(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "empty file",
+			want: "[submodule \"lib\"]\n\tpath = lib\n\turl = https://example.com/new.git\n",
+		},
+		{
+			name:    "appends a section",
+			content: "[submodule \"other\"]\n\tpath = other\n\turl = https://example.com/other.git",
+			want: "[submodule \"other\"]\n\tpath = other\n\turl = https://example.com/other.git\n" +
+				"[submodule \"lib\"]\n\tpath = lib\n\turl = https://example.com/new.git\n",
+		},
+		{
+			name:    "replaces the url of the section with the path",
+			content: "[submodule \"vendored\"]\n\tpath = lib\n\turl = https://example.com/old.git\n\tbranch = main\n[submodule \"other\"]\n\tpath = other\n\turl = x\n",
+			want:    "[submodule \"vendored\"]\n\tpath = lib\n\turl = https://example.com/new.git\n\tbranch = main\n[submodule \"other\"]\n\tpath = other\n\turl = x\n",
+		},
+		{
+			name:    "adds a missing url",
+			content: "[submodule \"lib\"]\n\tpath = lib\n[submodule \"other\"]\n\tpath = other\n",
+			want:    "[submodule \"lib\"]\n\tpath = lib\n\turl = https://example.com/new.git\n[submodule \"other\"]\n\tpath = other\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, string(setGitmodulesURL([]byte(tt.content), "lib", "https://example.com/new.git")))
+		})
+	}
+}
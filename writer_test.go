@@ -5,6 +5,7 @@ import (
 	"crypto"
 	"errors"
 	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -66,6 +67,10 @@ func (m *mockRawClient) UploadPack(ctx context.Context, data io.Reader) (io.Read
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockRawClient) ObjectInfo(ctx context.Context, hashes []hash.Hash) (map[hash.Hash]int64, error) {
+	return nil, client.ErrObjectInfoUnsupported
+}
+
 func (m *mockRawClient) FetchReceivePackCapabilities(ctx context.Context) ([]protocol.Capability, error) {
 	return nil, errors.New("not implemented")
 }
@@ -377,3 +382,198 @@ func TestSignerOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestStagedWriter_RootCommit(t *testing.T) {
+	t.Parallel()
+
+	author := Author{Name: "A", Email: "a@example.com", Time: time.Unix(1700000000, 0)}
+	committer := Committer{Name: "C", Email: "c@example.com", Time: time.Unix(1700000000, 0)}
+	// write stages files on a writer, commits and pushes them.
+	write := func(t *testing.T, writer StagedWriter, files map[string]string) *Commit {
+		t.Helper()
+		ctx := context.Background()
+		for path, content := range files {
+			_, err := writer.CreateBlob(ctx, path, []byte(content))
+			require.NoError(t, err)
+		}
+		commit, err := writer.Commit(ctx, "Initial commit", author, committer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Push(ctx))
+		return commit
+	}
+	// paths returns the paths of the files of a commit.
+	paths := func(t *testing.T, repo *testRepo, commit hash.Hash) []string {
+		t.Helper()
+		tree, err := repo.client().GetFlatTree(context.Background(), commit)
+		require.NoError(t, err)
+		var out []string
+		for _, entry := range tree.Entries {
+			if entry.Type == protocol.ObjectTypeBlob {
+				out = append(out, entry.Path)
+			}
+		}
+		return out
+	}
+
+	t.Run("creates the first branch of an empty repository", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main"})
+		require.NoError(t, err)
+
+		exists, err := writer.BlobExists(context.Background(), "README.md")
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		commit := write(t, writer, map[string]string{"README.md": "readme", "docs/guide.md": "guide"})
+		require.Equal(t, commit.Hash, repo.refHash("refs/heads/main"))
+		require.Empty(t, commit.Parents)
+		require.Equal(t, hash.Zero, commit.Parent)
+
+		pushed, err := repo.client().GetCommit(context.Background(), commit.Hash)
+		require.NoError(t, err)
+		require.Empty(t, pushed.Parents)
+		require.ElementsMatch(t, []string{"README.md", "docs/guide.md"}, paths(t, repo, commit.Hash))
+
+		// The writer continues from the pushed commit.
+		next := write(t, writer, map[string]string{"CHANGELOG.md": "changes"})
+		require.Equal(t, []hash.Hash{commit.Hash}, next.Parents)
+		require.Equal(t, next.Hash, repo.refHash("refs/heads/main"))
+	})
+
+	t.Run("new branch without history", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		main := repo.commit("main", map[string]string{"README.md": "readme"})
+		repo.ref("refs/heads/main", main)
+
+		writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/gh-pages"})
+		require.NoError(t, err)
+		commit := write(t, writer, map[string]string{"index.html": "<html>"})
+		require.Empty(t, commit.Parents)
+		require.Equal(t, commit.Hash, repo.refHash("refs/heads/gh-pages"))
+		require.Equal(t, main, repo.refHash("refs/heads/main"))
+		require.Equal(t, []string{"index.html"}, paths(t, repo, commit.Hash))
+	})
+
+	t.Run("orphan commit replaces the history of a branch", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		main := repo.commit("main", map[string]string{"README.md": "readme"})
+		repo.ref("refs/heads/main", main)
+
+		_, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main", Hash: main}, WithOrphan())
+		var exists *RefAlreadyExistsError
+		require.ErrorAs(t, err, &exists)
+		require.Equal(t, "refs/heads/main", exists.RefName)
+
+		writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main", Hash: main}, WithOrphan(), WithReplaceHistory())
+		require.NoError(t, err)
+		commit := write(t, writer, map[string]string{"fresh.txt": "fresh"})
+		require.Empty(t, commit.Parents)
+		require.Equal(t, commit.Hash, repo.refHash("refs/heads/main"))
+		require.Equal(t, []string{"fresh.txt"}, paths(t, repo, commit.Hash))
+	})
+
+	t.Run("nothing to commit on an empty tree", func(t *testing.T) {
+		t.Parallel()
+		repo := newTestRepo(t)
+		writer, err := repo.client().NewStagedWriter(context.Background(), Ref{Name: "refs/heads/main"})
+		require.NoError(t, err)
+		_, err = writer.Commit(context.Background(), "Empty", author, committer)
+		require.ErrorIs(t, err, ErrNothingToCommit)
+	})
+}
+
+func TestStagedWriter_FileModes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	author := Author{Name: "A", Email: "a@example.com", Time: time.Unix(1700000000, 0)}
+	committer := Committer{Name: "C", Email: "c@example.com", Time: time.Unix(1700000000, 0)}
+	repo := newTestRepo(t)
+	main := repo.commit("main", map[string]string{
+		"run.sh":        "exec:#!/bin/sh\n",
+		"README.md":     "readme",
+		"docs/guide.md": "guide",
+	})
+	repo.ref("refs/heads/main", main)
+
+	writer, err := repo.client().NewStagedWriter(ctx, Ref{Name: "refs/heads/main", Hash: main})
+	require.NoError(t, err)
+
+	_, err = writer.CreateBlob(ctx, "scripts/build.sh", []byte("#!/bin/sh\nmake\n"), WithExecutable())
+	require.NoError(t, err)
+	_, err = writer.CreateBlob(ctx, "latest", []byte("docs/guide.md"), WithSymlink())
+	require.NoError(t, err)
+	_, err = writer.UpdateBlob(ctx, "run.sh", []byte("#!/bin/sh\nexit 0\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.SetMode(ctx, "README.md", 0o100755))
+	require.NoError(t, writer.SetMode(ctx, "README.md", 0o100644))
+	_, err = writer.UpdateBlob(ctx, "docs/guide.md", []byte("guide\n"), WithExecutable())
+	require.NoError(t, err)
+
+	_, err = writer.CreateBlob(ctx, "private.key", []byte("key"), WithFileMode(0o100600))
+	require.ErrorIs(t, err, ErrInvalidFileMode)
+	require.ErrorIs(t, writer.SetMode(ctx, "run.sh", 0o40000), ErrInvalidFileMode)
+	require.ErrorIs(t, writer.SetMode(ctx, "docs", 0o100755), ErrUnexpectedObjectType)
+	require.ErrorIs(t, writer.SetMode(ctx, "missing.sh", 0o100755), ErrObjectNotFound)
+
+	commit, err := writer.Commit(ctx, "Modes", author, committer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Push(ctx))
+
+	tree, err := repo.client().GetFlatTree(ctx, commit.Hash)
+	require.NoError(t, err)
+	modes := make(map[string]uint32)
+	for _, entry := range tree.Entries {
+		if entry.Type == protocol.ObjectTypeBlob {
+			modes[entry.Path] = entry.Mode
+		}
+	}
+	require.Equal(t, map[string]uint32{
+		"README.md":        0o100644,
+		"run.sh":           0o100755,
+		"latest":           0o120000,
+		"scripts/build.sh": 0o100755,
+		"docs/guide.md":    0o100755,
+	}, modes)
+}
+
+func TestStagedWriter_CreateBlobFromReader(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	author := Author{Name: "A", Email: "a@example.com", Time: time.Unix(1700000000, 0)}
+	committer := Committer{Name: "C", Email: "c@example.com", Time: time.Unix(1700000000, 0)}
+	repo := newTestRepo(t)
+	main := repo.commit("main", map[string]string{"README.md": "readme"})
+	repo.ref("refs/heads/main", main)
+
+	writer, err := repo.client().NewStagedWriter(ctx, Ref{Name: "refs/heads/main", Hash: main}, WithDiskStorage())
+	require.NoError(t, err)
+
+	content := strings.Repeat("artifact bytes\n", 4096)
+	blobHash, err := writer.CreateBlobFromReader(ctx, "dist/app.bin", strings.NewReader(content), int64(len(content)), WithExecutable())
+	require.NoError(t, err)
+	_, err = writer.CreateBlobFromReader(ctx, "README.md", strings.NewReader("x"), 1)
+	require.ErrorIs(t, err, ErrObjectAlreadyExists)
+	_, err = writer.CreateBlobFromReader(ctx, "short.bin", strings.NewReader("x"), 2)
+	require.Error(t, err)
+
+	commit, err := writer.Commit(ctx, "Add artifact", author, committer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Push(ctx))
+
+	blob, err := repo.client().GetBlobByPath(ctx, commit.Tree, "dist/app.bin")
+	require.NoError(t, err)
+	require.Equal(t, blobHash, blob.Hash)
+	require.Equal(t, content, string(blob.Content))
+	tree, err := repo.client().GetFlatTree(ctx, commit.Hash)
+	require.NoError(t, err)
+	for _, entry := range tree.Entries {
+		if entry.Path == "dist/app.bin" {
+			require.Equal(t, uint32(0o100755), entry.Mode)
+		}
+	}
+}